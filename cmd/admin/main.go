@@ -0,0 +1,277 @@
+package main
+
+import (
+	"amadeus-trip-parser/internal/adapter/repository"
+	"amadeus-trip-parser/internal/adapter/secrets"
+	"amadeus-trip-parser/internal/config"
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"amadeus-trip-parser/internal/usecase"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"text/tabwriter"
+)
+
+const usage = `usage: admin [-db name] <command> <subcommand> [flags]
+
+Manages the users, mailboxes and API keys of the database of repository.driver, -db names the SQLite database.
+
+commands:
+  user create -subject alice [-name Alice] [-role user|admin]
+  user list
+  user delete -subject alice                          deletes its mailboxes and api keys too, trips are kept
+  mailbox connect -user alice -token gmail_token.json [-credentials client.json] [-address alice@example.com]
+  mailbox list [-user alice]
+  mailbox disconnect -id <mailbox id>
+  apikey create -subject alice [-role user|admin] [-name laptop]   prints the new key, it cannot be shown again
+  apikey list                                                      lists keys without their value
+  apikey revoke -id <key id>
+`
+
+// loadConfig reads the configuration of the server, for its database and the key of mailbox tokens
+func loadConfig() config.Config {
+	cfg, _, err := config.Load(".")
+	if err != nil {
+		log.Panicf("cannot read configuration: %v", err)
+	}
+	provider, err := secrets.NewProvider(cfg.Secrets.Provider, secrets.VaultConfig{
+		Address:   cfg.Secrets.Vault.Address,
+		Token:     cfg.Secrets.Vault.Token,
+		Mount:     cfg.Secrets.Vault.Mount,
+		KVVersion: cfg.Secrets.Vault.KVVersion,
+	})
+	if err != nil {
+		log.Panicf("cannot read secrets: %v", err)
+	}
+	if provider != nil {
+		if cfg, err = cfg.ResolveSecrets(context.Background(), provider); err != nil {
+			log.Panicf("cannot read secrets: %v", err)
+		}
+	}
+	return cfg
+}
+
+func tokenCipher(cfg config.MailConfig) *secrets.Cipher {
+	if cfg.TokenKey == "" {
+		return nil
+	}
+	key, err := secrets.ParseKey(cfg.TokenKey)
+	if err != nil {
+		log.Panicf("invalid mail.token_key: %v", err)
+	}
+	cipher, err := secrets.NewCipher(key)
+	if err != nil {
+		log.Panicf("cannot init token encryption: %v", err)
+	}
+	return cipher
+}
+
+type services struct {
+	users domain.UserService
+	keys  domain.APIKeyService
+}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	cfg := loadConfig()
+	db := flag.String("db", cfg.Repository.Name, "SQLite database name, with the sqlite3 driver")
+	flag.Parse()
+	if flag.NArg() < 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	// the stores are in the database of repository.driver, as for the server
+	driver, dsn, dialect := "sqlite3", *db, repository.DialectSQLite
+	if cfg.Repository.Driver == config.DriverPostgres {
+		driver, dsn, dialect = "postgres", cfg.Repository.DSN, repository.DialectPostgres
+	}
+	conn, err := sql.Open(driver, dsn)
+	if err != nil {
+		log.Panicf("cannot open %s database: %v", dialect, err)
+	}
+	defer conn.Close()
+	if err := repository.Migrate(conn, dialect); err != nil {
+		log.Panicf("cannot migrate %s database: %v", dialect, err)
+	}
+	keys, err := repository.NewAPIKeyStore(conn, dialect)
+	if err != nil {
+		log.Panicf("cannot open api key store: %v", err)
+	}
+	users, err := repository.NewUserStore(conn, dialect)
+	if err != nil {
+		log.Panicf("cannot open user store: %v", err)
+	}
+	mailboxes, err := repository.NewMailboxStore(conn, dialect, tokenCipher(cfg.Mail))
+	if err != nil {
+		log.Panicf("cannot open mailbox store: %v", err)
+	}
+	s := services{
+		users: usecase.NewUserService(users, mailboxes, keys),
+		keys:  usecase.NewAPIKeyService(keys),
+	}
+
+	name := flag.Arg(0) + " " + flag.Arg(1)
+	cmd := flag.NewFlagSet(name, flag.ExitOnError)
+	args := flag.Args()[2:]
+	switch name {
+	case "user create":
+		s.createUser(cmd, args)
+	case "user list":
+		s.listUsers(cmd, args)
+	case "user delete":
+		s.deleteUser(cmd, args)
+	case "mailbox connect":
+		s.connectMailbox(cmd, args)
+	case "mailbox list":
+		s.listMailboxes(cmd, args)
+	case "mailbox disconnect":
+		s.disconnectMailbox(cmd, args)
+	case "apikey create":
+		s.createAPIKey(cmd, args)
+	case "apikey list":
+		s.listAPIKeys(cmd, args)
+	case "apikey revoke":
+		s.revokeAPIKey(cmd, args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+func (s services) user(subject string) model.User {
+	u, err := s.users.GetUserBySubject(subject)
+	if err != nil {
+		log.Panicf("cannot find user %s: %v", subject, err)
+	}
+	return u
+}
+
+func (s services) createUser(cmd *flag.FlagSet, args []string) {
+	subject := cmd.String("subject", "", "subject of the user, the one of its bearer tokens")
+	name := cmd.String("name", "", "display name")
+	role := cmd.String("role", model.RoleUser, "user or admin")
+	cmd.Parse(args)
+	u, err := s.users.CreateUser(*subject, *name, model.Role(*role))
+	if err != nil {
+		log.Panicf("cannot create user: %v", err)
+	}
+	fmt.Printf("created user %s for %s (%s)\n", u.ID, u.Subject, u.Role)
+}
+
+func (s services) listUsers(cmd *flag.FlagSet, args []string) {
+	cmd.Parse(args)
+	users, err := s.users.ListUsers()
+	if err != nil {
+		log.Panicf("cannot list users: %v", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSUBJECT\tROLE\tNAME\tCREATED")
+	for _, u := range users {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", u.ID, u.Subject, u.Role, u.Name, u.CreatedAt.Format("2006-01-02"))
+	}
+	w.Flush()
+}
+
+func (s services) deleteUser(cmd *flag.FlagSet, args []string) {
+	subject := cmd.String("subject", "", "subject of the user to delete")
+	cmd.Parse(args)
+	if err := s.users.DeleteUser(s.user(*subject).ID); err != nil {
+		log.Panicf("cannot delete user %s: %v", *subject, err)
+	}
+	fmt.Printf("deleted user %s\n", *subject)
+}
+
+func (s services) connectMailbox(cmd *flag.FlagSet, args []string) {
+	subject := cmd.String("user", "", "subject of the user owning the mailbox")
+	token := cmd.String("token", "", "OAuth token file of the mailbox, written by gentoken")
+	credentials := cmd.String("credentials", "", "OAuth client file, the configured one of the server when empty")
+	address := cmd.String("address", "", "email address of the mailbox")
+	cmd.Parse(args)
+	mailbox := model.Mailbox{Provider: model.MailboxProviderGmail, Address: *address}
+	mailbox.Token = readFile(*token)
+	if *credentials != "" {
+		mailbox.Credentials = readFile(*credentials)
+	}
+	m, err := s.users.ConnectMailbox(s.user(*subject).ID, mailbox)
+	if err != nil {
+		log.Panicf("cannot connect mailbox: %v", err)
+	}
+	fmt.Printf("connected mailbox %s of %s\n", m.ID, m.Owner)
+}
+
+func (s services) listMailboxes(cmd *flag.FlagSet, args []string) {
+	subject := cmd.String("user", "", "subject of the user owning the mailboxes, all mailboxes when empty")
+	cmd.Parse(args)
+	id := ""
+	if *subject != "" {
+		id = s.user(*subject).ID
+	}
+	mailboxes, err := s.users.ListMailboxes(id)
+	if err != nil {
+		log.Panicf("cannot list mailboxes: %v", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tOWNER\tPROVIDER\tADDRESS\tUPDATED")
+	for _, m := range mailboxes {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", m.ID, m.Owner, m.Provider, m.Address, m.UpdatedAt.Format("2006-01-02"))
+	}
+	w.Flush()
+}
+
+func (s services) disconnectMailbox(cmd *flag.FlagSet, args []string) {
+	id := cmd.String("id", "", "id of the mailbox to disconnect")
+	cmd.Parse(args)
+	if err := s.users.DisconnectMailbox(*id); err != nil {
+		log.Panicf("cannot disconnect mailbox %s: %v", *id, err)
+	}
+	fmt.Printf("disconnected mailbox %s\n", *id)
+}
+
+func (s services) createAPIKey(cmd *flag.FlagSet, args []string) {
+	subject := cmd.String("subject", "", "user owning the key, trips are scoped to it")
+	role := cmd.String("role", model.RoleUser, "user or admin, admins see all trips")
+	name := cmd.String("name", "", "description of the key")
+	cmd.Parse(args)
+	key, k, err := s.keys.Create(*name, *subject, model.Role(*role))
+	if err != nil {
+		log.Panicf("cannot create api key: %v", err)
+	}
+	fmt.Printf("created key %s for %s (%s):\n%s\n", k.ID, k.Subject, k.Role, key)
+}
+
+func (s services) listAPIKeys(cmd *flag.FlagSet, args []string) {
+	cmd.Parse(args)
+	keys, err := s.keys.List()
+	if err != nil {
+		log.Panicf("cannot list api keys: %v", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tSUBJECT\tROLE\tNAME\tCREATED")
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", k.ID, k.Subject, k.Role, k.Name, k.CreatedAt.Format("2006-01-02"))
+	}
+	w.Flush()
+}
+
+func (s services) revokeAPIKey(cmd *flag.FlagSet, args []string) {
+	id := cmd.String("id", "", "id of the key to revoke")
+	cmd.Parse(args)
+	if err := s.keys.Revoke(*id); err != nil {
+		log.Panicf("cannot revoke api key %s: %v", *id, err)
+	}
+	fmt.Printf("revoked key %s\n", *id)
+}
+
+func readFile(name string) string {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		log.Panicf("cannot read %s: %v", name, err)
+	}
+	return string(b)
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// loginTimeout is given to the user to authorize access in the browser
+const loginTimeout = 5 * time.Minute
+
+func randomString() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Panicf("unable to generate random value: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// login runs the authorization code flow of installed applications: the browser is redirected to a local server
+// with the code, its state must match the one sent, and the code is only exchanged with the PKCE verifier.
+func login(config *oauth2.Config, port int) *token {
+	ln, err := net.Listen("tcp", fmt.Sprintf("127.0.0.1:%d", port))
+	if err != nil {
+		log.Panicf("unable to start local redirect server: %v", err)
+	}
+	config.RedirectURL = fmt.Sprintf("http://127.0.0.1:%d/callback", ln.Addr().(*net.TCPAddr).Port)
+
+	state := randomString()
+	verifier := randomString()
+	challenge := sha256.Sum256([]byte(verifier))
+	authURL := config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce,
+		oauth2.SetAuthURLParam("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:])),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"))
+
+	type callback struct {
+		code string
+		err  error
+	}
+	callbacks := make(chan callback, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/callback" {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		var res callback
+		switch {
+		case q.Get("state") != state:
+			res.err = fmt.Errorf("state of the redirect does not match, the request was not sent by this login")
+		case q.Get("error") != "":
+			res.err = fmt.Errorf("authorization denied: %s %s", q.Get("error"), q.Get("error_description"))
+		case q.Get("code") == "":
+			res.err = fmt.Errorf("redirect without authorization code")
+		default:
+			res.code = q.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "Access authorized, you can close this window.")
+		}
+		select {
+		case callbacks <- res:
+		default:
+		}
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	fmt.Printf("open the following link in your browser to authorize access:\n%s\n", authURL)
+	var res callback
+	select {
+	case res = <-callbacks:
+	case <-time.After(loginTimeout):
+		log.Panicf("no authorization within %s", loginTimeout)
+	}
+	if res.err != nil {
+		log.Panicf("unable to authorize: %v", res.err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	tok, err := config.Exchange(ctx, res.code, oauth2.SetAuthURLParam("code_verifier", verifier))
+	if err != nil {
+		log.Panicf("unable to retrieve token: %v", err)
+	}
+	scope, _ := tok.Extra("scope").(string)
+	return &token{Token: *tok, Scope: scope}
+}
@@ -1,109 +1,126 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"log"
-	"net/http"
 	"os"
+	"strings"
 
-	"golang.org/x/net/context"
+	"amadeus-trip-parser/internal/adapter/secrets"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
-	"google.golang.org/api/gmail/v1"
 )
 
-// Retrieve a token, saves the token, then returns the generated client.
-func getClient(tokFile string, config *oauth2.Config) *http.Client {
-	// The file gmail_token.json stores the user's access and refresh tokens, and is
-	// created automatically when the authorization flow completes for the first
-	// time.
-	tok, err := tokenFromFile(tokFile)
-	if err != nil {
-		tok = getTokenFromWeb(config)
-		saveToken(tokFile, tok)
-	}
-	return config.Client(context.Background(), tok)
-}
+const usage = `usage: gentoken <command> [flags]
 
-// Request a token from the web, then returns the retrieved token.
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
-	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
-	fmt.Printf("Go to the following link in your browser then type the "+
-		"authorization code: \n%v\n", authURL)
+commands:
+  login [-credentials client_credentials.json] [-output gmail_token.json] [-scopes gmail.readonly] [-port 0]
+        authorizes access in the browser, redirected to a local server, and saves the token
+  info [-token gmail_token.json]
+        shows the expiry and the scopes of a token
+  refresh [-credentials client_credentials.json] [-token gmail_token.json]
+        refreshes the access token and saves it in place
 
-	var authCode string
-	if _, err := fmt.Scan(&authCode); err != nil {
-		log.Panicf("Unable to read authorization code: %v", err)
-	}
+Tokens are encrypted with the key of MAIL_TOKEN_KEY when set, as the server does.
+`
 
-	tok, err := config.Exchange(context.TODO(), authCode)
-	if err != nil {
-		log.Panicf("Unable to retrieve token from web: %v", err)
-	}
-	return tok
-}
+// scopePrefix completes the short scope names, such as gmail.readonly
+const scopePrefix = "https://www.googleapis.com/auth/"
 
-// Retrieves a token from a local file.
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
+func parseScopes(s string) []string {
+	var scopes []string
+	for _, scope := range strings.Split(s, ",") {
+		scope = strings.TrimSpace(scope)
+		if scope == "" {
+			continue
+		}
+		if !strings.Contains(scope, "://") {
+			scope = scopePrefix + scope
+		}
+		scopes = append(scopes, scope)
 	}
-	defer f.Close()
-	tok := &oauth2.Token{}
-	err = json.NewDecoder(f).Decode(tok)
-	return tok, err
+	return scopes
 }
 
-// Saves a token to a file path.
-func saveToken(path string, token *oauth2.Token) {
-	fmt.Printf("Saving credential file to: %s\n", path)
-	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
-	if err != nil {
-		log.Panicf("Unable to cache oauth token: %v", err)
-	}
-	defer f.Close()
-	json.NewEncoder(f).Encode(token)
-}
-
-func main() {
-	var credentials string
-	var output string
-	flag.StringVar(&credentials, "credentials", "client_credentials.json", "credentials json file location")
-	flag.StringVar(&output, "output", "gmail_token.json", "output file for token")
-	flag.Parse()
-
-	b, err := ioutil.ReadFile(credentials)
+// oauthConfig reads the OAuth client of the credentials file, encrypted or not
+func oauthConfig(file string, cipher *secrets.Cipher, scopes ...string) *oauth2.Config {
+	b, err := ioutil.ReadFile(file)
 	if err != nil {
 		log.Panicf("unable to read client secret file: %v", err)
 	}
-
-	// If modifying these scopes, delete your previously saved gmail_token.json.
-	config, err := google.ConfigFromJSON(b, gmail.GmailReadonlyScope)
+	if b, err = cipher.Decrypt(b); err != nil {
+		log.Panicf("unable to decrypt client secret file: %v", err)
+	}
+	config, err := google.ConfigFromJSON(b, scopes...)
 	if err != nil {
 		log.Panicf("unable to parse client secret file to config: %v", err)
 	}
-	client := getClient(output, config)
+	return config
+}
 
-	srv, err := gmail.New(client)
+// tokenCipher encrypts the token with the key of the MAIL_TOKEN_KEY environment variable, as the server does
+func tokenCipher() *secrets.Cipher {
+	env := os.Getenv("MAIL_TOKEN_KEY")
+	if env == "" {
+		return nil
+	}
+	key, err := secrets.ParseKey(env)
 	if err != nil {
-		log.Panicf("unable to retrieve Gmail client: %v", err)
+		log.Panicf("invalid MAIL_TOKEN_KEY: %v", err)
 	}
-
-	user := "me"
-	r, err := srv.Users.Labels.List(user).Do()
+	cipher, err := secrets.NewCipher(key)
 	if err != nil {
-		log.Panicf("unable to retrieve labels: %v", err)
+		log.Panicf("unable to init token encryption: %v", err)
 	}
-	if len(r.Labels) == 0 {
-		fmt.Println("no labels found.")
-		return
+	return cipher
+}
+
+func main() {
+	flag.Usage = func() { fmt.Fprint(os.Stderr, usage) }
+	flag.Parse()
+	if flag.NArg() < 1 {
+		flag.Usage()
+		os.Exit(2)
 	}
-	fmt.Println("labels:")
-	for _, l := range r.Labels {
-		fmt.Printf("- %s\n", l.Name)
+
+	cmd := flag.NewFlagSet(flag.Arg(0), flag.ExitOnError)
+	cmd.Usage = flag.Usage
+	args := flag.Args()[1:]
+	cipher := tokenCipher()
+	switch flag.Arg(0) {
+	case "login":
+		credentials := cmd.String("credentials", "client_credentials.json", "credentials json file location")
+		output := cmd.String("output", "gmail_token.json", "output file for token")
+		scopes := cmd.String("scopes", "gmail.readonly", "comma separated scopes, short names are Google API ones")
+		port := cmd.Int("port", 0, "port of the local redirect server, a free one when 0")
+		cmd.Parse(args)
+		config := oauthConfig(*credentials, cipher, parseScopes(*scopes)...)
+		tok := login(config, *port)
+		saveToken(*output, tok, cipher)
+		printToken(*output, tok)
+	case "info":
+		file := cmd.String("token", "gmail_token.json", "token file")
+		cmd.Parse(args)
+		tok, err := readToken(*file, cipher)
+		if err != nil {
+			log.Panicf("unable to read token: %v", err)
+		}
+		printToken(*file, tok)
+	case "refresh":
+		credentials := cmd.String("credentials", "client_credentials.json", "credentials json file location")
+		file := cmd.String("token", "gmail_token.json", "token file")
+		cmd.Parse(args)
+		tok, err := readToken(*file, cipher)
+		if err != nil {
+			log.Panicf("unable to read token: %v", err)
+		}
+		tok = refresh(oauthConfig(*credentials, cipher), tok)
+		saveToken(*file, tok, cipher)
+		printToken(*file, tok)
+	default:
+		flag.Usage()
+		os.Exit(2)
 	}
 }
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"amadeus-trip-parser/internal/adapter/secrets"
+	"golang.org/x/oauth2"
+)
+
+// token is saved with its granted scopes, the server reads it as a plain oauth2.Token
+type token struct {
+	oauth2.Token
+	Scope string `json:"scope,omitempty"`
+}
+
+// readToken reads a token file, encrypted or not
+func readToken(file string, cipher *secrets.Cipher) (*token, error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if b, err = cipher.Decrypt(b); err != nil {
+		return nil, err
+	}
+	tok := &token{}
+	err = json.Unmarshal(b, tok)
+	return tok, err
+}
+
+// saveToken replaces the token file atomically, a temporary file is renamed so that the server never reads
+// a partial token. It is encrypted when a key is given.
+func saveToken(path string, tok *token, cipher *secrets.Cipher) {
+	b, err := json.Marshal(tok)
+	if err != nil {
+		log.Panicf("unable to encode oauth token: %v", err)
+	}
+	if b, err = cipher.Encrypt(b); err != nil {
+		log.Panicf("unable to encrypt oauth token: %v", err)
+	}
+	f, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		log.Panicf("unable to save oauth token: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		log.Panicf("unable to save oauth token: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		log.Panicf("unable to save oauth token: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Panicf("unable to save oauth token: %v", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		log.Panicf("unable to save oauth token: %v", err)
+	}
+	fmt.Printf("token saved to %s\n", path)
+}
+
+// refresh obtains a new access token with the refresh token, which Google may rotate
+func refresh(config *oauth2.Config, tok *token) *token {
+	if tok.RefreshToken == "" {
+		log.Panicf("the token has no refresh token, login again")
+	}
+	expired := tok.Token
+	expired.Expiry = time.Now().Add(-time.Minute)
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	refreshed, err := config.TokenSource(ctx, &expired).Token()
+	if err != nil {
+		log.Panicf("unable to refresh token: %v", err)
+	}
+	scope := tok.Scope
+	if s, ok := refreshed.Extra("scope").(string); ok && s != "" {
+		scope = s
+	}
+	return &token{Token: *refreshed, Scope: scope}
+}
+
+func printToken(file string, tok *token) {
+	fmt.Printf("token:         %s\n", file)
+	switch {
+	case tok.Expiry.IsZero():
+		fmt.Println("access token:  never expires")
+	case tok.Expiry.Before(time.Now()):
+		fmt.Printf("access token:  expired at %s\n", tok.Expiry.Format(time.RFC3339))
+	default:
+		fmt.Printf("access token:  expires at %s, in %s\n", tok.Expiry.Format(time.RFC3339),
+			time.Until(tok.Expiry).Round(time.Second))
+	}
+	if tok.RefreshToken != "" {
+		fmt.Println("refresh token: present")
+	} else {
+		fmt.Println("refresh token: missing, access stops when the access token expires")
+	}
+	if tok.Scope == "" {
+		fmt.Println("scopes:        unknown, the token was saved without them")
+		return
+	}
+	fmt.Println("scopes:")
+	for _, s := range strings.Fields(tok.Scope) {
+		fmt.Printf("  %s\n", s)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"amadeus-trip-parser/internal/config"
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"amadeus-trip-parser/internal/usecase"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+)
+
+const exportUsage = `usage: parser export -file <trips.jsonl> [-format jsonl|json]
+
+Writes every trip of the repository, of all owners, with its steps and travellers to a versioned archive. The format
+defaults to the extension of -file, JSON Lines otherwise.
+
+flags:
+`
+
+const importUsage = `usage: parser import -file <trips.jsonl>
+
+Loads the trips of an archive written by export, in either format, into the repository: a trip replaces the one with
+the same owner and reference, others are created. The archive is read on the standard input when -file is -.
+An interrupted import can be run again.
+
+flags:
+`
+
+// openArchiveService opens the trip repository of the configuration, closeDB releases its database
+func openArchiveService(cfg config.RepositoryConfig) (archives domain.ArchiveService, closeDB func()) {
+	db, dialect := openDB(cfg)
+	migrateDB(db, dialect)
+	return usecase.NewArchiveService(initRepository(db, dialect)), func() { db.Close() }
+}
+
+// runExport dumps the trips of the repository, to back them up or to move them to another backend
+func runExport(cfg config.Config, args []string) {
+	cmd := flag.NewFlagSet("export", flag.ExitOnError)
+	cmd.Usage = func() {
+		fmt.Fprint(os.Stderr, exportUsage)
+		cmd.PrintDefaults()
+	}
+	// the standard output is left out, gorm logs to it
+	file := cmd.String("file", "", "archive written")
+	format := cmd.String("format", "", "archive format, jsonl or json")
+	cmd.Parse(args)
+	if *format == "" {
+		*format = model.ArchiveFormatJSONL
+		if strings.EqualFold(filepath.Ext(*file), ".json") {
+			*format = model.ArchiveFormatJSON
+		}
+	}
+	if *file == "" || (*format != model.ArchiveFormatJSONL && *format != model.ArchiveFormatJSON) {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	f, err := os.Create(*file)
+	if err != nil {
+		log.Panic().Msgf("cannot create archive: %s", err)
+	}
+	defer f.Close()
+	archives, closeDB := openArchiveService(cfg.Repository)
+	defer closeDB()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	n, err := archives.Export(ctx, f, model.ArchiveFormat(*format))
+	if err != nil {
+		log.Panic().Msgf("cannot export trips: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		log.Panic().Msgf("cannot write archive: %s", err)
+	}
+	log.Info().Msgf("%d trips exported", n)
+}
+
+// runImport merges the trips of an archive into the repository
+func runImport(cfg config.Config, args []string) {
+	cmd := flag.NewFlagSet("import", flag.ExitOnError)
+	cmd.Usage = func() {
+		fmt.Fprint(os.Stderr, importUsage)
+		cmd.PrintDefaults()
+	}
+	file := cmd.String("file", "", "archive read, - for the standard input")
+	cmd.Parse(args)
+	if *file == "" {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	var r io.Reader = os.Stdin
+	if *file != "-" {
+		f, err := os.Open(*file)
+		if err != nil {
+			log.Panic().Msgf("cannot open archive: %s", err)
+		}
+		defer f.Close()
+		r = f
+	}
+	archives, closeDB := openArchiveService(cfg.Repository)
+	defer closeDB()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	res, err := archives.Import(ctx, r)
+	if err != nil {
+		log.Panic().Msgf("cannot import trips, %d created and %d updated before: %s", res.Created, res.Updated, err)
+	}
+	log.Info().Msgf("%d trips created, %d updated", res.Created, res.Updated)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"amadeus-trip-parser/internal/adapter/repository"
+	"amadeus-trip-parser/internal/config"
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"amadeus-trip-parser/internal/usecase"
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+const backfillUsage = `usage: parser backfill -after <date> [-before <date>] [-mailbox <id>] [-from <sender>]
+
+Imports the past emails of a mailbox received in a date range, through the same pipeline as the server. Emails are
+fetched one window of time after the other from the oldest, and the parser calls are limited by -rate. Each window
+queued is saved as a checkpoint: a backfill of the same mailbox, start and sender resumes from it, up to its own
+end. Dates are given as 2006-01-02.
+
+flags:
+`
+
+const dateLayout = "2006-01-02"
+
+// backfillWait is the delay between two checks of the emails left to parse
+const backfillWait = 5 * time.Second
+
+func initBackfillStore(db *sql.DB, dialect string) domain.BackfillStore {
+	backfills, err := repository.NewBackfillStore(db, dialect)
+	if err != nil {
+		log.Panic().Msgf("cannot open backfill store: %s", err)
+	}
+	return backfills
+}
+
+// runBackfill queues the past emails of a mailbox and waits for them to be parsed
+func runBackfill(cfg config.Config, args []string) {
+	cmd := flag.NewFlagSet("backfill", flag.ExitOnError)
+	cmd.Usage = func() {
+		fmt.Fprint(os.Stderr, backfillUsage)
+		cmd.PrintDefaults()
+	}
+	mailboxID := cmd.String("mailbox", configuredMailbox, "ID of the mailbox, the one of mail.token by default")
+	afterDate := cmd.String("after", "", "first day of the emails imported")
+	beforeDate := cmd.String("before", "", "day after the last emails imported, tomorrow by default")
+	sender := cmd.String("from", "", "sender of the emails imported, such as an address or a domain")
+	window := cmd.Duration("window", usecase.DefaultBackfillWindow, "time range of the emails fetched at once")
+	rate := cmd.Float64("rate", 1, "maximum parser calls per second, unlimited when 0")
+	cmd.Parse(args)
+	after, err := time.ParseInLocation(dateLayout, *afterDate, time.Local)
+	if err != nil {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	y, mo, d := time.Now().Date()
+	before := time.Date(y, mo, d+1, 0, 0, 0, 0, time.Local)
+	if *beforeDate != "" {
+		if before, err = time.ParseInLocation(dateLayout, *beforeDate, time.Local); err != nil {
+			cmd.Usage()
+			os.Exit(2)
+		}
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Panic().Msgf("invalid configuration: %s", err)
+	}
+
+	db, dialect := openDB(cfg.Repository)
+	defer db.Close()
+	migrateDB(db, dialect)
+	// the queued emails are resumed by the processor, which must not run in a server at the same time
+	lock := initProcessingLock(db, dialect)
+	locked, err := lock.TryLock(context.Background())
+	if err != nil {
+		log.Panic().Msgf("cannot take the processing lock: %s", err)
+	}
+	if !locked {
+		log.Panic().Msg("emails are processed by a running server, stop it to run a backfill")
+	}
+	defer lock.Unlock()
+	queue := initJobQueue(db, dialect)
+	cipher := initTokenCipher(cfg.Mail)
+	mailboxes := initMailboxStore(db, dialect, cipher)
+	m := domain.NopMetrics{}
+	providers := initMailProviders(cfg.Mail, cipher, mailboxes, m)
+	parser := usecase.NewRateLimitedParser(initMailParser(cfg.Parser, m), *rate)
+	// the mailboxes are left to the server, only the backfill emails are queued
+	pc := processorConfig(cfg.Processor)
+	pc.DisablePoll = true
+	repo := initLocator(cfg.Geo, db, dialect, initRepository(db, dialect))
+	proc := usecase.NewEmailProcessor(mailboxes, providers, parser, repo, queue, initMessageLedger(db, dialect),
+		initFailureStore(db, dialect), m, pc)
+	proc.Process()
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.Shutdown.Timeout)
+		defer cancel()
+		if err := proc.Stop(ctx); err != nil {
+			log.Error().Msgf("cannot stop email processor gracefully: %s", err)
+		}
+	}()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go keepLock(ctx, lock, stop)
+	backfills := usecase.NewBackfillService(mailboxes, providers, proc, initBackfillStore(db, dialect), *window)
+	request := model.Backfill{MailboxID: *mailboxID, Sender: *sender, After: after, Before: before}
+	b, err := backfills.Run(ctx, request, func(b model.Backfill) {
+		done := float64(b.Checkpoint.Sub(b.After)) / float64(b.Before.Sub(b.After)) * 100
+		log.Info().Msgf("backfill of mailbox %s %.0f%% done, up to %s: %d emails found, %d queued", b.MailboxID,
+			done, b.Checkpoint.Format(dateLayout), b.Fetched, b.Queued)
+	})
+	if err != nil {
+		log.Error().Msgf("backfill of mailbox %s stopped at %s, run the same command to resume: %s",
+			*mailboxID, b.Checkpoint.Format(dateLayout), err)
+		return
+	}
+	waitParsed(ctx, queue, *mailboxID)
+}
+
+// keepLock checks the processing lock every lock interval, which renews its lease, and cancels the backfill once the
+// lock is lost
+func keepLock(ctx context.Context, lock domain.ProcessingLock, cancel context.CancelFunc) {
+	for {
+		select {
+		case <-time.After(usecase.DefaultLockInterval):
+		case <-ctx.Done():
+			return
+		}
+		if err := lock.Check(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Msgf("processing lock lost, stopping the backfill: %s", err)
+			cancel()
+			return
+		}
+	}
+}
+
+// waitParsed reports the emails of the mailbox left to parse until there are none, or ctx is done. Those left are
+// resumed on next start of the server or of a backfill.
+func waitParsed(ctx context.Context, queue domain.JobQueue, mailboxID string) {
+	for {
+		items, err := queue.ListByState(model.ProcessingStateFetched, model.ProcessingStateSubmitted,
+			model.ProcessingStatePending)
+		if err != nil {
+			log.Error().Msgf("cannot list emails left to parse: %s", err)
+			return
+		}
+		left := 0
+		for _, item := range items {
+			if item.MailboxID == mailboxID {
+				left++
+			}
+		}
+		if left == 0 {
+			log.Info().Msgf("backfill of mailbox %s done", mailboxID)
+			return
+		}
+		log.Info().Msgf("%d emails of mailbox %s left to parse", left, mailboxID)
+		select {
+		case <-time.After(backfillWait):
+		case <-ctx.Done():
+			log.Info().Msgf("%d emails of mailbox %s are parsed on next start", left, mailboxID)
+			return
+		}
+	}
+}
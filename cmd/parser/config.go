@@ -0,0 +1,66 @@
+package main
+
+import (
+	"amadeus-trip-parser/internal/config"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v2"
+	"os"
+)
+
+const configUsage = `usage: parser config <command>
+
+commands:
+  validate              checks the configuration, lists the invalid keys
+  print [--redacted]    prints the configuration with defaults and environment variables applied
+`
+
+// runConfig checks or shows the configuration the service would run with
+func runConfig(args []string) {
+	if len(args) == 0 {
+		fmt.Fprint(os.Stderr, configUsage)
+		os.Exit(2)
+	}
+	cfg, file, err := config.Load(".", rootDir())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "validate":
+		if cfg, err = resolveSecrets(cfg); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		err = cfg.Validate()
+		var errs config.ValidationErrors
+		if errors.As(err, &errs) {
+			for _, e := range errs {
+				fmt.Println(e)
+			}
+			os.Exit(1)
+		}
+		if file == "" {
+			file = "defaults"
+		}
+		fmt.Printf("configuration of %s is valid\n", file)
+	case "print":
+		cmd := flag.NewFlagSet("print", flag.ExitOnError)
+		cmd.Usage = func() { fmt.Fprint(os.Stderr, configUsage) }
+		redact := cmd.Bool("redacted", false, "hide secrets")
+		cmd.Parse(args[1:])
+		if *redact {
+			cfg = cfg.Redacted()
+		}
+		b, err := yaml.Marshal(cfg.Settings())
+		if err != nil {
+			log.Panic().Msgf("cannot print configuration: %s", err)
+		}
+		fmt.Print(string(b))
+	default:
+		fmt.Fprint(os.Stderr, configUsage)
+		os.Exit(2)
+	}
+}
@@ -2,23 +2,40 @@ package main
 
 import (
 	"amadeus-trip-parser/internal/adapter/api"
+	"amadeus-trip-parser/internal/adapter/auth"
+	"amadeus-trip-parser/internal/adapter/backend/geo"
 	"amadeus-trip-parser/internal/adapter/backend/mail/gmail"
 	"amadeus-trip-parser/internal/adapter/backend/parser/amadeus"
+	"amadeus-trip-parser/internal/adapter/metrics"
 	"amadeus-trip-parser/internal/adapter/repository"
+	"amadeus-trip-parser/internal/adapter/secrets"
+	"amadeus-trip-parser/internal/adapter/tracing"
+	"amadeus-trip-parser/internal/config"
 	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
 	"amadeus-trip-parser/internal/usecase"
+	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"github.com/labstack/echo-contrib/prometheus"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
+	prom "github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
-	"github.com/spf13/viper"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"os/signal"
 	"path"
 	"path/filepath"
 	"runtime"
-	"strings"
+	"sync"
+	"syscall"
+	"time"
+	// the time zones of calendar events are loaded on hosts without a time zone database
+	_ "time/tzdata"
 )
 
 func rootDir() string {
@@ -27,84 +44,479 @@ func rootDir() string {
 	return filepath.Dir(d)
 }
 
-func loadConfig() {
-	//load environment variables: e.g. 'parser.url' config key matches 'PARSER_URL' environment variable
-	viper.AutomaticEnv()
-	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+// loadConfig reads the config file of the working directory or of the project root, defaults are used without
+// file. The configuration is validated by the commands needing it.
+func loadConfig() config.Config {
+	cfg, file, err := config.Load(".", rootDir())
+	if err != nil {
+		log.Panic().Msgf("error reading config: %s", err)
+	}
+	if file == "" {
+		log.Info().Msg("no config file found, using defaults and environment variables")
+	} else {
+		log.Debug().Msgf("config read from %s", file)
+	}
+	if cfg, err = resolveSecrets(cfg); err != nil {
+		log.Panic().Msgf("cannot read secrets: %s", err)
+	}
+	return cfg
+}
+
+// resolveSecrets reads the secret:<path>#<key> values from the configured secret provider
+func resolveSecrets(cfg config.Config) (config.Config, error) {
+	provider, err := newSecretProvider(cfg.Secrets)
+	if err != nil || provider == nil {
+		return cfg, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return cfg.ResolveSecrets(ctx, provider)
+}
+
+// newSecretProvider returns the provider of 'secrets.provider', nil when none is configured
+func newSecretProvider(cfg config.SecretsConfig) (domain.SecretProvider, error) {
+	return secrets.NewProvider(cfg.Provider, secrets.VaultConfig{
+		Address:   cfg.Vault.Address,
+		Token:     cfg.Vault.Token,
+		Mount:     cfg.Vault.Mount,
+		KVVersion: cfg.Vault.KVVersion,
+	})
+}
+
+// initTokenCipher encrypts mailbox tokens at rest with 'mail.token_key', they are kept plain without key
+func initTokenCipher(cfg config.MailConfig) *secrets.Cipher {
+	if cfg.TokenKey == "" {
+		log.Warn().Msg("mail.token_key is not set, mailbox tokens are stored unencrypted")
+		return nil
+	}
+	key, err := secrets.ParseKey(cfg.TokenKey)
+	if err != nil {
+		log.Panic().Msgf("invalid mail.token_key: %s", err)
+	}
+	cipher, err := secrets.NewCipher(key)
+	if err != nil {
+		log.Panic().Msgf("cannot init token encryption: %s", err)
+	}
+	return cipher
+}
 
-	viper.SetConfigName("config")
-	viper.AddConfigPath(".")
-	viper.AddConfigPath(rootDir())
+func initMessageLedger(db *sql.DB, dialect string) domain.MessageLedger {
+	ledger, err := repository.NewMessageLedger(db, dialect)
+	if err != nil {
+		log.Panic().Msgf("cannot open message ledger: %s", err)
+	}
+	return ledger
+}
 
-	err := viper.ReadInConfig()
+func initFailureStore(db *sql.DB, dialect string) domain.FailureStore {
+	failures, err := repository.NewFailureStore(db, dialect)
 	if err != nil {
-		log.Panic().Msgf("error reading config file: %s", err)
-		//TODO set default configuration
+		log.Panic().Msgf("cannot open failure store: %s", err)
 	}
-	log.Debug().Msgf("config keys: %s", viper.AllKeys())
+	return failures
 }
 
-func initMailClient() domain.EmailProvider {
-	mc, err := gmail.NewGMailClient(
-		viper.GetString("mail.credentials"),
-		viper.GetString("mail.token"))
+func initAPIKeyStore(db *sql.DB, dialect string) domain.APIKeyStore {
+	keys, err := repository.NewAPIKeyStore(db, dialect)
+	if err != nil {
+		log.Panic().Msgf("cannot open api key store: %s", err)
+	}
+	return keys
+}
+
+// initAuthenticator accepts API keys, and bearer tokens when a JWKS is configured
+func initAuthenticator(cfg config.JWTConfig, keys domain.APIKeyStore) domain.Authenticator {
+	var verifier domain.TokenVerifier
+	if cfg.JWKS != "" {
+		v, err := auth.NewJWTVerifier(auth.JWTConfig{
+			JWKS:       cfg.JWKS,
+			Issuer:     cfg.Issuer,
+			Audience:   cfg.Audience,
+			RolesClaim: cfg.RolesClaim,
+			AdminRole:  cfg.AdminRole,
+		})
+		if err != nil {
+			log.Panic().Msgf("cannot init bearer token validation: %s", err)
+		}
+		verifier = v
+	}
+	return usecase.NewAuthenticator(keys, verifier)
+}
+
+func initMetrics() domain.Metrics {
+	// registered next to the HTTP metrics of the echo middleware
+	m, err := metrics.NewPrometheusMetrics(prom.DefaultRegisterer)
+	if err != nil {
+		log.Panic().Msgf("cannot register metrics: %s", err)
+	}
+	return m
+}
+
+func initTracing(cfg config.TracingConfig) func(ctx context.Context) error {
+	shutdown, err := tracing.Init(tracing.Config{
+		Exporter:    cfg.Exporter,
+		ServiceName: "amadeus-trip-parser",
+		Endpoint:    cfg.Endpoint,
+		Insecure:    cfg.Insecure,
+	})
+	if err != nil {
+		log.Panic().Msgf("cannot init tracing: %s", err)
+	}
+	return shutdown
+}
+
+func initUserStore(db *sql.DB, dialect string) domain.UserStore {
+	users, err := repository.NewUserStore(db, dialect)
+	if err != nil {
+		log.Panic().Msgf("cannot open user store: %s", err)
+	}
+	return users
+}
+
+func initMailboxStore(db *sql.DB, dialect string, cipher *secrets.Cipher) domain.MailboxStore {
+	mailboxes, err := repository.NewMailboxStore(db, dialect, cipher)
+	if err != nil {
+		log.Panic().Msgf("cannot open mailbox store: %s", err)
+	}
+	return mailboxes
+}
+
+func initMailProviders(cfg config.MailConfig, cipher *secrets.Cipher, mailboxes domain.MailboxStore,
+	m domain.Metrics) domain.EmailProviderFactory {
+	f, err := gmail.NewProviderFactory(cfg.Credentials, cipher, mailboxes, m)
 	if err != nil {
 		log.Panic().Msgf("when creating mail client: %s", err)
 	}
-	return mc
+	return f
+}
+
+// refreshToken reads the refresh token of an OAuth token JSON, empty when invalid
+func refreshToken(token string) string {
+	var tok struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	json.Unmarshal([]byte(token), &tok)
+	return tok.RefreshToken
 }
 
-func initMailParser() domain.EmailParser {
-	p, err := amadeus.NewAmadeusTripAPI(
-		viper.GetString("parser.url"),
-		viper.GetString("parser.key"),
-		viper.GetString("parser.secret"))
+// configuredMailbox is the ID of the mailbox of the 'mail.token' configuration
+const configuredMailbox = "configured"
+
+// importConfiguredMailbox keeps the mailbox of the configured token, owned by 'mail.owner' which is created as a
+// user when missing, so that a single mailbox setup works without the admin API. The token file may be encrypted.
+func importConfiguredMailbox(cfg config.MailConfig, cipher *secrets.Cipher, users domain.UserService,
+	mailboxes domain.MailboxStore) {
+	if cfg.Token == "" {
+		return
+	}
+	file, err := ioutil.ReadFile(cfg.Token)
+	if err != nil {
+		log.Panic().Msgf("cannot read mail token: %s", err)
+	}
+	token, err := cipher.Decrypt(file)
+	if err != nil {
+		log.Panic().Msgf("cannot decrypt mail token: %s", err)
+	}
+	owner := cfg.Owner
+	if owner != "" {
+		if _, err := users.GetUserBySubject(owner); errors.Is(err, domain.ErrorNoUser) {
+			if _, err := users.CreateUser(owner, "", model.RoleUser); err != nil {
+				log.Panic().Msgf("cannot create mailbox owner %s: %s", owner, err)
+			}
+		} else if err != nil {
+			log.Panic().Msgf("cannot find mailbox owner %s: %s", owner, err)
+		}
+	}
+	mailbox := &model.Mailbox{ID: configuredMailbox, Owner: owner, Provider: model.MailboxProviderGmail}
+	if existing, err := mailboxes.Get(configuredMailbox); err == nil {
+		mailbox = &existing
+		mailbox.Owner = owner
+	}
+	// the stored token is the last refreshed one, it is only replaced by a new authorization
+	if refreshToken(mailbox.Token) == "" || refreshToken(mailbox.Token) != refreshToken(string(token)) {
+		mailbox.Token = string(token)
+	}
+	if err := mailboxes.Save(mailbox); err != nil {
+		log.Panic().Msgf("cannot save configured mailbox: %s", err)
+	}
+}
+
+func initMailParser(cfg config.ParserConfig, m domain.Metrics) domain.EmailParser {
+	p, err := amadeus.NewAmadeusTripAPI(cfg.URL, cfg.Key, cfg.Secret, m)
 	if err != nil {
 		log.Panic().Msgf("when creating parser: %s", err)
 	}
 	return p
 }
 
-func initRepository() domain.TripRepository {
-	name := viper.GetString("repository.name")
-	db, err := sql.Open("sqlite3", name)
+// openDB opens the database of 'repository.driver' holding the trips along with the processing state, users and
+// mailboxes: the SQLite file of 'repository.name' by default, or the PostgreSQL database of 'repository.dsn' shared
+// by replicas. Its dialect is returned with it.
+func openDB(cfg config.RepositoryConfig) (*sql.DB, string) {
+	if cfg.Driver == config.DriverPostgres {
+		db, err := sql.Open("postgres", cfg.DSN)
+		if err != nil {
+			log.Panic().Msgf("cannot open postgres connection: %s", err)
+		}
+		return db, repository.DialectPostgres
+	}
+	db, err := sql.Open("sqlite3", cfg.Name)
 	if err != nil {
-		log.Panic().Msgf("cannot open DB connection with db name %s: %s", name, err)
+		log.Panic().Msgf("cannot open DB connection with db name %s: %s", cfg.Name, err)
+	}
+	// a single connection serializes writes and keeps a ':memory:' database shared by all repositories
+	db.SetMaxOpenConns(1)
+	return db, repository.DialectSQLite
+}
+
+// migrateDB applies the pending migrations, it refuses a database migrated by a newer version
+func migrateDB(db *sql.DB, dialect string) {
+	if err := repository.Migrate(db, dialect); err != nil {
+		log.Panic().Msgf("cannot migrate %s database: %s", dialect, err)
+	}
+}
+
+func initRepository(db *sql.DB, dialect string) domain.TripRepository {
+	var repo domain.TripRepository
+	var err error
+	if dialect == repository.DialectPostgres {
+		repo, err = repository.NewPostgresTripRepo(db)
+	} else {
+		repo, err = repository.NewSQLiteTripRepo(db)
 	}
-	repo, err := repository.NewSQLiteTripRepo(db)
 	if err != nil {
-		log.Panic().Msgf("cannot open repository with db name %s: %s", name, err)
+		log.Panic().Msgf("cannot open repository: %s", err)
 	}
 	return repo
 }
 
-func runServer(repo domain.TripRepository) {
+// initLocator locates the steps of the trips stored in repo, hotels are only located with a geocoder configured. Its
+// results are cached in the database.
+func initLocator(cfg config.GeoConfig, db *sql.DB, dialect string, repo domain.TripRepository) domain.TripRepository {
+	airports, err := geo.NewAirports(cfg.Airports)
+	if err != nil {
+		log.Panic().Msgf("cannot read airports: %s", err)
+	}
+	var geocoder domain.Geocoder
+	if cfg.Geocoder == config.GeocoderNominatim {
+		cache, err := repository.NewGeocodeCache(db, dialect)
+		if err != nil {
+			log.Panic().Msgf("cannot open geocode cache: %s", err)
+		}
+		geocoder = usecase.NewCachedGeocoder(geo.NewNominatimGeocoder(cfg.URL, cfg.UserAgent), cache)
+	}
+	return usecase.NewLocatingRepository(repo, airports, geocoder, cfg.Timeout)
+}
+
+func initJobQueue(db *sql.DB, dialect string) domain.JobQueue {
+	queue, err := repository.NewJobQueue(db, dialect)
+	if err != nil {
+		log.Panic().Msgf("cannot open job queue: %s", err)
+	}
+	return queue
+}
+
+// processingLeaseTTL is the time the processing lock of a SQLite database is held without check, once its holder
+// stopped
+const processingLeaseTTL = 3 * usecase.DefaultLockInterval
+
+// initProcessingLock returns the lock of the process handling emails: an advisory lock of a PostgreSQL database
+// shared by replicas, or a lease of a SQLite database shared by the server and the backfill command
+func initProcessingLock(db *sql.DB, dialect string) domain.ProcessingLock {
+	if dialect == repository.DialectPostgres {
+		return repository.NewAdvisoryLock(db, repository.ProcessingLockName)
+	}
+	return repository.NewLeaseLock(db, repository.ProcessingLockName, processingLeaseTTL)
+}
+
+// initProcessor processes emails in a single process among those sharing the database, the one holding its
+// processing lock
+func initProcessor(db *sql.DB, dialect string, proc domain.EmailProcessor) domain.EmailProcessor {
+	return usecase.NewExclusiveProcessor(proc, initProcessingLock(db, dialect), usecase.DefaultLockInterval)
+}
+
+func processorConfig(cfg config.ProcessorConfig) usecase.ProcessorConfig {
+	return usecase.ProcessorConfig{
+		MailInterval:  cfg.MailInterval,
+		PollInterval:  cfg.PollInterval,
+		QueueSize:     cfg.QueueSize,
+		CreateWorkers: cfg.Workers.Create,
+		StatusWorkers: cfg.Workers.Status,
+		ResultWorkers: cfg.Workers.Result,
+	}
+}
+
+func healthConfig(cfg config.HealthConfig) usecase.HealthConfig {
+	return usecase.HealthConfig{
+		Timeout:        cfg.Timeout,
+		StallThreshold: cfg.StallThreshold,
+	}
+}
+
+func newServer(cfg config.CalendarConfig, repo domain.TripRepository, parseJobs domain.ParseJobService, proc domain.EmailProcessor,
+	failures domain.FailureService, health domain.HealthService, authenticator domain.Authenticator,
+	users domain.UserService) *echo.Echo {
 	finder := usecase.NewTripFinder(repo)
-	api := api.NewTripAPI(finder)
+	tripAPI := api.NewTripAPI(finder)
+	calendarAPI := api.NewCalendarAPI(finder)
+	reportAPI := api.NewReportAPI(finder)
+	geoAPI := api.NewGeoJSONAPI(finder)
+	calendarAuth := api.CalendarTokenAuth(cfg.Tokens)
+	parseJobAPI := api.NewParseJobAPI(parseJobs)
+	messageAPI := api.NewMessageAPI(proc)
+	failureAPI := api.NewFailureAPI(failures)
+	healthAPI := api.NewHealthAPI(health)
+	adminAPI := api.NewAdminAPI(users)
+	archiveAPI := api.NewArchiveAPI(usecase.NewArchiveService(repo))
+	authenticate := api.Authenticate(authenticator)
 
 	e := echo.New()
+	e.Use(tracing.Middleware())
 	e.Use(middleware.Logger())
 	e.Use(middleware.Recover())
 	p := prometheus.NewPrometheus("echo", nil)
 	p.Use(e)
 
-	e.GET("/trip", api.Get)
+	// calendar tokens authenticate feeds before the API credentials
+	e.GET("/trip", tripAPI.Get, authenticate)
+	e.GET("/trips.ics", calendarAPI.GetAll, calendarAuth, authenticate)
+	e.GET("/trips.csv", reportAPI.CSV, authenticate)
+	e.GET("/trips.xlsx", reportAPI.XLSX, authenticate)
+	e.GET("/trips/:id", api.ByExtension("id", map[string]echo.HandlerFunc{
+		".ics":     calendarAuth(authenticate(calendarAPI.GetOne)),
+		".geojson": authenticate(geoAPI.GetOne),
+	}))
+	e.POST("/parse-jobs", parseJobAPI.Create, authenticate)
+	e.GET("/parse-jobs/:id", parseJobAPI.Get, authenticate)
+	// the processing pipeline is operated by admins
+	e.POST("/messages/:id/reprocess", messageAPI.Reprocess, authenticate, api.RequireAdmin)
+	e.GET("/failures", failureAPI.List, authenticate, api.RequireAdmin)
+	e.GET("/failures/:id", failureAPI.Get, authenticate, api.RequireAdmin)
+	e.POST("/failures/:id/retry", failureAPI.Retry, authenticate, api.RequireAdmin)
+	e.DELETE("/failures/:id", failureAPI.Delete, authenticate, api.RequireAdmin)
+	e.POST("/users", adminAPI.CreateUser, authenticate, api.RequireAdmin)
+	e.GET("/users", adminAPI.ListUsers, authenticate, api.RequireAdmin)
+	e.DELETE("/users/:id", adminAPI.DeleteUser, authenticate, api.RequireAdmin)
+	e.POST("/users/:id/mailboxes", adminAPI.ConnectMailbox, authenticate, api.RequireAdmin)
+	e.GET("/mailboxes", adminAPI.ListMailboxes, authenticate, api.RequireAdmin)
+	e.DELETE("/mailboxes/:id", adminAPI.DisconnectMailbox, authenticate, api.RequireAdmin)
+	e.GET("/export", archiveAPI.Export, authenticate, api.RequireAdmin)
+	e.POST("/import", archiveAPI.Import, authenticate, api.RequireAdmin)
+	e.GET("/healthz", healthAPI.Live)
+	e.GET("/readyz", healthAPI.Ready)
+	return e
+}
+
+// shutdown stops the server, the processor and the parse jobs together, in-flight requests and jobs are given until
+// the configured timeout to complete, their spans are flushed afterwards
+func shutdown(timeout time.Duration, e *echo.Echo, proc domain.EmailProcessor, parseJobs domain.ParseJobService,
+	stopTracing func(ctx context.Context) error) {
+	log.Info().Msgf("shutting down within %s", timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
 
-	e.Start(viper.GetString("api.listen"))
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := e.Shutdown(ctx); err != nil {
+			log.Error().Msgf("cannot shutdown server gracefully: %s", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		if err := proc.Stop(ctx); err != nil {
+			log.Error().Msgf("cannot stop email processor gracefully: %s", err)
+		}
+	}()
+	wg.Wait()
+	// uploads are refused once the server is stopped, the jobs running are cancelled
+	if err := parseJobs.Stop(ctx); err != nil {
+		log.Error().Msgf("cannot stop parse jobs gracefully: %s", err)
+	}
+	if err := stopTracing(ctx); err != nil {
+		log.Error().Msgf("cannot flush traces: %s", err)
+	}
 }
 
 func main() {
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr})
 
-	loadConfig()
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		runConfig(os.Args[2:])
+		return
+	}
+	cfg := loadConfig()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(cfg.Repository, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "parse" {
+		runParse(cfg, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		runBackfill(cfg, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "export" {
+		runExport(cfg, os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		runImport(cfg, os.Args[2:])
+		return
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Panic().Msgf("invalid configuration: %s", err)
+	}
+	stopTracing := initTracing(cfg.Tracing)
 
-	repo := initRepository()
-	mail := initMailClient()
-	parser := initMailParser()
-	proc := usecase.NewEmailProcessor(mail, parser, repo)
+	db, dialect := openDB(cfg.Repository)
+	migrateDB(db, dialect)
+	repo := initLocator(cfg.Geo, db, dialect, initRepository(db, dialect))
+	queue := initJobQueue(db, dialect)
+	ledger := initMessageLedger(db, dialect)
+	failures := initFailureStore(db, dialect)
+	keys := initAPIKeyStore(db, dialect)
+	cipher := initTokenCipher(cfg.Mail)
+	mailboxes := initMailboxStore(db, dialect, cipher)
+	users := usecase.NewUserService(initUserStore(db, dialect), mailboxes, keys)
+	importConfiguredMailbox(cfg.Mail, cipher, users, mailboxes)
+	m := initMetrics()
+	providers := initMailProviders(cfg.Mail, cipher, mailboxes, m)
+	parser := initMailParser(cfg.Parser, m)
+	proc := initProcessor(db, dialect, usecase.NewEmailProcessor(mailboxes, providers, parser, repo, queue, ledger,
+		failures, m, processorConfig(cfg.Processor)))
 	proc.Process()
 
-	runServer(repo)
-	proc.Stop()
+	checks := map[string]domain.HealthChecker{
+		dialect:   domain.HealthCheckerFunc(db.PingContext),
+		"amadeus": parser,
+		"gmail":   usecase.NewMailboxHealthChecker(mailboxes, providers),
+	}
+	health := usecase.NewHealthService(checks, proc, healthConfig(cfg.Health))
+	parseJobs := usecase.NewParseJobService(parser, repo, m)
+	e := newServer(cfg.Calendar, repo, parseJobs, proc,
+		usecase.NewFailureService(failures, proc), health, initAuthenticator(cfg.Auth.JWT, keys), users)
+	stopped := make(chan struct{})
+	go func() {
+		if err := e.Start(cfg.API.Listen); err != nil && err != http.ErrServerClosed {
+			log.Error().Msgf("server stopped: %s", err)
+		}
+		close(stopped)
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	select {
+	case sig := <-quit:
+		log.Info().Msgf("received %s", sig)
+	case <-stopped:
+	}
+	shutdown(cfg.Shutdown.Timeout, e, proc, parseJobs, stopTracing)
+	if err := db.Close(); err != nil {
+		log.Error().Msgf("cannot close database: %s", err)
+	}
 }
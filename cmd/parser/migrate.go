@@ -0,0 +1,113 @@
+package main
+
+import (
+	"amadeus-trip-parser/internal/adapter/repository"
+	"amadeus-trip-parser/internal/config"
+	"database/sql"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+)
+
+const migrateUsage = `usage: parser migrate <command>
+
+Manages the schema of the database of repository.driver.
+
+commands:
+  status        lists the migrations and when they were applied
+  up            applies all pending migrations
+  down          reverts the last applied migration
+  to <version>  applies or reverts migrations until the schema is at version, 0 reverts all
+  copy <file>   copies the users, mailboxes, API keys and processing state of a SQLite database, such as the one
+                of a replica before its stores moved to postgres, rows already there are kept
+`
+
+// runMigrate manages the schema of the configured database
+func runMigrate(cfg config.RepositoryConfig, args []string) {
+	cmd := flag.NewFlagSet("migrate", flag.ExitOnError)
+	cmd.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+	cmd.Parse(args)
+	if cmd.NArg() == 0 {
+		cmd.Usage()
+		os.Exit(2)
+	}
+
+	db, dialect := openDB(cfg)
+	defer db.Close()
+	m, err := repository.NewMigrator(db, dialect)
+	if err != nil {
+		log.Panic().Msgf("cannot load migrations: %s", err)
+	}
+
+	switch cmd.Arg(0) {
+	case "status":
+		printMigrations(m)
+	case "up":
+		err = m.Up()
+	case "down":
+		err = m.Down()
+	case "copy":
+		if cmd.Arg(1) == "" {
+			cmd.Usage()
+			os.Exit(2)
+		}
+		if err = m.Up(); err == nil {
+			copyStores(cmd.Arg(1), db, dialect)
+		}
+	case "to":
+		version, convErr := strconv.Atoi(cmd.Arg(1))
+		if convErr != nil {
+			cmd.Usage()
+			os.Exit(2)
+		}
+		err = m.To(version)
+	default:
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Panic().Msgf("cannot migrate %s database: %s", dialect, err)
+	}
+	if version, err := m.Version(); err == nil {
+		fmt.Printf("%s schema is at version %d of %d\n", dialect, version, m.Latest())
+	}
+}
+
+func printMigrations(m *repository.Migrator) {
+	status, err := m.Status()
+	if err != nil {
+		log.Panic().Msgf("cannot read migrations: %s", err)
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range status {
+		applied := "pending"
+		if s.AppliedAt != nil {
+			applied = s.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+	}
+	w.Flush()
+}
+
+// copyStores copies the stores of a SQLite database into the migrated database db, the trips are moved by export
+// and import
+func copyStores(file string, db *sql.DB, dialect string) {
+	// sqlite3 would create a missing file
+	if _, err := os.Stat(file); err != nil {
+		log.Panic().Msgf("cannot open %s: %s", file, err)
+	}
+	src, srcDialect := openDB(config.RepositoryConfig{Name: file, Driver: config.DriverSQLite})
+	defer src.Close()
+	migrateDB(src, srcDialect)
+	copies, err := repository.CopyStores(src, srcDialect, db, dialect)
+	for _, c := range copies {
+		fmt.Printf("%s: %d of %d rows copied\n", c.Table, c.Copied, c.Rows)
+	}
+	if err != nil {
+		log.Panic().Msgf("cannot copy %s: %s", file, err)
+	}
+}
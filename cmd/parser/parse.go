@@ -0,0 +1,179 @@
+package main
+
+import (
+	"amadeus-trip-parser/internal/adapter/backend/parser/ics"
+	"amadeus-trip-parser/internal/adapter/backend/parser/jsonld"
+	"amadeus-trip-parser/internal/config"
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"amadeus-trip-parser/internal/usecase"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/rs/zerolog/log"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const parseUsage = `usage: parser parse -file <email.eml> [-parser amadeus|jsonld|ics] [-format json|yaml|table] [-save]
+
+Parses an RFC822 email with a parser, waiting for its job to end, and prints the trip with the parser warnings.
+With -save the trip is stored in the repository, owned by -owner which defaults to mail.owner.
+
+parsers:
+  amadeus   the Amadeus trip parser API of the configuration
+  jsonld    the schema.org flight and lodging reservations embedded as JSON-LD in the HTML of the email
+  ics       the flight and hotel events of the iCalendar parts and attachments of the email
+
+flags:
+`
+
+// parsers are the ones the parse command can run, by name
+var parsers = map[string]func(cfg config.Config) domain.EmailParser{
+	"amadeus": func(cfg config.Config) domain.EmailParser {
+		return initMailParser(cfg.Parser, domain.NopMetrics{})
+	},
+	jsonld.ParserName: func(config.Config) domain.EmailParser { return jsonld.NewParser() },
+	ics.ParserName:    func(config.Config) domain.EmailParser { return ics.NewParser() },
+}
+
+// parseOutput is the trip printed by the parse command
+type parseOutput struct {
+	Trip     model.Trip `json:"trip" yaml:"trip"`
+	Warnings []string   `json:"warnings" yaml:"warnings"`
+}
+
+// runParse runs the parser job of a single email file synchronously, to debug the parser output
+func runParse(cfg config.Config, args []string) {
+	cmd := flag.NewFlagSet("parse", flag.ExitOnError)
+	cmd.Usage = func() {
+		fmt.Fprint(os.Stderr, parseUsage)
+		cmd.PrintDefaults()
+	}
+	file := cmd.String("file", "", "RFC822 email file to parse, such as a .eml export")
+	parserName := cmd.String("parser", "amadeus", "parser of the email")
+	format := cmd.String("format", "json", "output format, json, yaml or table")
+	save := cmd.Bool("save", false, "store the trip in the repository")
+	owner := cmd.String("owner", cfg.Mail.Owner, "subject of the user owning the saved trip")
+	cmd.Parse(args)
+	newParser, ok := parsers[*parserName]
+	if *file == "" || !ok || (*format != "json" && *format != "yaml" && *format != "table") {
+		cmd.Usage()
+		os.Exit(2)
+	}
+	if err := validateParse(cfg, *parserName); err != nil {
+		log.Panic().Msgf("invalid configuration: %s", err)
+	}
+
+	raw, err := ioutil.ReadFile(*file)
+	if err != nil {
+		log.Panic().Msgf("cannot read email: %s", err)
+	}
+	email, err := model.NewEmail(raw)
+	if err != nil {
+		log.Panic().Msgf("cannot read email: %s", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	log.Info().Msgf("parsing %q with %s parser", email.Subject, *parserName)
+	job, err := usecase.NewParseJobService(newParser(cfg), nil, domain.NopMetrics{}).Parse(ctx, email)
+	if err != nil {
+		log.Panic().Msgf("cannot parse email: %s", err)
+	}
+	if err := printTrip(os.Stdout, *format, parseOutput{Trip: job.Trip, Warnings: job.Warnings}); err != nil {
+		log.Panic().Msgf("cannot print trip: %s", err)
+	}
+	if *save {
+		saveTrip(ctx, cfg, *owner, job.Trip)
+	}
+}
+
+// validateParse validates the configuration, the parser keys only matter to the Amadeus parser
+func validateParse(cfg config.Config, parserName string) error {
+	err := cfg.Validate()
+	var errs config.ValidationErrors
+	if parserName == "amadeus" || !errors.As(err, &errs) {
+		return err
+	}
+	var kept config.ValidationErrors
+	for _, e := range errs {
+		if !strings.HasPrefix(e.Key, "parser.") {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+func printTrip(w io.Writer, format string, out parseOutput) error {
+	switch format {
+	case "yaml":
+		b, err := yaml.Marshal(out)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(b)
+		return err
+	case "table":
+		return printTripTable(w, out)
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+}
+
+func printTripTable(w io.Writer, out parseOutput) error {
+	t := out.Trip
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	var travellers []string
+	for _, tr := range t.Travellers {
+		travellers = append(travellers, strings.TrimSpace(tr.FirstName+" "+tr.LastName))
+	}
+	fmt.Fprintf(tw, "reference:\t%s\n", t.Reference)
+	fmt.Fprintf(tw, "start:\t%s\n", t.Start.Format(time.RFC3339))
+	fmt.Fprintf(tw, "end:\t%s\n", t.End.Format(time.RFC3339))
+	fmt.Fprintf(tw, "travellers:\t%s\n", strings.Join(travellers, ", "))
+	fmt.Fprintln(tw)
+	fmt.Fprintln(tw, "TYPE\tDATE\tLOCATION\tDESCRIPTION")
+	for _, s := range t.TripSteps {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", s.Type, s.DateTime.Format(time.RFC3339), s.Location, s.Description)
+	}
+	if len(out.Warnings) > 0 {
+		fmt.Fprintln(tw)
+		fmt.Fprintln(tw, "WARNINGS")
+		for _, warning := range out.Warnings {
+			fmt.Fprintln(tw, warning)
+		}
+	}
+	return tw.Flush()
+}
+
+// saveTrip stores the trip parsed as the server would, in the database of the configuration, in place of the trip of
+// owner with the same reference
+func saveTrip(ctx context.Context, cfg config.Config, owner string, trip model.Trip) {
+	db, dialect := openDB(cfg.Repository)
+	defer db.Close()
+	migrateDB(db, dialect)
+	trip.Owner = owner
+	created, err := initLocator(cfg.Geo, db, dialect, initRepository(db, dialect)).Merge(ctx, &trip)
+	if err != nil {
+		log.Panic().Msgf("cannot save trip: %s", err)
+	}
+	if created {
+		log.Info().Msgf("trip %s (ref: %s) saved for %q", trip.ID, trip.Reference, owner)
+	} else {
+		log.Info().Msgf("trip %s (ref: %s) of %q updated", trip.ID, trip.Reference, owner)
+	}
+}
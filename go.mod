@@ -1,16 +1,26 @@
 module amadeus-trip-parser
 
-go 1.14
+go 1.16
 
 require (
-	github.com/google/uuid v1.1.1
+	github.com/fergusstrange/embedded-postgres v1.19.0
+	github.com/golang-jwt/jwt/v4 v4.5.2
+	github.com/google/uuid v1.1.2
 	github.com/jinzhu/gorm v1.9.12
 	github.com/labstack/echo-contrib v0.9.0
 	github.com/labstack/echo/v4 v4.1.16
+	github.com/mitchellh/mapstructure v1.1.2
+	github.com/prometheus/client_golang v1.1.0
 	github.com/rs/zerolog v1.19.0
 	github.com/spf13/viper v1.7.0
-	github.com/stretchr/testify v1.4.0
-	golang.org/x/net v0.0.0-20200324143707-d3edc9973b7e
-	golang.org/x/oauth2 v0.0.0-20200107190931-bf48bf16ab8d
-	google.golang.org/api v0.25.0
+	github.com/stretchr/testify v1.7.1
+	go.opentelemetry.io/otel v1.7.0
+	go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp v1.7.0
+	go.opentelemetry.io/otel/exporters/stdout/stdouttrace v1.7.0
+	go.opentelemetry.io/otel/sdk v1.7.0
+	go.opentelemetry.io/otel/trace v1.7.0
+	golang.org/x/net v0.0.0-20210405180319-a5a99cb37ef4
+	golang.org/x/oauth2 v0.0.0-20211104180415-d3ed0bb246c8
+	google.golang.org/api v0.30.0
+	gopkg.in/yaml.v2 v2.2.7
 )
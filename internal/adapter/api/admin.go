@@ -0,0 +1,113 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"encoding/json"
+	"errors"
+	"github.com/labstack/echo/v4"
+	. "net/http"
+)
+
+// AdminAPI manages users and their mailboxes, it is meant for admins only
+type AdminAPI interface {
+	CreateUser(c echo.Context) error
+	ListUsers(c echo.Context) error
+	DeleteUser(c echo.Context) error
+	ConnectMailbox(c echo.Context) error
+	ListMailboxes(c echo.Context) error
+	DisconnectMailbox(c echo.Context) error
+}
+
+type adminAPI struct {
+	service domain.UserService
+}
+
+func NewAdminAPI(service domain.UserService) AdminAPI {
+	return &adminAPI{service: service}
+}
+
+type userRequest struct {
+	Subject string
+	Name    string
+	Role    model.Role
+}
+
+// mailboxRequest carries the OAuth client and token JSON as documents, the client is the configured one when omitted
+type mailboxRequest struct {
+	Provider    model.MailboxProvider
+	Address     string
+	Credentials json.RawMessage
+	Token       json.RawMessage
+}
+
+func (a *adminAPI) CreateUser(c echo.Context) error {
+	var req userRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	user, err := a.service.CreateUser(req.Subject, req.Name, req.Role)
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(StatusCreated, user)
+}
+
+func (a *adminAPI) ListUsers(c echo.Context) error {
+	users, err := a.service.ListUsers()
+	if err != nil {
+		return echo.NewHTTPError(StatusInternalServerError, err)
+	}
+	return c.JSON(StatusOK, users)
+}
+
+func (a *adminAPI) DeleteUser(c echo.Context) error {
+	if err := a.service.DeleteUser(c.Param("id")); err != nil {
+		return adminError(err)
+	}
+	return c.NoContent(StatusNoContent)
+}
+
+func (a *adminAPI) ConnectMailbox(c echo.Context) error {
+	var req mailboxRequest
+	if err := c.Bind(&req); err != nil {
+		return err
+	}
+	mailbox, err := a.service.ConnectMailbox(c.Param("id"), model.Mailbox{
+		Provider:    req.Provider,
+		Address:     req.Address,
+		Credentials: string(req.Credentials),
+		Token:       string(req.Token),
+	})
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(StatusCreated, mailbox)
+}
+
+// ListMailboxes lists the mailboxes of the 'user' query parameter, or all of them
+func (a *adminAPI) ListMailboxes(c echo.Context) error {
+	mailboxes, err := a.service.ListMailboxes(c.QueryParam("user"))
+	if err != nil {
+		return adminError(err)
+	}
+	return c.JSON(StatusOK, mailboxes)
+}
+
+func (a *adminAPI) DisconnectMailbox(c echo.Context) error {
+	if err := a.service.DisconnectMailbox(c.Param("id")); err != nil {
+		return adminError(err)
+	}
+	return c.NoContent(StatusNoContent)
+}
+
+func adminError(err error) error {
+	switch {
+	case errors.Is(err, domain.ErrorInvalidUser), errors.Is(err, domain.ErrorInvalidMailbox):
+		return echo.NewHTTPError(StatusBadRequest, err.Error())
+	case errors.Is(err, domain.ErrorNoUser), errors.Is(err, domain.ErrorNoMailbox):
+		return echo.NewHTTPError(StatusNotFound, err.Error())
+	default:
+		return echo.NewHTTPError(StatusInternalServerError, err)
+	}
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_adminAPI(t *testing.T) {
+	mockService := &mocks.UserService{}
+	mockService.On("CreateUser", "alice", "Alice", model.Role("")).
+		Return(model.User{ID: "U0", Subject: "alice", Name: "Alice", Role: model.RoleUser}, nil)
+	mockService.On("CreateUser", "", "", model.Role("")).
+		Return(model.User{}, fmt.Errorf("%w: no subject", domain.ErrorInvalidUser))
+	mockService.On("DeleteUser", "U0").Return(nil)
+	mockService.On("DeleteUser", "U9").Return(domain.ErrorNoUser)
+	mockService.On("ConnectMailbox", "U0", model.Mailbox{Token: `{"refresh_token":"r"}`}).
+		Return(model.Mailbox{ID: "M0", Owner: "alice", Provider: model.MailboxProviderGmail, Token: "secret"}, nil)
+	mockService.On("ConnectMailbox", "U0", model.Mailbox{Provider: "imap"}).
+		Return(model.Mailbox{}, fmt.Errorf("%w: unknown provider imap", domain.ErrorInvalidMailbox))
+	mockService.On("DisconnectMailbox", "M9").Return(domain.ErrorNoMailbox)
+	a := NewAdminAPI(mockService)
+	e := echo.New()
+
+	tests := []struct {
+		name     string
+		method   string
+		handler  echo.HandlerFunc
+		id       string
+		body     string
+		code     int
+		wantBody string
+	}{
+		{"create user", http.MethodPost, a.CreateUser, "", `{"Subject":"alice","Name":"Alice"}`, 201,
+			`{"ID":"U0","Subject":"alice","Name":"Alice","Role":"user","CreatedAt":"0001-01-01T00:00:00Z"}`},
+		{"create user without subject", http.MethodPost, a.CreateUser, "", `{}`, 400, ""},
+		{"delete user", http.MethodDelete, a.DeleteUser, "U0", "", 204, ""},
+		{"delete unknown user", http.MethodDelete, a.DeleteUser, "U9", "", 404, ""},
+		// the token is not sent back
+		{"connect mailbox", http.MethodPost, a.ConnectMailbox, "U0", `{"Token":{"refresh_token":"r"}}`, 201,
+			`{"ID":"M0","Owner":"alice","Provider":"gmail","Address":"",` +
+				`"CreatedAt":"0001-01-01T00:00:00Z","UpdatedAt":"0001-01-01T00:00:00Z"}`},
+		{"connect unsupported mailbox", http.MethodPost, a.ConnectMailbox, "U0", `{"Provider":"imap"}`, 400, ""},
+		{"disconnect unknown mailbox", http.MethodDelete, a.DisconnectMailbox, "M9", "", 404, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/", strings.NewReader(tt.body))
+			req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues(tt.id)
+
+			err := tt.handler(ctx)
+			if tt.code >= 400 {
+				if assert.IsType(t, &echo.HTTPError{}, err) {
+					assert.Equal(t, tt.code, err.(*echo.HTTPError).Code)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	. "net/http"
+	"time"
+)
+
+// MIMEApplicationJSONLines is the type of the JSON Lines archives
+const MIMEApplicationJSONLines = "application/x-ndjson"
+
+// ArchiveAPI exports and imports the trips of every owner, it is meant for admins only
+type ArchiveAPI interface {
+	Export(c echo.Context) error
+	Import(c echo.Context) error
+}
+
+type archiveAPI struct {
+	service domain.ArchiveService
+}
+
+func NewArchiveAPI(service domain.ArchiveService) ArchiveAPI {
+	return &archiveAPI{service: service}
+}
+
+// Export downloads the archive of the 'format' query parameter, JSON Lines by default
+func (a *archiveAPI) Export(c echo.Context) error {
+	format := model.ArchiveFormat(c.QueryParam("format"))
+	if format == "" {
+		format = model.ArchiveFormatJSONL
+	}
+	mime := MIMEApplicationJSONLines
+	switch format {
+	case model.ArchiveFormatJSONL:
+	case model.ArchiveFormatJSON:
+		mime = echo.MIMEApplicationJSON
+	default:
+		return echo.NewHTTPError(StatusBadRequest, fmt.Sprintf("unknown archive format %q", format))
+	}
+	// the archive is written once complete, so that a failure is not sent as a truncated one
+	var b bytes.Buffer
+	if _, err := a.service.Export(c.Request().Context(), &b, format); err != nil {
+		return echo.NewHTTPError(StatusInternalServerError, err)
+	}
+	c.Response().Header().Set(echo.HeaderContentDisposition,
+		fmt.Sprintf("attachment; filename=trips-%s.%s", time.Now().UTC().Format("20060102"), format))
+	return c.Blob(StatusOK, mime, b.Bytes())
+}
+
+// Import merges the trips of the archive of the request body, of either format
+func (a *archiveAPI) Import(c echo.Context) error {
+	res, err := a.service.Import(c.Request().Context(), c.Request().Body)
+	if err != nil {
+		if errors.Is(err, domain.ErrorInvalidArchive) {
+			return echo.NewHTTPError(StatusBadRequest, err.Error())
+		}
+		return echo.NewHTTPError(StatusInternalServerError, err)
+	}
+	return c.JSON(StatusOK, res)
+}
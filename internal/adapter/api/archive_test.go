@@ -0,0 +1,84 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_archiveAPI_Export(t *testing.T) {
+	mockService := &mocks.ArchiveService{}
+	for _, format := range []model.ArchiveFormat{model.ArchiveFormatJSONL, model.ArchiveFormatJSON} {
+		format := format
+		mockService.On("Export", mock.Anything, mock.Anything, format).Return(func(_ context.Context, w io.Writer,
+			_ model.ArchiveFormat) int {
+			fmt.Fprintf(w, "%s archive\n", format)
+			return 1
+		}, nil)
+	}
+	a := NewArchiveAPI(mockService)
+
+	tests := []struct {
+		name    string
+		query   string
+		mime    string
+		body    string
+		wantErr bool
+	}{
+		{"default format", "", MIMEApplicationJSONLines, "jsonl archive\n", false},
+		{"json", "?format=json", echo.MIMEApplicationJSON, "json archive\n", false},
+		{"unknown format", "?format=xml", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/export"+tt.query, nil), rec)
+			err := a.Export(ctx)
+			if tt.wantErr {
+				assert.IsType(t, &echo.HTTPError{}, err)
+				assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, tt.mime, rec.Header().Get(echo.HeaderContentType))
+				assert.Contains(t, rec.Header().Get(echo.HeaderContentDisposition), "attachment")
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func Test_archiveAPI_Import(t *testing.T) {
+	mockService := &mocks.ArchiveService{}
+	mockService.On("Import", mock.Anything, mock.MatchedBy(func(r io.Reader) bool {
+		b, _ := io.ReadAll(r)
+		return string(b) == "invalid"
+	})).Return(model.ArchiveImport{}, fmt.Errorf("%w: unknown kind", domain.ErrorInvalidArchive)).Once()
+	mockService.On("Import", mock.Anything, mock.Anything).Return(model.ArchiveImport{Created: 2, Updated: 1}, nil)
+	a := NewArchiveAPI(mockService)
+
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/import", strings.NewReader("invalid")), rec)
+	err := a.Import(ctx)
+	if assert.IsType(t, &echo.HTTPError{}, err) {
+		assert.Equal(t, http.StatusBadRequest, err.(*echo.HTTPError).Code)
+	}
+
+	rec = httptest.NewRecorder()
+	ctx = echo.New().NewContext(httptest.NewRequest(http.MethodPost, "/import", strings.NewReader("{}")), rec)
+	if assert.NoError(t, a.Import(ctx)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, `{"Created":2,"Updated":1}`+"\n", rec.Body.String())
+	}
+}
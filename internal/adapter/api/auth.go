@@ -0,0 +1,64 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"github.com/labstack/echo/v4"
+	. "net/http"
+	"strings"
+)
+
+const (
+	// ContextKeyPrincipal holds the authenticated model.Principal of a request
+	ContextKeyPrincipal = "principal"
+	HeaderAPIKey        = "X-API-Key"
+)
+
+// Authenticate requires an API key as X-API-Key header, or a bearer token in the Authorization header.
+// Requests already authenticated by a previous middleware, e.g. with a calendar token, are let through.
+func Authenticate(auth domain.Authenticator) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if _, ok := c.Get(ContextKeyPrincipal).(model.Principal); ok {
+				return next(c)
+			}
+			var p model.Principal
+			var err error
+			authorization := c.Request().Header.Get(echo.HeaderAuthorization)
+			switch key := c.Request().Header.Get(HeaderAPIKey); {
+			case key != "":
+				p, err = auth.AuthenticateKey(key)
+			case strings.HasPrefix(authorization, "Bearer "):
+				p, err = auth.AuthenticateBearer(strings.TrimPrefix(authorization, "Bearer "))
+			default:
+				err = domain.ErrorUnauthenticated
+			}
+			if err != nil {
+				if errors.Is(err, domain.ErrorUnauthenticated) {
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, "Bearer")
+					return echo.NewHTTPError(StatusUnauthorized, "missing or invalid credentials")
+				}
+				return echo.NewHTTPError(StatusInternalServerError, err)
+			}
+			c.Set(ContextKeyPrincipal, p)
+			return next(c)
+		}
+	}
+}
+
+// RequireAdmin restricts a route to admins, it runs after Authenticate
+func RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !principal(c).IsAdmin() {
+			return echo.NewHTTPError(StatusForbidden, "admin role required")
+		}
+		return next(c)
+	}
+}
+
+// principal returns the caller of an authenticated request, the zero principal is refused by services
+func principal(c echo.Context) model.Principal {
+	p, _ := c.Get(ContextKeyPrincipal).(model.Principal)
+	return p
+}
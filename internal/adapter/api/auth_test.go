@@ -0,0 +1,78 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	alice := model.Principal{Subject: "alice", Role: model.RoleUser}
+	admin := model.Principal{Subject: "root", Role: model.RoleAdmin}
+	auth := &mocks.Authenticator{}
+	auth.On("AuthenticateKey", "tp_alice").Return(alice, nil)
+	auth.On("AuthenticateKey", "tp_guess").Return(model.Principal{}, domain.ErrorUnauthenticated)
+	auth.On("AuthenticateKey", "tp_broken").Return(model.Principal{}, errors.New("database is locked"))
+	auth.On("AuthenticateBearer", "jwt.admin").Return(admin, nil)
+	auth.On("AuthenticateBearer", "jwt.expired").Return(model.Principal{}, domain.ErrorUnauthenticated)
+	ok := func(c echo.Context) error {
+		return c.String(http.StatusOK, principal(c).Subject)
+	}
+	e := echo.New()
+
+	tests := []struct {
+		name     string
+		header   string
+		value    string
+		handler  echo.HandlerFunc
+		code     int
+		wantBody string
+	}{
+		{"api key", HeaderAPIKey, "tp_alice", ok, http.StatusOK, "alice"},
+		{"invalid api key", HeaderAPIKey, "tp_guess", ok, http.StatusUnauthorized, ""},
+		{"api key check failing", HeaderAPIKey, "tp_broken", ok, http.StatusInternalServerError, ""},
+		{"bearer token", echo.HeaderAuthorization, "Bearer jwt.admin", ok, http.StatusOK, "root"},
+		{"expired bearer token", echo.HeaderAuthorization, "Bearer jwt.expired", ok, http.StatusUnauthorized, ""},
+		{"basic credentials", echo.HeaderAuthorization, "Basic YWxpY2U6c2VjcmV0", ok, http.StatusUnauthorized, ""},
+		{"no credentials", "", "", ok, http.StatusUnauthorized, ""},
+		{"admin route as admin", echo.HeaderAuthorization, "Bearer jwt.admin", RequireAdmin(ok), http.StatusOK, "root"},
+		{"admin route as user", HeaderAPIKey, "tp_alice", RequireAdmin(ok), http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/trip", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			rec := httptest.NewRecorder()
+			err := Authenticate(auth)(tt.handler)(e.NewContext(req, rec))
+			if tt.code != http.StatusOK {
+				if assert.IsType(t, &echo.HTTPError{}, err) {
+					assert.Equal(t, tt.code, err.(*echo.HTTPError).Code)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantBody, rec.Body.String())
+		})
+	}
+}
+
+func TestAuthenticate_calendarToken(t *testing.T) {
+	// the mock fails the test when called
+	auth := &mocks.Authenticator{}
+	handler := CalendarTokenAuth(map[string]string{"alice": "secret-a"})(Authenticate(auth)(func(c echo.Context) error {
+		return c.String(http.StatusOK, principal(c).Subject)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/trips.ics?token=secret-a", nil)
+	rec := httptest.NewRecorder()
+	if assert.NoError(t, handler(echo.New().NewContext(req, rec))) {
+		assert.Equal(t, "alice", rec.Body.String())
+	}
+}
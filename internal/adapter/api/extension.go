@@ -0,0 +1,31 @@
+package api
+
+import (
+	"github.com/labstack/echo/v4"
+	"path"
+	"strings"
+)
+
+// ByExtension dispatches requests like /trips/:id.ics on the handler registered for the
+// extension of the given path parameter, which is then passed without its extension.
+// Echo cannot route on a static suffix following a path parameter.
+func ByExtension(param string, handlers map[string]echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		value := c.Param(param)
+		ext := path.Ext(value)
+		h, ok := handlers[ext]
+		if !ok {
+			return echo.ErrNotFound
+		}
+		names := c.ParamNames()
+		values := make([]string, len(names))
+		copy(values, c.ParamValues())
+		for i, n := range names {
+			if n == param {
+				values[i] = strings.TrimSuffix(value, ext)
+			}
+		}
+		c.SetParamValues(values...)
+		return h(c)
+	}
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestByExtension(t *testing.T) {
+	e := echo.New()
+	handler := ByExtension("id", map[string]echo.HandlerFunc{
+		".ics": func(c echo.Context) error { return c.String(http.StatusOK, "ics "+c.Param("id")) },
+	})
+
+	tests := []struct {
+		name    string
+		value   string
+		body    string
+		wantErr bool
+	}{
+		{"registered extension", "ID0.ics", "ics ID0", false},
+		{"dotted id", "ID.0.ics", "ics ID.0", false},
+		{"unknown extension", "ID0.pdf", "", true},
+		{"no extension", "ID0", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(http.MethodGet, "/trips/"+tt.value, nil), rec)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues(tt.value)
+
+			err := handler(ctx)
+			if tt.wantErr {
+				assert.Equal(t, echo.ErrNotFound, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.body, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	. "net/http"
+)
+
+type FailureAPI interface {
+	List(c echo.Context) error
+	Get(c echo.Context) error
+	Retry(c echo.Context) error
+	Delete(c echo.Context) error
+}
+
+type failureAPI struct {
+	service domain.FailureService
+}
+
+func NewFailureAPI(service domain.FailureService) FailureAPI {
+	return &failureAPI{service: service}
+}
+
+func (a *failureAPI) List(c echo.Context) error {
+	failures, err := a.service.List()
+	if err != nil {
+		return echo.NewHTTPError(StatusInternalServerError, err)
+	}
+	return c.JSON(StatusOK, failures)
+}
+
+func (a *failureAPI) Get(c echo.Context) error {
+	id := c.Param("id")
+	f, err := a.service.Get(id)
+	if err != nil {
+		return failureError(id, err)
+	}
+	return c.JSON(StatusOK, f)
+}
+
+func (a *failureAPI) Retry(c echo.Context) error {
+	id := c.Param("id")
+	if err := a.service.Retry(id); err != nil {
+		return failureError(id, err)
+	}
+	return c.NoContent(StatusAccepted)
+}
+
+func (a *failureAPI) Delete(c echo.Context) error {
+	id := c.Param("id")
+	if err := a.service.Delete(id); err != nil {
+		return failureError(id, err)
+	}
+	return c.NoContent(StatusNoContent)
+}
+
+func failureError(id string, err error) error {
+	switch {
+	case errors.Is(err, domain.ErrorNoFailure):
+		return echo.NewHTTPError(StatusNotFound, fmt.Sprintf("no failure with id %s", id))
+	case errors.Is(err, domain.ErrorAlreadyQueued):
+		return echo.NewHTTPError(StatusConflict, fmt.Sprintf("email of failure %s is being processed", id))
+	case errors.Is(err, domain.ErrorStandby):
+		return echo.NewHTTPError(StatusServiceUnavailable, err.Error())
+	default:
+		return echo.NewHTTPError(StatusInternalServerError, err)
+	}
+}
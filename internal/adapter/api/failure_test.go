@@ -0,0 +1,82 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var failureJSON = `[{"ID":"F0","EmailID":"MSG0","Subject":"Booking","Date":"","Stage":"parse","JobID":"JOB0",` +
+	`"Detail":"no trip found","Warnings":["unknown carrier"],"Attempts":2,` +
+	`"CreatedAt":"0001-01-01T00:00:00Z","UpdatedAt":"0001-01-01T00:00:00Z"}]
+`
+
+func Test_failureAPI_List(t *testing.T) {
+	mockService := &mocks.FailureService{}
+	mockService.On("List").Return([]model.Failure{{
+		ID:       "F0",
+		EmailID:  "MSG0",
+		Subject:  "Booking",
+		Stage:    model.FailureStageParse,
+		JobID:    "JOB0",
+		Detail:   "no trip found",
+		Warnings: model.Warnings{"unknown carrier"},
+		Attempts: 2,
+	}}, nil)
+
+	rec := httptest.NewRecorder()
+	ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/failures", nil), rec)
+	if assert.NoError(t, NewFailureAPI(mockService).List(ctx)) {
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, failureJSON, rec.Body.String())
+	}
+}
+
+func Test_failureAPI_Retry_Delete(t *testing.T) {
+	mockService := &mocks.FailureService{}
+	mockService.On("Retry", "F0").Return(nil)
+	mockService.On("Retry", "F1").Return(fmt.Errorf("cannot retry failure F1: %w", domain.ErrorAlreadyQueued))
+	mockService.On("Retry", "1111").Return(domain.ErrorNoFailure)
+	mockService.On("Delete", "F0").Return(nil)
+	mockService.On("Delete", "1111").Return(domain.ErrorNoFailure)
+	a := NewFailureAPI(mockService)
+	e := echo.New()
+
+	tests := []struct {
+		name    string
+		method  string
+		handler echo.HandlerFunc
+		id      string
+		code    int
+		wantErr bool
+	}{
+		{"retry failure", http.MethodPost, a.Retry, "F0", 202, false},
+		{"retry failure being processed", http.MethodPost, a.Retry, "F1", 409, true},
+		{"retry unknown failure", http.MethodPost, a.Retry, "1111", 404, true},
+		{"delete failure", http.MethodDelete, a.Delete, "F0", 204, false},
+		{"delete unknown failure", http.MethodDelete, a.Delete, "1111", 404, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(tt.method, "/failures/"+tt.id, nil), rec)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues(tt.id)
+
+			err := tt.handler(ctx)
+			if tt.wantErr {
+				assert.IsType(t, &echo.HTTPError{}, err)
+				assert.Equal(t, tt.code, err.(*echo.HTTPError).Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+		})
+	}
+}
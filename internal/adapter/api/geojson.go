@@ -0,0 +1,180 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"math"
+	. "net/http"
+	"sort"
+)
+
+const (
+	MIMEApplicationGeoJSON = "application/geo+json"
+	// step times are local times without offset, as in the calendar
+	geoJSONDateTime = "2006-01-02T15:04:05"
+	// arcSegments is the number of segments drawing a flight along its great circle
+	arcSegments = 32
+)
+
+// GeoJSONAPI maps a trip, flights are great circle arcs and other steps, such as hotels, are points
+type GeoJSONAPI interface {
+	GetOne(c echo.Context) error
+}
+
+type geoJSONAPI struct {
+	tripFinder domain.TripFinder
+}
+
+func NewGeoJSONAPI(tripFinder domain.TripFinder) GeoJSONAPI {
+	return &geoJSONAPI{tripFinder: tripFinder}
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   geoJSONGeometry        `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// geoJSONGeometry is a Point of a position or a LineString of positions, positions are [longitude, latitude]
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+func (a *geoJSONAPI) GetOne(c echo.Context) error {
+	id := c.Param("id")
+	trip, err := a.tripFinder.GetByID(principal(c), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrorNotFound):
+			return echo.NewHTTPError(StatusNotFound, fmt.Sprintf("no trip with id %s", id))
+		case errors.Is(err, domain.ErrorForbidden):
+			return echo.NewHTTPError(StatusForbidden, err.Error())
+		default:
+			return echo.NewHTTPError(StatusInternalServerError, err)
+		}
+	}
+	c.Response().Header().Set(echo.HeaderContentType, MIMEApplicationGeoJSON)
+	return c.JSON(StatusOK, tripFeatures(trip))
+}
+
+// tripFeatures merges each flight start with the following flight end into an arc and keeps other steps as points.
+// Steps without coordinates are left out, the collection is empty when none is located.
+func tripFeatures(t model.Trip) geoJSONFeatureCollection {
+	steps := make([]model.TripStep, len(t.TripSteps))
+	copy(steps, t.TripSteps)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].DateTime.Before(steps[j].DateTime) })
+
+	fc := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	for i := 0; i < len(steps); i++ {
+		s := steps[i]
+		from, ok := s.Coordinates()
+		if !ok {
+			continue
+		}
+		if s.Type == model.TripStepTypeFlightStart && i+1 < len(steps) &&
+			steps[i+1].Type == model.TripStepTypeFlightEnd {
+			arrival := steps[i+1]
+			if to, ok := arrival.Coordinates(); ok {
+				fc.Features = append(fc.Features, geoJSONFeature{
+					Type:     "Feature",
+					Geometry: geoJSONGeometry{Type: "LineString", Coordinates: greatCircle(from, to, arcSegments)},
+					Properties: featureProperties(map[string]interface{}{
+						"type":      "flight",
+						"reference": t.Reference,
+						"from":      stepPlace(s),
+						"to":        stepPlace(arrival),
+						"departure": s.DateTime.Format(geoJSONDateTime),
+						"arrival":   arrival.DateTime.Format(geoJSONDateTime),
+						"provider":  s.Provider,
+					}),
+				})
+				i++
+				continue
+			}
+		}
+		fc.Features = append(fc.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{Type: "Point", Coordinates: position(from)},
+			Properties: featureProperties(map[string]interface{}{
+				"type":        string(s.Type),
+				"reference":   t.Reference,
+				"location":    stepPlace(s),
+				"date":        s.DateTime.Format(geoJSONDateTime),
+				"provider":    s.Provider,
+				"description": s.Description,
+			}),
+		})
+	}
+	return fc
+}
+
+// featureProperties leaves out the empty properties
+func featureProperties(props map[string]interface{}) map[string]interface{} {
+	for k, v := range props {
+		if v == "" {
+			delete(props, k)
+		}
+	}
+	return props
+}
+
+// stepPlace is the airport code of a flight step, or its location
+func stepPlace(s model.TripStep) string {
+	if s.LocationCode != "" {
+		return s.LocationCode
+	}
+	return s.Location
+}
+
+func position(c model.Coordinates) []float64 {
+	return []float64{round6(c.Longitude), round6(c.Latitude)}
+}
+
+// round6 keeps 6 decimals, about 10 cm
+func round6(v float64) float64 {
+	return math.Round(v*1e6) / 1e6
+}
+
+// greatCircle returns the positions of the shortest path from a to b on the sphere, split in n segments. The
+// longitudes are unwrapped past ±180 so that a flight across the antimeridian is drawn as one line.
+func greatCircle(a model.Coordinates, b model.Coordinates, n int) [][]float64 {
+	rad := math.Pi / 180
+	lat1, lon1, lat2, lon2 := a.Latitude*rad, a.Longitude*rad, b.Latitude*rad, b.Longitude*rad
+	// central angle, with the haversine formula
+	d := 2 * math.Asin(math.Sqrt(math.Pow(math.Sin((lat2-lat1)/2), 2)+
+		math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin((lon2-lon1)/2), 2)))
+	// the path is undefined between the same or antipodal points
+	if math.Sin(d) < 1e-9 {
+		return [][]float64{position(a), position(b)}
+	}
+	positions := make([][]float64, 0, n+1)
+	prev := a.Longitude
+	for i := 0; i <= n; i++ {
+		f := float64(i) / float64(n)
+		p := math.Sin((1-f)*d) / math.Sin(d)
+		q := math.Sin(f*d) / math.Sin(d)
+		x := p*math.Cos(lat1)*math.Cos(lon1) + q*math.Cos(lat2)*math.Cos(lon2)
+		y := p*math.Cos(lat1)*math.Sin(lon1) + q*math.Cos(lat2)*math.Sin(lon2)
+		z := p*math.Sin(lat1) + q*math.Sin(lat2)
+		lat := math.Atan2(z, math.Sqrt(x*x+y*y)) / rad
+		lon := math.Atan2(y, x) / rad
+		for lon-prev > 180 {
+			lon -= 360
+		}
+		for lon-prev < -180 {
+			lon += 360
+		}
+		prev = lon
+		positions = append(positions, position(model.Coordinates{Latitude: lat, Longitude: lon}))
+	}
+	return positions
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"encoding/json"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func locatedTrip() model.Trip {
+	trip := calendarTrip
+	trip.TripSteps = make([]model.TripStep, len(calendarTrip.TripSteps))
+	copy(trip.TripSteps, calendarTrip.TripSteps)
+	for i := range trip.TripSteps {
+		s := &trip.TripSteps[i]
+		switch s.Type {
+		case model.TripStepTypeFlightStart:
+			s.LocationCode = "ORY"
+			s.SetCoordinates(model.Coordinates{Latitude: 48.7233, Longitude: 2.3794})
+		case model.TripStepTypeFlightEnd:
+			s.LocationCode = "TUN"
+			s.SetCoordinates(model.Coordinates{Latitude: 36.851, Longitude: 10.2272})
+		case model.TripStepTypeHotel:
+			s.SetCoordinates(model.Coordinates{Latitude: 36.4, Longitude: 10.6})
+		}
+	}
+	// a step not located is left out
+	trip.TripSteps = append(trip.TripSteps, model.TripStep{Type: model.TripStepTypeHotel, Location: "Nowhere"})
+	return trip
+}
+
+func Test_geoJSONAPI_GetOne(t *testing.T) {
+	mockFinder := &mocks.TripFinder{}
+	mockFinder.On("GetByID", mock.Anything, "ID1").Return(locatedTrip(), nil)
+	mockFinder.On("GetByID", mock.Anything, "ID2").Return(calendarTrip, nil)
+	mockFinder.On("GetByID", mock.Anything, "1111").Return(model.Trip{}, domain.ErrorNotFound)
+	mockFinder.On("GetByID", mock.Anything, "2222").Return(model.Trip{}, domain.ErrorForbidden)
+	a := NewGeoJSONAPI(mockFinder)
+
+	get := func(id string) (*httptest.ResponseRecorder, error) {
+		req := httptest.NewRequest(http.MethodGet, "/trips/"+id+".geojson", nil)
+		rec := httptest.NewRecorder()
+		ctx := echo.New().NewContext(req, rec)
+		ctx.SetParamNames("id")
+		ctx.SetParamValues(id)
+		return rec, a.GetOne(ctx)
+	}
+
+	rec, err := get("ID1")
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, MIMEApplicationGeoJSON, rec.Header().Get(echo.HeaderContentType))
+	var fc struct {
+		Type     string
+		Features []struct {
+			Type     string
+			Geometry struct {
+				Type        string
+				Coordinates json.RawMessage
+			}
+			Properties map[string]string
+		}
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &fc))
+	assert.Equal(t, "FeatureCollection", fc.Type)
+	require.Len(t, fc.Features, 2)
+
+	flight := fc.Features[0]
+	assert.Equal(t, "LineString", flight.Geometry.Type)
+	assert.Equal(t, map[string]string{"type": "flight", "reference": "XXX999", "from": "ORY", "to": "TUN",
+		"departure": "2020-04-06T16:10:00", "arrival": "2020-04-06T17:45:00", "provider": "TRANSAVIA FRANCE"},
+		flight.Properties)
+	var line [][]float64
+	require.NoError(t, json.Unmarshal(flight.Geometry.Coordinates, &line))
+	require.Len(t, line, arcSegments+1)
+	assert.Equal(t, []float64{2.3794, 48.7233}, line[0])
+	assert.Equal(t, []float64{10.2272, 36.851}, line[arcSegments])
+
+	hotel := fc.Features[1]
+	assert.Equal(t, "Point", hotel.Geometry.Type)
+	assert.JSONEq(t, `[10.6, 36.4]`, string(hotel.Geometry.Coordinates))
+	assert.Equal(t, "La Badira", hotel.Properties["provider"])
+	assert.Equal(t, "Hammamet, 8050, Tunisia", hotel.Properties["location"])
+	assert.Equal(t, "hotel", hotel.Properties["type"])
+
+	rec, err = get("ID2")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"FeatureCollection","features":[]}`, rec.Body.String(), "no step is located")
+
+	for id, code := range map[string]int{"1111": http.StatusNotFound, "2222": http.StatusForbidden} {
+		_, err := get(id)
+		if assert.IsType(t, &echo.HTTPError{}, err) {
+			assert.Equal(t, code, err.(*echo.HTTPError).Code)
+		}
+	}
+}
+
+func Test_greatCircle(t *testing.T) {
+	// Tokyo Haneda to San Francisco crosses the antimeridian
+	line := greatCircle(model.Coordinates{Latitude: 35.5523, Longitude: 139.7798},
+		model.Coordinates{Latitude: 37.619, Longitude: -122.3749}, 8)
+	require.Len(t, line, 9)
+	for i := 1; i < len(line); i++ {
+		assert.Less(t, line[i][0]-line[i-1][0], 180.0)
+		assert.Greater(t, line[i][0], line[i-1][0], "the flight heads east")
+		assert.Greater(t, line[i][1], 35.0, "the path bends north of both ends")
+	}
+	assert.InDelta(t, -122.3749+360, line[8][0], 1e-6, "longitudes are unwrapped")
+
+	same := model.Coordinates{Latitude: 48.7233, Longitude: 2.3794}
+	assert.Equal(t, [][]float64{{2.3794, 48.7233}, {2.3794, 48.7233}}, greatCircle(same, same, 8))
+}
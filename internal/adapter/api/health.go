@@ -0,0 +1,37 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"github.com/labstack/echo/v4"
+	. "net/http"
+)
+
+type HealthAPI interface {
+	Live(c echo.Context) error
+	Ready(c echo.Context) error
+}
+
+type healthAPI struct {
+	service domain.HealthService
+}
+
+func NewHealthAPI(service domain.HealthService) HealthAPI {
+	return &healthAPI{service: service}
+}
+
+func (a *healthAPI) Live(c echo.Context) error {
+	return healthResponse(c, a.service.Live())
+}
+
+// Ready answers with the breakdown per component, with a 503 status when any is down
+func (a *healthAPI) Ready(c echo.Context) error {
+	return healthResponse(c, a.service.Ready(c.Request().Context()))
+}
+
+func healthResponse(c echo.Context, health model.Health) error {
+	if health.Status != model.HealthStatusUp {
+		return c.JSON(StatusServiceUnavailable, health)
+	}
+	return c.JSON(StatusOK, health)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_healthAPI_Ready(t *testing.T) {
+	last := time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		health model.Health
+		code   int
+		body   string
+	}{
+		{
+			"ready",
+			model.Health{Status: model.HealthStatusUp, Components: map[string]model.ComponentHealth{
+				"sqlite":           {Status: model.HealthStatusUp},
+				"processor.status": {Status: model.HealthStatusUp, LastProgress: &last, Pending: 2},
+			}},
+			http.StatusOK,
+			`{"Status":"up","Components":{"processor.status":{"Status":"up",` +
+				`"LastProgress":"2020-06-01T10:00:00Z","Pending":2},"sqlite":{"Status":"up"}}}` + "\n",
+		},
+		{
+			"not ready",
+			model.Health{Status: model.HealthStatusDown, Components: map[string]model.ComponentHealth{
+				"gmail": {Status: model.HealthStatusDown, Detail: "invalid gmail credentials"},
+			}},
+			http.StatusServiceUnavailable,
+			`{"Status":"down","Components":{"gmail":{"Status":"down","Detail":"invalid gmail credentials"}}}` + "\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockService := &mocks.HealthService{}
+			mockService.On("Ready", mock.Anything).Return(tt.health)
+
+			rec := httptest.NewRecorder()
+			ctx := echo.New().NewContext(httptest.NewRequest(http.MethodGet, "/readyz", nil), rec)
+			if assert.NoError(t, NewHealthAPI(mockService).Ready(ctx)) {
+				assert.Equal(t, tt.code, rec.Code)
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	. "net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	MIMETextCalendar = "text/calendar; charset=utf-8"
+	calendarProdID   = "-//amadeus-trip-parser//trips//EN"
+	calendarUIDHost  = "amadeus-trip-parser"
+	// Amadeus reports local times without offset, events give them in the time zone of the step,
+	// or as floating times when the zone is unknown
+	icalDateTime = "20060102T150405"
+	icalDate     = "20060102"
+	icalLineMax  = 75
+)
+
+type CalendarAPI interface {
+	GetAll(c echo.Context) error
+	GetOne(c echo.Context) error
+}
+
+type calendarAPI struct {
+	tripFinder domain.TripFinder
+	now        func() time.Time
+}
+
+func NewCalendarAPI(tripFinder domain.TripFinder) CalendarAPI {
+	return &calendarAPI{tripFinder: tripFinder, now: time.Now}
+}
+
+// GetAll streams the feed of the trips matching the listing parameters. Outlook and older Apple Calendar only apply the
+// VTIMEZONEs given before the events, the trips are read a first time for their zones and a second time for their
+// events, those of a zone not read the first time are floating.
+func (a *calendarAPI) GetAll(c echo.Context) error {
+	zones := &calendarZones{}
+	err := eachTripPage(c, a.tripFinder, func(trips []model.Trip) error {
+		for _, t := range trips {
+			zones.add(tripEvents(t))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	var w *calendarWriter
+	err = eachTripPage(c, a.tripFinder, func(trips []model.Trip) error {
+		if w == nil {
+			c.Response().Header().Set(echo.HeaderContentType, MIMETextCalendar)
+			c.Response().WriteHeader(StatusOK)
+			w = newCalendarWriter(zones, a.now())
+		}
+		for _, t := range trips {
+			w.add(tripEvents(t))
+		}
+		return w.flush(c.Response())
+	})
+	if err != nil {
+		return err
+	}
+	w.end()
+	return w.flush(c.Response())
+}
+
+func (a *calendarAPI) GetOne(c echo.Context) error {
+	id := c.Param("id")
+	trip, err := a.tripFinder.GetByID(principal(c), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrorNotFound):
+			return echo.NewHTTPError(StatusNotFound, fmt.Sprintf("no trip with id %s", id))
+		case errors.Is(err, domain.ErrorForbidden):
+			return echo.NewHTTPError(StatusForbidden, err.Error())
+		default:
+			return echo.NewHTTPError(StatusInternalServerError, err)
+		}
+	}
+	return c.Blob(StatusOK, MIMETextCalendar, a.encode([]model.Trip{trip}))
+}
+
+// CalendarTokenAuth authenticates calendar feeds with a per-user token given as 'token' query parameter,
+// since calendar clients subscribing to a URL cannot send headers. Requests without token are left to
+// Authenticate.
+func CalendarTokenAuth(tokens map[string]string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if c.QueryParam("token") == "" {
+				return next(c)
+			}
+			given := []byte(c.QueryParam("token"))
+			for user, token := range tokens {
+				if token != "" && subtle.ConstantTimeCompare(given, []byte(token)) == 1 {
+					c.Set(ContextKeyPrincipal, model.Principal{Subject: user, Role: model.RoleUser})
+					return next(c)
+				}
+			}
+			return echo.NewHTTPError(StatusUnauthorized, "missing or invalid calendar token")
+		}
+	}
+}
+
+type calendarEvent struct {
+	uid         string
+	summary     string
+	location    string
+	description string
+	start       time.Time
+	end         time.Time
+	// startZone and endZone are the IANA time zones of start and end, empty for floating times
+	startZone string
+	endZone   string
+	allDay    bool
+}
+
+func (a *calendarAPI) encode(trips []model.Trip) []byte {
+	var events []calendarEvent
+	for _, t := range trips {
+		events = append(events, tripEvents(t)...)
+	}
+	zones := &calendarZones{}
+	zones.add(events)
+	w := newCalendarWriter(zones, a.now())
+	w.add(events)
+	w.end()
+	return w.buf.Bytes()
+}
+
+// calendarWriter writes a calendar with the VTIMEZONEs of its zones first, then its events
+type calendarWriter struct {
+	icalWriter
+	zones *calendarZones
+	stamp string
+}
+
+func newCalendarWriter(zones *calendarZones, now time.Time) *calendarWriter {
+	w := &calendarWriter{zones: zones, stamp: now.UTC().Format(icalDateTime) + "Z"}
+	w.line("BEGIN", "VCALENDAR")
+	w.line("VERSION", "2.0")
+	w.line("PRODID", calendarProdID)
+	w.line("CALSCALE", "GREGORIAN")
+	w.line("METHOD", "PUBLISH")
+	zones.write(&w.icalWriter)
+	return w
+}
+
+// add writes events, times in a zone without VTIMEZONE are floating
+func (w *calendarWriter) add(events []calendarEvent) {
+	for _, ev := range events {
+		w.line("BEGIN", "VEVENT")
+		w.line("UID", ev.uid)
+		w.line("DTSTAMP", w.stamp)
+		if ev.allDay {
+			w.line("DTSTART;VALUE=DATE", ev.start.Format(icalDate))
+			w.line("DTEND;VALUE=DATE", ev.end.Format(icalDate))
+		} else {
+			w.line(zoned("DTSTART", w.zones.known(ev.startZone)), ev.start.Format(icalDateTime))
+			w.line(zoned("DTEND", w.zones.known(ev.endZone)), ev.end.Format(icalDateTime))
+		}
+		w.line("SUMMARY", escapeText(ev.summary))
+		if ev.location != "" {
+			w.line("LOCATION", escapeText(ev.location))
+		}
+		w.line("DESCRIPTION", escapeText(ev.description))
+		w.line("END", "VEVENT")
+	}
+}
+
+func (w *calendarWriter) end() {
+	w.line("END", "VCALENDAR")
+}
+
+// tripEvents merges each flight start with the following flight end and keeps other steps as is.
+// The UID of an event is that of its first step, so that adding or removing a step of a booking
+// leaves the events of the other steps in place. Steps of an unknown time zone are in the zone of
+// the previous step, such as a hotel after the arrival flight.
+func tripEvents(t model.Trip) []calendarEvent {
+	steps := make([]model.TripStep, len(t.TripSteps))
+	copy(steps, t.TripSteps)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].DateTime.Before(steps[j].DateTime) })
+	zones := stepZones(steps)
+
+	desc := fmt.Sprintf("Booking reference %s", t.Reference)
+
+	var events []calendarEvent
+	for i := 0; i < len(steps); i++ {
+		s := steps[i]
+		ev := calendarEvent{
+			uid:         fmt.Sprintf("%s@%s", s.ID, calendarUIDHost),
+			location:    s.Location,
+			description: desc + "\n" + s.Description,
+			start:       s.DateTime,
+			end:         s.DateTime,
+			startZone:   zones[i],
+			endZone:     zones[i],
+		}
+		switch s.Type {
+		case model.TripStepTypeFlightStart:
+			ev.summary = fmt.Sprintf("Flight from %s", s.Location)
+			if i+1 < len(steps) && steps[i+1].Type == model.TripStepTypeFlightEnd {
+				arrival := steps[i+1]
+				ev.summary = fmt.Sprintf("Flight %s → %s", s.Location, arrival.Location)
+				ev.description = desc + "\n" + s.Description + "\n" + arrival.Description
+				ev.end = arrival.DateTime
+				ev.endZone = zones[i+1]
+				i++
+			}
+		case model.TripStepTypeFlightEnd:
+			ev.summary = fmt.Sprintf("Flight to %s", s.Location)
+		case model.TripStepTypeHotel:
+			ev.summary = s.Description
+		default:
+			ev.summary = fmt.Sprintf("%s %s", s.Type, s.Location)
+		}
+		if isDate(ev.start) && isDate(ev.end) {
+			ev.allDay = true
+			ev.end = ev.end.AddDate(0, 0, 1)
+		}
+		events = append(events, ev)
+	}
+	return events
+}
+
+// stepZones returns the time zones of sorted steps, those of an unknown zone take the zone of the previous
+// step, or of the next one for the first steps. Zones the time zone database does not know are left out.
+func stepZones(steps []model.TripStep) []string {
+	zones := make([]string, len(steps))
+	zone := ""
+	for i, s := range steps {
+		if s.TimeZone != "" {
+			if _, err := time.LoadLocation(s.TimeZone); err == nil {
+				zone = s.TimeZone
+			}
+		}
+		zones[i] = zone
+	}
+	for i := len(steps) - 2; i >= 0; i-- {
+		if zones[i] == "" {
+			zones[i] = zones[i+1]
+		}
+	}
+	return zones
+}
+
+// zoned adds the TZID parameter of a zone to a date-time property, floating without zone
+func zoned(name string, zone string) string {
+	if zone == "" {
+		return name
+	}
+	return name + ";TZID=" + zone
+}
+
+// calendarZones collects the zones of timed events and the time span of these events
+type calendarZones struct {
+	zones    []string
+	from, to time.Time
+}
+
+func (z *calendarZones) add(events []calendarEvent) {
+	for _, ev := range events {
+		if ev.allDay {
+			continue
+		}
+		for _, zone := range []string{ev.startZone, ev.endZone} {
+			if zone != "" && z.known(zone) == "" {
+				z.zones = append(z.zones, zone)
+			}
+		}
+		if z.from.IsZero() || ev.start.Before(z.from) {
+			z.from = ev.start
+		}
+		if z.to.IsZero() || ev.end.After(z.to) {
+			z.to = ev.end
+		}
+	}
+}
+
+// known returns zone when it was collected, empty otherwise
+func (z *calendarZones) known(zone string) string {
+	for _, known := range z.zones {
+		if known == zone {
+			return zone
+		}
+	}
+	return ""
+}
+
+// write writes a VTIMEZONE of each zone, with the observances from the year before the first event to the end of the
+// year of the last one
+func (z *calendarZones) write(w *icalWriter) {
+	from := time.Date(z.from.Year()-1, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(z.to.Year()+1, 1, 1, 0, 0, 0, 0, time.UTC)
+	zones := make([]string, len(z.zones))
+	copy(zones, z.zones)
+	sort.Strings(zones)
+	for _, zone := range zones {
+		loc, err := time.LoadLocation(zone)
+		if err != nil {
+			continue
+		}
+		w.line("BEGIN", "VTIMEZONE")
+		w.line("TZID", zone)
+		at := from.In(loc)
+		name, offset := at.Zone()
+		writeObservance(w, "STANDARD", at, offset, offset, name)
+		for _, t := range zoneTransitions(loc, from, to) {
+			next, nextOffset := t.In(loc).Zone()
+			kind := "STANDARD"
+			if nextOffset > offset {
+				kind = "DAYLIGHT"
+			}
+			// the onset is given in the local time before the transition
+			writeObservance(w, kind, t.In(time.FixedZone(name, offset)), offset, nextOffset, next)
+			name, offset = next, nextOffset
+		}
+		w.line("END", "VTIMEZONE")
+	}
+}
+
+func writeObservance(w *icalWriter, kind string, onset time.Time, from int, to int, name string) {
+	w.line("BEGIN", kind)
+	w.line("DTSTART", onset.Format(icalDateTime))
+	w.line("TZOFFSETFROM", icalOffset(from))
+	w.line("TZOFFSETTO", icalOffset(to))
+	w.line("TZNAME", escapeText(name))
+	w.line("END", kind)
+}
+
+// zoneTransitions returns the instants the offset of loc changes between from and to, found day by day
+// and then to the second
+func zoneTransitions(loc *time.Location, from time.Time, to time.Time) []time.Time {
+	var transitions []time.Time
+	_, offset := from.In(loc).Zone()
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		if _, nextOffset := next.In(loc).Zone(); nextOffset == offset {
+			continue
+		}
+		before, after := day, next
+		for after.Sub(before) > time.Second {
+			mid := before.Add(after.Sub(before) / 2)
+			if _, o := mid.In(loc).Zone(); o == offset {
+				before = mid
+			} else {
+				after = mid
+			}
+		}
+		transitions = append(transitions, after)
+		_, offset = after.In(loc).Zone()
+	}
+	return transitions
+}
+
+// icalOffset formats a UTC offset in seconds as +hhmm
+func icalOffset(offset int) string {
+	sign := '+'
+	if offset < 0 {
+		sign, offset = '-', -offset
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, offset/3600, offset/60%60)
+}
+
+func isDate(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+func escapeText(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icalWriter writes content lines with CRLF endings, folded at 75 octets without splitting runes
+type icalWriter struct {
+	buf bytes.Buffer
+}
+
+// flush writes the lines buffered so far to a response
+func (w *icalWriter) flush(r *echo.Response) error {
+	if _, err := w.buf.WriteTo(r); err != nil {
+		return err
+	}
+	r.Flush()
+	return nil
+}
+
+func (w *icalWriter) line(name string, value string) {
+	l := name + ":" + value
+	width := 0
+	for _, r := range l {
+		n := len(string(r))
+		if width+n > icalLineMax {
+			w.buf.WriteString("\r\n ")
+			width = 1
+		}
+		w.buf.WriteRune(r)
+		width += n
+	}
+	w.buf.WriteString("\r\n")
+}
@@ -0,0 +1,300 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var calendarTrip = model.Trip{
+	ID:        "ID1",
+	Reference: "XXX999",
+	TripSteps: []model.TripStep{
+		{
+			ID:          "IDS12",
+			Type:        model.TripStepTypeHotel,
+			DateTime:    time.Date(2020, 4, 7, 0, 0, 0, 0, time.UTC),
+			Location:    "Hammamet, 8050, Tunisia",
+			Description: "Hotel at La Badira",
+			Provider:    "La Badira",
+		},
+		{
+			ID:          "IDS11",
+			Type:        model.TripStepTypeFlightEnd,
+			DateTime:    time.Date(2020, 4, 6, 17, 45, 0, 0, time.UTC),
+			Location:    "TUNIS",
+			Description: "Flight end with TRANSAVIA FRANCE",
+			TimeZone:    "Africa/Tunis",
+			Provider:    "TRANSAVIA FRANCE",
+		},
+		{
+			ID:          "IDS10",
+			Type:        model.TripStepTypeFlightStart,
+			DateTime:    time.Date(2020, 4, 6, 16, 10, 0, 0, time.UTC),
+			Location:    "PARIS",
+			Description: "Flight start with TRANSAVIA FRANCE",
+			TimeZone:    "Europe/Paris",
+			Provider:    "TRANSAVIA FRANCE",
+		},
+	},
+}
+
+var calendarICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//amadeus-trip-parser//trips//EN\r\n" +
+	"CALSCALE:GREGORIAN\r\n" +
+	"METHOD:PUBLISH\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Africa/Tunis\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:20190101T010000\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"TZNAME:CET\r\n" +
+	"END:STANDARD\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VTIMEZONE\r\n" +
+	"TZID:Europe/Paris\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:20190101T010000\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"TZNAME:CET\r\n" +
+	"END:STANDARD\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:20190331T020000\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0200\r\n" +
+	"TZNAME:CEST\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:20191027T030000\r\n" +
+	"TZOFFSETFROM:+0200\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"TZNAME:CET\r\n" +
+	"END:STANDARD\r\n" +
+	"BEGIN:DAYLIGHT\r\n" +
+	"DTSTART:20200329T020000\r\n" +
+	"TZOFFSETFROM:+0100\r\n" +
+	"TZOFFSETTO:+0200\r\n" +
+	"TZNAME:CEST\r\n" +
+	"END:DAYLIGHT\r\n" +
+	"BEGIN:STANDARD\r\n" +
+	"DTSTART:20201025T030000\r\n" +
+	"TZOFFSETFROM:+0200\r\n" +
+	"TZOFFSETTO:+0100\r\n" +
+	"TZNAME:CET\r\n" +
+	"END:STANDARD\r\n" +
+	"END:VTIMEZONE\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:IDS10@amadeus-trip-parser\r\n" +
+	"DTSTAMP:20200501T120000Z\r\n" +
+	"DTSTART;TZID=Europe/Paris:20200406T161000\r\n" +
+	"DTEND;TZID=Africa/Tunis:20200406T174500\r\n" +
+	"SUMMARY:Flight PARIS → TUNIS\r\n" +
+	"LOCATION:PARIS\r\n" +
+	"DESCRIPTION:Booking reference XXX999\\nFlight start with TRANSAVIA FRANCE\\nF\r\n" +
+	" light end with TRANSAVIA FRANCE\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:IDS12@amadeus-trip-parser\r\n" +
+	"DTSTAMP:20200501T120000Z\r\n" +
+	"DTSTART;VALUE=DATE:20200407\r\n" +
+	"DTEND;VALUE=DATE:20200408\r\n" +
+	"SUMMARY:Hotel at La Badira\r\n" +
+	"LOCATION:Hammamet\\, 8050\\, Tunisia\r\n" +
+	"DESCRIPTION:Booking reference XXX999\\nHotel at La Badira\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func newCalendarAPI(finder domain.TripFinder) *calendarAPI {
+	return &calendarAPI{
+		tripFinder: finder,
+		now:        func() time.Time { return time.Date(2020, 5, 1, 12, 0, 0, 0, time.UTC) },
+	}
+}
+
+func Test_calendarAPI_GetOne(t *testing.T) {
+	mockFinder := &mocks.TripFinder{}
+	mockFinder.On("GetByID", mock.Anything, "ID1").Return(calendarTrip, nil)
+	mockFinder.On("GetByID", mock.Anything, "1111").Return(model.Trip{}, domain.ErrorNotFound)
+	e := echo.New()
+
+	tests := []struct {
+		name    string
+		id      string
+		code    int
+		body    string
+		wantErr bool
+	}{
+		{
+			"get trip calendar",
+			"ID1",
+			200,
+			calendarICS,
+			false,
+		},
+		{
+			"get 404",
+			"1111",
+			404,
+			"",
+			true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newCalendarAPI(mockFinder)
+			req := httptest.NewRequest(http.MethodGet, "/trips/"+tt.id+".ics", nil)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(req, rec)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues(tt.id)
+
+			err := a.GetOne(ctx)
+			if tt.wantErr {
+				assert.IsType(t, &echo.HTTPError{}, err)
+				assert.Equal(t, tt.code, err.(*echo.HTTPError).Code)
+			} else {
+				assert.Equal(t, tt.code, rec.Code)
+				assert.Equal(t, MIMETextCalendar, rec.Header().Get(echo.HeaderContentType))
+				assert.Equal(t, tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func Test_calendarAPI_GetAll(t *testing.T) {
+	other := model.Trip{ID: "ID2", Reference: "YYY888", TripSteps: calendarTrip.TripSteps[:1]}
+	mockFinder := &mocks.TripFinder{}
+	mockFinder.On("Get", mock.Anything, model.TripQuery{Period: model.TripPeriodUpcoming, Limit: feedPageSize}).
+		Return(model.TripPage{Trips: []model.Trip{calendarTrip}, Total: 2, Limit: 1}, nil)
+	mockFinder.On("Get", mock.Anything, model.TripQuery{Period: model.TripPeriodUpcoming, Offset: 1,
+		Limit: feedPageSize}).Return(model.TripPage{Trips: []model.Trip{other}, Total: 2, Offset: 1, Limit: 1}, nil)
+	mockFinder.On("Get", mock.Anything, model.TripQuery{Period: model.TripPeriodUpcoming, Limit: 1}).
+		Return(model.TripPage{Trips: []model.Trip{calendarTrip}, Total: 2, Limit: 1}, nil)
+	e := echo.New()
+
+	tests := []struct {
+		name   string
+		query  string
+		events int
+	}{
+		{"all", "", 3},
+		{"limit", "&limit=1", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newCalendarAPI(mockFinder)
+			req := httptest.NewRequest(http.MethodGet, "/trips.ics?period=upcoming"+tt.query, nil)
+			rec := httptest.NewRecorder()
+			if err := a.GetAll(e.NewContext(req, rec)); err != nil {
+				t.Fatalf("GetAll() error = %v", err)
+			}
+			ics := rec.Body.String()
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, tt.events, strings.Count(ics, "BEGIN:VEVENT"))
+			assert.Less(t, strings.LastIndex(ics, "END:VTIMEZONE"), strings.Index(ics, "BEGIN:VEVENT"))
+			assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+		})
+	}
+}
+
+func Test_calendarAPI_GetAll_newZone(t *testing.T) {
+	// a trip stored between the two readings has a zone without VTIMEZONE
+	hotel := model.Trip{ID: "ID2", Reference: "YYY888", TripSteps: []model.TripStep{
+		{ID: "IDS20", Type: model.TripStepTypeHotel, DateTime: time.Date(2020, 4, 7, 15, 0, 0, 0, time.UTC),
+			Description: "Hotel at La Badira", TimeZone: "Africa/Tunis"},
+	}}
+	mockFinder := &mocks.TripFinder{}
+	query := model.TripQuery{Limit: feedPageSize}
+	mockFinder.On("Get", mock.Anything, query).Return(model.TripPage{}, nil).Once()
+	mockFinder.On("Get", mock.Anything, query).Return(model.TripPage{Trips: []model.Trip{hotel}, Total: 1}, nil)
+	e := echo.New()
+
+	a := newCalendarAPI(mockFinder)
+	req := httptest.NewRequest(http.MethodGet, "/trips.ics", nil)
+	rec := httptest.NewRecorder()
+	if err := a.GetAll(e.NewContext(req, rec)); err != nil {
+		t.Fatalf("GetAll() error = %v", err)
+	}
+	assert.NotContains(t, rec.Body.String(), "TZID")
+	assert.Contains(t, rec.Body.String(), "DTSTART:20200407T150000\r\n")
+}
+
+func Test_tripEvents_stepUID(t *testing.T) {
+	// a step added before the others leaves their UIDs in place
+	reparsed := calendarTrip
+	reparsed.TripSteps = append([]model.TripStep{{
+		ID:          "IDS9",
+		Type:        model.TripStepTypeHotel,
+		DateTime:    time.Date(2020, 4, 6, 12, 0, 0, 0, time.UTC),
+		Location:    "Paris",
+		Description: "Hotel at Orly",
+	}}, calendarTrip.TripSteps...)
+
+	a, b := tripEvents(calendarTrip), tripEvents(reparsed)
+	if assert.Len(t, b, len(a)+1) {
+		assert.Equal(t, "IDS9@amadeus-trip-parser", b[0].uid)
+		for i := range a {
+			assert.Equal(t, a[i].uid, b[i+1].uid)
+		}
+		assert.Equal(t, "Europe/Paris", b[0].startZone, "zone of the next step")
+	}
+}
+
+func Test_tripEvents_floating(t *testing.T) {
+	trip := model.Trip{Reference: "XXX999", TripSteps: []model.TripStep{{
+		ID:       "IDS1",
+		Type:     model.TripStepTypeFlightStart,
+		DateTime: time.Date(2020, 4, 6, 16, 10, 0, 0, time.UTC),
+		Location: "PARIS",
+		TimeZone: "Europe/Nowhere",
+	}}}
+	ics := string(newCalendarAPI(nil).encode([]model.Trip{trip}))
+	assert.Contains(t, ics, "DTSTART:20200406T161000\r\n")
+	assert.NotContains(t, ics, "VTIMEZONE")
+}
+
+func TestCalendarTokenAuth(t *testing.T) {
+	e := echo.New()
+	ok := func(c echo.Context) error {
+		return c.String(http.StatusOK, principal(c).Subject)
+	}
+
+	tests := []struct {
+		name    string
+		tokens  map[string]string
+		path    string
+		body    string
+		wantErr bool
+	}{
+		{"no token configured", nil, "/trips.ics?token=secret-b", "", true},
+		{"valid token", map[string]string{"alice": "secret-a", "bob": "secret-b"}, "/trips.ics?token=secret-b", "bob", false},
+		{"invalid token", map[string]string{"alice": "secret-a"}, "/trips.ics?token=secret-b", "", true},
+		// left to the authentication of the API
+		{"missing token", map[string]string{"alice": "secret-a"}, "/trips.ics", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			rec := httptest.NewRecorder()
+			err := CalendarTokenAuth(tt.tokens)(ok)(e.NewContext(req, rec))
+			if tt.wantErr {
+				assert.IsType(t, &echo.HTTPError{}, err)
+				assert.Equal(t, http.StatusUnauthorized, err.(*echo.HTTPError).Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.body, rec.Body.String())
+		})
+	}
+}
@@ -0,0 +1,38 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	. "net/http"
+)
+
+type MessageAPI interface {
+	Reprocess(c echo.Context) error
+}
+
+type messageAPI struct {
+	processor domain.EmailProcessor
+}
+
+func NewMessageAPI(processor domain.EmailProcessor) MessageAPI {
+	return &messageAPI{processor: processor}
+}
+
+func (a *messageAPI) Reprocess(c echo.Context) error {
+	id := c.Param("id")
+	if err := a.processor.Reprocess(id); err != nil {
+		switch {
+		case errors.Is(err, domain.ErrorNotProcessed):
+			return echo.NewHTTPError(StatusNotFound, fmt.Sprintf("no processed message with id %s", id))
+		case errors.Is(err, domain.ErrorAlreadyQueued):
+			return echo.NewHTTPError(StatusConflict, fmt.Sprintf("message %s is being processed", id))
+		case errors.Is(err, domain.ErrorStandby):
+			return echo.NewHTTPError(StatusServiceUnavailable, err.Error())
+		default:
+			return echo.NewHTTPError(StatusInternalServerError, err)
+		}
+	}
+	return c.NoContent(StatusAccepted)
+}
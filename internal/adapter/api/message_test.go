@@ -0,0 +1,50 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_messageAPI_Reprocess(t *testing.T) {
+	mockProcessor := &mocks.EmailProcessor{}
+	mockProcessor.On("Reprocess", "MSG0").Return(nil)
+	mockProcessor.On("Reprocess", "MSG1").Return(domain.ErrorAlreadyQueued)
+	mockProcessor.On("Reprocess", "1111").Return(domain.ErrorNotProcessed)
+	mockProcessor.On("Reprocess", "MSG2").Return(domain.ErrorStandby)
+	e := echo.New()
+
+	tests := []struct {
+		name    string
+		id      string
+		code    int
+		wantErr bool
+	}{
+		{"reprocess message", "MSG0", 202, false},
+		{"reprocess message being processed", "MSG1", 409, true},
+		{"reprocess unknown message", "1111", 404, true},
+		{"reprocess on a standby replica", "MSG2", 503, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewMessageAPI(mockProcessor)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(http.MethodPost, "/messages/"+tt.id+"/reprocess", nil), rec)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues(tt.id)
+
+			err := a.Reprocess(ctx)
+			if tt.wantErr {
+				assert.IsType(t, &echo.HTTPError{}, err)
+				assert.Equal(t, tt.code, err.(*echo.HTTPError).Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+		})
+	}
+}
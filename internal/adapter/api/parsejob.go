@@ -0,0 +1,126 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	"io"
+	"io/ioutil"
+	"mime"
+	. "net/http"
+)
+
+const (
+	MIMEMessageRFC822 = "message/rfc822"
+	maxMessageSize    = 10 << 20
+)
+
+type ParseJobAPI interface {
+	Create(c echo.Context) error
+	Get(c echo.Context) error
+}
+
+type parseJobAPI struct {
+	service domain.ParseJobService
+}
+
+func NewParseJobAPI(service domain.ParseJobService) ParseJobAPI {
+	return &parseJobAPI{service: service}
+}
+
+// Create accepts a raw RFC822 message, as request body or as 'file' multipart field,
+// or a bare HTML or text body which is wrapped into a message
+func (a *parseJobAPI) Create(c echo.Context) error {
+	raw, err := readMessage(c)
+	if err != nil {
+		return echo.NewHTTPError(StatusBadRequest, err.Error())
+	}
+	email, err := model.NewEmail(raw)
+	if err != nil {
+		return echo.NewHTTPError(StatusBadRequest, err.Error())
+	}
+	job, err := a.service.Submit(c.Request().Context(), principal(c), email)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrorInvalidEmail):
+			return echo.NewHTTPError(StatusBadRequest, err.Error())
+		case errors.Is(err, domain.ErrorForbidden):
+			return echo.NewHTTPError(StatusForbidden, err.Error())
+		case errors.Is(err, domain.ErrorStopped):
+			return echo.NewHTTPError(StatusServiceUnavailable, err.Error())
+		default:
+			return echo.NewHTTPError(StatusInternalServerError, err)
+		}
+	}
+	return c.JSON(StatusAccepted, job)
+}
+
+func (a *parseJobAPI) Get(c echo.Context) error {
+	id := c.Param("id")
+	job, err := a.service.Get(principal(c), id)
+	if err != nil {
+		switch {
+		case errors.Is(err, domain.ErrorJobNotFound):
+			return echo.NewHTTPError(StatusNotFound, fmt.Sprintf("no parse job with id %s", id))
+		default:
+			return echo.NewHTTPError(StatusInternalServerError, err)
+		}
+	}
+	return c.JSON(StatusOK, job)
+}
+
+func readMessage(c echo.Context) ([]byte, error) {
+	req := c.Request()
+	mediaType, _, _ := mime.ParseMediaType(req.Header.Get(echo.HeaderContentType))
+
+	var body io.Reader = req.Body
+	if mediaType == echo.MIMEMultipartForm {
+		fh, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("missing message file: %w", err)
+		}
+		f, err := fh.Open()
+		if err != nil {
+			return nil, fmt.Errorf("cannot open message file: %w", err)
+		}
+		defer f.Close()
+		body = f
+		mediaType = MIMEMessageRFC822
+	}
+
+	raw, err := ioutil.ReadAll(io.LimitReader(body, maxMessageSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read message: %w", err)
+	}
+	if len(raw) > maxMessageSize {
+		return nil, fmt.Errorf("message is larger than %d bytes", maxMessageSize)
+	}
+	if len(bytes.TrimSpace(raw)) == 0 {
+		return nil, errors.New("empty message")
+	}
+
+	switch mediaType {
+	case "", MIMEMessageRFC822:
+		return raw, nil
+	case echo.MIMETextHTML, echo.MIMETextPlain:
+		return wrapBody(raw, mediaType, c.QueryParam("subject")), nil
+	default:
+		return nil, fmt.Errorf("unsupported content type %s", mediaType)
+	}
+}
+
+func wrapBody(body []byte, mediaType string, subject string) []byte {
+	if subject == "" {
+		subject = "Uploaded confirmation"
+	}
+	var b bytes.Buffer
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", subject) + "\r\n")
+	b.WriteString("Content-Type: " + mediaType + "; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.Write(body)
+	return b.Bytes()
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"bytes"
+	"encoding/base64"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const rawMessage = "From: booking@example.com\r\n" +
+	"Message-Id: <MSG0@example.com>\r\n" +
+	"Subject: =?utf-8?q?R=C3=A9servation_XXX999?=\r\n" +
+	"Date: Sat, 30 May 2020 12:36:37 +0000\r\n" +
+	"\r\n" +
+	"Your flight to TUNIS"
+
+func multipartMessage(t *testing.T) (string, *bytes.Buffer) {
+	var b bytes.Buffer
+	w := multipart.NewWriter(&b)
+	f, err := w.CreateFormFile("file", "confirmation.eml")
+	if err != nil {
+		t.Fatalf("cannot create multipart body: %v", err)
+	}
+	f.Write([]byte(rawMessage))
+	w.Close()
+	return w.FormDataContentType(), &b
+}
+
+func Test_parseJobAPI_Create(t *testing.T) {
+	job := model.ParseJob{ID: "J0", Status: model.MailParsingStatusPending}
+	mockService := &mocks.ParseJobService{}
+	mockService.On("Submit", mock.Anything, mock.Anything, mock.MatchedBy(func(e *model.Email) bool {
+		raw, _ := base64.URLEncoding.DecodeString(e.Content)
+		switch e.ID {
+		case "MSG0@example.com":
+			return e.Subject == "Réservation XXX999" && string(raw) == rawMessage
+		default:
+			return e.Subject == "Booking" && strings.HasSuffix(string(raw), "\r\n\r\n<p>Your flight</p>")
+		}
+	})).Return(job, nil)
+	e := echo.New()
+	multipartType, multipartBody := multipartMessage(t)
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        *bytes.Buffer
+		code        int
+		wantErr     bool
+	}{
+		{"raw message", "/parse-jobs", MIMEMessageRFC822, bytes.NewBufferString(rawMessage), 202, false},
+		{"uploaded message", "/parse-jobs", multipartType, multipartBody, 202, false},
+		{"html body", "/parse-jobs?subject=Booking", echo.MIMETextHTMLCharsetUTF8, bytes.NewBufferString("<p>Your flight</p>"), 202, false},
+		{"empty body", "/parse-jobs", MIMEMessageRFC822, &bytes.Buffer{}, 400, true},
+		{"malformed message", "/parse-jobs", MIMEMessageRFC822, bytes.NewBufferString("no headers"), 400, true},
+		{"unsupported type", "/parse-jobs", echo.MIMEApplicationJSON, bytes.NewBufferString("{}"), 400, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewParseJobAPI(mockService)
+			req := httptest.NewRequest(http.MethodPost, tt.path, tt.body)
+			req.Header.Set(echo.HeaderContentType, tt.contentType)
+			rec := httptest.NewRecorder()
+
+			err := a.Create(e.NewContext(req, rec))
+			if tt.wantErr {
+				assert.IsType(t, &echo.HTTPError{}, err)
+				assert.Equal(t, tt.code, err.(*echo.HTTPError).Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"ID":"J0"`)
+		})
+	}
+}
+
+func Test_parseJobAPI_Get(t *testing.T) {
+	mockService := &mocks.ParseJobService{}
+	mockService.On("Get", mock.Anything, "J0").Return(model.ParseJob{ID: "J0", Status: model.MailParsingStatusDone}, nil)
+	mockService.On("Get", mock.Anything, "1111").Return(model.ParseJob{}, domain.ErrorJobNotFound)
+	e := echo.New()
+
+	tests := []struct {
+		name    string
+		id      string
+		code    int
+		wantErr bool
+	}{
+		{"get job", "J0", 200, false},
+		{"get 404", "1111", 404, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewParseJobAPI(mockService)
+			rec := httptest.NewRecorder()
+			ctx := e.NewContext(httptest.NewRequest(http.MethodGet, "/parse-jobs/"+tt.id, nil), rec)
+			ctx.SetParamNames("id")
+			ctx.SetParamValues(tt.id)
+
+			err := a.Get(ctx)
+			if tt.wantErr {
+				assert.IsType(t, &echo.HTTPError{}, err)
+				assert.Equal(t, tt.code, err.(*echo.HTTPError).Code)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.code, rec.Code)
+			assert.Contains(t, rec.Body.String(), `"Status":"DONE"`)
+		})
+	}
+}
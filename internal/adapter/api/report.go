@@ -0,0 +1,281 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"encoding/csv"
+	"fmt"
+	"github.com/labstack/echo/v4"
+	. "net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MIMETextCSV         = "text/csv; charset=utf-8"
+	MIMEApplicationXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	reportDateTime      = "2006-01-02 15:04"
+)
+
+// Report rows, a row per trip or a row per step of the trips
+const (
+	reportRowsTrip = "trip"
+	reportRowsStep = "step"
+)
+
+// ReportAPI lists trips for spreadsheets, with the filters of the trip listing
+type ReportAPI interface {
+	CSV(c echo.Context) error
+	XLSX(c echo.Context) error
+}
+
+type reportAPI struct {
+	tripFinder domain.TripFinder
+}
+
+func NewReportAPI(tripFinder domain.TripFinder) ReportAPI {
+	return &reportAPI{tripFinder: tripFinder}
+}
+
+// reportRow is a trip, or one of its steps in a report by step
+type reportRow struct {
+	trip model.Trip
+	step *model.TripStep
+}
+
+type reportColumn struct {
+	header string
+	// perStep columns are only available in reports by step
+	perStep bool
+	// value is a string, an int or a time, zero values are left empty
+	value func(r reportRow) interface{}
+}
+
+var reportColumns = map[string]reportColumn{
+	"reference": {header: "Reference", value: func(r reportRow) interface{} { return r.trip.Reference }},
+	"owner":     {header: "Owner", value: func(r reportRow) interface{} { return r.trip.Owner }},
+	"traveller": {header: "Traveller", value: func(r reportRow) interface{} { return travellerNames(r.trip) }},
+	"start":     {header: "Start", value: func(r reportRow) interface{} { return r.trip.Start }},
+	"end":       {header: "End", value: func(r reportRow) interface{} { return r.trip.End }},
+	"duration":  {header: "Duration (days)", value: func(r reportRow) interface{} { return tripDays(r.trip) }},
+	"type": {header: "Type", perStep: true, value: func(r reportRow) interface{} {
+		if r.step == nil {
+			return ""
+		}
+		return string(r.step.Type)
+	}},
+	"date": {header: "Date", perStep: true, value: func(r reportRow) interface{} {
+		if r.step == nil {
+			return time.Time{}
+		}
+		return r.step.DateTime
+	}},
+	"location": {header: "Location", value: func(r reportRow) interface{} {
+		return stepValues(r, func(s model.TripStep) string { return s.Location })
+	}},
+	"provider": {header: "Carrier/Hotel", value: func(r reportRow) interface{} {
+		return stepValues(r, func(s model.TripStep) string { return s.Provider })
+	}},
+	"description": {header: "Description", perStep: true, value: func(r reportRow) interface{} {
+		return stepValues(r, func(s model.TripStep) string { return s.Description })
+	}},
+}
+
+var defaultReportColumns = map[string][]string{
+	reportRowsTrip: {"reference", "traveller", "start", "end", "duration", "location", "provider"},
+	reportRowsStep: {"reference", "traveller", "type", "date", "location", "provider"},
+}
+
+type report struct {
+	columns []reportColumn
+	perStep bool
+}
+
+// CSV downloads the report, e.g. /trips.csv?rows=step&columns=reference,date,location&period=past
+func (a *reportAPI) CSV(c echo.Context) error {
+	r, err := newReport(c)
+	if err != nil {
+		return err
+	}
+	var w *csv.Writer
+	return eachTripPage(c, a.tripFinder, func(trips []model.Trip) error {
+		if w == nil {
+			download(c, MIMETextCSV, "trips.csv")
+			w = csv.NewWriter(c.Response())
+			w.Write(r.headers())
+		}
+		for _, row := range r.rows(trips) {
+			cells := r.cells(row)
+			record := make([]string, len(cells))
+			for i, v := range cells {
+				record[i] = csvValue(v)
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		return w.Error()
+	})
+}
+
+// XLSX downloads the report as a workbook, dates are date cells and durations numbers
+func (a *reportAPI) XLSX(c echo.Context) error {
+	r, err := newReport(c)
+	if err != nil {
+		return err
+	}
+	var x *xlsxWriter
+	err = eachTripPage(c, a.tripFinder, func(trips []model.Trip) error {
+		if x == nil {
+			download(c, MIMEApplicationXLSX, "trips.xlsx")
+			var err error
+			if x, err = newXLSXWriter(c.Response(), "Trips", r.headers()); err != nil {
+				return err
+			}
+		}
+		for _, row := range r.rows(trips) {
+			if err := x.Write(r.cells(row)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return x.Close()
+}
+
+// download starts the response of a report once its first page of trips is read, so that errors before are
+// answered as such. Errors after are logged only, the report ends where they occur.
+func download(c echo.Context, contentType string, filename string) {
+	c.Response().Header().Set(echo.HeaderContentDisposition, "attachment; filename="+filename)
+	c.Response().Header().Set(echo.HeaderContentType, contentType)
+	c.Response().WriteHeader(StatusOK)
+}
+
+// newReport reads the 'rows' and 'columns' parameters, a comma separated list of column names
+func newReport(c echo.Context) (report, error) {
+	var r report
+	switch c.QueryParam("rows") {
+	case "", reportRowsTrip:
+	case reportRowsStep:
+		r.perStep = true
+	default:
+		return r, echo.NewHTTPError(StatusBadRequest, fmt.Sprintf("parameter rows must be %s or %s, got %s",
+			reportRowsTrip, reportRowsStep, c.QueryParam("rows")))
+	}
+	names := defaultReportColumns[reportRowsTrip]
+	if r.perStep {
+		names = defaultReportColumns[reportRowsStep]
+	}
+	if v := c.QueryParam("columns"); v != "" {
+		names = strings.Split(v, ",")
+	}
+	for _, name := range names {
+		col, ok := reportColumns[strings.TrimSpace(name)]
+		if !ok {
+			return r, echo.NewHTTPError(StatusBadRequest, fmt.Sprintf("unknown column %s", name))
+		}
+		if col.perStep && !r.perStep {
+			return r, echo.NewHTTPError(StatusBadRequest, fmt.Sprintf("column %s requires rows=step", name))
+		}
+		r.columns = append(r.columns, col)
+	}
+	return r, nil
+}
+
+// rows returns the rows of trips, a row per trip or per step in date order
+func (r report) rows(trips []model.Trip) []reportRow {
+	var rows []reportRow
+	for _, t := range trips {
+		if !r.perStep || len(t.TripSteps) == 0 {
+			rows = append(rows, reportRow{trip: t})
+			continue
+		}
+		steps := make([]model.TripStep, len(t.TripSteps))
+		copy(steps, t.TripSteps)
+		sort.SliceStable(steps, func(i, j int) bool { return steps[i].DateTime.Before(steps[j].DateTime) })
+		for i := range steps {
+			rows = append(rows, reportRow{trip: t, step: &steps[i]})
+		}
+	}
+	return rows
+}
+
+func (r report) headers() []string {
+	headers := make([]string, len(r.columns))
+	for i, col := range r.columns {
+		headers[i] = col.header
+	}
+	return headers
+}
+
+func (r report) cells(row reportRow) []interface{} {
+	cells := make([]interface{}, len(r.columns))
+	for i, col := range r.columns {
+		cells[i] = col.value(row)
+	}
+	return cells
+}
+
+// csvValue formats a cell, text which spreadsheets would run as a formula is quoted
+func csvValue(v interface{}) string {
+	switch v := v.(type) {
+	case int:
+		if v == 0 {
+			return ""
+		}
+		return strconv.Itoa(v)
+	case time.Time:
+		if v.IsZero() {
+			return ""
+		}
+		return v.Format(reportDateTime)
+	case string:
+		if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+			return "'" + v
+		}
+		return v
+	default:
+		return ""
+	}
+}
+
+func travellerNames(t model.Trip) string {
+	var names []string
+	for _, tr := range t.Travellers {
+		names = append(names, strings.TrimSpace(tr.FirstName+" "+tr.LastName))
+	}
+	return strings.Join(names, "; ")
+}
+
+// tripDays counts the days of a trip, the first and the last included, zero when its dates are unknown
+func tripDays(t model.Trip) int {
+	if t.Start.IsZero() || t.End.IsZero() {
+		return 0
+	}
+	start := time.Date(t.Start.Year(), t.Start.Month(), t.Start.Day(), 0, 0, 0, 0, time.UTC)
+	end := time.Date(t.End.Year(), t.End.Month(), t.End.Day(), 0, 0, 0, 0, time.UTC)
+	return int(end.Sub(start).Hours()/24) + 1
+}
+
+// stepValues is the value of the step of a row, or the distinct values of the steps of a trip in date order
+func stepValues(r reportRow, value func(s model.TripStep) string) string {
+	if r.step != nil {
+		return value(*r.step)
+	}
+	steps := make([]model.TripStep, len(r.trip.TripSteps))
+	copy(steps, r.trip.TripSteps)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].DateTime.Before(steps[j].DateTime) })
+	var values []string
+	seen := make(map[string]bool)
+	for _, s := range steps {
+		if v := value(s); v != "" && !seen[v] {
+			seen[v] = true
+			values = append(values, v)
+		}
+	}
+	return strings.Join(values, "; ")
+}
@@ -0,0 +1,153 @@
+package api
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func reportTrips() []model.Trip {
+	trip := calendarTrip
+	trip.Owner = "alice"
+	trip.Start = time.Date(2020, 4, 6, 16, 10, 0, 0, time.UTC)
+	trip.End = time.Date(2020, 4, 7, 0, 0, 0, 0, time.UTC)
+	trip.Travellers = []model.Traveller{{FirstName: "JOHN", LastName: "SMITH"}, {FirstName: "JANE", LastName: "SMITH"}}
+	other := model.Trip{ID: "ID2", Reference: "=YYY888"}
+	return []model.Trip{trip, other}
+}
+
+func newReportAPI() ReportAPI {
+	mockFinder := &mocks.TripFinder{}
+	trips := reportTrips()
+	// a page of a trip, as a report reads the trips page by page
+	mockFinder.On("Get", mock.Anything, model.TripQuery{Period: model.TripPeriodPast, Limit: feedPageSize}).
+		Return(model.TripPage{Trips: trips[:1], Total: 2, Limit: 1}, nil)
+	mockFinder.On("Get", mock.Anything, model.TripQuery{Period: model.TripPeriodPast, Offset: 1, Limit: feedPageSize}).
+		Return(model.TripPage{Trips: trips[1:], Total: 2, Offset: 1, Limit: 1}, nil)
+	// limit and offset bound the trips reported
+	mockFinder.On("Get", mock.Anything, model.TripQuery{Period: model.TripPeriodPast, Limit: 1}).
+		Return(model.TripPage{Trips: trips[:1], Total: 2, Limit: 1}, nil)
+	mockFinder.On("Get", mock.Anything, model.TripQuery{Period: model.TripPeriodPast, Offset: 1, Limit: 1}).
+		Return(model.TripPage{Trips: trips[1:], Total: 2, Offset: 1, Limit: 1}, nil)
+	mockFinder.On("Get", mock.Anything, model.TripQuery{Period: model.TripPeriodPast, Traveller: "bob",
+		Limit: feedPageSize}).
+		Return(model.TripPage{}, domain.ErrorForbidden)
+	return NewReportAPI(mockFinder)
+}
+
+func Test_reportAPI_CSV(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		code  int
+		want  string
+	}{
+		{
+			"by trip",
+			"",
+			http.StatusOK,
+			"Reference,Traveller,Start,End,Duration (days),Location,Carrier/Hotel\n" +
+				"XXX999,JOHN SMITH; JANE SMITH,2020-04-06 16:10,2020-04-07 00:00,2," +
+				"\"PARIS; TUNIS; Hammamet, 8050, Tunisia\",TRANSAVIA FRANCE; La Badira\n" +
+				"'=YYY888,,,,,,\n",
+		},
+		{
+			"by step",
+			"&rows=step",
+			http.StatusOK,
+			"Reference,Traveller,Type,Date,Location,Carrier/Hotel\n" +
+				"XXX999,JOHN SMITH; JANE SMITH,flight-start,2020-04-06 16:10,PARIS,TRANSAVIA FRANCE\n" +
+				"XXX999,JOHN SMITH; JANE SMITH,flight-end,2020-04-06 17:45,TUNIS,TRANSAVIA FRANCE\n" +
+				"XXX999,JOHN SMITH; JANE SMITH,hotel,2020-04-07 00:00,\"Hammamet, 8050, Tunisia\",La Badira\n" +
+				"'=YYY888,,,,,\n",
+		},
+		{
+			"selected columns",
+			"&rows=step&columns=owner,date,description",
+			http.StatusOK,
+			"Owner,Date,Description\n" +
+				"alice,2020-04-06 16:10,Flight start with TRANSAVIA FRANCE\n" +
+				"alice,2020-04-06 17:45,Flight end with TRANSAVIA FRANCE\n" +
+				"alice,2020-04-07 00:00,Hotel at La Badira\n" +
+				",,\n",
+		},
+		{"limit", "&limit=1&columns=reference", http.StatusOK, "Reference\nXXX999\n"},
+		{"offset and limit", "&offset=1&limit=1&columns=reference", http.StatusOK, "Reference\n'=YYY888\n"},
+		{"unknown column", "&columns=reference,price", http.StatusBadRequest, ""},
+		{"step column by trip", "&columns=reference,date", http.StatusBadRequest, ""},
+		{"unknown rows", "&rows=traveller", http.StatusBadRequest, ""},
+		{"forbidden", "&traveller=bob", http.StatusForbidden, ""},
+	}
+	a := newReportAPI()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/trips.csv?period=past"+tt.query, nil)
+			err := a.CSV(echo.New().NewContext(req, rec))
+			if tt.code != http.StatusOK {
+				if assert.IsType(t, &echo.HTTPError{}, err) {
+					assert.Equal(t, tt.code, err.(*echo.HTTPError).Code)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, MIMETextCSV, rec.Header().Get(echo.HeaderContentType))
+			assert.Equal(t, tt.want, rec.Body.String())
+		})
+	}
+}
+
+func Test_reportAPI_XLSX(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/trips.xlsx?period=past&columns=reference,start,duration", nil)
+	require.NoError(t, newReportAPI().XLSX(echo.New().NewContext(req, rec)))
+	assert.Equal(t, MIMEApplicationXLSX, rec.Header().Get(echo.HeaderContentType))
+
+	z, err := zip.NewReader(bytes.NewReader(rec.Body.Bytes()), int64(rec.Body.Len()))
+	require.NoError(t, err)
+	parts := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		require.NoError(t, err)
+		b, err := io.ReadAll(r)
+		require.NoError(t, err)
+		// every part is well formed XML
+		dec := xml.NewDecoder(bytes.NewReader(b))
+		for err == nil {
+			_, err = dec.Token()
+		}
+		assert.Equal(t, io.EOF, err, f.Name)
+		parts[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml",
+		"xl/_rels/workbook.xml.rels", "xl/styles.xml"} {
+		assert.Contains(t, parts, name)
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	assert.Contains(t, sheet, `<c r="A1" s="1" t="inlineStr"><is><t xml:space="preserve">Reference</t></is></c>`)
+	assert.Contains(t, sheet, `<c r="A2" s="0" t="inlineStr"><is><t xml:space="preserve">XXX999</t></is></c>`)
+	// 2020-04-06 16:10 is the serial date 43927 and 16h10 of 24h
+	assert.Contains(t, sheet, `<c r="B2" s="2"><v>43927.67361111111</v></c>`)
+	assert.Contains(t, sheet, `<c r="C2"><v>2</v></c>`)
+	// formulas are not a concern in a workbook, the reference is kept as is
+	assert.Contains(t, sheet, `<t xml:space="preserve">=YYY888</t>`)
+	assert.NotContains(t, sheet, `r="B3"`, "the unknown start is left empty")
+}
+
+func Test_xlsxColumn(t *testing.T) {
+	for i, want := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		assert.Equal(t, want, xlsxColumn(i))
+	}
+}
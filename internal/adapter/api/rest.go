@@ -2,12 +2,24 @@ package api
 
 import (
 	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
 	"errors"
 	"fmt"
 	"github.com/labstack/echo/v4"
 	. "net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const (
+	HeaderTotalCount = "X-Total-Count"
+	HeaderLink       = "Link"
+)
+
+// feedPageSize is the number of trips read at once by the feeds and reports
+const feedPageSize = 100
+
 type TripAPI interface {
 	Get(c echo.Context) error
 }
@@ -23,21 +35,154 @@ func NewTripAPI(tripFinder domain.TripFinder) TripAPI {
 func (a *tripAPI) Get(c echo.Context) error {
 	ref := c.QueryParam("ref")
 	if ref == "" {
-		trips, err := a.tripFinder.Get()
+		query, err := parseTripQuery(c)
 		if err != nil {
-			return echo.NewHTTPError(StatusInternalServerError, err)
+			return echo.NewHTTPError(StatusBadRequest, err.Error())
+		}
+		page, err := a.tripFinder.Get(principal(c), query)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrorInvalidQuery):
+				return echo.NewHTTPError(StatusBadRequest, err.Error())
+			case errors.Is(err, domain.ErrorForbidden):
+				return echo.NewHTTPError(StatusForbidden, err.Error())
+			default:
+				return echo.NewHTTPError(StatusInternalServerError, err)
+			}
 		}
-		return c.JSON(StatusOK, trips)
+		c.Response().Header().Set(HeaderTotalCount, strconv.Itoa(page.Total))
+		if next := page.Offset + len(page.Trips); len(page.Trips) > 0 && next < page.Total {
+			c.Response().Header().Set(HeaderLink, nextPageLink(c, next, page.Limit))
+		}
+		return c.JSON(StatusOK, page.Trips)
 	}
 
-	trip, err := a.tripFinder.GetByReference(ref)
+	trip, err := a.tripFinder.GetByReference(principal(c), ref)
 	if err != nil {
 		switch {
 		case errors.Is(err, domain.ErrorNotFound):
 			return echo.NewHTTPError(StatusNotFound, fmt.Sprintf("no trip with reference %s", ref))
+		case errors.Is(err, domain.ErrorForbidden):
+			return echo.NewHTTPError(StatusForbidden, err.Error())
 		default:
 			return echo.NewHTTPError(StatusInternalServerError, err)
 		}
 	}
 	return c.JSON(StatusOK, trip)
 }
+
+// nextPageLink links the page of the listing after the one answered, from offset and of the same limit
+func nextPageLink(c echo.Context, offset int, limit int) string {
+	u := *c.Request().URL
+	query := u.Query()
+	query.Set("offset", strconv.Itoa(offset))
+	query.Set("limit", strconv.Itoa(limit))
+	u.RawQuery = query.Encode()
+	return fmt.Sprintf(`<%s>; rel="next"`, u.RequestURI())
+}
+
+// eachTripPage reads the trips matching the listing parameters page by page, for the feeds and reports to write
+// them out without holding all of them in memory. Pages are of feedPageSize trips at most, offset and limit bound
+// the trips read, which are not capped as a page of the listing is: every matching trip is read without limit. fn is
+// called with the first page even when empty, an error of fn stops the reading and is returned.
+func eachTripPage(c echo.Context, tripFinder domain.TripFinder, fn func(trips []model.Trip) error) error {
+	query, err := parseTripQuery(c)
+	if err != nil {
+		return echo.NewHTTPError(StatusBadRequest, err.Error())
+	}
+	// negative limits are left to the finder to reject
+	limit := query.Limit
+	for read := 0; ; {
+		if limit >= 0 {
+			query.Limit = feedPageSize
+			if limit > 0 && limit-read < feedPageSize {
+				query.Limit = limit - read
+			}
+		}
+		page, err := tripFinder.Get(principal(c), query)
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrorInvalidQuery):
+				return echo.NewHTTPError(StatusBadRequest, err.Error())
+			case errors.Is(err, domain.ErrorForbidden):
+				return echo.NewHTTPError(StatusForbidden, err.Error())
+			default:
+				return echo.NewHTTPError(StatusInternalServerError, err)
+			}
+		}
+		if err := fn(page.Trips); err != nil {
+			return err
+		}
+		read += len(page.Trips)
+		if len(page.Trips) == 0 || page.Offset+len(page.Trips) >= page.Total || (limit > 0 && read >= limit) {
+			return nil
+		}
+		query.Offset = page.Offset + len(page.Trips)
+	}
+}
+
+// parseTripQuery reads listing parameters, sort accepts a '-' prefix for descending order
+// e.g. /trip?period=upcoming&location=paris&sort=-start&offset=20&limit=10
+func parseTripQuery(c echo.Context) (model.TripQuery, error) {
+	q := model.TripQuery{
+		Period:    model.TripPeriod(c.QueryParam("period")),
+		Location:  c.QueryParam("location"),
+		StepType:  model.TripStepType(c.QueryParam("type")),
+		Traveller: c.QueryParam("traveller"),
+		Text:      c.QueryParam("q"),
+		Order:     model.SortOrder(c.QueryParam("order")),
+	}
+
+	sort := c.QueryParam("sort")
+	if strings.HasPrefix(sort, "-") {
+		sort = strings.TrimPrefix(sort, "-")
+		q.Order = model.SortOrderDesc
+	}
+	q.Sort = model.TripSortField(sort)
+
+	var err error
+	if q.From, err = parseTimeParam(c, "from", false); err != nil {
+		return q, err
+	}
+	if q.To, err = parseTimeParam(c, "to", true); err != nil {
+		return q, err
+	}
+	if q.Offset, err = parseIntParam(c, "offset"); err != nil {
+		return q, err
+	}
+	if q.Limit, err = parseIntParam(c, "limit"); err != nil {
+		return q, err
+	}
+	return q, nil
+}
+
+// parseTimeParam accepts a RFC3339 time or a day, which is extended to its last instant with endOfDay
+func parseTimeParam(c echo.Context, name string, endOfDay bool) (time.Time, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("parameter %s must be a date (YYYY-MM-DD) or a RFC3339 time, got %s", name, v)
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
+
+func parseIntParam(c echo.Context, name string) (int, error) {
+	v := c.QueryParam(name)
+	if v == "" {
+		return 0, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("parameter %s must be an integer, got %s", name, v)
+	}
+	return i, nil
+}
@@ -4,11 +4,14 @@ import (
 	"amadeus-trip-parser/internal/domain"
 	"amadeus-trip-parser/internal/domain/mocks"
 	"amadeus-trip-parser/internal/domain/model"
+	"fmt"
 	"github.com/labstack/echo/v4"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 )
 
 var trip = []model.Trip{
@@ -34,13 +37,29 @@ var trip = []model.Trip{
 	},
 }
 
-var tripJSON = `[{"ID":"ID0","Reference":"REF0","Start":"0001-01-01T00:00:00Z","End":"0001-01-01T00:00:00Z","TripSteps":[{"ID":"IDS00","TripID":"ID0","Type":"flight-start","DateTime":"0001-01-01T00:00:00Z","Location":"PARIS","Description":"DESC00"},{"ID":"IDS01","TripID":"ID0","Type":"flight-end","DateTime":"0001-01-01T00:00:00Z","Location":"ROME","Description":"DESC01"}]}]
+var tripJSON = `[{"ID":"ID0","Owner":"","Reference":"REF0","Start":"0001-01-01T00:00:00Z","End":"0001-01-01T00:00:00Z","TripSteps":[{"ID":"IDS00","TripID":"ID0","Type":"flight-start","DateTime":"0001-01-01T00:00:00Z","Location":"PARIS","Description":"DESC00"},{"ID":"IDS01","TripID":"ID0","Type":"flight-end","DateTime":"0001-01-01T00:00:00Z","Location":"ROME","Description":"DESC01"}],"Travellers":null}]
 `
 
 func Test_tripAPI_Get(t *testing.T) {
 	mockFinder := &mocks.TripFinder{}
-	mockFinder.On("Get").Return(trip, nil)
-	mockFinder.On("GetByReference", "1111").Return(model.Trip{}, domain.ErrorNotFound)
+	mockFinder.On("Get", mock.Anything, model.TripQuery{}).Return(model.TripPage{Trips: trip, Total: 1}, nil)
+	mockFinder.On("Get", mock.Anything, model.TripQuery{
+		Period:    model.TripPeriodUpcoming,
+		From:      time.Date(2020, 4, 1, 0, 0, 0, 0, time.UTC),
+		To:        time.Date(2020, 4, 30, 23, 59, 59, 999999999, time.UTC),
+		Location:  "rome",
+		StepType:  model.TripStepTypeFlightEnd,
+		Traveller: "smith",
+		Sort:      model.TripSortReference,
+		Order:     model.SortOrderDesc,
+		Offset:    20,
+		Limit:     10,
+	}).Return(model.TripPage{Trips: trip, Total: 21, Offset: 20, Limit: 10}, nil)
+	mockFinder.On("Get", mock.Anything, model.TripQuery{Period: model.TripPeriodUpcoming}).
+		Return(model.TripPage{Trips: trip, Total: 3, Limit: 1}, nil)
+	mockFinder.On("Get", mock.Anything, model.TripQuery{Sort: "price"}).
+		Return(model.TripPage{}, fmt.Errorf("%w: unknown sort field price", domain.ErrorInvalidQuery))
+	mockFinder.On("GetByReference", mock.Anything, "1111").Return(model.Trip{}, domain.ErrorNotFound)
 	e := echo.New()
 
 	type fields struct {
@@ -52,6 +71,8 @@ func Test_tripAPI_Get(t *testing.T) {
 		path    string
 		code    int
 		body    string
+		total   string
+		link    string
 		wantErr bool
 	}{
 		{
@@ -60,14 +81,69 @@ func Test_tripAPI_Get(t *testing.T) {
 			"/trip",
 			200,
 			tripJSON,
+			"1",
+			"",
+			false,
+		},
+		{
+			"get filtered page",
+			fields{mockFinder},
+			"/trip?period=upcoming&from=2020-04-01&to=2020-04-30&location=rome&type=flight-end" +
+				"&traveller=smith&sort=-reference&offset=20&limit=10",
+			200,
+			tripJSON,
+			"21",
+			"",
 			false,
 		},
+		{
+			"get first page",
+			fields{mockFinder},
+			"/trip?period=upcoming",
+			200,
+			tripJSON,
+			"3",
+			`</trip?limit=1&offset=1&period=upcoming>; rel="next"`,
+			false,
+		},
+		{
+			"get with malformed limit",
+			fields{mockFinder},
+			"/trip?limit=ten",
+			400,
+			"",
+			"",
+			"",
+			true,
+		},
+		{
+			"get with malformed date",
+			fields{mockFinder},
+			"/trip?from=yesterday",
+			400,
+			"",
+			"",
+			"",
+			true,
+		},
+		{
+			"get with invalid query",
+			fields{mockFinder},
+			"/trip?sort=price",
+			400,
+			"",
+			"",
+			"",
+			true,
+		},
 		{
 			"get 404",
 			fields{mockFinder},
 			"/trip?ref=1111",
 			404,
 			"",
+			"",
+			"",
 			true,
 		},
 	}
@@ -90,6 +166,8 @@ func Test_tripAPI_Get(t *testing.T) {
 			} else {
 				assert.Equal(t, tt.code, rec.Code)
 				assert.Equal(t, tt.body, rec.Body.String())
+				assert.Equal(t, tt.total, rec.Header().Get(HeaderTotalCount))
+				assert.Equal(t, tt.link, rec.Header().Get(HeaderLink))
 			}
 
 		})
@@ -0,0 +1,175 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Cell styles of xlsxStyles
+const (
+	xlsxStyleHeader   = 1
+	xlsxStyleDateTime = 2
+)
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+	`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+	`<Default Extension="xml" ContentType="application/xml"/>` +
+	`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+	`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+	`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+	`</Types>`
+
+const xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+	`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+	`</Relationships>`
+
+const xlsxWorkbook = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+	`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+	`<sheets><sheet name="%s" sheetId="1" r:id="rId1"/></sheets></workbook>`
+
+// xlsxStyles has a bold style for the header and a date time format, after the default style
+const xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<numFmts count="1"><numFmt numFmtId="164" formatCode="yyyy-mm-dd hh:mm"/></numFmts>` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+	`<cellStyles count="1"><cellStyle name="Normal" xfId="0" builtinId="0"/></cellStyles>` +
+	`</styleSheet>`
+
+// xlsxEpoch is the day 0 of spreadsheet serial dates, which are right from March 1900
+var xlsxEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxWriter writes a workbook of a single sheet with a frozen header row, row by row. Cells are strings, ints or
+// times, which are written as local date times without zone as the trips are, zero values are left empty. Strings are
+// inlined so that the workbook is written in one pass, without shared strings table.
+type xlsxWriter struct {
+	z    *zip.Writer
+	data io.Writer
+	buf  bytes.Buffer
+	row  int
+}
+
+// newXLSXWriter writes the parts of the workbook before its sheet and the header row, the sheet is the last part so
+// that its rows are compressed as they are written
+func newXLSXWriter(w io.Writer, sheet string, header []string) (*xlsxWriter, error) {
+	var workbook bytes.Buffer
+	fmt.Fprintf(&workbook, xlsxWorkbook, xmlEscape(sheet))
+	x := &xlsxWriter{z: zip.NewWriter(w)}
+	for _, part := range []struct {
+		name    string
+		content []byte
+	}{
+		{"[Content_Types].xml", []byte(xlsxContentTypes)},
+		{"_rels/.rels", []byte(xlsxRels)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", []byte(xlsxWorkbookRels)},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+	} {
+		f, err := x.z.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := f.Write(part.content); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if x.data, err = x.z.Create("xl/worksheets/sheet1.xml"); err != nil {
+		return nil, err
+	}
+	x.buf.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" ` +
+		`state="frozen"/></sheetView></sheetViews><sheetData>`)
+	cells := make([]interface{}, len(header))
+	for i, h := range header {
+		cells[i] = h
+	}
+	return x, x.write(cells, xlsxStyleHeader)
+}
+
+// Write writes a row of cells after the previous one
+func (x *xlsxWriter) Write(cells []interface{}) error {
+	return x.write(cells, 0)
+}
+
+func (x *xlsxWriter) write(cells []interface{}, style int) error {
+	x.row++
+	writeXLSXRow(&x.buf, x.row, cells, style)
+	_, err := x.data.Write(x.buf.Bytes())
+	x.buf.Reset()
+	return err
+}
+
+// Close ends the sheet and the workbook, the underlying writer is left open
+func (x *xlsxWriter) Close() error {
+	if _, err := io.WriteString(x.data, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.z.Close()
+}
+
+func writeXLSXRow(buf *bytes.Buffer, r int, cells []interface{}, style int) {
+	fmt.Fprintf(buf, `<row r="%d">`, r)
+	for i, v := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(r)
+		switch v := v.(type) {
+		case int:
+			if v == 0 {
+				continue
+			}
+			fmt.Fprintf(buf, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case time.Time:
+			if v.IsZero() {
+				continue
+			}
+			day := time.Date(v.Year(), v.Month(), v.Day(), 0, 0, 0, 0, time.UTC)
+			seconds := v.Hour()*3600 + v.Minute()*60 + v.Second()
+			serial := float64(day.Sub(xlsxEpoch)/(24*time.Hour)) + float64(seconds)/86400
+			fmt.Fprintf(buf, `<c r="%s" s="%d"><v>%s</v></c>`, ref, xlsxStyleDateTime,
+				strconv.FormatFloat(serial, 'f', -1, 64))
+		case string:
+			if v == "" {
+				continue
+			}
+			fmt.Fprintf(buf, `<c r="%s" s="%d" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref,
+				style, xmlEscape(v))
+		}
+	}
+	buf.WriteString(`</row>`)
+}
+
+// xlsxColumn names the column of index i, such as A, Z, AA
+func xlsxColumn(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// xmlEscape escapes text, characters invalid in XML are replaced
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
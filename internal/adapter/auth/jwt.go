@@ -0,0 +1,135 @@
+package auth
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"crypto"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// minRefresh limits the reloads of a remote key set triggered by tokens signed with unknown keys
+const minRefresh = time.Minute
+
+type JWTConfig struct {
+	// JWKS is the URL or the file path of the key set signing the tokens
+	JWKS     string
+	Issuer   string
+	Audience string
+	// RolesClaim names the claim listing the roles of the subject, 'roles' by default
+	RolesClaim string
+	// AdminRole is the role granting access to all trips, 'admin' by default
+	AdminRole string
+}
+
+type jwtVerifier struct {
+	cfg      JWTConfig
+	client   *http.Client
+	parser   *jwt.Parser
+	mu       sync.Mutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+}
+
+// NewJWTVerifier validates bearer tokens signed by a key of the set, with the configured issuer and audience
+func NewJWTVerifier(cfg JWTConfig) (domain.TokenVerifier, error) {
+	if cfg.JWKS == "" {
+		return nil, fmt.Errorf("missing JWKS location")
+	}
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = "roles"
+	}
+	if cfg.AdminRole == "" {
+		cfg.AdminRole = model.RoleAdmin
+	}
+	v := &jwtVerifier{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		parser: &jwt.Parser{ValidMethods: []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512"}},
+	}
+	keys, err := loadJWKS(v.client, cfg.JWKS)
+	if err != nil {
+		return nil, err
+	}
+	v.keys, v.loadedAt = keys, time.Now()
+	return v, nil
+}
+
+func (v *jwtVerifier) Verify(token string) (model.Principal, error) {
+	claims := jwt.MapClaims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.key); err != nil {
+		return model.Principal{}, fmt.Errorf("%w: invalid token: %v", domain.ErrorUnauthenticated, err)
+	}
+	// the parser only checks the expiry of tokens having one, tokens valid forever are refused
+	if !claims.VerifyExpiresAt(time.Now().Unix(), true) {
+		return model.Principal{}, fmt.Errorf("%w: token without expiry", domain.ErrorUnauthenticated)
+	}
+	if v.cfg.Issuer != "" && !claims.VerifyIssuer(v.cfg.Issuer, true) {
+		return model.Principal{}, fmt.Errorf("%w: unexpected token issuer", domain.ErrorUnauthenticated)
+	}
+	if v.cfg.Audience != "" && !contains(claimStrings(claims["aud"]), v.cfg.Audience) {
+		return model.Principal{}, fmt.Errorf("%w: unexpected token audience", domain.ErrorUnauthenticated)
+	}
+	sub, _ := claims["sub"].(string)
+	if sub == "" {
+		return model.Principal{}, fmt.Errorf("%w: token without subject", domain.ErrorUnauthenticated)
+	}
+	p := model.Principal{Subject: sub, Role: model.RoleUser}
+	if contains(claimStrings(claims[v.cfg.RolesClaim]), v.cfg.AdminRole) {
+		p.Role = model.RoleAdmin
+	}
+	return p, nil
+}
+
+// key returns the key signing the token, a remote key set is reloaded when the key is unknown
+// since the provider may have rotated its keys
+func (v *jwtVerifier) key(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	if strings.HasPrefix(v.cfg.JWKS, "http") && time.Since(v.loadedAt) > minRefresh {
+		keys, err := loadJWKS(v.client, v.cfg.JWKS)
+		if err != nil {
+			return nil, err
+		}
+		v.keys, v.loadedAt = keys, time.Now()
+		if key, ok := v.keys[kid]; ok {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %s", kid)
+}
+
+// claimStrings reads a claim holding a string or an array of strings
+func claimStrings(claim interface{}) []string {
+	switch c := claim.(type) {
+	case string:
+		return []string{c}
+	case []interface{}:
+		var values []string
+		for _, v := range c {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// writeJWKS writes a static key set with the public parts of the keys
+func writeJWKS(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) string {
+	set := fmt.Sprintf(`{"keys":[
+		{"kid":"rsa1","kty":"RSA","use":"sig","alg":"RS256","n":"%s","e":"%s"},
+		{"kid":"ec1","kty":"EC","crv":"P-256","x":"%s","y":"%s"},
+		{"kid":"enc1","kty":"oct","use":"enc"}]}`,
+		encodeInt(rsaKey.N), encodeInt(big.NewInt(int64(rsaKey.E))), encodeInt(ecKey.X), encodeInt(ecKey.Y))
+	dir, err := ioutil.TempDir("", "jwks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	file := filepath.Join(dir, "jwks.json")
+	if err := ioutil.WriteFile(file, []byte(set), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key interface{}, claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = kid
+	s, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func Test_jwtVerifier_Verify(t *testing.T) {
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	otherKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	v, err := NewJWTVerifier(JWTConfig{
+		JWKS:     writeJWKS(t, rsaKey, ecKey),
+		Issuer:   "https://id.example.com",
+		Audience: "trip-parser",
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := func(extra jwt.MapClaims) jwt.MapClaims {
+		c := jwt.MapClaims{"sub": "alice", "iss": "https://id.example.com", "aud": "trip-parser", "exp": exp}
+		// nil values leave the claim out
+		for k, v := range extra {
+			if v == nil {
+				delete(c, k)
+			} else {
+				c[k] = v
+			}
+		}
+		return c
+	}
+	tests := []struct {
+		name  string
+		token string
+		want  model.Principal
+		err   bool
+	}{
+		{"rsa signed", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey, claims(nil)),
+			model.Principal{Subject: "alice", Role: model.RoleUser}, false},
+		{"ec signed admin", sign(t, jwt.SigningMethodES256, "ec1", ecKey,
+			claims(jwt.MapClaims{"aud": []string{"other", "trip-parser"}, "roles": []string{"admin"}})),
+			model.Principal{Subject: "alice", Role: model.RoleAdmin}, false},
+		{"unknown key", sign(t, jwt.SigningMethodRS256, "rsa2", otherKey, claims(nil)), model.Principal{}, true},
+		{"wrong signature", sign(t, jwt.SigningMethodRS256, "rsa1", otherKey, claims(nil)), model.Principal{}, true},
+		{"hmac signed", sign(t, jwt.SigningMethodHS256, "rsa1", []byte("secret"), claims(nil)), model.Principal{}, true},
+		{"expired", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey,
+			claims(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()})), model.Principal{}, true},
+		{"wrong issuer", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey,
+			claims(jwt.MapClaims{"iss": "https://evil.example.com"})), model.Principal{}, true},
+		{"wrong audience", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey,
+			claims(jwt.MapClaims{"aud": "other"})), model.Principal{}, true},
+		{"no subject", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey,
+			claims(jwt.MapClaims{"sub": ""})), model.Principal{}, true},
+		{"no expiry", sign(t, jwt.SigningMethodRS256, "rsa1", rsaKey,
+			claims(jwt.MapClaims{"exp": nil})), model.Principal{}, true},
+		{"malformed", "not.a.token", model.Principal{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Verify(tt.token)
+			if tt.err {
+				assert.True(t, errors.Is(err, domain.ErrorUnauthenticated), "error = %v", err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
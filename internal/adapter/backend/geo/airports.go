@@ -0,0 +1,87 @@
+package geo
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// airportsCSV lists major airports only, as iata,name,city,country,latitude,longitude,timezone with a header row.
+// Others are given by the file of NewAirports.
+//
+//go:embed airports.csv
+var airportsCSV string
+
+// airports geocodes IATA airport codes
+type airports map[string]model.Coordinates
+
+// NewAirports geocodes the airports of the embedded list, and those of file when not empty, in the same CSV format.
+// The timezone column may be left out of file. The airports of file replace the embedded ones with the same code.
+func NewAirports(file string) (domain.Geocoder, error) {
+	a := make(airports)
+	if err := a.read(strings.NewReader(airportsCSV)); err != nil {
+		return nil, fmt.Errorf("cannot read embedded airports: %w", err)
+	}
+	if file == "" {
+		return a, nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open airports: %w", err)
+	}
+	defer f.Close()
+	if err := a.read(f); err != nil {
+		return nil, fmt.Errorf("cannot read airports of %s: %w", file, err)
+	}
+	return a, nil
+}
+
+func (a airports) read(r io.Reader) error {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	records, err := cr.ReadAll()
+	if err != nil {
+		return err
+	}
+	for i, rec := range records {
+		if len(rec) != 6 && len(rec) != 7 {
+			return fmt.Errorf("record on line %d: wrong number of fields", i+1)
+		}
+		if i == 0 {
+			continue
+		}
+		c := model.Coordinates{}
+		if len(rec) == 7 {
+			c.TimeZone = strings.TrimSpace(rec[6])
+			if _, err := time.LoadLocation(c.TimeZone); err != nil {
+				return fmt.Errorf("invalid time zone of %s: %w", rec[0], err)
+			}
+		}
+		var err error
+		if c.Latitude, err = strconv.ParseFloat(rec[4], 64); err != nil {
+			return fmt.Errorf("invalid latitude of %s: %w", rec[0], err)
+		}
+		if c.Longitude, err = strconv.ParseFloat(rec[5], 64); err != nil {
+			return fmt.Errorf("invalid longitude of %s: %w", rec[0], err)
+		}
+		a[strings.ToUpper(strings.TrimSpace(rec[0]))] = c
+	}
+	return nil
+}
+
+// Geocode returns the coordinates of the airport of an IATA code, such as ORY
+func (a airports) Geocode(_ context.Context, code string) (model.Coordinates, error) {
+	c, ok := a[strings.ToUpper(strings.TrimSpace(code))]
+	if !ok {
+		return model.Coordinates{}, domain.ErrorNotGeocoded
+	}
+	return c, nil
+}
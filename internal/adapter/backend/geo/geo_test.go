@@ -0,0 +1,98 @@
+package geo
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewAirports(t *testing.T) {
+	a, err := NewAirports("")
+	require.NoError(t, err)
+	got, err := a.Geocode(context.Background(), "ory")
+	assert.NoError(t, err)
+	assert.Equal(t, model.Coordinates{Latitude: 48.7233, Longitude: 2.3794, TimeZone: "Europe/Paris"}, got)
+	_, err = a.Geocode(context.Background(), "XXX")
+	assert.ErrorIs(t, err, domain.ErrorNotGeocoded)
+
+	file := filepath.Join(t.TempDir(), "airports.csv")
+	require.NoError(t, os.WriteFile(file, []byte("iata,name,city,country,latitude,longitude\n"+
+		"XXX,Test Airport,Test,FR,45.5,-1.25\nORY,Paris Orly,Paris,FR,48.7,2.4,Europe/Paris\n"), 0600))
+	a, err = NewAirports(file)
+	require.NoError(t, err)
+	got, err = a.Geocode(context.Background(), "XXX")
+	assert.NoError(t, err)
+	assert.Equal(t, model.Coordinates{Latitude: 45.5, Longitude: -1.25}, got)
+	got, err = a.Geocode(context.Background(), "ORY")
+	assert.NoError(t, err)
+	assert.Equal(t, model.Coordinates{Latitude: 48.7, Longitude: 2.4, TimeZone: "Europe/Paris"}, got,
+		"file airports replace embedded ones")
+	_, err = a.Geocode(context.Background(), "TUN")
+	assert.NoError(t, err, "embedded airports are kept")
+
+	require.NoError(t, os.WriteFile(file, []byte("iata,name,city,country,latitude,longitude,timezone\n"+
+		"XXX,Test Airport,Test,FR,45.5,-1.25,Europe/Nowhere\n"), 0600))
+	_, err = NewAirports(file)
+	assert.Error(t, err, "invalid time zone")
+
+	require.NoError(t, os.WriteFile(file, []byte("iata,name\nXXX,Test\n"), 0600))
+	_, err = NewAirports(file)
+	assert.Error(t, err)
+	_, err = NewAirports(filepath.Join(t.TempDir(), "missing.csv"))
+	assert.Error(t, err)
+}
+
+func Test_nominatim_Geocode(t *testing.T) {
+	var requests []time.Time
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, time.Now())
+		assert.Equal(t, "/search", r.URL.Path)
+		assert.Equal(t, "amadeus-trip-parser-test", r.Header.Get("User-Agent"))
+		assert.Equal(t, "jsonv2", r.URL.Query().Get("format"))
+		switch r.URL.Query().Get("q") {
+		case "Hammamet, 8050, Tunisia":
+			w.Write([]byte(`[{"place_id":1,"lat":"36.4","lon":"10.6","display_name":"Hammamet"}]`))
+		case "error":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer srv.Close()
+
+	g := NewNominatimGeocoder(srv.URL+"/", "amadeus-trip-parser-test")
+	got, err := g.Geocode(context.Background(), "Hammamet, 8050, Tunisia")
+	assert.NoError(t, err)
+	assert.Equal(t, model.Coordinates{Latitude: 36.4, Longitude: 10.6}, got)
+	_, err = g.Geocode(context.Background(), "Nowhere")
+	assert.ErrorIs(t, err, domain.ErrorNotGeocoded)
+	_, err = g.Geocode(context.Background(), "error")
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, domain.ErrorNotGeocoded)
+
+	require.Len(t, requests, 3)
+	assert.GreaterOrEqual(t, int64(requests[2].Sub(requests[0])), int64(2*nominatimInterval-50*time.Millisecond),
+		"requests are spaced")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = g.Geocode(ctx, "Hammamet, 8050, Tunisia")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// the next turn is a second away, past the deadline
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = g.Geocode(ctx, "Hammamet, 8050, Tunisia")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, int64(time.Since(start)), int64(50*time.Millisecond), "fails without waiting")
+	assert.Len(t, requests, 3)
+}
@@ -0,0 +1,110 @@
+package geo
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultNominatimURL = "https://nominatim.openstreetmap.org"
+
+// nominatimInterval spaces the requests, the public Nominatim instance allows one request per second
+const nominatimInterval = time.Second
+
+type nominatim struct {
+	url       string
+	userAgent string
+	client    *http.Client
+	mu        sync.Mutex
+	next      time.Time
+}
+
+// nominatimPlace is a search result, whose coordinates are strings
+type nominatimPlace struct {
+	Lat string `json:"lat"`
+	Lon string `json:"lon"`
+}
+
+// NewNominatimGeocoder geocodes addresses with the search API of a Nominatim server, such as DefaultNominatimURL.
+// The user agent identifies the application, as required by the usage policy of OpenStreetMap.
+func NewNominatimGeocoder(url string, userAgent string) domain.Geocoder {
+	return &nominatim{
+		url:       strings.TrimSuffix(url, "/"),
+		userAgent: userAgent,
+		client: &http.Client{
+			Timeout: time.Second * 15,
+		},
+	}
+}
+
+// wait returns once the request is allowed, or with the error of ctx when done first. A request which could not
+// be sent before the deadline of ctx fails right away, without taking the turn of the next ones.
+func (n *nominatim) wait(ctx context.Context) error {
+	n.mu.Lock()
+	now := time.Now()
+	at := n.next
+	if at.Before(now) {
+		at = now
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(at) {
+		n.mu.Unlock()
+		return context.DeadlineExceeded
+	}
+	n.next = at.Add(nominatimInterval)
+	n.mu.Unlock()
+
+	t := time.NewTimer(time.Until(at))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Geocode returns the coordinates of the first place found for an address
+func (n *nominatim) Geocode(ctx context.Context, address string) (model.Coordinates, error) {
+	if err := n.wait(ctx); err != nil {
+		return model.Coordinates{}, err
+	}
+	query := url.Values{"q": {address}, "format": {"jsonv2"}, "limit": {"1"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.url+"/search?"+query.Encode(), nil)
+	if err != nil {
+		return model.Coordinates{}, err
+	}
+	req.Header.Set("User-Agent", n.userAgent)
+	req.Header.Set("Accept", "application/json")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return model.Coordinates{}, fmt.Errorf("cannot geocode %q: %w", address, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return model.Coordinates{}, fmt.Errorf("cannot geocode %q: nominatim status %s", address, resp.Status)
+	}
+	var places []nominatimPlace
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return model.Coordinates{}, fmt.Errorf("cannot read geocode of %q: %w", address, err)
+	}
+	if len(places) == 0 {
+		return model.Coordinates{}, domain.ErrorNotGeocoded
+	}
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return model.Coordinates{}, fmt.Errorf("invalid latitude of %q: %w", address, err)
+	}
+	lon, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return model.Coordinates{}, fmt.Errorf("invalid longitude of %q: %w", address, err)
+	}
+	return model.Coordinates{Latitude: lat, Longitude: lon}, nil
+}
@@ -0,0 +1,83 @@
+package gmail
+
+import (
+	"amadeus-trip-parser/internal/adapter/secrets"
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"encoding/json"
+	"fmt"
+	"golang.org/x/oauth2"
+	"io/ioutil"
+	"sync"
+	"time"
+)
+
+type factory struct {
+	credentials []byte
+	mailboxes   domain.MailboxStore
+	metrics     domain.Metrics
+	mu          sync.Mutex
+	clients     map[string]cachedClient
+}
+
+type cachedClient struct {
+	updatedAt time.Time
+	client    *client
+}
+
+// NewProviderFactory connects to gmail mailboxes, with the client credentials of credFile unless the mailbox
+// has its own. A client is kept per mailbox until the mailbox is updated, so that its tokens are refreshed once,
+// and the refreshed tokens are saved to mailboxes unless nil. The credentials file is decrypted with the cipher
+// when it is encrypted.
+func NewProviderFactory(credFile string, cipher *secrets.Cipher, mailboxes domain.MailboxStore,
+	metrics domain.Metrics) (domain.EmailProviderFactory, error) {
+	f := &factory{mailboxes: mailboxes, metrics: metrics, clients: make(map[string]cachedClient)}
+	if credFile != "" {
+		cred, err := ioutil.ReadFile(credFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read credentials: %w", err)
+		}
+		if f.credentials, err = cipher.Decrypt(cred); err != nil {
+			return nil, fmt.Errorf("cannot read credentials: %w", err)
+		}
+	}
+	return f, nil
+}
+
+func (f *factory) Provider(mailbox model.Mailbox) (domain.EmailProvider, error) {
+	if mailbox.Provider != model.MailboxProviderGmail {
+		return nil, fmt.Errorf("unsupported provider %s for mailbox %s", mailbox.Provider, mailbox.ID)
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if c, ok := f.clients[mailbox.ID]; ok && c.updatedAt.Equal(mailbox.UpdatedAt) {
+		return c.client, nil
+	}
+	cred := f.credentials
+	if mailbox.Credentials != "" {
+		cred = []byte(mailbox.Credentials)
+	}
+	if len(cred) == 0 {
+		return nil, fmt.Errorf("no credentials for mailbox %s", mailbox.ID)
+	}
+	c, err := newClient(cred, []byte(mailbox.Token), f.metrics, f.saveToken(mailbox.ID))
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to mailbox %s: %w", mailbox.ID, err)
+	}
+	f.clients[mailbox.ID] = cachedClient{mailbox.UpdatedAt, c}
+	return c, nil
+}
+
+// saveToken keeps the tokens refreshed for a mailbox, its client is not renewed as the mailbox update time is kept
+func (f *factory) saveToken(id string) func(*oauth2.Token) error {
+	if f.mailboxes == nil {
+		return nil
+	}
+	return func(tok *oauth2.Token) error {
+		b, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		return f.mailboxes.UpdateToken(id, string(b))
+	}
+}
@@ -5,52 +5,115 @@ import (
 	"amadeus-trip-parser/internal/domain/model"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/gmail/v1"
 	"google.golang.org/api/option"
 	"io/ioutil"
-	"os"
+	"sync"
 )
 
+const source = "gmail"
+
+var tracer = otel.Tracer("amadeus-trip-parser/gmail")
+
 type client struct {
 	service *gmail.Service
+	tokens  oauth2.TokenSource
+	metrics domain.Metrics
+}
+
+// refreshCounter reports the access tokens obtained by the wrapped source, a refresh token gives a new one
+// each time the previous one expires. Each new token is given to save, when set, so that it outlives the client.
+// A refresh token revoked or expired fails with domain.ErrorMailboxRevoked.
+type refreshCounter struct {
+	src     oauth2.TokenSource
+	metrics domain.Metrics
+	save    func(*oauth2.Token) error
+	mu      sync.Mutex
+	last    string
+	revoked bool
 }
 
-func credentialsFromFile(file string) (*oauth2.Config, error) {
-	f, err := os.Open(file)
+func (r *refreshCounter) Token() (*oauth2.Token, error) {
+	tok, err := r.src.Token()
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	if err != nil {
-		return nil, err
+		if !isInvalidGrant(err) {
+			return nil, err
+		}
+		if !r.revoked {
+			r.revoked = true
+			r.metrics.TokenRevoked(source)
+		}
+		return nil, fmt.Errorf("%w, authorize access again: %v", domain.ErrorMailboxRevoked, err)
 	}
-	b, err := ioutil.ReadAll(f)
-	if err != nil {
-		return nil, err
+	r.revoked = false
+	if tok.AccessToken != r.last {
+		if r.last != "" {
+			r.metrics.TokenRefreshed(source)
+			if r.save != nil {
+				if err := r.save(tok); err != nil {
+					log.Error().Msgf("cannot save refreshed gmail token: %v", err)
+				}
+			}
+		}
+		r.last = tok.AccessToken
 	}
-	return google.ConfigFromJSON(b, gmail.GmailReadonlyScope)
+	return tok, nil
 }
 
-func tokenFromFile(file string) (*oauth2.Token, error) {
-	f, err := os.Open(file)
-	if err != nil {
-		return nil, err
+// isInvalidGrant tells whether the token endpoint refused the refresh token, the OAuth error code is given
+// in the JSON body of the response
+func isInvalidGrant(err error) bool {
+	var re *oauth2.RetrieveError
+	if !errors.As(err, &re) {
+		return false
 	}
-	tok := &oauth2.Token{}
-	return tok, json.NewDecoder(f).Decode(tok)
+	var body struct {
+		Error string `json:"error"`
+	}
+	if json.Unmarshal(re.Body, &body) != nil {
+		return false
+	}
+	return body.Error == "invalid_grant"
 }
 
-func NewGMailClient(credFile string, tokenFile string) (domain.EmailProvider, error) {
-	g := &client{}
-	cred, err := credentialsFromFile(credFile)
+func NewGMailClient(credFile string, tokenFile string, metrics domain.Metrics) (domain.EmailProvider, error) {
+	cred, err := ioutil.ReadFile(credFile)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read credentials: %w", err)
 	}
-	tok, err := tokenFromFile(tokenFile)
+	tok, err := ioutil.ReadFile(tokenFile)
 	if err != nil {
 		return nil, fmt.Errorf("cannot read token: %w", err)
 	}
-	http := cred.Client(context.Background(), tok)
+	return newClient(cred, tok, metrics, nil)
+}
+
+// newClient connects with the OAuth client and token JSON, refreshed tokens are given to save when set
+func newClient(credentials []byte, token []byte, metrics domain.Metrics, save func(*oauth2.Token) error) (*client,
+	error) {
+	g := &client{metrics: metrics}
+	cred, err := google.ConfigFromJSON(credentials, gmail.GmailReadonlyScope)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read credentials: %w", err)
+	}
+	tok := &oauth2.Token{}
+	if err := json.Unmarshal(token, tok); err != nil {
+		return nil, fmt.Errorf("cannot read token: %w", err)
+	}
+	ts := &refreshCounter{src: cred.TokenSource(context.Background(), tok), metrics: metrics, save: save,
+		last: tok.AccessToken}
+	g.tokens = ts
+	http := oauth2.NewClient(context.Background(), ts)
 	svc, err := gmail.NewService(context.Background(), option.WithHTTPClient(http))
 	if err != nil {
 		return nil, fmt.Errorf("unable to create gmail service: %w", err)
@@ -59,50 +122,57 @@ func NewGMailClient(credFile string, tokenFile string) (domain.EmailProvider, er
 	return g, nil
 }
 
-func (g *client) GetEmails(filter string) []*model.Email {
+// CheckHealth gets an access token, refreshed when expired, it fails once the credentials or the refresh token
+// are no longer valid
+func (g *client) CheckHealth(ctx context.Context) error {
+	errs := make(chan error, 1)
+	go func() {
+		_, err := g.tokens.Token()
+		errs <- err
+	}()
+	select {
+	case err := <-errs:
+		if err != nil {
+			return fmt.Errorf("invalid gmail credentials: %w", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("cannot check gmail credentials: %w", ctx.Err())
+	}
+}
+
+// GetEmails returns the emails matching filter. A message that cannot be fetched is reported in the
+// *domain.FetchError returned with the others, as is the error ending the listing.
+func (g *client) GetEmails(ctx context.Context, filter string) ([]*model.Email, error) {
+	ctx, span := tracer.Start(ctx, "gmail.GetEmails", trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attribute.String("gmail.filter", filter)))
+	defer span.End()
+
 	var ms []*model.Email
+	fetchErr := &domain.FetchError{}
 	pageToken := ""
 	for {
-		req := g.service.Users.Messages.List("me").Q(filter)
+		req := g.service.Users.Messages.List("me").Q(filter).Context(ctx)
 		if pageToken != "" {
 			req.PageToken(pageToken)
 		}
 
 		r, err := req.Do()
 		if err != nil {
-			log.Error().Msgf("unable to retrieve messages: %v", err)
+			span.RecordError(err)
+			fetchErr.Err = fmt.Errorf("unable to retrieve messages: %w", err)
+			break
 		}
 
 		log.Debug().Msgf("getting %v messages", len(r.Messages))
 		for _, m := range r.Messages {
-			//first get only meta to have parsed headers
-			msg, err := g.service.Users.Messages.Get("me", m.Id).Format("metadata").Do()
-			if err != nil {
-				log.Error().Msgf("Unable to retrieve message %v: %v", m.Id, err)
-			}
-			date := ""
-			subject := ""
-			for _, h := range msg.Payload.Headers {
-				switch h.Name {
-				case "Date":
-					date = h.Value
-				case "Subject":
-					subject = h.Value
-				}
-			}
-			//then to have all email as raw content for ulterior parsing purpose
-			rawMail, err := g.service.Users.Messages.Get("me", m.Id).Format("raw").Do()
+			email, err := g.getEmail(ctx, m.Id)
 			if err != nil {
-				log.Error().Msgf("Unable to retrieve message raw content %v: %v", m.Id, err)
+				span.RecordError(err)
+				fetchErr.Messages = append(fetchErr.Messages, &domain.MessageError{MessageID: m.Id, Err: err})
+				continue
 			}
-			ms = append(ms, &model.Email{
-				Subject: subject,
-				Size:    msg.SizeEstimate,
-				ID:      msg.Id,
-				Date:    date,
-				Snippet: msg.Snippet,
-				Content: rawMail.Raw,
-			})
+			ms = append(ms, email)
 		}
 
 		if r.NextPageToken == "" {
@@ -110,5 +180,42 @@ func (g *client) GetEmails(filter string) []*model.Email {
 		}
 		pageToken = r.NextPageToken
 	}
-	return ms
+	g.metrics.EmailsFetched(source, len(ms))
+	span.SetAttributes(attribute.Int("gmail.messages", len(ms)), attribute.Int("gmail.failed", len(fetchErr.Messages)))
+	if fetchErr.Err != nil || len(fetchErr.Messages) > 0 {
+		return ms, fetchErr
+	}
+	return ms, nil
+}
+
+// getEmail reads the headers of a message, then its raw content for the parser
+func (g *client) getEmail(ctx context.Context, id string) (*model.Email, error) {
+	msg, err := g.service.Users.Messages.Get("me", id).Format("metadata").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve message: %w", err)
+	}
+	date := ""
+	subject := ""
+	if msg.Payload != nil {
+		for _, h := range msg.Payload.Headers {
+			switch h.Name {
+			case "Date":
+				date = h.Value
+			case "Subject":
+				subject = h.Value
+			}
+		}
+	}
+	rawMail, err := g.service.Users.Messages.Get("me", id).Format("raw").Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve message raw content: %w", err)
+	}
+	return &model.Email{
+		Subject: subject,
+		Size:    msg.SizeEstimate,
+		ID:      msg.Id,
+		Date:    date,
+		Snippet: msg.Snippet,
+		Content: rawMail.Raw,
+	}, nil
 }
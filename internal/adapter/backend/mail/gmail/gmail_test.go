@@ -1,8 +1,23 @@
 package gmail
 
 import (
+	"amadeus-trip-parser/internal/adapter/secrets"
+	"amadeus-trip-parser/internal/domain"
 	"amadeus-trip-parser/internal/domain/model"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"golang.org/x/oauth2"
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/option"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 const credentials = "../../../../client_credentials.json"
@@ -35,7 +50,7 @@ func TestNewGMailClient(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			_, err := NewGMailClient(tt.args.credFile, tt.args.tokenFile)
+			_, err := NewGMailClient(tt.args.credFile, tt.args.tokenFile, domain.NopMetrics{})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("NewGMailClient() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -49,7 +64,7 @@ func Test_client_GetEmails(t *testing.T) {
 		return
 	}
 
-	g, err := NewGMailClient(credentials, token)
+	g, err := NewGMailClient(credentials, token, domain.NopMetrics{})
 	if err != nil {
 		t.Errorf("cannot connect: %v", err)
 	}
@@ -72,8 +87,261 @@ func Test_client_GetEmails(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := g.GetEmails(tt.args.filter)
+			got, err := g.GetEmails(context.Background(), tt.args.filter)
+			if err != nil {
+				t.Errorf("GetEmails() error = %v", err)
+			}
 			tt.want(t, got)
 		})
 	}
 }
+
+type tokenSequence []*oauth2.Token
+
+func (s *tokenSequence) Token() (*oauth2.Token, error) {
+	tok := (*s)[0]
+	if len(*s) > 1 {
+		*s = (*s)[1:]
+	}
+	return tok, nil
+}
+
+type refreshMetrics struct {
+	domain.NopMetrics
+	refreshes   int
+	revocations int
+}
+
+func (m *refreshMetrics) TokenRefreshed(string) {
+	m.refreshes++
+}
+
+func (m *refreshMetrics) TokenRevoked(string) {
+	m.revocations++
+}
+
+func Test_refreshCounter_Token(t *testing.T) {
+	src := &tokenSequence{{AccessToken: "A"}, {AccessToken: "A"}, {AccessToken: "B"}, {AccessToken: "C"}}
+	metrics := &refreshMetrics{}
+	var saved []string
+	save := func(tok *oauth2.Token) error {
+		saved = append(saved, tok.AccessToken)
+		return errors.New("store unavailable")
+	}
+	r := &refreshCounter{src: src, metrics: metrics, save: save, last: "A"}
+	for i := 0; i < 5; i++ {
+		if _, err := r.Token(); err != nil {
+			t.Fatalf("Token() error = %v", err)
+		}
+	}
+	if metrics.refreshes != 2 {
+		t.Errorf("Token() reported %d refreshes, want 2", metrics.refreshes)
+	}
+	if len(saved) != 2 || saved[0] != "B" || saved[1] != "C" {
+		t.Errorf("Token() saved %v, want [B C]", saved)
+	}
+}
+
+type failingSource struct {
+	err error
+}
+
+func (s failingSource) Token() (*oauth2.Token, error) {
+	return nil, s.err
+}
+
+func retrieveError(status int, body string) error {
+	return &oauth2.RetrieveError{Response: &http.Response{Status: http.StatusText(status), StatusCode: status},
+		Body: []byte(body)}
+}
+
+func Test_refreshCounter_Token_revoked(t *testing.T) {
+	metrics := &refreshMetrics{}
+	revoked := retrieveError(http.StatusBadRequest,
+		`{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`)
+	r := &refreshCounter{src: failingSource{revoked}, metrics: metrics, last: "A"}
+	for i := 0; i < 2; i++ {
+		if _, err := r.Token(); !errors.Is(err, domain.ErrorMailboxRevoked) {
+			t.Errorf("Token() error = %v, want %v", err, domain.ErrorMailboxRevoked)
+		}
+	}
+	if metrics.revocations != 1 {
+		t.Errorf("Token() reported %d revocations, want 1", metrics.revocations)
+	}
+
+	for name, err := range map[string]error{
+		"server error":   retrieveError(http.StatusInternalServerError, "unavailable"),
+		"invalid client": retrieveError(http.StatusUnauthorized, `{"error":"invalid_client"}`),
+		"network":        errors.New("connection refused"),
+	} {
+		r := &refreshCounter{src: failingSource{err}, metrics: metrics}
+		if _, got := r.Token(); got == nil || errors.Is(got, domain.ErrorMailboxRevoked) {
+			t.Errorf("Token() with %s error = %v, want it unchanged", name, got)
+		}
+	}
+}
+
+const testCredentials = `{"installed":{"client_id":"id","client_secret":"secret",
+"auth_uri":"https://accounts.google.com/o/oauth2/auth","token_uri":"https://oauth2.googleapis.com/token",
+"redirect_uris":["http://localhost"]}}`
+
+func Test_factory_Provider(t *testing.T) {
+	f, err := NewProviderFactory("", nil, nil, domain.NopMetrics{})
+	if err != nil {
+		t.Fatalf("NewProviderFactory() error = %v", err)
+	}
+	mailbox := model.Mailbox{ID: "m1", Provider: model.MailboxProviderGmail, Credentials: testCredentials,
+		Token: `{"access_token":"a","refresh_token":"r"}`, UpdatedAt: time.Now()}
+
+	first, err := f.Provider(mailbox)
+	if err != nil {
+		t.Fatalf("Provider() error = %v", err)
+	}
+	if again, _ := f.Provider(mailbox); again != first {
+		t.Errorf("Provider() of the same mailbox returned a new client")
+	}
+	mailbox.UpdatedAt = mailbox.UpdatedAt.Add(time.Second)
+	if updated, _ := f.Provider(mailbox); updated == first {
+		t.Errorf("Provider() of an updated mailbox returned the previous client")
+	}
+
+	for name, m := range map[string]model.Mailbox{
+		"unsupported provider": {ID: "m2", Provider: "imap", Credentials: testCredentials},
+		"no credentials":       {ID: "m3", Provider: model.MailboxProviderGmail, Token: "{}"},
+		"invalid token":        {ID: "m4", Provider: model.MailboxProviderGmail, Credentials: testCredentials, Token: "nope"},
+	} {
+		if _, err := f.Provider(m); err == nil {
+			t.Errorf("Provider() with %s succeeded", name)
+		}
+	}
+}
+
+func TestNewProviderFactory_encrypted(t *testing.T) {
+	cipher, _ := secrets.NewCipher(bytes.Repeat([]byte{1}, 32))
+	encrypted, _ := cipher.Encrypt([]byte(testCredentials))
+	file := filepath.Join(t.TempDir(), "client_credentials.json")
+	if err := ioutil.WriteFile(file, encrypted, 0600); err != nil {
+		t.Fatalf("cannot write credentials: %v", err)
+	}
+
+	f, err := NewProviderFactory(file, cipher, nil, domain.NopMetrics{})
+	if err != nil {
+		t.Fatalf("NewProviderFactory() error = %v", err)
+	}
+	mailbox := model.Mailbox{ID: "m1", Provider: model.MailboxProviderGmail, Token: `{"refresh_token":"r"}`}
+	if _, err := f.Provider(mailbox); err != nil {
+		t.Errorf("Provider() with encrypted default credentials error = %v", err)
+	}
+	if _, err := NewProviderFactory(file, nil, nil, domain.NopMetrics{}); !errors.Is(err, secrets.ErrorNoKey) {
+		t.Errorf("NewProviderFactory() without key error = %v, want %v", err, secrets.ErrorNoKey)
+	}
+}
+
+// tokenStore keeps the tokens saved by the factory
+type tokenStore struct {
+	domain.MailboxStore
+	tokens map[string]string
+}
+
+func (s *tokenStore) UpdateToken(id string, token string) error {
+	s.tokens[id] = token
+	return nil
+}
+
+func Test_factory_Provider_tokens(t *testing.T) {
+	var revoked bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if revoked {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Token has been expired or revoked."}`)
+			return
+		}
+		fmt.Fprint(w, `{"access_token":"refreshed","token_type":"Bearer","expires_in":3600}`)
+	}))
+	defer srv.Close()
+	cred := strings.Replace(testCredentials, "https://oauth2.googleapis.com/token", srv.URL, 1)
+	store := &tokenStore{tokens: make(map[string]string)}
+	f, err := NewProviderFactory("", nil, store, domain.NopMetrics{})
+	if err != nil {
+		t.Fatalf("NewProviderFactory() error = %v", err)
+	}
+	expired := `{"access_token":"a","refresh_token":"r","expiry":"2020-01-01T00:00:00Z"}`
+
+	p, err := f.Provider(model.Mailbox{ID: "m1", Provider: model.MailboxProviderGmail, Credentials: cred,
+		Token: expired})
+	if err != nil {
+		t.Fatalf("Provider() error = %v", err)
+	}
+	if err := p.CheckHealth(context.Background()); err != nil {
+		t.Errorf("CheckHealth() error = %v", err)
+	}
+	if !strings.Contains(store.tokens["m1"], `"access_token":"refreshed"`) ||
+		!strings.Contains(store.tokens["m1"], `"refresh_token":"r"`) {
+		t.Errorf("saved token = %s, want the refreshed one", store.tokens["m1"])
+	}
+
+	revoked = true
+	p, _ = f.Provider(model.Mailbox{ID: "m2", Provider: model.MailboxProviderGmail, Credentials: cred,
+		Token: expired})
+	if err := p.CheckHealth(context.Background()); !errors.Is(err, domain.ErrorMailboxRevoked) {
+		t.Errorf("CheckHealth() of a revoked mailbox error = %v, want %v", err, domain.ErrorMailboxRevoked)
+	}
+	if _, ok := store.tokens["m2"]; ok {
+		t.Errorf("token of a revoked mailbox saved")
+	}
+}
+
+// fakeGmail lists the messages MSG0 to MSG2 over two pages, MSG1 is not found and the listing of the third page
+// fails when failList is set
+func fakeGmail(failList bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.URL.Path == "/gmail/v1/users/me/messages" && r.URL.Query().Get("pageToken") == "":
+			fmt.Fprint(w, `{"messages":[{"id":"MSG0"},{"id":"MSG1"}],"nextPageToken":"P2"}`)
+		case r.URL.Path == "/gmail/v1/users/me/messages" && r.URL.Query().Get("pageToken") == "P2":
+			fmt.Fprint(w, `{"messages":[{"id":"MSG2"}],"nextPageToken":"P3"}`)
+		case r.URL.Path == "/gmail/v1/users/me/messages" && !failList:
+			fmt.Fprint(w, `{}`)
+		case r.URL.Path == "/gmail/v1/users/me/messages/MSG0" || r.URL.Path == "/gmail/v1/users/me/messages/MSG2":
+			id := strings.TrimPrefix(r.URL.Path, "/gmail/v1/users/me/messages/")
+			fmt.Fprintf(w, `{"id":%q,"sizeEstimate":10,"raw":"cmF3",
+"payload":{"headers":[{"name":"Subject","value":"Trip"},{"name":"Date","value":"today"}]}}`, id)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":{"code":404,"message":"Requested entity was not found."}}`)
+		}
+	}))
+}
+
+func Test_client_GetEmails_errors(t *testing.T) {
+	for _, failList := range []bool{false, true} {
+		t.Run(fmt.Sprintf("failing list %v", failList), func(t *testing.T) {
+			srv := fakeGmail(failList)
+			defer srv.Close()
+			svc, err := gmail.NewService(context.Background(), option.WithEndpoint(srv.URL),
+				option.WithHTTPClient(srv.Client()))
+			if err != nil {
+				t.Fatalf("cannot create service: %v", err)
+			}
+			g := &client{service: svc, metrics: domain.NopMetrics{}}
+
+			got, err := g.GetEmails(context.Background(), "is:unread")
+			if len(got) != 2 || got[0].ID != "MSG0" || got[1].ID != "MSG2" || got[0].Subject != "Trip" ||
+				got[0].Content != "cmF3" {
+				t.Errorf("GetEmails() got = %v, want MSG0 and MSG2", got)
+			}
+			var fetchErr *domain.FetchError
+			if !errors.As(err, &fetchErr) {
+				t.Fatalf("GetEmails() error = %v, want a fetch error", err)
+			}
+			if len(fetchErr.Messages) != 1 || fetchErr.Messages[0].MessageID != "MSG1" {
+				t.Errorf("GetEmails() failed messages = %v, want MSG1", fetchErr.Messages)
+			}
+			if (fetchErr.Err != nil) != failList {
+				t.Errorf("GetEmails() listing error = %v, want error %v", fetchErr.Err, failList)
+			}
+		})
+	}
+}
@@ -4,13 +4,21 @@ import (
 	"amadeus-trip-parser/internal/domain"
 	"amadeus-trip-parser/internal/domain/model"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -32,11 +40,21 @@ const (
 	StatusError      Status = "ERROR"
 )
 
+// endpoint names used to measure API requests
+const (
+	endpointAuthorize = "authorize"
+	endpointCreate    = "create"
+	endpointStatus    = "status"
+	endpointResult    = "result"
+)
+
 const (
 	ContentType string = "application/vnd.amadeus+json"
 	APIType     string = "trip-parser-job"
 )
 
+var tracer = otel.Tracer("amadeus-trip-parser/amadeus")
+
 type amadeusConfig struct {
 	url    string
 	key    string
@@ -46,18 +64,21 @@ type amadeusConfig struct {
 type tripAPI struct {
 	cfg       amadeusConfig
 	client    *http.Client
+	metrics   domain.Metrics
+	mu        sync.Mutex
 	token     string
 	expiresAt time.Time
 }
 
-func NewAmadeusTripAPI(url string, key string, secret string) (domain.EmailParser, error) {
+func NewAmadeusTripAPI(url string, key string, secret string, metrics domain.Metrics) (domain.EmailParser, error) {
 	t := tripAPI{
 		client: &http.Client{
 			Timeout: time.Second * 15,
 		},
-		cfg: amadeusConfig{url, key, secret},
+		cfg:     amadeusConfig{url, key, secret},
+		metrics: metrics,
 	}
-	if err := t.authorize(); err != nil {
+	if err := t.authorize(context.Background()); err != nil {
 		return nil, fmt.Errorf("cannot create amadeus api: %w", err)
 	}
 	return &t, nil
@@ -79,18 +100,42 @@ func (t *tripAPI) convertAmadeusStatus(s Status) model.MailParsingStatus {
 	}
 }
 
-func (t *tripAPI) buildRequest(method string, resource string, body io.Reader) (*http.Request, error) {
+func (t *tripAPI) buildRequest(ctx context.Context, method string, resource string, body io.Reader) (*http.Request,
+	error) {
+	token, err := t.accessToken(ctx)
+	if err != nil {
+		return nil, err
+	}
 	resURL := fmt.Sprintf("%s/%s", t.cfg.url, resource)
 	req, err := http.NewRequest(method, resURL, body)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Add("Content-Type", ContentType)
-	req.Header.Add("Authorization", "Bearer "+t.token)
+	req.Header.Add("Authorization", "Bearer "+token)
 	return req, nil
 }
 
-func (t *tripAPI) authorize() error {
+// accessToken returns the current token, a new one is requested once it is expired
+func (t *tripAPI) accessToken(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err := t.authorize(ctx); err != nil {
+		return "", err
+	}
+	return t.token, nil
+}
+
+// CheckHealth fails when the token is expired and a new one cannot be obtained
+func (t *tripAPI) CheckHealth(ctx context.Context) error {
+	if _, err := t.accessToken(ctx); err != nil {
+		return fmt.Errorf("invalid amadeus token: %w", err)
+	}
+	return nil
+}
+
+// authorize requests a token unless the current one is still valid, t.mu is held by callers but on creation
+func (t *tripAPI) authorize(ctx context.Context) error {
 	// token is not expired, do nothing
 	if t.token != "" && !t.expiresAt.IsZero() && time.Now().Before(t.expiresAt) {
 		return nil
@@ -105,7 +150,7 @@ func (t *tripAPI) authorize() error {
 	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
 
 	var resp authorizeResponse
-	code, err := t.doRequest(req, &resp)
+	code, err := t.doRequest(ctx, endpointAuthorize, req, &resp)
 	if err != nil {
 		return fmt.Errorf("failed authorize request: %w", err)
 	}
@@ -114,17 +159,33 @@ func (t *tripAPI) authorize() error {
 		return fmt.Errorf("failed to authorize, got status code %d", code)
 	}
 
+	t.metrics.TokenRefreshed("amadeus")
 	t.token = resp.AccessToken
 	t.expiresAt = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
 	return nil
 }
 
-func (t *tripAPI) doRequest(req *http.Request, payload interface{}) (int, error) {
+// doRequest sends the request in a client span, its trace context is propagated to the API
+func (t *tripAPI) doRequest(ctx context.Context, endpoint string, req *http.Request, payload interface{}) (int, error) {
+	ctx, span := tracer.Start(ctx, "amadeus."+endpoint,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.HTTPClientAttributesFromHTTPRequest(req)...))
+	defer span.End()
+	req = req.WithContext(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	start := time.Now()
 	httpResp, err := t.client.Do(req)
 	if err != nil {
+		t.metrics.ParserCall(endpoint, "error", time.Since(start))
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
 		return 0, fmt.Errorf("failed request %v: %w", req, err)
 	}
 	defer httpResp.Body.Close()
+	t.metrics.ParserCall(endpoint, strconv.Itoa(httpResp.StatusCode), time.Since(start))
+	span.SetAttributes(semconv.HTTPStatusCodeKey.Int(httpResp.StatusCode))
+	span.SetStatus(semconv.SpanStatusFromHTTPStatusCodeAndSpanKind(httpResp.StatusCode, trace.SpanKindClient))
 
 	byt, err := ioutil.ReadAll(httpResp.Body)
 	if err != nil {
@@ -155,7 +216,7 @@ func (t *tripAPI) getWarnings(errors []apiError) []string {
 	return all
 }
 
-func (t *tripAPI) CreateJob(mail *model.Email) (*model.EmailParsingJob, error) {
+func (t *tripAPI) CreateJob(ctx context.Context, mail *model.Email) (*model.EmailParsingJob, error) {
 	content := strings.ReplaceAll(mail.Content, "-", "+")
 	content = strings.ReplaceAll(content, "_", "/")
 	payload := createRequest{
@@ -169,13 +230,13 @@ func (t *tripAPI) CreateJob(mail *model.Email) (*model.EmailParsingJob, error) {
 		return nil, fmt.Errorf("failed to encode create request for %v: %w", mail, err)
 	}
 
-	req, err := t.buildRequest(http.MethodPost, ResourceJobs, bytes.NewReader(byt))
+	req, err := t.buildRequest(ctx, http.MethodPost, ResourceJobs, bytes.NewReader(byt))
 	if err != nil {
 		return nil, fmt.Errorf("cannot create request for %v: %w", mail, err)
 	}
 
 	var body createResponse
-	code, err := t.doRequest(req, &body)
+	code, err := t.doRequest(ctx, endpointCreate, req, &body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process create request for %v: %w", mail, err)
 	}
@@ -191,15 +252,15 @@ func (t *tripAPI) CreateJob(mail *model.Email) (*model.EmailParsingJob, error) {
 	}, nil
 }
 
-func (t *tripAPI) GetJobStatus(job model.EmailParsingJob) (*model.EmailParsingJob, error) {
+func (t *tripAPI) GetJobStatus(ctx context.Context, job model.EmailParsingJob) (*model.EmailParsingJob, error) {
 	res := fmt.Sprintf("%s/%s", ResourceJobs, job.ID)
-	req, err := t.buildRequest(http.MethodGet, res, nil)
+	req, err := t.buildRequest(ctx, http.MethodGet, res, nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create request for %s: %w", job, err)
 	}
 
 	var body statusResponse
-	code, err := t.doRequest(req, &body)
+	code, err := t.doRequest(ctx, endpointStatus, req, &body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process status request for %v: %w", job, err)
 	}
@@ -217,15 +278,15 @@ func (t *tripAPI) GetJobStatus(job model.EmailParsingJob) (*model.EmailParsingJo
 	}, nil
 }
 
-func (t *tripAPI) GetJobResult(job model.EmailParsingJob) (*model.EmailParsingJob, error) {
+func (t *tripAPI) GetJobResult(ctx context.Context, job model.EmailParsingJob) (*model.EmailParsingJob, error) {
 	res := fmt.Sprintf("%s/%s/result", ResourceJobs, job.ID)
-	req, err := t.buildRequest(http.MethodGet, res, nil)
+	req, err := t.buildRequest(ctx, http.MethodGet, res, nil)
 	if err != nil {
 		return nil, fmt.Errorf("cannot create request for %s: %w", job, err)
 	}
 
 	var body resultResponse
-	code, err := t.doRequest(req, &body)
+	code, err := t.doRequest(ctx, endpointResult, req, &body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to process status request for %v: %w", job, err)
 	}
@@ -1,7 +1,9 @@
 package amadeus
 
 import (
+	"amadeus-trip-parser/internal/domain"
 	"amadeus-trip-parser/internal/domain/model"
+	"context"
 	"os"
 	"testing"
 )
@@ -60,7 +62,7 @@ func TestNewAmadeusTripAPI(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t1 *testing.T) {
-			if _, err := NewAmadeusTripAPI(tt.args.config["url"], tt.args.config["key"], tt.args.config["secret"]);
+			if _, err := NewAmadeusTripAPI(tt.args.config["url"], tt.args.config["key"], tt.args.config["secret"], domain.NopMetrics{});
 				(err != nil) != tt.wantErr {
 				t1.Errorf("Connect() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -80,7 +82,7 @@ func Test_tripAPI_CreateJob(t *testing.T) {
 		return
 	}
 
-	api, err := NewAmadeusTripAPI(config["url"], config["key"], config["secret"])
+	api, err := NewAmadeusTripAPI(config["url"], config["key"], config["secret"], domain.NopMetrics{})
 	if err != nil {
 		t.Errorf("cannot create amadeus API: %s", err)
 	}
@@ -129,7 +131,7 @@ func Test_tripAPI_CreateJob(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t1 *testing.T) {
-			got, err := api.CreateJob(tt.args.mail)
+			got, err := api.CreateJob(context.Background(), tt.args.mail)
 			if (err != nil) != tt.wantErr {
 				t1.Errorf("CreateJob() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -61,15 +61,54 @@ func (c converter) getTrip(d resultResponseData) (model.Trip, error) {
 		steps = append(steps, ts...)
 	}
 
+	start, end := d.Start.DateTime.Time, d.End.DateTime.Time
+	// bounds are often missing from the aggregated trip, use the steps instead
+	for _, ts := range steps {
+		if start.IsZero() || ts.DateTime.Before(start) {
+			start = ts.DateTime
+		}
+		if end.IsZero() || ts.DateTime.After(end) {
+			end = ts.DateTime
+		}
+	}
+
 	return model.Trip{
-		ID:        uuid.New().String(),
-		Reference: d.Reference,
-		Start:     d.Start.DateTime.Time,
-		End:       d.End.DateTime.Time,
-		TripSteps: steps,
+		ID:         uuid.New().String(),
+		Reference:  d.Reference,
+		Start:      start,
+		End:        end,
+		TripSteps:  steps,
+		Travellers: c.getTravellers(d),
 	}, nil
 }
 
+func (c converter) getTravellers(d resultResponseData) []model.Traveller {
+	var travellers []model.Traveller
+	for _, s := range d.Stakeholders {
+		if len(s.Names) == 0 || !isTraveller(s.Roles) {
+			continue
+		}
+		travellers = append(travellers, model.Traveller{
+			ID:        uuid.New().String(),
+			FirstName: s.Names[0].FirstName,
+			LastName:  s.Names[0].LastName,
+		})
+	}
+	return travellers
+}
+
+func isTraveller(roles []string) bool {
+	if len(roles) == 0 {
+		return true
+	}
+	for _, r := range roles {
+		if r == "TRAVELLER" {
+			return true
+		}
+	}
+	return false
+}
+
 type tripStepConverter interface {
 	getTripStep(interface{}) ([]model.TripStep, error)
 }
@@ -97,18 +136,22 @@ func (s stepConverter) getTripStep(i interface{}) ([]model.TripStep, error) {
 		a := i.(*AirProduct)
 		return []model.TripStep{
 			{
-				ID:          uuid.New().String(),
-				Type:        model.TripStepTypeFlightStart,
-				DateTime:    a.Start.DateTime.Time,
-				Location:    addrConv.String(a.Start.Address),
-				Description: fmt.Sprintf("Flight start with %s", a.ServiceProvider.Name),
+				ID:           uuid.New().String(),
+				Type:         model.TripStepTypeFlightStart,
+				DateTime:     a.Start.DateTime.Time,
+				Location:     addrConv.String(a.Start.Address),
+				LocationCode: a.Start.LocationCode,
+				Description:  fmt.Sprintf("Flight start with %s", a.ServiceProvider.Name),
+				Provider:     a.ServiceProvider.Name,
 			},
 			{
-				ID:          uuid.New().String(),
-				Type:        model.TripStepTypeFlightEnd,
-				DateTime:    a.End.DateTime.Time,
-				Location:    addrConv.String(a.End.Address),
-				Description: fmt.Sprintf("Flight end with %s", a.ServiceProvider.Name),
+				ID:           uuid.New().String(),
+				Type:         model.TripStepTypeFlightEnd,
+				DateTime:     a.End.DateTime.Time,
+				Location:     addrConv.String(a.End.Address),
+				LocationCode: a.End.LocationCode,
+				Description:  fmt.Sprintf("Flight end with %s", a.ServiceProvider.Name),
+				Provider:     a.ServiceProvider.Name,
 			},
 		}, nil
 	case *HotelProduct:
@@ -119,6 +162,7 @@ func (s stepConverter) getTripStep(i interface{}) ([]model.TripStep, error) {
 			DateTime:    h.Start.DateTime.Time,
 			Location:    addrConv.String(h.Start.Address),
 			Description: fmt.Sprintf("Hotel at %s", h.ServiceProvider.Name),
+			Provider:    h.ServiceProvider.Name,
 		}}, nil
 	default:
 		return []model.TripStep{}, fmt.Errorf("cannot convert type %T to TripStep", i)
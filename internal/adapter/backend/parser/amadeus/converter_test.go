@@ -22,6 +22,20 @@ func compareTrip(a model.Trip, b model.Trip, t *testing.T) bool {
 		t.Logf("reference are different: %s != %s", a.Reference, b.Reference)
 		return false
 	}
+	if !a.Start.Equal(b.Start) || !a.End.Equal(b.End) {
+		t.Logf("bounds are different: %s-%s != %s-%s", a.Start, a.End, b.Start, b.End)
+		return false
+	}
+	if len(a.Travellers) != len(b.Travellers) {
+		t.Logf("not the same number of travellers: %d != %d", len(a.Travellers), len(b.Travellers))
+		return false
+	}
+	for i, at := range a.Travellers {
+		if at.FirstName != b.Travellers[i].FirstName || at.LastName != b.Travellers[i].LastName {
+			t.Logf("travellers are different: %v != %v", at, b.Travellers[i])
+			return false
+		}
+	}
 	if len(a.TripSteps) != len(b.TripSteps) {
 		t.Logf("not the same number of steps: %d != %d", len(a.TripSteps), len(b.TripSteps))
 		return false
@@ -36,6 +50,14 @@ func compareTrip(a model.Trip, b model.Trip, t *testing.T) bool {
 			t.Logf("step times are different: %s != %s", ats.DateTime, bts.DateTime)
 			return false
 		}
+		if ats.LocationCode != bts.LocationCode {
+			t.Logf("step location codes are different: %s != %s", ats.LocationCode, bts.LocationCode)
+			return false
+		}
+		if ats.Provider != bts.Provider {
+			t.Logf("step providers are different: %s != %s", ats.Provider, bts.Provider)
+			return false
+		}
 	}
 	return true
 }
@@ -126,22 +148,36 @@ func Test_converter_getTrip(t *testing.T) {
 			},
 			want: model.Trip{
 				Reference: "XXX999",
+				Start:     time.Date(2020, 04, 06, 16, 10, 00, 0, time.UTC),
+				End:       time.Date(2020, 04, 12, 15, 30, 00, 0, time.UTC),
+				Travellers: []model.Traveller{
+					{FirstName: "JOHN", LastName: "SMITH"},
+					{FirstName: "MARY", LastName: "SMITH"},
+				},
 				TripSteps: []model.TripStep{
 					{
-						Type:     model.TripStepTypeFlightStart,
-						DateTime: time.Date(2020, 04, 06, 16, 10, 00, 0, time.UTC),
+						Type:         model.TripStepTypeFlightStart,
+						DateTime:     time.Date(2020, 04, 06, 16, 10, 00, 0, time.UTC),
+						LocationCode: "ORY",
+						Provider:     "TRANSAVIA FRANCE",
 					},
 					{
-						Type:     model.TripStepTypeFlightEnd,
-						DateTime: time.Date(2020, 04, 06, 17, 45, 00, 0, time.UTC),
+						Type:         model.TripStepTypeFlightEnd,
+						DateTime:     time.Date(2020, 04, 06, 17, 45, 00, 0, time.UTC),
+						LocationCode: "TUN",
+						Provider:     "TRANSAVIA FRANCE",
 					},
 					{
-						Type:     model.TripStepTypeFlightStart,
-						DateTime: time.Date(2020, 04, 12, 11, 55, 00, 0, time.UTC),
+						Type:         model.TripStepTypeFlightStart,
+						DateTime:     time.Date(2020, 04, 12, 11, 55, 00, 0, time.UTC),
+						LocationCode: "TUN",
+						Provider:     "TRANSAVIA FRANCE",
 					},
 					{
-						Type:     model.TripStepTypeFlightEnd,
-						DateTime: time.Date(2020, 04, 12, 15, 30, 00, 0, time.UTC),
+						Type:         model.TripStepTypeFlightEnd,
+						DateTime:     time.Date(2020, 04, 12, 15, 30, 00, 0, time.UTC),
+						LocationCode: "ORY",
+						Provider:     "TRANSAVIA FRANCE",
 					},
 				},
 			},
@@ -0,0 +1,120 @@
+package ics
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// property is a content line of an event, such as DTSTART;TZID=Europe/Paris:20200406T161000
+type property struct {
+	params map[string]string
+	value  string
+}
+
+// event holds the properties of a VEVENT by name, the first of a repeated property
+type event map[string]property
+
+// readEvents reads the VEVENT components of an iCalendar object, content lines are unfolded first
+func readEvents(calendar string) []event {
+	unfolded := strings.NewReplacer("\r\n ", "", "\r\n\t", "", "\n ", "", "\n\t", "").Replace(calendar)
+	var events []event
+	var current event
+	for _, line := range strings.Split(unfolded, "\n") {
+		line = strings.TrimRight(line, "\r")
+		name, p, ok := parseLine(line)
+		if !ok {
+			continue
+		}
+		switch {
+		case name == "BEGIN" && strings.EqualFold(p.value, "VEVENT"):
+			current = event{}
+		case name == "END" && strings.EqualFold(p.value, "VEVENT") && current != nil:
+			events = append(events, current)
+			current = nil
+		case current != nil:
+			if _, ok := current[name]; !ok {
+				current[name] = p
+			}
+		}
+	}
+	return events
+}
+
+// parseLine splits a content line in its name, parameters and value, quoted parameter values are not split
+func parseLine(line string) (string, property, bool) {
+	quoted := false
+	colon := -1
+	for i, r := range line {
+		if r == '"' {
+			quoted = !quoted
+		}
+		if r == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+	if colon <= 0 {
+		return "", property{}, false
+	}
+	fields := strings.Split(line[:colon], ";")
+	p := property{params: map[string]string{}, value: line[colon+1:]}
+	for _, param := range fields[1:] {
+		if i := strings.Index(param, "="); i > 0 {
+			p.params[strings.ToUpper(param[:i])] = strings.Trim(param[i+1:], `"`)
+		}
+	}
+	return strings.ToUpper(fields[0]), p, true
+}
+
+// text returns the unescaped TEXT value of a property
+func (e event) text(name string) string {
+	return strings.NewReplacer(`\n`, "\n", `\N`, "\n", `\,`, ",", `\;`, ";", `\\`, `\`).Replace(e[name].value)
+}
+
+// date is true for a DATE value, such as the start of an all-day event
+func (e event) date(name string) bool {
+	return strings.EqualFold(e[name].params["VALUE"], "DATE") || len(e[name].value) == len("20060102")
+}
+
+// time returns the local time of a DATE or DATE-TIME property in UTC, as the Amadeus parser does, with its time
+// zone. Times in UTC keep the UTC zone, floating times have none.
+func (e event) time(name string) (time.Time, string, error) {
+	p, ok := e[name]
+	if !ok {
+		return time.Time{}, "", fmt.Errorf("missing %s", name)
+	}
+	if e.date(name) {
+		t, err := time.Parse("20060102", p.value)
+		return t, "", err
+	}
+	if strings.HasSuffix(p.value, "Z") {
+		t, err := time.Parse("20060102T150405Z", p.value)
+		return t, "UTC", err
+	}
+	t, err := time.Parse("20060102T150405", p.value)
+	if err != nil {
+		return time.Time{}, "", fmt.Errorf("invalid %s: %w", name, err)
+	}
+	zone := p.params["TZID"]
+	if _, err := time.LoadLocation(zone); err != nil {
+		// custom TZIDs of the VTIMEZONE of the calendar are not resolved
+		zone = ""
+	}
+	return t, zone, nil
+}
+
+// organizer returns the common name of the organizer of the event, such as the airline of a flight
+func (e event) organizer() string {
+	return e["ORGANIZER"].params["CN"]
+}
+
+// reference returns the booking reference given in the summary or the description
+func (e event) reference() string {
+	for _, name := range []string{"SUMMARY", "DESCRIPTION"} {
+		if m := reference.FindStringSubmatch(e.text(name)); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,143 @@
+package ics
+
+import (
+	"amadeus-trip-parser/internal/adapter/backend/parser/local"
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ParserName names the parser in the jobs and their errors
+const ParserName = "ics"
+
+// ErrorNoEvent is returned for emails without calendar event
+var ErrorNoEvent = errors.New("no flight or hotel calendar event found")
+
+var (
+	// airportCodes are the IATA codes of a flight summary, such as 'AF1234 Paris (ORY) - Tunis (TUN)' or 'ORY → TUN'
+	airportCodes = regexp.MustCompile(`\(([A-Z]{3})\)|\b([A-Z]{3})\s*(?:→|->|-|–)\s*([A-Z]{3})\b`)
+	flight       = regexp.MustCompile(`(?i)\b(flight|vol|flug)\b`)
+	hotel        = regexp.MustCompile(`(?i)\b(hotel|hôtel|stay|check-?in|lodging)`)
+	// hotelPrefix is left out of the name of a hotel, such as 'Stay at ' in 'Stay at La Badira'
+	hotelPrefix = regexp.MustCompile(`(?i)^\s*(hotel|hôtel|stay|check-?in|lodging)\s*(at|:|-)?\s*`)
+	// reference is a booking reference given in an event, such as 'Booking reference: XXX999'
+	reference = regexp.MustCompile(
+		`\b(?i:booking|reservation|confirmation)\s*(?i:reference|number|code|ref)?\s*[:#]?\s*([A-Z0-9]{5,10})\b`)
+)
+
+// NewParser parses the iCalendar events that airlines and hotels attach to their confirmation emails, as
+// text/calendar parts or .ics attachments
+func NewParser() domain.EmailParser {
+	return local.NewParser(ParserName, Parse)
+}
+
+// Parse returns the trip of the flight and hotel events of an email, other events are reported as warnings
+func Parse(email *model.Email) (model.Trip, []string, error) {
+	parts, err := local.Parts(email)
+	if err != nil {
+		return model.Trip{}, nil, err
+	}
+	var warnings []string
+	trip := model.Trip{ID: uuid.New().String()}
+	for _, p := range parts {
+		if p.MediaType != "text/calendar" && p.MediaType != "application/ics" &&
+			!strings.HasSuffix(strings.ToLower(p.Filename), ".ics") {
+			continue
+		}
+		for _, ev := range readEvents(string(p.Body)) {
+			steps, err := eventSteps(ev)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("event %q left out: %s", ev.text("SUMMARY"), err))
+				continue
+			}
+			if trip.Reference == "" {
+				trip.Reference = ev.reference()
+			}
+			trip.TripSteps = append(trip.TripSteps, steps...)
+		}
+	}
+	if len(trip.TripSteps) == 0 {
+		return model.Trip{}, warnings, ErrorNoEvent
+	}
+	sort.SliceStable(trip.TripSteps, func(i, j int) bool {
+		return trip.TripSteps[i].DateTime.Before(trip.TripSteps[j].DateTime)
+	})
+	trip.Start, trip.End = trip.TripSteps[0].DateTime, trip.TripSteps[len(trip.TripSteps)-1].DateTime
+	return trip, warnings, nil
+}
+
+// eventSteps returns the start and end steps of a flight, or the step of a hotel stay
+func eventSteps(ev event) ([]model.TripStep, error) {
+	summary, location := ev.text("SUMMARY"), ev.text("LOCATION")
+	start, startZone, err := ev.time("DTSTART")
+	if err != nil {
+		return nil, err
+	}
+	var codes []string
+	for _, m := range airportCodes.FindAllStringSubmatch(summary, -1) {
+		for _, code := range m[1:] {
+			if code != "" {
+				codes = append(codes, code)
+			}
+		}
+	}
+	switch {
+	case len(codes) >= 2 || flight.MatchString(summary):
+		end, endZone, err := ev.time("DTEND")
+		if err != nil {
+			return nil, err
+		}
+		from, to := location, ""
+		var fromCode, toCode string
+		if len(codes) >= 2 {
+			fromCode, toCode = codes[0], codes[1]
+			if from == "" {
+				from = fromCode
+			}
+			to = toCode
+		}
+		return []model.TripStep{
+			{
+				ID:           uuid.New().String(),
+				Type:         model.TripStepTypeFlightStart,
+				DateTime:     start,
+				Location:     from,
+				LocationCode: fromCode,
+				TimeZone:     startZone,
+				Description:  fmt.Sprintf("Flight start with %s", summary),
+				Provider:     ev.organizer(),
+			},
+			{
+				ID:           uuid.New().String(),
+				Type:         model.TripStepTypeFlightEnd,
+				DateTime:     end,
+				Location:     to,
+				LocationCode: toCode,
+				TimeZone:     endZone,
+				Description:  fmt.Sprintf("Flight end with %s", summary),
+				Provider:     ev.organizer(),
+			},
+		}, nil
+	case hotel.MatchString(summary) || ev.date("DTSTART"):
+		name := hotelPrefix.ReplaceAllString(summary, "")
+		if name == "" {
+			name = location
+		}
+		return []model.TripStep{{
+			ID:          uuid.New().String(),
+			Type:        model.TripStepTypeHotel,
+			DateTime:    start,
+			Location:    location,
+			TimeZone:    startZone,
+			Description: fmt.Sprintf("Hotel at %s", name),
+			Provider:    name,
+		}}, nil
+	default:
+		return nil, errors.New("neither a flight nor a hotel stay")
+	}
+}
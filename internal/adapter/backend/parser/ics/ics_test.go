@@ -0,0 +1,60 @@
+package ics
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	raw, err := ioutil.ReadFile("testdata/trip.eml")
+	require.NoError(t, err)
+	email, err := model.NewEmail(raw)
+	require.NoError(t, err)
+
+	trip, warnings, err := Parse(email)
+	require.NoError(t, err)
+	assert.Equal(t, "XXX999", trip.Reference)
+	require.Len(t, trip.TripSteps, 3)
+	assert.Equal(t, model.TripStep{ID: trip.TripSteps[0].ID, Type: model.TripStepTypeFlightStart,
+		DateTime: time.Date(2020, 4, 6, 16, 10, 0, 0, time.UTC), Location: "Paris Orly", LocationCode: "ORY",
+		TimeZone: "Europe/Paris", Description: "Flight start with TO4122 Paris (ORY) - Tunis (TUN)",
+		Provider: "Tunisair"},
+		trip.TripSteps[0])
+	assert.Equal(t, model.TripStep{ID: trip.TripSteps[1].ID, Type: model.TripStepTypeFlightEnd,
+		DateTime: time.Date(2020, 4, 6, 17, 45, 0, 0, time.UTC), Location: "TUN", LocationCode: "TUN",
+		TimeZone: "Africa/Tunis", Description: "Flight end with TO4122 Paris (ORY) - Tunis (TUN)",
+		Provider: "Tunisair"},
+		trip.TripSteps[1])
+	assert.Equal(t, model.TripStep{ID: trip.TripSteps[2].ID, Type: model.TripStepTypeHotel,
+		DateTime: time.Date(2020, 4, 7, 0, 0, 0, 0, time.UTC), Location: "Route Touristique, 8050 Hammamet, Tunisia",
+		Description: "Hotel at La Badira", Provider: "La Badira"}, trip.TripSteps[2])
+	assert.Equal(t, trip.TripSteps[0].DateTime, trip.Start)
+	assert.Equal(t, trip.TripSteps[2].DateTime, trip.End)
+	assert.Equal(t, []string{`event "Dinner with the team, a long summary folded over two lines" left out: ` +
+		`neither a flight nor a hotel stay`}, warnings)
+
+	email, err = model.NewEmail([]byte("Subject: Hello\r\n\r\nHello\r\n"))
+	require.NoError(t, err)
+	_, _, err = Parse(email)
+	assert.ErrorIs(t, err, ErrorNoEvent)
+}
+
+func Test_readEvents(t *testing.T) {
+	events := readEvents("BEGIN:VEVENT\nSUMMARY:Flight\nDTSTART;TZID=\"Custom: Zone\":20200406T161000\n" +
+		"DTEND:20200406T154500Z\nEND:VEVENT\n")
+	require.Len(t, events, 1)
+	start, zone, err := events[0].time("DTSTART")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 4, 6, 16, 10, 0, 0, time.UTC), start)
+	assert.Empty(t, zone, "zones unknown to the time zone database are left out")
+	end, zone, err := events[0].time("DTEND")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 4, 6, 15, 45, 0, 0, time.UTC), end)
+	assert.Equal(t, "UTC", zone)
+	_, _, err = events[0].time("DTSTAMP")
+	assert.Error(t, err)
+}
@@ -0,0 +1,130 @@
+package jsonld
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// reservation is a schema.org Reservation, with the properties of the flight and lodging ones
+type reservation struct {
+	Type              string  `json:"@type"`
+	ReservationNumber string  `json:"reservationNumber"`
+	UnderName         persons `json:"underName"`
+	ReservationFor    struct {
+		// Flight
+		Airline struct {
+			Name     string `json:"name"`
+			IATACode string `json:"iataCode"`
+		} `json:"airline"`
+		DepartureAirport airport `json:"departureAirport"`
+		DepartureTime    string  `json:"departureTime"`
+		ArrivalAirport   airport `json:"arrivalAirport"`
+		ArrivalTime      string  `json:"arrivalTime"`
+		// LodgingBusiness
+		Name    string  `json:"name"`
+		Address address `json:"address"`
+	} `json:"reservationFor"`
+	CheckinTime string `json:"checkinTime"`
+	CheckinDate string `json:"checkinDate"`
+}
+
+type airport struct {
+	Name     string `json:"name"`
+	IATACode string `json:"iataCode"`
+}
+
+func (a airport) location() string {
+	if a.Name != "" {
+		return a.Name
+	}
+	return a.IATACode
+}
+
+// address is a schema.org PostalAddress, or a plain text one
+type address struct {
+	Text            string  `json:"-"`
+	StreetAddress   string  `json:"streetAddress"`
+	AddressLocality string  `json:"addressLocality"`
+	PostalCode      string  `json:"postalCode"`
+	AddressCountry  country `json:"addressCountry"`
+}
+
+func (a *address) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, &a.Text); err == nil {
+		return nil
+	}
+	type postalAddress address
+	return json.Unmarshal(b, (*postalAddress)(a))
+}
+
+// String formats the address as the Amadeus parser does, 'street, postal code locality, country'
+func (a address) String() string {
+	if a.Text != "" {
+		return a.Text
+	}
+	var s []string
+	for _, v := range []string{a.StreetAddress, strings.TrimSpace(a.PostalCode + " " + a.AddressLocality),
+		string(a.AddressCountry)} {
+		if v != "" {
+			s = append(s, v)
+		}
+	}
+	return strings.Join(s, ", ")
+}
+
+// country is the name of a schema.org Country, or a plain text one
+type country string
+
+func (c *country) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err == nil {
+		*c = country(name)
+		return nil
+	}
+	var place struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(b, &place); err != nil {
+		return err
+	}
+	*c = country(place.Name)
+	return nil
+}
+
+// persons are the schema.org Person, or the array of them, a reservation is under
+type persons []person
+
+type person struct {
+	Name       string `json:"name"`
+	GivenName  string `json:"givenName"`
+	FamilyName string `json:"familyName"`
+}
+
+func (p *persons) UnmarshalJSON(b []byte) error {
+	var one person
+	if err := json.Unmarshal(b, &one); err == nil {
+		*p = persons{one}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]person)(p))
+}
+
+// names returns the first and last names of the persons, a full name is split on its last space
+func (p persons) names() [][2]string {
+	var names [][2]string
+	for _, n := range p {
+		first, last := n.GivenName, n.FamilyName
+		if first == "" && last == "" {
+			name := strings.TrimSpace(n.Name)
+			if i := strings.LastIndex(name, " "); i > 0 {
+				first, last = name[:i], name[i+1:]
+			} else {
+				last = name
+			}
+		}
+		if first != "" || last != "" {
+			names = append(names, [2]string{first, last})
+		}
+	}
+	return names
+}
@@ -0,0 +1,186 @@
+package jsonld
+
+import (
+	"amadeus-trip-parser/internal/adapter/backend/parser/local"
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ParserName names the parser in the jobs and their errors
+const ParserName = "jsonld"
+
+// ErrorNoReservation is returned for emails without schema.org reservation markup
+var ErrorNoReservation = errors.New("no flight or lodging reservation markup found")
+
+// scripts are the JSON-LD blocks of an HTML part
+var scripts = regexp.MustCompile(`(?is)<script[^>]+type\s*=\s*["']?application/ld\+json["']?[^>]*>(.*?)</script>`)
+
+// NewParser parses the schema.org FlightReservation and LodgingReservation markup that airlines, hotels and
+// travel agencies embed as JSON-LD in the HTML of their confirmation emails
+func NewParser() domain.EmailParser {
+	return local.NewParser(ParserName, Parse)
+}
+
+// Parse returns the trip of the reservations of an email, reservations of other types are reported as warnings
+func Parse(email *model.Email) (model.Trip, []string, error) {
+	parts, err := local.Parts(email)
+	if err != nil {
+		return model.Trip{}, nil, err
+	}
+	var reservations []reservation
+	var warnings []string
+	for _, p := range parts {
+		if p.MediaType != "text/html" {
+			continue
+		}
+		for _, m := range scripts.FindAllSubmatch(p.Body, -1) {
+			found, err := readReservations(m[1])
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("invalid JSON-LD block: %s", err))
+				continue
+			}
+			reservations = append(reservations, found...)
+		}
+	}
+
+	trip := model.Trip{ID: uuid.New().String()}
+	travellers := map[string]bool{}
+	for _, r := range reservations {
+		var steps []model.TripStep
+		switch r.Type {
+		case "FlightReservation":
+			steps, err = flightSteps(r)
+		case "LodgingReservation":
+			steps, err = lodgingSteps(r)
+		default:
+			warnings = append(warnings, fmt.Sprintf("%s %s left out, only flights and lodgings are read", r.Type,
+				r.ReservationNumber))
+			continue
+		}
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("%s %s left out: %s", r.Type, r.ReservationNumber, err))
+			continue
+		}
+		if trip.Reference == "" {
+			trip.Reference = r.ReservationNumber
+		}
+		trip.TripSteps = append(trip.TripSteps, steps...)
+		for _, name := range r.UnderName.names() {
+			if !travellers[name[0]+" "+name[1]] {
+				travellers[name[0]+" "+name[1]] = true
+				trip.Travellers = append(trip.Travellers,
+					model.Traveller{ID: uuid.New().String(), FirstName: name[0], LastName: name[1]})
+			}
+		}
+	}
+	if len(trip.TripSteps) == 0 {
+		return model.Trip{}, warnings, ErrorNoReservation
+	}
+	sort.SliceStable(trip.TripSteps, func(i, j int) bool {
+		return trip.TripSteps[i].DateTime.Before(trip.TripSteps[j].DateTime)
+	})
+	trip.Start, trip.End = trip.TripSteps[0].DateTime, trip.TripSteps[len(trip.TripSteps)-1].DateTime
+	return trip, warnings, nil
+}
+
+// readReservations reads the items of a JSON-LD block, an object, an array or a @graph of objects
+func readReservations(b []byte) ([]reservation, error) {
+	var items []json.RawMessage
+	if err := json.Unmarshal(b, &items); err != nil {
+		items = []json.RawMessage{b}
+	}
+	var reservations []reservation
+	for _, item := range items {
+		var graph struct {
+			Graph []reservation `json:"@graph"`
+		}
+		if err := json.Unmarshal(item, &graph); err != nil {
+			return nil, err
+		}
+		if len(graph.Graph) > 0 {
+			reservations = append(reservations, graph.Graph...)
+			continue
+		}
+		var r reservation
+		if err := json.Unmarshal(item, &r); err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, r)
+	}
+	return reservations, nil
+}
+
+func flightSteps(r reservation) ([]model.TripStep, error) {
+	f := r.ReservationFor
+	start, err := parseTime(f.DepartureTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid departure time: %w", err)
+	}
+	end, err := parseTime(f.ArrivalTime)
+	if err != nil {
+		return nil, fmt.Errorf("invalid arrival time: %w", err)
+	}
+	airline := f.Airline.Name
+	if airline == "" {
+		airline = f.Airline.IATACode
+	}
+	return []model.TripStep{
+		{
+			ID:           uuid.New().String(),
+			Type:         model.TripStepTypeFlightStart,
+			DateTime:     start,
+			Location:     f.DepartureAirport.location(),
+			LocationCode: f.DepartureAirport.IATACode,
+			Description:  fmt.Sprintf("Flight start with %s", airline),
+			Provider:     airline,
+		},
+		{
+			ID:           uuid.New().String(),
+			Type:         model.TripStepTypeFlightEnd,
+			DateTime:     end,
+			Location:     f.ArrivalAirport.location(),
+			LocationCode: f.ArrivalAirport.IATACode,
+			Description:  fmt.Sprintf("Flight end with %s", airline),
+			Provider:     airline,
+		},
+	}, nil
+}
+
+func lodgingSteps(r reservation) ([]model.TripStep, error) {
+	checkin := r.CheckinTime
+	if checkin == "" {
+		checkin = r.CheckinDate
+	}
+	start, err := parseTime(checkin)
+	if err != nil {
+		return nil, fmt.Errorf("invalid checkin time: %w", err)
+	}
+	return []model.TripStep{{
+		ID:          uuid.New().String(),
+		Type:        model.TripStepTypeHotel,
+		DateTime:    start,
+		Location:    r.ReservationFor.Address.String(),
+		Description: fmt.Sprintf("Hotel at %s", r.ReservationFor.Name),
+		Provider:    r.ReservationFor.Name,
+	}}, nil
+}
+
+// parseTime returns the local time of a schema.org DateTime or Date in UTC, as the Amadeus parser does, so that
+// steps are stored at the time written on the booking
+func parseTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+		t, err := time.Parse(layout, strings.TrimSpace(s))
+		if err == nil {
+			return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse %q", s)
+}
@@ -0,0 +1,59 @@
+package jsonld
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func readEmail(t *testing.T, file string) *model.Email {
+	raw, err := ioutil.ReadFile(file)
+	require.NoError(t, err)
+	email, err := model.NewEmail(raw)
+	require.NoError(t, err)
+	return email
+}
+
+func TestParse(t *testing.T) {
+	trip, warnings, err := Parse(readEmail(t, "testdata/reservations.eml"))
+	require.NoError(t, err)
+	assert.Equal(t, "XXX999", trip.Reference)
+	assert.Equal(t, time.Date(2020, 4, 6, 16, 10, 0, 0, time.UTC), trip.Start, "local time of the booking")
+	assert.Equal(t, time.Date(2020, 4, 7, 15, 0, 0, 0, time.UTC), trip.End)
+	require.Len(t, trip.TripSteps, 3)
+	assert.Equal(t, model.TripStep{ID: trip.TripSteps[0].ID, Type: model.TripStepTypeFlightStart,
+		DateTime: time.Date(2020, 4, 6, 16, 10, 0, 0, time.UTC), Location: "Paris Orly", LocationCode: "ORY",
+		Description: "Flight start with TRANSAVIA FRANCE", Provider: "TRANSAVIA FRANCE"}, trip.TripSteps[0])
+	assert.Equal(t, model.TripStepType(model.TripStepTypeFlightEnd), trip.TripSteps[1].Type)
+	assert.Equal(t, "TUN", trip.TripSteps[1].LocationCode)
+	assert.Equal(t, time.Date(2020, 4, 6, 17, 45, 0, 0, time.UTC), trip.TripSteps[1].DateTime)
+	assert.Equal(t, "Route Touristique, 8050 Hammamet, Tunisia", trip.TripSteps[2].Location)
+	assert.Equal(t, "Hotel at La Badira", trip.TripSteps[2].Description)
+	assert.Equal(t, "La Badira", trip.TripSteps[2].Provider)
+	require.Len(t, trip.Travellers, 2, "travellers of several reservations are listed once")
+	assert.Equal(t, "Alice", trip.Travellers[0].FirstName)
+	assert.Equal(t, "Martin", trip.Travellers[0].LastName)
+	assert.Equal(t, "Bob", trip.Travellers[1].FirstName)
+	assert.Equal(t, []string{"RentalCarReservation C777 left out, only flights and lodgings are read"}, warnings)
+
+	email, err := model.NewEmail([]byte("Subject: Hello\r\nContent-Type: text/html\r\n\r\n<p>Hello</p>\r\n"))
+	require.NoError(t, err)
+	_, _, err = Parse(email)
+	assert.ErrorIs(t, err, ErrorNoReservation)
+}
+
+func Test_readReservations(t *testing.T) {
+	got, err := readReservations([]byte(`{"@context": "http://schema.org", "@graph": [
+		{"@type": "LodgingReservation", "reservationNumber": "H1", "reservationFor": {"address": "Hammamet"}},
+		{"@type": "FlightReservation", "reservationNumber": "F1"}]}`))
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Equal(t, "Hammamet", got[0].ReservationFor.Address.String())
+	assert.Equal(t, "F1", got[1].ReservationNumber)
+
+	_, err = readReservations([]byte(`{"@type": `))
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package local
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"sync"
+)
+
+// ParseFunc reads the trip of an email, with the warnings of the parts left out. An error fails the job.
+type ParseFunc func(email *model.Email) (model.Trip, []string, error)
+
+// ErrorNoReference fails the parsing of an email without booking reference, its trip would be merged with the
+// other trips without reference
+var ErrorNoReference = errors.New("no booking reference found")
+
+// parser runs a parser in process behind the job lifecycle of the remote parsers, the jobs are done on creation
+// and kept in memory until their result is read
+type parser struct {
+	name  string
+	parse ParseFunc
+	mu    sync.Mutex
+	jobs  map[string]model.EmailParsingJob
+}
+
+// NewParser returns the email parser named name parsing emails with parse
+func NewParser(name string, parse ParseFunc) domain.EmailParser {
+	return &parser{name: name, parse: parse, jobs: make(map[string]model.EmailParsingJob)}
+}
+
+func (p *parser) CheckHealth(context.Context) error {
+	return nil
+}
+
+func (p *parser) CreateJob(_ context.Context, mail *model.Email) (*model.EmailParsingJob, error) {
+	job := model.EmailParsingJob{ID: uuid.New().String(), Subject: mail.Subject, Status: model.MailParsingStatusDone}
+	trip, warnings, err := p.parse(mail)
+	if err == nil && trip.Reference == "" {
+		err = ErrorNoReference
+	}
+	if err != nil {
+		job.Status, job.Detail = model.MailParsingStatusError, fmt.Sprintf("%s parser: %s", p.name, err)
+	} else {
+		job.Trip, job.Warnings = trip, warnings
+	}
+	p.mu.Lock()
+	p.jobs[job.ID] = job
+	p.mu.Unlock()
+	return &model.EmailParsingJob{ID: job.ID, Subject: job.Subject, Status: job.Status, Detail: job.Detail}, nil
+}
+
+func (p *parser) GetJobStatus(_ context.Context, job model.EmailParsingJob) (*model.EmailParsingJob, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	done, ok := p.jobs[job.ID]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s parser job %s", p.name, job.ID)
+	}
+	return &model.EmailParsingJob{ID: done.ID, Subject: done.Subject, Status: done.Status, Detail: done.Detail}, nil
+}
+
+// GetJobResult returns the trip of a job once, the job is forgotten afterwards
+func (p *parser) GetJobResult(_ context.Context, job model.EmailParsingJob) (*model.EmailParsingJob, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	done, ok := p.jobs[job.ID]
+	if !ok {
+		return nil, fmt.Errorf("unknown %s parser job %s", p.name, job.ID)
+	}
+	delete(p.jobs, job.ID)
+	return &done, nil
+}
@@ -0,0 +1,63 @@
+package local
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	p := NewParser("test", func(email *model.Email) (model.Trip, []string, error) {
+		switch email.Subject {
+		case "trip":
+			return model.Trip{Reference: "XXX999"}, []string{"car left out"}, nil
+		case "no reference":
+			return model.Trip{}, nil, nil
+		default:
+			return model.Trip{}, nil, errors.New("no trip")
+		}
+	})
+	ctx := context.Background()
+
+	job, err := p.CreateJob(ctx, &model.Email{Subject: "trip"})
+	require.NoError(t, err)
+	assert.Equal(t, model.MailParsingStatus(model.MailParsingStatusDone), job.Status)
+	status, err := p.GetJobStatus(ctx, *job)
+	require.NoError(t, err)
+	assert.Equal(t, job.Status, status.Status)
+	result, err := p.GetJobResult(ctx, *job)
+	require.NoError(t, err)
+	assert.Equal(t, "XXX999", result.Trip.Reference)
+	assert.Equal(t, []string{"car left out"}, result.Warnings)
+	_, err = p.GetJobResult(ctx, *job)
+	assert.Error(t, err, "results are read once")
+
+	for subject, detail := range map[string]string{"other": "test parser: no trip",
+		"no reference": "test parser: " + ErrorNoReference.Error()} {
+		job, err = p.CreateJob(ctx, &model.Email{Subject: subject})
+		require.NoError(t, err)
+		assert.Equal(t, model.MailParsingStatus(model.MailParsingStatusError), job.Status)
+		assert.Equal(t, detail, job.Detail)
+	}
+	_, err = p.GetJobStatus(ctx, model.EmailParsingJob{ID: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestParts(t *testing.T) {
+	raw := "Subject: Parts\r\nContent-Type: multipart/mixed; boundary=B\r\n\r\n" +
+		"--B\r\nContent-Type: text/plain\r\n\r\nHello\r\n" +
+		"--B\r\nContent-Type: text/calendar; name=trip.ics\r\nContent-Transfer-Encoding: base64\r\n\r\n" +
+		"QkVHSU46VkNBTEVOREFS\r\n--B--\r\n"
+	email, err := model.NewEmail([]byte(raw))
+	require.NoError(t, err)
+	parts, err := Parts(email)
+	require.NoError(t, err)
+	assert.Equal(t, []Part{{MediaType: "text/plain", Body: []byte("Hello")},
+		{MediaType: "text/calendar", Filename: "trip.ics", Body: []byte("BEGIN:VCALENDAR")}}, parts)
+
+	_, err = Parts(&model.Email{Content: "%"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,80 @@
+package local
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+)
+
+// Part is a decoded part of an email
+type Part struct {
+	MediaType string
+	// Filename is the name of an attachment, empty for inline parts
+	Filename string
+	Body     []byte
+}
+
+// Parts returns the decoded leaf parts of an email whose raw message is its base64url content, in message order
+func Parts(email *model.Email) ([]Part, error) {
+	raw, err := base64.URLEncoding.DecodeString(email.Content)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode email content: %w", err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read RFC822 message: %w", err)
+	}
+	return readParts(textproto.MIMEHeader(msg.Header), msg.Body)
+}
+
+func readParts(header textproto.MIMEHeader, body io.Reader) ([]Part, error) {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// RFC 2045 default
+		mediaType, params = "text/plain", nil
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		var parts []Part
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			p, err := r.NextPart()
+			if err == io.EOF {
+				return parts, nil
+			}
+			if err != nil {
+				return parts, fmt.Errorf("cannot read %s part: %w", mediaType, err)
+			}
+			nested, err := readParts(p.Header, p)
+			if err != nil {
+				return parts, err
+			}
+			parts = append(parts, nested...)
+		}
+	}
+
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	}
+	b, err := ioutil.ReadAll(body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read %s part: %w", mediaType, err)
+	}
+	part := Part{MediaType: mediaType, Body: b, Filename: params["name"]}
+	if _, dispositionParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil &&
+		dispositionParams["filename"] != "" {
+		part.Filename = dispositionParams["filename"]
+	}
+	return []Part{part}, nil
+}
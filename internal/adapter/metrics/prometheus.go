@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	"time"
+)
+
+const namespace = "tripparser"
+
+type prometheusMetrics struct {
+	emailsFetched    *prometheus.CounterVec
+	jobsCreated      prometheus.Counter
+	jobsFailed       *prometheus.CounterVec
+	jobsCompleted    prometheus.Counter
+	emailToTrip      prometheus.Histogram
+	parserCalls      *prometheus.HistogramVec
+	queueDepth       *prometheus.GaugeVec
+	tokenRefreshes   *prometheus.CounterVec
+	tokenRevocations *prometheus.CounterVec
+	repositoryErrors *prometheus.CounterVec
+}
+
+// NewPrometheusMetrics registers the pipeline metrics, with prometheus.DefaultRegisterer they are
+// exposed on the same endpoint as the HTTP metrics
+func NewPrometheusMetrics(reg prometheus.Registerer) (domain.Metrics, error) {
+	m := &prometheusMetrics{
+		emailsFetched: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "emails_fetched_total",
+			Help:      "Number of emails fetched, by source.",
+		}, []string{"source"}),
+		jobsCreated: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_created_total",
+			Help:      "Number of parser jobs created.",
+		}),
+		jobsFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_failed_total",
+			Help:      "Number of emails whose processing failed, by stage.",
+		}, []string{"stage"}),
+		jobsCompleted: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "jobs_completed_total",
+			Help:      "Number of emails whose trip was stored.",
+		}),
+		emailToTrip: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "email_to_trip_seconds",
+			Help:      "Time from an email being fetched to its trip being stored.",
+			Buckets:   []float64{5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+		}),
+		parserCalls: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "parser_request_duration_seconds",
+			Help:      "Latency of parser API requests, by endpoint and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"endpoint", "status"}),
+		queueDepth: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "queue_depth",
+			Help:      "Number of emails waiting in front of each pipeline stage.",
+		}, []string{"stage"}),
+		tokenRefreshes: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "token_refreshes_total",
+			Help:      "Number of access token refreshes, by source.",
+		}, []string{"source"}),
+		tokenRevocations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "token_revocations_total",
+			Help:      "Number of authorizations found revoked, whose tokens can no longer be refreshed, by source.",
+		}, []string{"source"}),
+		repositoryErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "repository_errors_total",
+			Help:      "Number of failed repository operations, by operation.",
+		}, []string{"operation"}),
+	}
+	for _, c := range []prometheus.Collector{m.emailsFetched, m.jobsCreated, m.jobsFailed, m.jobsCompleted,
+		m.emailToTrip, m.parserCalls, m.queueDepth, m.tokenRefreshes, m.tokenRevocations,
+		m.repositoryErrors} {
+		if err := reg.Register(c); err != nil {
+			return nil, fmt.Errorf("cannot register metric: %w", err)
+		}
+	}
+	return m, nil
+}
+
+func (m *prometheusMetrics) EmailsFetched(source string, count int) {
+	m.emailsFetched.WithLabelValues(source).Add(float64(count))
+}
+
+func (m *prometheusMetrics) JobCreated() {
+	m.jobsCreated.Inc()
+}
+
+func (m *prometheusMetrics) JobFailed(stage model.FailureStage) {
+	m.jobsFailed.WithLabelValues(string(stage)).Inc()
+}
+
+func (m *prometheusMetrics) JobCompleted() {
+	m.jobsCompleted.Inc()
+}
+
+func (m *prometheusMetrics) TripStored(sinceFetched time.Duration) {
+	m.emailToTrip.Observe(sinceFetched.Seconds())
+}
+
+func (m *prometheusMetrics) ParserCall(endpoint string, status string, duration time.Duration) {
+	m.parserCalls.WithLabelValues(endpoint, status).Observe(duration.Seconds())
+}
+
+func (m *prometheusMetrics) QueueDepth(stage string, depth int) {
+	m.queueDepth.WithLabelValues(stage).Set(float64(depth))
+}
+
+func (m *prometheusMetrics) TokenRefreshed(source string) {
+	m.tokenRefreshes.WithLabelValues(source).Inc()
+}
+
+func (m *prometheusMetrics) TokenRevoked(source string) {
+	m.tokenRevocations.WithLabelValues(source).Inc()
+}
+
+func (m *prometheusMetrics) RepositoryError(operation string) {
+	m.repositoryErrors.WithLabelValues(operation).Inc()
+}
@@ -0,0 +1,77 @@
+package metrics
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewPrometheusMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m, err := NewPrometheusMetrics(reg)
+	if err != nil {
+		t.Fatalf("NewPrometheusMetrics() error = %v", err)
+	}
+	if _, err := NewPrometheusMetrics(reg); err == nil {
+		t.Errorf("NewPrometheusMetrics() registered twice without error")
+	}
+
+	m.EmailsFetched("gmail", 3)
+	m.EmailsFetched("upload", 1)
+	m.JobCreated()
+	m.JobFailed(model.FailureStageParse)
+	m.JobCompleted()
+	m.TripStored(90 * time.Second)
+	m.ParserCall("create", "201", 300*time.Millisecond)
+	m.QueueDepth("create", 4)
+	m.QueueDepth("create", 2)
+	m.TokenRefreshed("amadeus")
+	m.TokenRevoked("gmail")
+	m.RepositoryError("queue")
+
+	p := m.(*prometheusMetrics)
+	tests := []struct {
+		name      string
+		collector prometheus.Collector
+		want      float64
+	}{
+		{"emails fetched from gmail", p.emailsFetched.WithLabelValues("gmail"), 3},
+		{"jobs created", p.jobsCreated, 1},
+		{"jobs failed at parse", p.jobsFailed.WithLabelValues(model.FailureStageParse), 1},
+		{"jobs completed", p.jobsCompleted, 1},
+		{"create queue depth", p.queueDepth.WithLabelValues("create"), 2},
+		{"amadeus token refreshes", p.tokenRefreshes.WithLabelValues("amadeus"), 1},
+		{"gmail token revocations", p.tokenRevocations.WithLabelValues("gmail"), 1},
+		{"queue errors", p.repositoryErrors.WithLabelValues("queue"), 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := testutil.ToFloat64(tt.collector); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	want := `
+# HELP tripparser_email_to_trip_seconds Time from an email being fetched to its trip being stored.
+# TYPE tripparser_email_to_trip_seconds histogram
+tripparser_email_to_trip_seconds_bucket{le="5"} 0
+tripparser_email_to_trip_seconds_bucket{le="15"} 0
+tripparser_email_to_trip_seconds_bucket{le="30"} 0
+tripparser_email_to_trip_seconds_bucket{le="60"} 0
+tripparser_email_to_trip_seconds_bucket{le="120"} 1
+tripparser_email_to_trip_seconds_bucket{le="300"} 1
+tripparser_email_to_trip_seconds_bucket{le="600"} 1
+tripparser_email_to_trip_seconds_bucket{le="1800"} 1
+tripparser_email_to_trip_seconds_bucket{le="3600"} 1
+tripparser_email_to_trip_seconds_bucket{le="+Inf"} 1
+tripparser_email_to_trip_seconds_sum 90
+tripparser_email_to_trip_seconds_count 1
+`
+	if err := testutil.CollectAndCompare(p.emailToTrip, strings.NewReader(want)); err != nil {
+		t.Errorf("email to trip histogram: %v", err)
+	}
+}
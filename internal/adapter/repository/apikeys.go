@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+type apiKeyStore struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyStore(db *sql.DB, dialect string) (domain.APIKeyStore, error) {
+	gdb, err := openGorm(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &apiKeyStore{gdb}, nil
+}
+
+func (s *apiKeyStore) Create(key *model.APIKey) error {
+	key.ID = uuid.New().String()
+	if dbc := s.db.Create(key); dbc.Error != nil {
+		return fmt.Errorf("failed creating api key %s of %s: %w", key.Name, key.Subject, dbc.Error)
+	}
+	return nil
+}
+
+func (s *apiKeyStore) GetByHash(hash string) (model.APIKey, error) {
+	var key model.APIKey
+	if dbc := s.db.Where("hash = ?", hash).First(&key); dbc.Error != nil {
+		if dbc.Error == gorm.ErrRecordNotFound {
+			return model.APIKey{}, domain.ErrorNoAPIKey
+		}
+		return model.APIKey{}, fmt.Errorf("failed database query when looking for api key: %w", dbc.Error)
+	}
+	return key, nil
+}
+
+func (s *apiKeyStore) List() ([]model.APIKey, error) {
+	keys := []model.APIKey{}
+	if dbc := s.db.Order("subject, created_at").Find(&keys); dbc.Error != nil {
+		return nil, fmt.Errorf("failed database query when listing api keys: %w", dbc.Error)
+	}
+	return keys, nil
+}
+
+func (s *apiKeyStore) Delete(id string) error {
+	dbc := s.db.Where("id = ?", id).Delete(&model.APIKey{})
+	if dbc.Error != nil {
+		return fmt.Errorf("failed deleting api key %s: %w", id, dbc.Error)
+	}
+	if dbc.RowsAffected == 0 {
+		return domain.ErrorNoAPIKey
+	}
+	return nil
+}
+
+func (s *apiKeyStore) DeleteBySubject(subject string) error {
+	if dbc := s.db.Where("subject = ?", subject).Delete(&model.APIKey{}); dbc.Error != nil {
+		return fmt.Errorf("failed deleting api keys of %s: %w", subject, dbc.Error)
+	}
+	return nil
+}
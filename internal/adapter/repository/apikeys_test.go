@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"testing"
+)
+
+func Test_apiKeyStore(t *testing.T) {
+	for dialect, db := range storeDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			s, err := NewAPIKeyStore(db, dialect)
+			if err != nil {
+				t.Fatalf("NewAPIKeyStore() error = %v", err)
+			}
+
+			key := &model.APIKey{Name: "laptop", Subject: "alice", Role: model.RoleUser, Hash: "5e88"}
+			if err := s.Create(key); err != nil || key.ID == "" {
+				t.Fatalf("Create() error = %v, id = %s", err, key.ID)
+			}
+			if err := s.Create(&model.APIKey{Name: "copy", Subject: "bob", Hash: "5e88"}); err == nil {
+				t.Errorf("Create() of a key with the same hash succeeded")
+			}
+
+			got, err := s.GetByHash("5e88")
+			if err != nil || got.ID != key.ID || got.Subject != "alice" {
+				t.Errorf("GetByHash() got = %v, %v, want key %s", got, err, key.ID)
+			}
+			if _, err := s.GetByHash("a665"); !errors.Is(err, domain.ErrorNoAPIKey) {
+				t.Errorf("GetByHash() of an unknown hash error = %v, want %v", err, domain.ErrorNoAPIKey)
+			}
+			if list, err := s.List(); err != nil || len(list) != 1 {
+				t.Errorf("List() got = %v, %v, want 1 key", list, err)
+			}
+
+			if err := s.Delete(key.ID); err != nil {
+				t.Errorf("Delete() error = %v", err)
+			}
+			if err := s.Delete(key.ID); !errors.Is(err, domain.ErrorNoAPIKey) {
+				t.Errorf("Delete() of a deleted key error = %v, want %v", err, domain.ErrorNoAPIKey)
+			}
+			if _, err := s.GetByHash("5e88"); !errors.Is(err, domain.ErrorNoAPIKey) {
+				t.Errorf("GetByHash() of a deleted key error = %v, want %v", err, domain.ErrorNoAPIKey)
+			}
+		})
+	}
+}
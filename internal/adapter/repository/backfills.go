@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"database/sql"
+	"fmt"
+	"github.com/jinzhu/gorm"
+)
+
+type backfillStore struct {
+	db *gorm.DB
+}
+
+func NewBackfillStore(db *sql.DB, dialect string) (domain.BackfillStore, error) {
+	gdb, err := openGorm(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &backfillStore{gdb}, nil
+}
+
+func (s *backfillStore) Get(id string) (model.Backfill, error) {
+	var b model.Backfill
+	if dbc := s.db.Where("id = ?", id).First(&b); dbc.Error != nil {
+		if dbc.Error == gorm.ErrRecordNotFound {
+			return model.Backfill{}, domain.ErrorNoBackfill
+		}
+		return model.Backfill{}, fmt.Errorf("failed database query when looking for backfill %s: %w", id, dbc.Error)
+	}
+	return b, nil
+}
+
+func (s *backfillStore) Save(backfill *model.Backfill) error {
+	if dbc := s.db.Save(backfill); dbc.Error != nil {
+		return fmt.Errorf("failed saving backfill %s: %w", backfill.ID, dbc.Error)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"testing"
+	"time"
+)
+
+func Test_backfillStore(t *testing.T) {
+	for dialect, db := range storeDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			s, err := NewBackfillStore(db, dialect)
+			if err != nil {
+				t.Fatalf("NewBackfillStore() error = %v", err)
+			}
+
+			if _, err := s.Get("B0"); !errors.Is(err, domain.ErrorNoBackfill) {
+				t.Errorf("Get() error = %v, want %v", err, domain.ErrorNoBackfill)
+			}
+
+			after := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+			b := &model.Backfill{ID: "B0", MailboxID: "M0", Sender: "booking@example.com", After: after,
+				Before: after.AddDate(2, 0, 0), Checkpoint: after}
+			if err := s.Save(b); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			b.Checkpoint = after.AddDate(0, 1, 0)
+			b.Fetched, b.Queued = 3, 2
+			if err := s.Save(b); err != nil {
+				t.Fatalf("Save() of an existing backfill error = %v", err)
+			}
+
+			got, err := s.Get("B0")
+			if err != nil || !got.Checkpoint.Equal(b.Checkpoint) || got.Fetched != 3 || got.Queued != 2 ||
+				got.Sender != b.Sender {
+				t.Errorf("Get() got = %v, %v, want checkpoint %s", got, err, b.Checkpoint)
+			}
+		})
+	}
+}
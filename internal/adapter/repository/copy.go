@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"reflect"
+)
+
+// StoreCopy is the number of rows of a table copied by CopyStores
+type StoreCopy struct {
+	Table  string
+	Rows   int
+	Copied int
+}
+
+// CopyStores copies the processing state, users, mailboxes, API keys, backfills and geocodes of a database into
+// another one, such as from the SQLite database of a replica into the PostgreSQL database shared by replicas. Rows
+// already in dst are kept, so that a copy can be run again. Trips are left out, they are moved by export and import.
+// Both databases must be migrated.
+func CopyStores(src *sql.DB, srcDialect string, dst *sql.DB, dstDialect string) ([]StoreCopy, error) {
+	from, err := openGorm(src, srcDialect)
+	if err != nil {
+		return nil, err
+	}
+	to, err := openGorm(dst, dstDialect)
+	if err != nil {
+		return nil, err
+	}
+	tables := []interface{}{
+		&[]model.User{},
+		&[]model.APIKey{},
+		// tokens are copied as stored, encrypted with the same key
+		&[]model.Mailbox{},
+		&[]model.ProcessedMessage{},
+		&[]model.QueueItem{},
+		&[]model.Failure{},
+		&[]model.Backfill{},
+		&[]model.Geocode{},
+	}
+	var copies []StoreCopy
+	for _, rows := range tables {
+		if dbc := from.Find(rows); dbc.Error != nil {
+			return copies, fmt.Errorf("failed reading %T: %w", rows, dbc.Error)
+		}
+		v := reflect.ValueOf(rows).Elem()
+		c := StoreCopy{Table: to.NewScope(reflect.New(v.Type().Elem()).Interface()).TableName(), Rows: v.Len()}
+		err := to.Transaction(func(tx *gorm.DB) error {
+			for i := 0; i < v.Len(); i++ {
+				dbc := tx.Set("gorm:insert_option", "ON CONFLICT DO NOTHING").Create(v.Index(i).Addr().Interface())
+				// rows left out by the conflict return none on PostgreSQL
+				if errors.Is(dbc.Error, sql.ErrNoRows) {
+					continue
+				}
+				if dbc.Error != nil {
+					return dbc.Error
+				}
+				c.Copied += int(dbc.RowsAffected)
+			}
+			return nil
+		})
+		if err != nil {
+			return copies, fmt.Errorf("failed copying %s: %w", c.Table, err)
+		}
+		copies = append(copies, c)
+	}
+	return copies, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"testing"
+)
+
+func Test_CopyStores(t *testing.T) {
+	src := getMemoryDB(t)
+	users, _ := NewUserStore(src, DialectSQLite)
+	mailboxes, _ := NewMailboxStore(src, DialectSQLite, nil)
+	ledger, _ := NewMessageLedger(src, DialectSQLite)
+	alice := &model.User{Subject: "alice", Role: model.RoleUser}
+	if err := users.Create(alice); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if err := mailboxes.Save(&model.Mailbox{Owner: "alice", Token: "TOKEN"}); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if err := ledger.Record(&model.ProcessedMessage{MessageID: "MSG0", Outcome: model.ProcessingStateDone}); err != nil {
+		t.Fatalf("Record() error = %v", err)
+	}
+
+	for dialect, dst := range storeDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			copies, err := CopyStores(src, DialectSQLite, dst, dialect)
+			if err != nil {
+				t.Fatalf("CopyStores() error = %v", err)
+			}
+			copied := map[string]int{}
+			for _, c := range copies {
+				copied[c.Table] = c.Copied
+			}
+			if copied["users"] != 1 || copied["mailboxes"] != 1 || copied["processed_messages"] != 1 {
+				t.Errorf("CopyStores() copied = %v, want a user, a mailbox and a message", copies)
+			}
+			dstMailboxes, _ := NewMailboxStore(dst, dialect, nil)
+			if got, err := dstMailboxes.List("alice"); err != nil || len(got) != 1 || got[0].Token != "TOKEN" {
+				t.Errorf("List() got = %v, %v, want the mailbox copied", got, err)
+			}
+
+			// the rows copied are kept on a second run
+			copies, err = CopyStores(src, DialectSQLite, dst, dialect)
+			if err != nil {
+				t.Fatalf("CopyStores() again error = %v", err)
+			}
+			for _, c := range copies {
+				if c.Copied != 0 {
+					t.Errorf("CopyStores() again copied %d rows of %s, want 0", c.Copied, c.Table)
+				}
+			}
+		})
+	}
+}
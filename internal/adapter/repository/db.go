@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/jinzhu/gorm"
+)
+
+// gormDialects are the gorm dialects of the migration dialects
+var gormDialects = map[string]string{DialectSQLite: "sqlite3", DialectPostgres: "postgres"}
+
+// openGorm opens the stores on a database of dialect, DialectSQLite or DialectPostgres
+func openGorm(db *sql.DB, dialect string) (*gorm.DB, error) {
+	if db == nil {
+		return nil, fmt.Errorf("failed due to nil DB pointer")
+	}
+	name, ok := gormDialects[dialect]
+	if !ok {
+		return nil, fmt.Errorf("unknown dialect %s", dialect)
+	}
+	gdb, err := gorm.Open(name, db)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open DB connection: %w", err)
+	}
+	return gdb, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+type failureStore struct {
+	db *gorm.DB
+}
+
+func NewFailureStore(db *sql.DB, dialect string) (domain.FailureStore, error) {
+	gdb, err := openGorm(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &failureStore{gdb}, nil
+}
+
+func (s *failureStore) Record(f *model.Failure) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var previous model.Failure
+		dbc := tx.Where("email_id = ?", f.EmailID).First(&previous)
+		switch {
+		case dbc.Error == gorm.ErrRecordNotFound:
+			f.ID = uuid.New().String()
+			f.Attempts = 1
+			if dbc := tx.Create(f); dbc.Error != nil {
+				return fmt.Errorf("failed recording failure of email %s: %w", f.EmailID, dbc.Error)
+			}
+			return nil
+		case dbc.Error != nil:
+			return fmt.Errorf("failed database query when looking for failure of email %s: %w", f.EmailID, dbc.Error)
+		}
+		f.ID = previous.ID
+		f.CreatedAt = previous.CreatedAt
+		f.Attempts = previous.Attempts + 1
+		if dbc := tx.Save(f); dbc.Error != nil {
+			return fmt.Errorf("failed recording failure of email %s: %w", f.EmailID, dbc.Error)
+		}
+		return nil
+	})
+}
+
+func (s *failureStore) Get(id string) (model.Failure, error) {
+	var f model.Failure
+	if dbc := s.db.Where("id = ?", id).First(&f); dbc.Error != nil {
+		if dbc.Error == gorm.ErrRecordNotFound {
+			return model.Failure{}, domain.ErrorNoFailure
+		}
+		return model.Failure{}, fmt.Errorf("failed database query when looking for failure %s: %w", id, dbc.Error)
+	}
+	return f, nil
+}
+
+func (s *failureStore) List() ([]model.Failure, error) {
+	failures := []model.Failure{}
+	if dbc := s.db.Order("updated_at DESC").Find(&failures); dbc.Error != nil {
+		return nil, fmt.Errorf("failed database query when listing failures: %w", dbc.Error)
+	}
+	return failures, nil
+}
+
+func (s *failureStore) Delete(id string) error {
+	dbc := s.db.Where("id = ?", id).Delete(&model.Failure{})
+	if dbc.Error != nil {
+		return fmt.Errorf("failed deleting failure %s: %w", id, dbc.Error)
+	}
+	if dbc.RowsAffected == 0 {
+		return domain.ErrorNoFailure
+	}
+	return nil
+}
+
+func (s *failureStore) DeleteByEmailID(emailID string) error {
+	if dbc := s.db.Where("email_id = ?", emailID).Delete(&model.Failure{}); dbc.Error != nil {
+		return fmt.Errorf("failed deleting failure of email %s: %w", emailID, dbc.Error)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func Test_failureStore(t *testing.T) {
+	for dialect, db := range storeDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			s, err := NewFailureStore(db, dialect)
+			if err != nil {
+				t.Fatalf("NewFailureStore() error = %v", err)
+			}
+
+			first := &model.Failure{EmailID: "MSG0", Stage: model.FailureStageCreate, Detail: "unauthorized"}
+			if err := s.Record(first); err != nil {
+				t.Fatalf("Record() error = %v", err)
+			}
+			if err := s.Record(&model.Failure{EmailID: "MSG1", Stage: model.FailureStageCreate}); err != nil {
+				t.Fatalf("Record() error = %v", err)
+			}
+			again := &model.Failure{
+				EmailID:  "MSG0",
+				Stage:    model.FailureStageParse,
+				JobID:    "JOB0",
+				Detail:   "no trip found",
+				Warnings: model.Warnings{"unknown carrier", "missing date"},
+			}
+			if err := s.Record(again); err != nil {
+				t.Fatalf("Record() of a failed email error = %v", err)
+			}
+			if again.ID != first.ID || again.Attempts != 2 {
+				t.Errorf("Record() of a failed email got id %s and %d attempts, want id %s and 2 attempts",
+					again.ID, again.Attempts, first.ID)
+			}
+
+			got, err := s.Get(first.ID)
+			if err != nil {
+				t.Fatalf("Get() error = %v", err)
+			}
+			if got.Stage != model.FailureStageParse || got.Attempts != 2 ||
+				!reflect.DeepEqual(got.Warnings, again.Warnings) || got.CreatedAt.IsZero() {
+				t.Errorf("Get() got = %v, want second parse failure with warnings", got)
+			}
+
+			list, err := s.List()
+			if err != nil || len(list) != 2 || list[0].EmailID != "MSG0" {
+				t.Errorf("List() got = %v, %v, want 2 failures, latest first", list, err)
+			}
+
+			if err := s.Delete(first.ID); err != nil {
+				t.Errorf("Delete() error = %v", err)
+			}
+			if err := s.Delete(first.ID); !errors.Is(err, domain.ErrorNoFailure) {
+				t.Errorf("Delete() of a deleted failure error = %v, want %v", err, domain.ErrorNoFailure)
+			}
+			if _, err := s.Get(first.ID); !errors.Is(err, domain.ErrorNoFailure) {
+				t.Errorf("Get() of a deleted failure error = %v, want %v", err, domain.ErrorNoFailure)
+			}
+			if err := s.DeleteByEmailID("MSG1"); err != nil {
+				t.Errorf("DeleteByEmailID() error = %v", err)
+			}
+			if err := s.DeleteByEmailID("MSG1"); err != nil {
+				t.Errorf("DeleteByEmailID() without failure error = %v", err)
+			}
+			if list, _ := s.List(); len(list) != 0 {
+				t.Errorf("List() got = %v, want no failure", list)
+			}
+		})
+	}
+}
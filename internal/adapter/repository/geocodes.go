@@ -0,0 +1,40 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"database/sql"
+	"fmt"
+	"github.com/jinzhu/gorm"
+)
+
+type geocodeCache struct {
+	db *gorm.DB
+}
+
+func NewGeocodeCache(db *sql.DB, dialect string) (domain.GeocodeCache, error) {
+	gdb, err := openGorm(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &geocodeCache{gdb}, nil
+}
+
+func (s *geocodeCache) Get(address string) (model.Geocode, error) {
+	var g model.Geocode
+	if dbc := s.db.Where("address = ?", address).First(&g); dbc.Error != nil {
+		if dbc.Error == gorm.ErrRecordNotFound {
+			return model.Geocode{}, domain.ErrorNoGeocode
+		}
+		return model.Geocode{}, fmt.Errorf("failed database query when looking for geocode of %q: %w", address,
+			dbc.Error)
+	}
+	return g, nil
+}
+
+func (s *geocodeCache) Save(geocode *model.Geocode) error {
+	if dbc := s.db.Save(geocode); dbc.Error != nil {
+		return fmt.Errorf("failed saving geocode of %q: %w", geocode.Address, dbc.Error)
+	}
+	return nil
+}
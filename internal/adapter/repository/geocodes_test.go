@@ -0,0 +1,43 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"testing"
+)
+
+func Test_geocodeCache(t *testing.T) {
+	for dialect, db := range storeDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			s, err := NewGeocodeCache(db, dialect)
+			if err != nil {
+				t.Fatalf("NewGeocodeCache() error = %v", err)
+			}
+
+			if _, err := s.Get("Hammamet, 8050, Tunisia"); !errors.Is(err, domain.ErrorNoGeocode) {
+				t.Errorf("Get() error = %v, want %v", err, domain.ErrorNoGeocode)
+			}
+
+			g := &model.Geocode{Address: "Hammamet, 8050, Tunisia"}
+			if err := s.Save(g); err != nil {
+				t.Fatalf("Save() error = %v", err)
+			}
+			g.Found, g.Latitude, g.Longitude = true, 36.4, 10.6
+			if err := s.Save(g); err != nil {
+				t.Fatalf("Save() of an existing geocode error = %v", err)
+			}
+			if err := s.Save(&model.Geocode{Address: "Nowhere"}); err != nil {
+				t.Fatalf("Save() of an unknown address error = %v", err)
+			}
+
+			got, err := s.Get("Hammamet, 8050, Tunisia")
+			if err != nil || !got.Found || got.Latitude != 36.4 || got.Longitude != 10.6 {
+				t.Errorf("Get() got = %v, %v, want %v", got, err, *g)
+			}
+			if got, err := s.Get("Nowhere"); err != nil || got.Found {
+				t.Errorf("Get() of an unknown address got = %v, %v, want not found", got, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"database/sql"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"time"
+)
+
+type messageLedger struct {
+	db *gorm.DB
+}
+
+func NewMessageLedger(db *sql.DB, dialect string) (domain.MessageLedger, error) {
+	gdb, err := openGorm(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &messageLedger{gdb}, nil
+}
+
+func (s *messageLedger) Get(messageID string) (model.ProcessedMessage, error) {
+	var msg model.ProcessedMessage
+	if dbc := s.db.Where("message_id = ?", messageID).First(&msg); dbc.Error != nil {
+		if dbc.Error == gorm.ErrRecordNotFound {
+			return model.ProcessedMessage{}, domain.ErrorNotProcessed
+		}
+		return model.ProcessedMessage{}, fmt.Errorf("failed database query when looking for message %s: %w", messageID, dbc.Error)
+	}
+	return msg, nil
+}
+
+func (s *messageLedger) Record(msg *model.ProcessedMessage) error {
+	if msg.ProcessedAt.IsZero() {
+		msg.ProcessedAt = time.Now()
+	}
+	if dbc := s.db.Save(msg); dbc.Error != nil {
+		return fmt.Errorf("failed recording message %s: %w", msg.MessageID, dbc.Error)
+	}
+	return nil
+}
+
+func (s *messageLedger) Delete(messageID string) error {
+	dbc := s.db.Where("message_id = ?", messageID).Delete(&model.ProcessedMessage{})
+	if dbc.Error != nil {
+		return fmt.Errorf("failed deleting message %s: %w", messageID, dbc.Error)
+	}
+	if dbc.RowsAffected == 0 {
+		return domain.ErrorNotProcessed
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"testing"
+)
+
+func Test_messageLedger(t *testing.T) {
+	for dialect, db := range storeDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			l, err := NewMessageLedger(db, dialect)
+			if err != nil {
+				t.Fatalf("NewMessageLedger() error = %v", err)
+			}
+
+			if _, err := l.Get("MSG0"); !errors.Is(err, domain.ErrorNotProcessed) {
+				t.Errorf("Get() error = %v, want %v", err, domain.ErrorNotProcessed)
+			}
+
+			msg := &model.ProcessedMessage{
+				MessageID:   "MSG0",
+				ContentHash: model.ContentHash("content"),
+				Outcome:     model.ProcessingStateFailed,
+				JobID:       "JOB0",
+			}
+			if err := l.Record(msg); err != nil {
+				t.Fatalf("Record() error = %v", err)
+			}
+			msg.Outcome = model.ProcessingStateDone
+			msg.TripID = "TRIP0"
+			if err := l.Record(msg); err != nil {
+				t.Fatalf("Record() of a known message error = %v", err)
+			}
+
+			got, err := l.Get("MSG0")
+			if err != nil || got.Outcome != model.ProcessingStateDone || got.TripID != "TRIP0" || got.ProcessedAt.IsZero() {
+				t.Errorf("Get() got = %v, %v, want message done with trip TRIP0", got, err)
+			}
+
+			if err := l.Delete("MSG0"); err != nil {
+				t.Errorf("Delete() error = %v", err)
+			}
+			if err := l.Delete("MSG0"); !errors.Is(err, domain.ErrorNotProcessed) {
+				t.Errorf("Delete() of a deleted message error = %v, want %v", err, domain.ErrorNotProcessed)
+			}
+		})
+	}
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// ProcessingLockName names the advisory lock of the replica processing emails
+const ProcessingLockName = "amadeus-trip-parser.processing"
+
+// advisoryKey is the key of the PostgreSQL advisory lock of a name
+func advisoryKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	return int64(h.Sum64())
+}
+
+// advisoryLock is a PostgreSQL session advisory lock, held as long as the connection taking it is open
+type advisoryLock struct {
+	db   *sql.DB
+	key  int64
+	mu   sync.Mutex
+	conn *sql.Conn
+}
+
+// NewAdvisoryLock returns a lock of the PostgreSQL database shared by the replicas, by name
+func NewAdvisoryLock(db *sql.DB, name string) domain.ProcessingLock {
+	return &advisoryLock{db: db, key: advisoryKey(name)}
+}
+
+func (l *advisoryLock) TryLock(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn != nil {
+		return true, nil
+	}
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return false, fmt.Errorf("cannot open lock connection: %w", err)
+	}
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&locked); err != nil {
+		conn.Close()
+		return false, fmt.Errorf("cannot take advisory lock: %w", err)
+	}
+	if !locked {
+		return false, conn.Close()
+	}
+	l.conn = conn
+	return true, nil
+}
+
+func (l *advisoryLock) Check(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return errors.New("advisory lock not held")
+	}
+	// the lock goes with the session, which is alive as long as its connection is
+	if err := l.conn.PingContext(ctx); err != nil {
+		return fmt.Errorf("advisory lock lost: %w", err)
+	}
+	return nil
+}
+
+func (l *advisoryLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.conn == nil {
+		return nil
+	}
+	_, err := l.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", l.key)
+	if cerr := l.conn.Close(); err == nil {
+		err = cerr
+	}
+	l.conn = nil
+	return err
+}
+
+// leaseLock is a lock row of a SQLite database, shared by the processes opening its file. It is held until its lease
+// expires, a holder renews the lease on each check so that the lock of a process that died is taken over.
+type leaseLock struct {
+	db     *sql.DB
+	name   string
+	holder string
+	ttl    time.Duration
+	now    func() time.Time
+	mu     sync.Mutex
+	// expires is the end of the lease taken or renewed last
+	expires time.Time
+}
+
+// NewLeaseLock returns a lock of the SQLite database by name, held for ttl after it is taken or checked
+func NewLeaseLock(db *sql.DB, name string, ttl time.Duration) domain.ProcessingLock {
+	return &leaseLock{db: db, name: name, holder: uuid.New().String(), ttl: ttl, now: time.Now}
+}
+
+func (l *leaseLock) TryLock(ctx context.Context) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	res, err := l.db.ExecContext(ctx, `INSERT INTO processing_locks (name, holder, expires_at) VALUES (?, ?, ?)
+		ON CONFLICT (name) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at
+		WHERE processing_locks.holder = excluded.holder OR processing_locks.expires_at < ?`,
+		l.name, l.holder, now.Add(l.ttl).Unix(), now.Unix())
+	if err != nil {
+		return false, fmt.Errorf("cannot take lease lock: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("cannot take lease lock: %w", err)
+	}
+	if n == 1 {
+		l.expires = now.Add(l.ttl)
+	}
+	return n == 1, nil
+}
+
+// Check renews the lease, a database busy with another process leaves the lock held until the lease expires
+func (l *leaseLock) Check(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	res, err := l.db.ExecContext(ctx, "UPDATE processing_locks SET expires_at = ? WHERE name = ? AND holder = ?",
+		now.Add(l.ttl).Unix(), l.name, l.holder)
+	if err != nil {
+		if now.Before(l.expires) {
+			return nil
+		}
+		return fmt.Errorf("cannot renew lease lock: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil || n != 1 {
+		return errors.New("lease lock lost")
+	}
+	l.expires = now.Add(l.ttl)
+	return nil
+}
+
+func (l *leaseLock) Unlock() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.expires = time.Time{}
+	_, err := l.db.Exec("DELETE FROM processing_locks WHERE name = ? AND holder = ?", l.name, l.holder)
+	return err
+}
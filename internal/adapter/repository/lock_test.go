@@ -0,0 +1,42 @@
+package repository
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func Test_leaseLock(t *testing.T) {
+	db := getMemoryDB(t)
+	ctx := context.Background()
+	first := NewLeaseLock(db, ProcessingLockName, time.Minute).(*leaseLock)
+	second := NewLeaseLock(db, ProcessingLockName, time.Minute).(*leaseLock)
+
+	locked, err := first.TryLock(ctx)
+	require.NoError(t, err)
+	assert.True(t, locked)
+	locked, err = first.TryLock(ctx)
+	require.NoError(t, err)
+	assert.True(t, locked, "taken again by its holder")
+	locked, err = second.TryLock(ctx)
+	require.NoError(t, err)
+	assert.False(t, locked)
+	assert.NoError(t, first.Check(ctx))
+
+	// the lease of a holder which stopped checking is taken over
+	second.now = func() time.Time { return time.Now().Add(2 * time.Minute) }
+	locked, err = second.TryLock(ctx)
+	require.NoError(t, err)
+	assert.True(t, locked)
+	assert.Error(t, first.Check(ctx))
+	assert.NoError(t, first.Unlock(), "the lock of another holder is left")
+	locked, _ = first.TryLock(ctx)
+	assert.False(t, locked)
+
+	assert.NoError(t, second.Unlock())
+	locked, err = first.TryLock(ctx)
+	require.NoError(t, err)
+	assert.True(t, locked)
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/adapter/secrets"
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+type mailboxStore struct {
+	db     *gorm.DB
+	cipher *secrets.Cipher
+}
+
+// NewMailboxStore encrypts the credentials and tokens of the mailboxes with the cipher, they are stored plain
+// with a nil cipher. Plain ones are read as is, and encrypted on next save.
+func NewMailboxStore(db *sql.DB, dialect string, cipher *secrets.Cipher) (domain.MailboxStore, error) {
+	gdb, err := openGorm(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &mailboxStore{gdb, cipher}, nil
+}
+
+func (s *mailboxStore) Save(mailbox *model.Mailbox) error {
+	if mailbox.ID == "" {
+		mailbox.ID = uuid.New().String()
+	}
+	stored := *mailbox
+	var err error
+	if stored.Credentials, err = s.encrypt(mailbox.Credentials); err != nil {
+		return fmt.Errorf("cannot encrypt credentials of mailbox %s: %w", mailbox.ID, err)
+	}
+	if stored.Token, err = s.encrypt(mailbox.Token); err != nil {
+		return fmt.Errorf("cannot encrypt token of mailbox %s: %w", mailbox.ID, err)
+	}
+	if dbc := s.db.Save(&stored); dbc.Error != nil {
+		return fmt.Errorf("failed saving mailbox %s of %s: %w", mailbox.ID, mailbox.Owner, dbc.Error)
+	}
+	mailbox.CreatedAt, mailbox.UpdatedAt = stored.CreatedAt, stored.UpdatedAt
+	return nil
+}
+
+// UpdateToken only writes the token column, the update time is kept as the mailbox itself is unchanged
+func (s *mailboxStore) UpdateToken(id string, token string) error {
+	stored, err := s.encrypt(token)
+	if err != nil {
+		return fmt.Errorf("cannot encrypt token of mailbox %s: %w", id, err)
+	}
+	dbc := s.db.Model(&model.Mailbox{}).Where("id = ?", id).UpdateColumn("token", stored)
+	if dbc.Error != nil {
+		return fmt.Errorf("failed updating token of mailbox %s: %w", id, dbc.Error)
+	}
+	if dbc.RowsAffected == 0 {
+		return domain.ErrorNoMailbox
+	}
+	return nil
+}
+
+func (s *mailboxStore) encrypt(value string) (string, error) {
+	if value == "" || secrets.IsEncrypted([]byte(value)) {
+		return value, nil
+	}
+	b, err := s.cipher.Encrypt([]byte(value))
+	return string(b), err
+}
+
+func (s *mailboxStore) decrypt(mailbox *model.Mailbox) error {
+	cred, err := s.cipher.Decrypt([]byte(mailbox.Credentials))
+	if err != nil {
+		return fmt.Errorf("cannot decrypt credentials of mailbox %s: %w", mailbox.ID, err)
+	}
+	token, err := s.cipher.Decrypt([]byte(mailbox.Token))
+	if err != nil {
+		return fmt.Errorf("cannot decrypt token of mailbox %s: %w", mailbox.ID, err)
+	}
+	mailbox.Credentials, mailbox.Token = string(cred), string(token)
+	return nil
+}
+
+func (s *mailboxStore) Get(id string) (model.Mailbox, error) {
+	var mailbox model.Mailbox
+	if dbc := s.db.Where("id = ?", id).First(&mailbox); dbc.Error != nil {
+		if dbc.Error == gorm.ErrRecordNotFound {
+			return model.Mailbox{}, domain.ErrorNoMailbox
+		}
+		return model.Mailbox{}, fmt.Errorf("failed database query when looking for mailbox %s: %w", id, dbc.Error)
+	}
+	if err := s.decrypt(&mailbox); err != nil {
+		return model.Mailbox{}, err
+	}
+	return mailbox, nil
+}
+
+func (s *mailboxStore) List(owner string) ([]model.Mailbox, error) {
+	mailboxes := []model.Mailbox{}
+	if dbc := s.db.Where(model.Mailbox{Owner: owner}).Order("owner, created_at").Find(&mailboxes); dbc.Error != nil {
+		return nil, fmt.Errorf("failed database query when listing mailboxes: %w", dbc.Error)
+	}
+	for i := range mailboxes {
+		if err := s.decrypt(&mailboxes[i]); err != nil {
+			return nil, err
+		}
+	}
+	return mailboxes, nil
+}
+
+func (s *mailboxStore) Delete(id string) error {
+	dbc := s.db.Where("id = ?", id).Delete(&model.Mailbox{})
+	if dbc.Error != nil {
+		return fmt.Errorf("failed deleting mailbox %s: %w", id, dbc.Error)
+	}
+	if dbc.RowsAffected == 0 {
+		return domain.ErrorNoMailbox
+	}
+	return nil
+}
+
+func (s *mailboxStore) DeleteByOwner(owner string) error {
+	if dbc := s.db.Where("owner = ?", owner).Delete(&model.Mailbox{}); dbc.Error != nil {
+		return fmt.Errorf("failed deleting mailboxes of %s: %w", owner, dbc.Error)
+	}
+	return nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/adapter/secrets"
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func Test_mailboxStore(t *testing.T) {
+	for dialect, db := range storeDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			s, err := NewMailboxStore(db, dialect, nil)
+			if err != nil {
+				t.Fatalf("NewMailboxStore() error = %v", err)
+			}
+
+			work := &model.Mailbox{Owner: "alice", Provider: model.MailboxProviderGmail, Token: `{"access_token":"a"}`}
+			for _, m := range []*model.Mailbox{work, {Owner: "alice"}, {Owner: "bob"}} {
+				if err := s.Save(m); err != nil || m.ID == "" {
+					t.Fatalf("Save() error = %v, id = %s", err, m.ID)
+				}
+			}
+
+			work.Token = `{"access_token":"b"}`
+			if err := s.Save(work); err != nil {
+				t.Errorf("Save() of an existing mailbox error = %v", err)
+			}
+			if got, err := s.Get(work.ID); err != nil || got.Token != work.Token {
+				t.Errorf("Get() got = %v, %v, want updated token", got, err)
+			}
+			saved, _ := s.Get(work.ID)
+			if err := s.UpdateToken(work.ID, `{"access_token":"c"}`); err != nil {
+				t.Errorf("UpdateToken() error = %v", err)
+			}
+			if got, err := s.Get(work.ID); err != nil || got.Token != `{"access_token":"c"}` ||
+				!got.UpdatedAt.Equal(saved.UpdatedAt) {
+				t.Errorf("Get() after UpdateToken() got = %v, %v, want refreshed token and same update time", got, err)
+			}
+			if err := s.UpdateToken("unknown", "{}"); !errors.Is(err, domain.ErrorNoMailbox) {
+				t.Errorf("UpdateToken() of an unknown mailbox error = %v, want %v", err, domain.ErrorNoMailbox)
+			}
+			if _, err := s.Get("unknown"); !errors.Is(err, domain.ErrorNoMailbox) {
+				t.Errorf("Get() of an unknown mailbox error = %v, want %v", err, domain.ErrorNoMailbox)
+			}
+			if list, err := s.List(""); err != nil || len(list) != 3 {
+				t.Errorf("List() got = %v, %v, want 3 mailboxes", list, err)
+			}
+			if list, err := s.List("alice"); err != nil || len(list) != 2 {
+				t.Errorf("List(alice) got = %v, %v, want 2 mailboxes", list, err)
+			}
+
+			if err := s.Delete(work.ID); err != nil {
+				t.Errorf("Delete() error = %v", err)
+			}
+			if err := s.Delete(work.ID); !errors.Is(err, domain.ErrorNoMailbox) {
+				t.Errorf("Delete() of a deleted mailbox error = %v, want %v", err, domain.ErrorNoMailbox)
+			}
+			if err := s.DeleteByOwner("alice"); err != nil {
+				t.Errorf("DeleteByOwner() error = %v", err)
+			}
+			if list, err := s.List(""); err != nil || len(list) != 1 {
+				t.Errorf("List() after DeleteByOwner() got = %v, %v, want 1 mailbox", list, err)
+			}
+		})
+	}
+}
+
+func Test_mailboxStore_encrypted(t *testing.T) {
+	db := getMemoryDB(t)
+	cipher, _ := secrets.NewCipher(bytes.Repeat([]byte{1}, 32))
+	plain, _ := NewMailboxStore(db, DialectSQLite, nil)
+	encrypted, _ := NewMailboxStore(db, DialectSQLite, cipher)
+
+	old := &model.Mailbox{Owner: "alice", Token: `{"access_token":"a"}`}
+	if err := plain.Save(old); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	m := &model.Mailbox{Owner: "alice", Credentials: `{"installed":{}}`, Token: `{"access_token":"b"}`}
+	if err := encrypted.Save(m); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if m.Token != `{"access_token":"b"}` {
+		t.Errorf("Save() changed the token of the caller to %s", m.Token)
+	}
+
+	var token, cred string
+	if err := db.QueryRow("SELECT token, credentials FROM mailboxes WHERE id = ?", m.ID).Scan(&token, &cred); err != nil {
+		t.Fatalf("cannot read mailbox row: %v", err)
+	}
+	if !secrets.IsEncrypted([]byte(token)) || !secrets.IsEncrypted([]byte(cred)) {
+		t.Errorf("stored token %s and credentials %s are not encrypted", token, cred)
+	}
+	if got, err := encrypted.Get(m.ID); err != nil || got.Token != m.Token || got.Credentials != m.Credentials {
+		t.Errorf("Get() got = %v, %v, want decrypted token and credentials", got, err)
+	}
+	if err := encrypted.UpdateToken(m.ID, `{"access_token":"c"}`); err != nil {
+		t.Errorf("UpdateToken() error = %v", err)
+	}
+	if err := db.QueryRow("SELECT token FROM mailboxes WHERE id = ?", m.ID).Scan(&token); err != nil ||
+		!secrets.IsEncrypted([]byte(token)) {
+		t.Errorf("updated token %s is not encrypted, %v", token, err)
+	}
+	if got, err := encrypted.Get(old.ID); err != nil || got.Token != old.Token {
+		t.Errorf("Get() of a plain mailbox got = %v, %v", got, err)
+	}
+	if _, err := plain.List("alice"); !errors.Is(err, secrets.ErrorNoKey) {
+		t.Errorf("List() of encrypted mailboxes without key error = %v, want %v", err, secrets.ErrorNoKey)
+	}
+}
@@ -0,0 +1,374 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialects of the migration sets, both hold all the tables
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+)
+
+var ErrorSchemaTooNew = errors.New("database schema is newer than the application")
+
+// migrationFiles are named <version>_<name>.up.sql and <version>_<name>.down.sql per dialect
+//
+//go:embed migrations
+var migrationFiles embed.FS
+
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus tells whether a migration is applied, AppliedAt is nil when it is not
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies the versioned migrations of a dialect, each in its own transaction, and records the applied
+// versions in the schema_migrations table
+type Migrator struct {
+	db         *sql.DB
+	dialect    string
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB, dialect string) (*Migrator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("failed due to nil DB pointer")
+	}
+	migrations, err := loadMigrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations}, nil
+}
+
+// Migrate refuses a database with a newer schema, and applies the pending migrations of an older one. Replicas
+// starting together migrate a PostgreSQL database one after the other.
+func Migrate(db *sql.DB, dialect string) error {
+	m, err := NewMigrator(db, dialect)
+	if err != nil {
+		return err
+	}
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := m.Check(); err != nil {
+		return err
+	}
+	return m.to(m.Latest())
+}
+
+// migrationLockName names the advisory lock taken while a PostgreSQL database is migrated
+const migrationLockName = "amadeus-trip-parser.migrations"
+
+// lock waits for the migrations of other processes to end, it returns the function releasing the lock
+func (m *Migrator) lock() (func(), error) {
+	if m.dialect != DialectPostgres {
+		return func() {}, nil
+	}
+	ctx := context.Background()
+	// the lock is held by the session, the migrations run on other connections of the pool
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open migration lock connection: %w", err)
+	}
+	key := advisoryKey(migrationLockName)
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("cannot take migration lock: %w", err)
+	}
+	return func() {
+		conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", key)
+		conn.Close()
+	}, nil
+}
+
+func loadMigrations(dialect string) ([]Migration, error) {
+	dir := path.Join("migrations", dialect)
+	entries, err := fs.ReadDir(migrationFiles, dir)
+	if err != nil {
+		return nil, fmt.Errorf("no migrations for dialect %s: %w", dialect, err)
+	}
+	byVersion := make(map[int]*Migration)
+	for _, e := range entries {
+		var direction string
+		name := e.Name()
+		switch {
+		case strings.HasSuffix(name, ".up.sql"):
+			direction, name = "up", strings.TrimSuffix(name, ".up.sql")
+		case strings.HasSuffix(name, ".down.sql"):
+			direction, name = "down", strings.TrimSuffix(name, ".down.sql")
+		default:
+			return nil, fmt.Errorf("unexpected migration file %s", e.Name())
+		}
+		parts := strings.SplitN(name, "_", 2)
+		version, err := strconv.Atoi(parts[0])
+		if err != nil || len(parts) != 2 || version <= 0 {
+			return nil, fmt.Errorf("migration file %s is not named <version>_<name>", e.Name())
+		}
+		b, err := fs.ReadFile(migrationFiles, path.Join(dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: parts[1]}
+			byVersion[version] = m
+		}
+		if direction == "up" {
+			m.up = string(b)
+		} else {
+			m.down = string(b)
+		}
+	}
+	var migrations []Migration
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("missing migration %d of dialect %s", i+1, dialect)
+		}
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %d of dialect %s needs both up and down files", m.Version, dialect)
+		}
+	}
+	return migrations, nil
+}
+
+// Latest is the version of the schema expected by the application
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version is the version of the database schema, 0 when no migration is applied
+func (m *Migrator) Version() (int, error) {
+	if err := m.createTable(); err != nil {
+		return 0, err
+	}
+	var version sql.NullInt64
+	if err := m.db.QueryRow("SELECT MAX(version) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, fmt.Errorf("cannot read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// Check fails with ErrorSchemaTooNew when the database was migrated by a newer application
+func (m *Migrator) Check() error {
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if version > m.Latest() {
+		return fmt.Errorf("%w: database is at version %d, application knows up to %d",
+			ErrorSchemaTooNew, version, m.Latest())
+	}
+	return nil
+}
+
+func (m *Migrator) Status() ([]MigrationStatus, error) {
+	if err := m.createTable(); err != nil {
+		return nil, err
+	}
+	rows, err := m.db.Query("SELECT version, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, fmt.Errorf("cannot read applied migrations: %w", err)
+	}
+	defer rows.Close()
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, fmt.Errorf("cannot read applied migrations: %w", err)
+		}
+		applied[version] = at
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read applied migrations: %w", err)
+	}
+	var status []MigrationStatus
+	for _, mig := range m.migrations {
+		s := MigrationStatus{Migration: mig}
+		if at, ok := applied[mig.Version]; ok {
+			s.AppliedAt = &at
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Up applies all pending migrations
+func (m *Migrator) Up() error {
+	return m.To(m.Latest())
+}
+
+// Down reverts the last applied migration
+func (m *Migrator) Down() error {
+	version, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if version == 0 {
+		return nil
+	}
+	return m.To(version - 1)
+}
+
+// To applies or reverts migrations one by one until the schema is at version
+func (m *Migrator) To(version int) error {
+	unlock, err := m.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return m.to(version)
+}
+
+func (m *Migrator) to(version int) error {
+	if version < 0 || version > m.Latest() {
+		return fmt.Errorf("unknown schema version %d, expected 0 to %d", version, m.Latest())
+	}
+	current, err := m.Version()
+	if err != nil {
+		return err
+	}
+	if current > m.Latest() {
+		return fmt.Errorf("%w: database is at version %d, application knows up to %d",
+			ErrorSchemaTooNew, current, m.Latest())
+	}
+	for ; current < version; current++ {
+		mig := m.migrations[current]
+		var prepare func(tx *sql.Tx) error
+		if mig.Version == 1 {
+			prepare = m.adoptColumns
+		}
+		if err := m.apply(mig.up, prepare, "INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+			mig.Version, mig.Name, time.Now().UTC()); err != nil {
+			return fmt.Errorf("cannot apply migration %d %s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	for ; current > version; current-- {
+		mig := m.migrations[current-1]
+		if err := m.apply(mig.down, nil, "DELETE FROM schema_migrations WHERE version = ?",
+			mig.Version); err != nil {
+			return fmt.Errorf("cannot revert migration %d %s: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return nil
+}
+
+// apply runs the statements of a migration, after prepare when not nil, and records it in the same transaction
+func (m *Migrator) apply(statements string, prepare func(tx *sql.Tx) error, record string,
+	args ...interface{}) error {
+	tx, err := m.db.Begin()
+	if err != nil {
+		return err
+	}
+	if prepare != nil {
+		if err := prepare(tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if _, err := tx.Exec(statements); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if _, err := tx.Exec(m.bind(record), args...); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// adoptedColumn is a column added by AutoMigrate to a table created by an earlier version
+type adoptedColumn struct {
+	table      string
+	column     string
+	definition string
+}
+
+// adoptedColumns are missing from databases created before the first migration by versions predating them, the
+// PostgreSQL tables were created with all of them
+var adoptedColumns = map[string][]adoptedColumn{
+	DialectSQLite: {
+		{"trips", "owner", "varchar(255)"},
+		{"queue_items", "trace_parent", "varchar(255)"},
+		{"queue_items", "mailbox_id", "varchar(255)"},
+		{"queue_items", "owner", "varchar(255)"},
+	},
+}
+
+// adoptColumns adds the adopted columns missing from the existing tables, before the first migration creates the
+// tables and indexes missing
+func (m *Migrator) adoptColumns(tx *sql.Tx) error {
+	for _, c := range adoptedColumns[m.dialect] {
+		var columns, found int
+		err := tx.QueryRow("SELECT COUNT(*), COALESCE(SUM(name = ?), 0) FROM pragma_table_info(?)",
+			c.column, c.table).Scan(&columns, &found)
+		if err != nil {
+			return fmt.Errorf("cannot read columns of %s: %w", c.table, err)
+		}
+		// a missing table is created whole
+		if columns == 0 || found > 0 {
+			continue
+		}
+		_, err = tx.Exec(fmt.Sprintf(`ALTER TABLE "%s" ADD COLUMN "%s" %s`, c.table, c.column, c.definition))
+		if err != nil {
+			return fmt.Errorf("cannot add column %s to %s: %w", c.column, c.table, err)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) createTable() error {
+	timestamp := "datetime"
+	if m.dialect == DialectPostgres {
+		timestamp = "timestamp with time zone"
+	}
+	_, err := m.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version integer PRIMARY KEY,
+		name varchar(255) NOT NULL, applied_at ` + timestamp + ` NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("cannot create schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+// bind numbers the placeholders of a query for PostgreSQL
+func (m *Migrator) bind(query string) string {
+	if m.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
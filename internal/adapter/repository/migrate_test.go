@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"database/sql"
+	"errors"
+	"github.com/jinzhu/gorm"
+	"testing"
+)
+
+// emptyDBs opens an empty database per dialect, PostgreSQL is left out without server
+func emptyDBs(t *testing.T) map[string]*sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("cannot create memory sqlite database: %s", err)
+	}
+	db.SetMaxOpenConns(1)
+	dbs := map[string]*sql.DB{DialectSQLite: db}
+	if postgresDSN != "" {
+		dbs[DialectPostgres] = getPostgresSchema(t)
+	}
+	return dbs
+}
+
+func tableExists(db *sql.DB, table string) bool {
+	_, err := db.Exec("SELECT 1 FROM " + table)
+	return err == nil
+}
+
+func Test_Migrator(t *testing.T) {
+	for dialect, db := range emptyDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			m, err := NewMigrator(db, dialect)
+			if err != nil {
+				t.Fatalf("NewMigrator() error = %v", err)
+			}
+			if v, err := m.Version(); err != nil || v != 0 {
+				t.Errorf("Version() of an empty database = %d, %v, want 0", v, err)
+			}
+
+			if err := m.Up(); err != nil {
+				t.Fatalf("Up() error = %v", err)
+			}
+			if v, _ := m.Version(); v != m.Latest() {
+				t.Errorf("Version() after Up() = %d, want %d", v, m.Latest())
+			}
+			status, err := m.Status()
+			if err != nil || len(status) != m.Latest() || status[0].AppliedAt == nil {
+				t.Errorf("Status() after Up() = %v, %v, want all applied", status, err)
+			}
+			if !tableExists(db, "trips") {
+				t.Errorf("Up() did not create the trips table")
+			}
+			if err := m.Up(); err != nil {
+				t.Errorf("Up() of a migrated database error = %v", err)
+			}
+
+			if err := m.To(0); err != nil {
+				t.Fatalf("To(0) error = %v", err)
+			}
+			if v, _ := m.Version(); v != 0 || tableExists(db, "trips") {
+				t.Errorf("To(0) left version %d and the trips table", v)
+			}
+			if err := m.To(m.Latest() + 1); err == nil {
+				t.Errorf("To() an unknown version succeeded")
+			}
+
+			if err := m.Up(); err != nil {
+				t.Fatalf("Up() error = %v", err)
+			}
+			if err := m.Down(); err != nil {
+				t.Fatalf("Down() error = %v", err)
+			}
+			if v, _ := m.Version(); v != m.Latest()-1 {
+				t.Errorf("Version() after Down() = %d, want %d", v, m.Latest()-1)
+			}
+
+			// a newer application migrated the database
+			if _, err := db.Exec(m.bind("INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)"),
+				m.Latest()+1, "future", "2030-01-01 00:00:00"); err != nil {
+				t.Fatal(err)
+			}
+			if err := Migrate(db, dialect); !errors.Is(err, ErrorSchemaTooNew) {
+				t.Errorf("Migrate() of a newer schema error = %v, want %v", err, ErrorSchemaTooNew)
+			}
+		})
+	}
+}
+
+// Test_migrations_models checks that the migrations create what gorm expects from the models, and that a database
+// created by AutoMigrate adopts the first migration
+func Test_migrations_models(t *testing.T) {
+	// both dialects hold all the tables
+	all := []interface{}{&model.Trip{}, &model.TripStep{}, &model.Traveller{}, &model.QueueItem{},
+		&model.ProcessedMessage{}, &model.Failure{}, &model.APIKey{}, &model.User{}, &model.Mailbox{},
+		&model.Backfill{}, &model.Geocode{}}
+	// the first migration adopts the tables AutoMigrate created
+	adopted := map[string][]interface{}{
+		DialectSQLite: {&model.Trip{}, &model.TripStep{}, &model.Traveller{}, &model.QueueItem{},
+			&model.ProcessedMessage{}, &model.Failure{}, &model.APIKey{}, &model.User{}, &model.Mailbox{}},
+		DialectPostgres: {&model.Trip{}, &model.TripStep{}, &model.Traveller{}},
+	}
+	for dialect, db := range emptyDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			gdb, err := gorm.Open(gormDialects[dialect], db)
+			if err != nil {
+				t.Fatalf("cannot open DB connection: %s", err)
+			}
+			if err := Migrate(db, dialect); err != nil {
+				t.Fatalf("Migrate() error = %v", err)
+			}
+			scope := gdb.NewScope(nil)
+			for _, m := range all {
+				ms := gdb.NewScope(m)
+				for _, f := range ms.GetModelStruct().StructFields {
+					if f.IsNormal && !scope.Dialect().HasColumn(ms.TableName(), f.DBName) {
+						t.Errorf("migrations miss column %s.%s", ms.TableName(), f.DBName)
+					}
+					if idx, ok := f.TagSettingsGet("INDEX"); ok {
+						if idx == "INDEX" {
+							idx = "idx_" + ms.TableName() + "_" + f.DBName
+						}
+						if !scope.Dialect().HasIndex(ms.TableName(), idx) {
+							t.Errorf("migrations miss index %s", idx)
+						}
+					}
+					if _, ok := f.TagSettingsGet("UNIQUE_INDEX"); ok {
+						if idx := "uix_" + ms.TableName() + "_" + f.DBName; !scope.Dialect().HasIndex(ms.TableName(), idx) {
+							t.Errorf("migrations miss unique index %s", idx)
+						}
+					}
+				}
+			}
+
+			// AutoMigrate created the schema before migrations existed
+			m, _ := NewMigrator(db, dialect)
+			if err := m.To(0); err != nil {
+				t.Fatalf("To(0) error = %v", err)
+			}
+			gdb.AutoMigrate(adopted[dialect]...)
+			if err := m.To(1); err != nil {
+				t.Errorf("To(1) of a database created by AutoMigrate error = %v", err)
+			}
+		})
+	}
+}
+
+// baselineSchema is the schema AutoMigrate created before trips had owners, and before the queue items were traced
+// and fetched from several mailboxes
+const baselineSchema = `
+CREATE TABLE "trips" ("id" varchar(255),"reference" varchar(255),"start" datetime,"end" datetime , PRIMARY KEY ("id"));
+CREATE TABLE "trip_steps" ("id" varchar(255),"trip_id" varchar(255),"type" varchar(255),"date_time" datetime,"location" varchar(255),"description" varchar(255) , PRIMARY KEY ("id"));
+CREATE TABLE "queue_items" ("id" varchar(255),"email_id" varchar(255),"subject" varchar(255),"date" varchar(255),"size" bigint,"content" varchar(255),"state" varchar(255),"job_id" varchar(255),"trip_id" varchar(255),"detail" varchar(255),"next_check_at" datetime,"created_at" datetime,"updated_at" datetime , PRIMARY KEY ("id"));
+INSERT INTO "trips" VALUES ('T0', 'REF0', '2023-01-01 00:00:00', '2023-01-02 00:00:00');
+INSERT INTO "trip_steps" VALUES ('S0', 'T0', 'hotel', '2023-01-01 00:00:00', 'Paris', 'Hotel');
+INSERT INTO "queue_items" ("id", "email_id", "state") VALUES ('Q0', 'MSG0', 'pending');
+`
+
+func Test_Migrate_baseline(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("cannot create memory sqlite database: %s", err)
+	}
+	db.SetMaxOpenConns(1)
+	if _, err := db.Exec(baselineSchema); err != nil {
+		t.Fatalf("cannot create baseline schema: %s", err)
+	}
+	if err := Migrate(db, DialectSQLite); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	// the owner index is on the column added, not on a literal
+	var column string
+	if err := db.QueryRow("SELECT name FROM pragma_index_info('idx_trips_owner')").Scan(&column); err != nil ||
+		column != "owner" {
+		t.Errorf("idx_trips_owner indexes %q, %v, want owner", column, err)
+	}
+	repo, err := NewSQLiteTripRepo(db)
+	if err != nil {
+		t.Fatalf("NewSQLiteTripRepo() error = %v", err)
+	}
+	trip, err := repo.GetOne(model.Trip{ID: "T0"})
+	if err != nil || trip.Reference != "REF0" || len(trip.TripSteps) != 1 {
+		t.Errorf("GetOne() of the baseline trip got = %v, %v", trip, err)
+	}
+	if err := repo.Create(context.Background(), &model.Trip{Owner: "alice", Reference: "REF1"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if page, err := repo.Find(model.TripQuery{Owner: "alice"}); err != nil || len(page.Trips) != 1 ||
+		page.Trips[0].Reference != "REF1" {
+		t.Errorf("Find() of the owner got = %v, %v, want trip REF1", page, err)
+	}
+
+	queue, err := NewJobQueue(db, DialectSQLite)
+	if err != nil {
+		t.Fatalf("NewJobQueue() error = %v", err)
+	}
+	item, err := queue.Get("Q0")
+	if err != nil || item.EmailID != "MSG0" {
+		t.Fatalf("Get() of the baseline item got = %v, %v", item, err)
+	}
+	item.MailboxID, item.Owner, item.TraceParent = "configured", "alice", "00-trace"
+	if err := queue.Update(&item); err != nil {
+		t.Errorf("Update() with the columns added error = %v", err)
+	}
+}
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS "travellers";
+DROP TABLE IF EXISTS "trip_steps";
+DROP TABLE IF EXISTS "trips";
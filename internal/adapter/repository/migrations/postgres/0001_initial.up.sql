@@ -0,0 +1,10 @@
+-- schema created by gorm AutoMigrate so far, existing databases are left as is
+CREATE TABLE IF NOT EXISTS "trips" ("id" text,"owner" text,"reference" text,"start" timestamp with time zone,"end" timestamp with time zone , PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS idx_trips_owner ON "trips"("owner");
+CREATE INDEX IF NOT EXISTS idx_trips_reference ON "trips"("reference");
+CREATE INDEX IF NOT EXISTS idx_trips_start ON "trips"("start");
+CREATE INDEX IF NOT EXISTS idx_trips_end ON "trips"("end");
+CREATE TABLE IF NOT EXISTS "trip_steps" ("id" text,"trip_id" text,"type" text,"date_time" timestamp with time zone,"location" text,"description" text , PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS idx_trip_steps_trip_id ON "trip_steps"(trip_id);
+CREATE TABLE IF NOT EXISTS "travellers" ("id" text,"trip_id" text,"first_name" text,"last_name" text , PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS idx_travellers_trip_id ON "travellers"(trip_id);
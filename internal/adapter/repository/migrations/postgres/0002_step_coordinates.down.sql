@@ -0,0 +1,3 @@
+ALTER TABLE "trip_steps" DROP COLUMN IF EXISTS "location_code";
+ALTER TABLE "trip_steps" DROP COLUMN IF EXISTS "latitude";
+ALTER TABLE "trip_steps" DROP COLUMN IF EXISTS "longitude";
@@ -0,0 +1,3 @@
+ALTER TABLE "trip_steps" ADD COLUMN "location_code" text;
+ALTER TABLE "trip_steps" ADD COLUMN "latitude" numeric;
+ALTER TABLE "trip_steps" ADD COLUMN "longitude" numeric;
@@ -0,0 +1,8 @@
+DROP TABLE "geocodes";
+DROP TABLE "backfills";
+DROP TABLE "mailboxes";
+DROP TABLE "users";
+DROP TABLE "api_keys";
+DROP TABLE "failures";
+DROP TABLE "processed_messages";
+DROP TABLE "queue_items";
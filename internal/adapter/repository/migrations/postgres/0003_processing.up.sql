@@ -0,0 +1,14 @@
+CREATE TABLE "queue_items" ("id" text,"email_id" text,"subject" text,"date" text,"size" bigint,"content" text,"state" text,"job_id" text,"trip_id" text,"detail" text,"next_check_at" timestamp with time zone,"trace_parent" text,"mailbox_id" text,"owner" text,"created_at" timestamp with time zone,"updated_at" timestamp with time zone , PRIMARY KEY ("id"));
+CREATE INDEX idx_queue_items_email_id ON "queue_items"(email_id);
+CREATE INDEX idx_queue_items_state ON "queue_items"("state");
+CREATE TABLE "processed_messages" ("message_id" text,"content_hash" text,"outcome" text,"job_id" text,"trip_id" text,"detail" text,"processed_at" timestamp with time zone , PRIMARY KEY ("message_id"));
+CREATE TABLE "failures" ("id" text,"email_id" text,"subject" text,"date" text,"stage" text,"job_id" text,"detail" text,"warnings" text,"attempts" integer,"created_at" timestamp with time zone,"updated_at" timestamp with time zone , PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX uix_failures_email_id ON "failures"(email_id);
+CREATE TABLE "api_keys" ("id" text,"name" text,"subject" text,"role" text,"hash" text,"created_at" timestamp with time zone , PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX uix_api_keys_hash ON "api_keys"("hash");
+CREATE TABLE "users" ("id" text,"subject" text,"name" text,"role" text,"created_at" timestamp with time zone , PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX uix_users_subject ON "users"("subject");
+CREATE TABLE "mailboxes" ("id" text,"owner" text,"provider" text,"address" text,"credentials" text,"token" text,"created_at" timestamp with time zone,"updated_at" timestamp with time zone , PRIMARY KEY ("id"));
+CREATE INDEX idx_mailboxes_owner ON "mailboxes"("owner");
+CREATE TABLE "backfills" ("id" text,"mailbox_id" text,"sender" text,"after" timestamp with time zone,"before" timestamp with time zone,"checkpoint" timestamp with time zone,"fetched" integer,"queued" integer,"created_at" timestamp with time zone,"updated_at" timestamp with time zone , PRIMARY KEY ("id"));
+CREATE TABLE "geocodes" ("address" text,"found" boolean,"latitude" numeric,"longitude" numeric,"created_at" timestamp with time zone , PRIMARY KEY ("address"));
@@ -0,0 +1 @@
+ALTER TABLE "trip_steps" ADD COLUMN "time_zone" text;
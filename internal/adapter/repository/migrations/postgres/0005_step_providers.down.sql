@@ -0,0 +1 @@
+ALTER TABLE "trip_steps" DROP COLUMN IF EXISTS "provider";
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS "mailboxes";
+DROP TABLE IF EXISTS "users";
+DROP TABLE IF EXISTS "api_keys";
+DROP TABLE IF EXISTS "failures";
+DROP TABLE IF EXISTS "processed_messages";
+DROP TABLE IF EXISTS "queue_items";
+DROP TABLE IF EXISTS "travellers";
+DROP TABLE IF EXISTS "trip_steps";
+DROP TABLE IF EXISTS "trips";
@@ -0,0 +1,25 @@
+-- schema created by gorm AutoMigrate so far, the columns added since the tables of an existing database were created
+-- are added beforehand by the migrator, its data is kept
+CREATE TABLE IF NOT EXISTS "trips" ("id" varchar(255),"owner" varchar(255),"reference" varchar(255),"start" datetime,"end" datetime , PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS idx_trips_owner ON "trips"("owner");
+CREATE INDEX IF NOT EXISTS idx_trips_reference ON "trips"("reference");
+CREATE INDEX IF NOT EXISTS idx_trips_start ON "trips"("start");
+CREATE INDEX IF NOT EXISTS idx_trips_end ON "trips"("end");
+CREATE TABLE IF NOT EXISTS "trip_steps" ("id" varchar(255),"trip_id" varchar(255),"type" varchar(255),"date_time" datetime,"location" varchar(255),"description" varchar(255) , PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS idx_trip_steps_trip_id ON "trip_steps"(trip_id);
+CREATE TABLE IF NOT EXISTS "travellers" ("id" varchar(255),"trip_id" varchar(255),"first_name" varchar(255),"last_name" varchar(255) , PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS idx_travellers_trip_id ON "travellers"(trip_id);
+
+CREATE TABLE IF NOT EXISTS "queue_items" ("id" varchar(255),"email_id" varchar(255),"subject" varchar(255),"date" varchar(255),"size" bigint,"content" varchar(255),"state" varchar(255),"job_id" varchar(255),"trip_id" varchar(255),"detail" varchar(255),"next_check_at" datetime,"trace_parent" varchar(255),"mailbox_id" varchar(255),"owner" varchar(255),"created_at" datetime,"updated_at" datetime , PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS idx_queue_items_email_id ON "queue_items"(email_id);
+CREATE INDEX IF NOT EXISTS idx_queue_items_state ON "queue_items"("state");
+CREATE TABLE IF NOT EXISTS "processed_messages" ("message_id" varchar(255),"content_hash" varchar(255),"outcome" varchar(255),"job_id" varchar(255),"trip_id" varchar(255),"detail" varchar(255),"processed_at" datetime , PRIMARY KEY ("message_id"));
+CREATE TABLE IF NOT EXISTS "failures" ("id" varchar(255),"email_id" varchar(255),"subject" varchar(255),"date" varchar(255),"stage" varchar(255),"job_id" varchar(255),"detail" varchar(255),"warnings" text,"attempts" integer,"created_at" datetime,"updated_at" datetime , PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS uix_failures_email_id ON "failures"(email_id);
+
+CREATE TABLE IF NOT EXISTS "api_keys" ("id" varchar(255),"name" varchar(255),"subject" varchar(255),"role" varchar(255),"hash" varchar(255),"created_at" datetime , PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS uix_api_keys_hash ON "api_keys"("hash");
+CREATE TABLE IF NOT EXISTS "users" ("id" varchar(255),"subject" varchar(255),"name" varchar(255),"role" varchar(255),"created_at" datetime , PRIMARY KEY ("id"));
+CREATE UNIQUE INDEX IF NOT EXISTS uix_users_subject ON "users"("subject");
+CREATE TABLE IF NOT EXISTS "mailboxes" ("id" varchar(255),"owner" varchar(255),"provider" varchar(255),"address" varchar(255),"credentials" varchar(255),"token" varchar(255),"created_at" datetime,"updated_at" datetime , PRIMARY KEY ("id"));
+CREATE INDEX IF NOT EXISTS idx_mailboxes_owner ON "mailboxes"("owner");
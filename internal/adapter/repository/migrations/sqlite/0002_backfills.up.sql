@@ -0,0 +1 @@
+CREATE TABLE "backfills" ("id" varchar(255),"mailbox_id" varchar(255),"sender" varchar(255),"after" datetime,"before" datetime,"checkpoint" datetime,"fetched" integer,"queued" integer,"created_at" datetime,"updated_at" datetime , PRIMARY KEY ("id"));
@@ -0,0 +1,4 @@
+ALTER TABLE "trip_steps" ADD COLUMN "location_code" varchar(255);
+ALTER TABLE "trip_steps" ADD COLUMN "latitude" real;
+ALTER TABLE "trip_steps" ADD COLUMN "longitude" real;
+CREATE TABLE "geocodes" ("address" varchar(255),"found" bool,"latitude" real,"longitude" real,"created_at" datetime , PRIMARY KEY ("address"));
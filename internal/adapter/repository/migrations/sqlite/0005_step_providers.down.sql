@@ -0,0 +1,6 @@
+-- SQLite cannot drop columns, the steps are copied to the previous table
+CREATE TABLE "trip_steps_previous" ("id" varchar(255),"trip_id" varchar(255),"type" varchar(255),"date_time" datetime,"location" varchar(255),"description" varchar(255),"location_code" varchar(255),"latitude" real,"longitude" real,"time_zone" varchar(255) , PRIMARY KEY ("id"));
+INSERT INTO "trip_steps_previous" SELECT "id", "trip_id", "type", "date_time", "location", "description", "location_code", "latitude", "longitude", "time_zone" FROM "trip_steps";
+DROP TABLE "trip_steps";
+ALTER TABLE "trip_steps_previous" RENAME TO "trip_steps";
+CREATE INDEX IF NOT EXISTS idx_trip_steps_trip_id ON "trip_steps"(trip_id);
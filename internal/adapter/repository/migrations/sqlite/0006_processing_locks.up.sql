@@ -0,0 +1 @@
+CREATE TABLE "processing_locks" ("name" varchar(255),"holder" varchar(255),"expires_at" integer , PRIMARY KEY ("name"));
@@ -0,0 +1,22 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"database/sql"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+// NewPostgresTripRepo stores trips in a PostgreSQL database, which can be shared by several replicas
+func NewPostgresTripRepo(db *sql.DB) (domain.TripRepository, error) {
+	if db == nil {
+		return nil, fmt.Errorf("failed due to nil DB pointer")
+	}
+	gdb, err := gorm.Open("postgres", db)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open DB connection: %w", err)
+	}
+	return newTripRepo(gdb, semconv.DBSystemPostgreSQL), nil
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"database/sql"
+	"flag"
+	"fmt"
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/google/uuid"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// postgresDSN is the server the trip repository suite also runs against: TEST_POSTGRES_DSN, in key=value form,
+// or an embedded server downloaded on first use, none in short mode. The PostgreSQL cases are skipped when the
+// embedded server cannot start, such as offline.
+var postgresDSN string
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	if dsn := os.Getenv("TEST_POSTGRES_DSN"); dsn != "" {
+		postgresDSN = dsn
+	} else if !testing.Short() {
+		dsn, stop, err := startEmbeddedPostgres()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "skipping postgres tests, cannot start embedded postgres: %v\n", err)
+		} else {
+			defer stop()
+			postgresDSN = dsn
+		}
+	}
+	return m.Run()
+}
+
+func startEmbeddedPostgres() (dsn string, stop func(), err error) {
+	dir, err := ioutil.TempDir("", "postgres")
+	if err != nil {
+		return "", nil, err
+	}
+	pg := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(54329).
+		RuntimePath(dir).
+		Logger(ioutil.Discard))
+	if err := pg.Start(); err != nil {
+		os.RemoveAll(dir)
+		return "", nil, err
+	}
+	return "host=localhost port=54329 user=postgres password=postgres dbname=postgres sslmode=disable", func() {
+		pg.Stop()
+		os.RemoveAll(dir)
+	}, nil
+}
+
+// getPostgresDB connects to a migrated schema, dropped once the test is done
+func getPostgresDB(t *testing.T) *sql.DB {
+	db := getPostgresSchema(t)
+	if err := Migrate(db, DialectPostgres); err != nil {
+		t.Fatalf("cannot migrate postgres schema: %s", err)
+	}
+	return db
+}
+
+// getPostgresSchema connects to an empty schema, dropped once the test is done
+func getPostgresSchema(t *testing.T) *sql.DB {
+	schema := "test_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+	admin, err := sql.Open("postgres", postgresDSN)
+	if err != nil {
+		t.Fatalf("cannot connect to postgres: %s", err)
+	}
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatalf("cannot create postgres schema: %s", err)
+	}
+	db, err := sql.Open("postgres", postgresDSN+" search_path="+schema)
+	if err != nil {
+		t.Fatalf("cannot connect to postgres: %s", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		admin.Close()
+	})
+	return db
+}
+
+func TestNewPostgresTripRepo(t *testing.T) {
+	if _, err := NewPostgresTripRepo(nil); err == nil {
+		t.Errorf("NewPostgresTripRepo() without DB succeeded")
+	}
+}
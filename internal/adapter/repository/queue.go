@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+type jobQueue struct {
+	db *gorm.DB
+}
+
+func NewJobQueue(db *sql.DB, dialect string) (domain.JobQueue, error) {
+	gdb, err := openGorm(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &jobQueue{gdb}, nil
+}
+
+func (s *jobQueue) Enqueue(item *model.QueueItem) error {
+	if item.ID == "" {
+		item.ID = uuid.New().String()
+	}
+	if item.State == "" {
+		item.State = model.ProcessingStateFetched
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var count int
+		dbc := tx.Model(&model.QueueItem{}).
+			Where("email_id = ? AND state NOT IN (?)", item.EmailID,
+				[]string{model.ProcessingStateDone, model.ProcessingStateFailed}).
+			Count(&count)
+		if dbc.Error != nil {
+			return fmt.Errorf("failed database query when looking for queued email %s: %w", item.EmailID, dbc.Error)
+		}
+		if count > 0 {
+			return fmt.Errorf("%w: %s", domain.ErrorAlreadyQueued, item.EmailID)
+		}
+		if dbc := tx.Create(item); dbc.Error != nil {
+			return fmt.Errorf("failed adding email %s to queue: %w", item.EmailID, dbc.Error)
+		}
+		return nil
+	})
+}
+
+func (s *jobQueue) Update(item *model.QueueItem) error {
+	if dbc := s.db.Save(item); dbc.Error != nil {
+		return fmt.Errorf("failed updating queue item %s: %w", item.ID, dbc.Error)
+	}
+	return nil
+}
+
+func (s *jobQueue) Get(id string) (model.QueueItem, error) {
+	var item model.QueueItem
+	if dbc := s.db.Where("id = ?", id).First(&item); dbc.Error != nil {
+		if dbc.Error == gorm.ErrRecordNotFound {
+			return model.QueueItem{}, domain.ErrorItemNotFound
+		}
+		return model.QueueItem{}, fmt.Errorf("failed database query when looking for queue item %s: %w", id, dbc.Error)
+	}
+	return item, nil
+}
+
+func (s *jobQueue) GetByEmailID(emailID string) (model.QueueItem, error) {
+	var item model.QueueItem
+	if dbc := s.db.Where("email_id = ?", emailID).Order("created_at DESC").First(&item); dbc.Error != nil {
+		if dbc.Error == gorm.ErrRecordNotFound {
+			return model.QueueItem{}, domain.ErrorItemNotFound
+		}
+		return model.QueueItem{}, fmt.Errorf("failed database query when looking for queued email %s: %w", emailID, dbc.Error)
+	}
+	return item, nil
+}
+
+func (s *jobQueue) ListByState(states ...model.ProcessingState) ([]model.QueueItem, error) {
+	var items []model.QueueItem
+	if dbc := s.db.Where("state IN (?)", states).Order("created_at").Find(&items); dbc.Error != nil {
+		return nil, fmt.Errorf("failed database query when listing queue items in states %v: %w", states, dbc.Error)
+	}
+	return items, nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"testing"
+)
+
+func Test_jobQueue(t *testing.T) {
+	for dialect, db := range storeDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			q, err := NewJobQueue(db, dialect)
+			if err != nil {
+				t.Fatalf("NewJobQueue() error = %v", err)
+			}
+
+			first := &model.QueueItem{EmailID: "MSG0", Subject: "booking", Content: "content"}
+			if err := q.Enqueue(first); err != nil {
+				t.Fatalf("Enqueue() error = %v", err)
+			}
+			if first.ID == "" || first.State != model.ProcessingStateFetched {
+				t.Errorf("Enqueue() got id %q and state %s, want an id and state %s", first.ID, first.State, model.ProcessingStateFetched)
+			}
+			if err := q.Enqueue(&model.QueueItem{EmailID: "MSG0"}); !errors.Is(err, domain.ErrorAlreadyQueued) {
+				t.Errorf("Enqueue() of an unfinished email error = %v, want %v", err, domain.ErrorAlreadyQueued)
+			}
+
+			second := &model.QueueItem{EmailID: "MSG1"}
+			if err := q.Enqueue(second); err != nil {
+				t.Fatalf("Enqueue() error = %v", err)
+			}
+			second.State = model.ProcessingStatePending
+			second.JobID = "JOB1"
+			if err := q.Update(second); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+
+			first.State = model.ProcessingStateDone
+			if err := q.Update(first); err != nil {
+				t.Fatalf("Update() error = %v", err)
+			}
+			if err := q.Enqueue(&model.QueueItem{EmailID: "MSG0"}); err != nil {
+				t.Errorf("Enqueue() of a finished email error = %v", err)
+			}
+
+			latest, err := q.GetByEmailID("MSG0")
+			if err != nil || latest.ID == first.ID || latest.State != model.ProcessingStateFetched {
+				t.Errorf("GetByEmailID() got = %v, %v, want the latest item of MSG0", latest, err)
+			}
+
+			got, err := q.Get(second.ID)
+			if err != nil || got.JobID != "JOB1" || got.State != model.ProcessingStatePending {
+				t.Errorf("Get() got = %v, %v, want job JOB1 in state pending", got, err)
+			}
+			if _, err := q.Get("1111"); !errors.Is(err, domain.ErrorItemNotFound) {
+				t.Errorf("Get() error = %v, want %v", err, domain.ErrorItemNotFound)
+			}
+
+			unfinished, err := q.ListByState(model.ProcessingStateFetched, model.ProcessingStatePending)
+			if err != nil {
+				t.Fatalf("ListByState() error = %v", err)
+			}
+			if len(unfinished) != 2 || unfinished[0].EmailID != "MSG1" || unfinished[1].EmailID != "MSG0" {
+				t.Errorf("ListByState() got = %v, want MSG1 then MSG0", unfinished)
+			}
+		})
+	}
+}
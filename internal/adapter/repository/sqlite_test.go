@@ -1,21 +1,59 @@
 package repository
 
 import (
+	"amadeus-trip-parser/internal/domain"
 	"amadeus-trip-parser/internal/domain/model"
+	"context"
 	"database/sql"
+	"errors"
 	"github.com/google/uuid"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 )
 
+// getMemoryDB opens a migrated memory database, on a single connection since each one has its own database
 func getMemoryDB(t *testing.T) *sql.DB {
 	db, err := sql.Open("sqlite3", ":memory:")
 	if err != nil {
 		t.Errorf("cannot create memory sqlite database: %s", err)
 		return nil
 	}
+	db.SetMaxOpenConns(1)
+	if err := Migrate(db, DialectSQLite); err != nil {
+		t.Fatalf("cannot migrate memory sqlite database: %s", err)
+	}
 	return db
 }
 
+// storeDBs opens an empty migrated database on each backend, by dialect, PostgreSQL is left out without server
+func storeDBs(t *testing.T) map[string]*sql.DB {
+	dbs := map[string]*sql.DB{DialectSQLite: getMemoryDB(t)}
+	if postgresDSN != "" {
+		dbs[DialectPostgres] = getPostgresDB(t)
+	}
+	return dbs
+}
+
+// tripRepos opens an empty trip repository on each backend, PostgreSQL is left out without server
+func tripRepos(t *testing.T) map[string]domain.TripRepository {
+	repos := make(map[string]domain.TripRepository)
+	sqlite, err := NewSQLiteTripRepo(getMemoryDB(t))
+	if err != nil {
+		t.Fatalf("NewSQLiteTripRepo() error = %v", err)
+	}
+	repos["sqlite"] = sqlite
+	if postgresDSN != "" {
+		postgres, err := NewPostgresTripRepo(getPostgresDB(t))
+		if err != nil {
+			t.Fatalf("NewPostgresTripRepo() error = %v", err)
+		}
+		repos["postgres"] = postgres
+	}
+	return repos
+}
+
 func TestNewSQLiteTripRepo(t *testing.T) {
 	type args struct {
 		db *sql.DB
@@ -47,7 +85,7 @@ func TestNewSQLiteTripRepo(t *testing.T) {
 	}
 }
 
-func Test_sqliteTripRepo_Create(t *testing.T) {
+func Test_tripRepo_Create(t *testing.T) {
 	type args struct {
 		trip *model.Trip
 	}
@@ -64,11 +102,268 @@ func Test_sqliteTripRepo_Create(t *testing.T) {
 			false,
 		},
 	}
-	s, _ := NewSQLiteTripRepo(getMemoryDB(t))
+	for backend, s := range tripRepos(t) {
+		for _, tt := range tests {
+			t.Run(backend+"/"+tt.name, func(t *testing.T) {
+				if err := s.Create(context.Background(), tt.args.trip); (err != nil) != tt.wantErr {
+					t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+				}
+			})
+		}
+	}
+}
+
+func newTrip(ref string, start time.Time, location string, traveller string) *model.Trip {
+	id := uuid.New().String()
+	return &model.Trip{
+		ID:        id,
+		Reference: ref,
+		Start:     start,
+		End:       start.Add(48 * time.Hour),
+		TripSteps: []model.TripStep{
+			{
+				ID:          uuid.New().String(),
+				TripID:      id,
+				Type:        model.TripStepTypeHotel,
+				DateTime:    start,
+				Location:    location,
+				Description: "Hotel at " + location,
+			},
+		},
+		Travellers: []model.Traveller{
+			{
+				ID:        uuid.New().String(),
+				TripID:    id,
+				FirstName: "JOHN",
+				LastName:  traveller,
+			},
+		},
+	}
+}
+
+// createTrips stores two past trips and two upcoming ones of alice
+func createTrips(t *testing.T, s domain.TripRepository, now time.Time) {
+	for _, tr := range []*model.Trip{
+		newTrip("PAST01", now.AddDate(0, -2, 0), "PARIS", "SMITH"),
+		newTrip("PAST02", now.AddDate(0, -1, 0), "ROME", "DOE"),
+		newTrip("NEXT01", now.AddDate(0, 1, 0), "HAMMAMET", "SMITH"),
+		newTrip("NEXT02", now.AddDate(0, 2, 0), "100%_SUN", "DOE"),
+	} {
+		if strings.HasPrefix(tr.Reference, "NEXT") {
+			tr.Owner = "alice"
+		}
+		if err := s.Create(context.Background(), tr); err != nil {
+			t.Fatalf("cannot create trip: %v", err)
+		}
+	}
+}
+
+func Test_tripRepo_GetAll_GetOne(t *testing.T) {
+	for backend, s := range tripRepos(t) {
+		t.Run(backend, func(t *testing.T) {
+			createTrips(t, s, time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC))
+			if all, err := s.GetAll(""); err != nil || len(all) != 4 {
+				t.Errorf("GetAll() got %d trips, %v, want 4", len(all), err)
+			}
+			if owned, err := s.GetAll("alice"); err != nil || len(owned) != 2 {
+				t.Errorf("GetAll(alice) got %d trips, %v, want 2", len(owned), err)
+			}
+			got, err := s.GetOne(model.Trip{Reference: "ROME"})
+			if !errors.Is(err, domain.ErrorNotFound) {
+				t.Errorf("GetOne() of an unknown reference got %v, %v", got, err)
+			}
+			got, err = s.GetOne(model.Trip{Reference: "PAST02"})
+			if err != nil || len(got.TripSteps) != 1 || got.TripSteps[0].Location != "ROME" {
+				t.Errorf("GetOne() got %v, %v, want PAST02 with its step", got, err)
+			}
+			if _, ok := got.TripSteps[0].Coordinates(); ok {
+				t.Errorf("GetOne() got coordinates of a step not located")
+			}
+		})
+	}
+}
+
+func Test_tripRepo_Create_coordinates(t *testing.T) {
+	for backend, s := range tripRepos(t) {
+		t.Run(backend, func(t *testing.T) {
+			trip := newTrip("GEO01", time.Date(2020, 4, 6, 0, 0, 0, 0, time.UTC), "Hammamet, 8050, Tunisia", "SMITH")
+			trip.TripSteps[0].SetCoordinates(model.Coordinates{Latitude: 36.4, Longitude: 10.6})
+			trip.TripSteps = append(trip.TripSteps, model.TripStep{ID: uuid.New().String(), TripID: trip.ID,
+				Type: model.TripStepTypeFlightEnd, Location: "TUNIS", LocationCode: "TUN"})
+			if err := s.Create(context.Background(), trip); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			got, err := s.GetOne(model.Trip{Reference: "GEO01"})
+			if err != nil || len(got.TripSteps) != 2 {
+				t.Fatalf("GetOne() got %v, %v, want GEO01 with its steps", got, err)
+			}
+			for _, step := range got.TripSteps {
+				c, ok := step.Coordinates()
+				switch step.Type {
+				case model.TripStepTypeHotel:
+					if !ok || c.Latitude != 36.4 || c.Longitude != 10.6 {
+						t.Errorf("GetOne() got hotel coordinates %v, %v, want 36.4, 10.6", c, ok)
+					}
+				default:
+					if ok || step.LocationCode != "TUN" {
+						t.Errorf("GetOne() got flight coordinates %v, %v and code %s, want TUN without coordinates",
+							c, ok, step.LocationCode)
+					}
+				}
+			}
+		})
+	}
+}
+
+func Test_tripRepo_Merge(t *testing.T) {
+	for backend, s := range tripRepos(t) {
+		t.Run(backend, func(t *testing.T) {
+			start := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+			existing := newTrip("ABC123", start, "PARIS", "SMITH")
+			existing.Owner = "alice"
+			if err := s.Create(context.Background(), existing); err != nil {
+				t.Fatalf("cannot create trip: %v", err)
+			}
+
+			merged := newTrip("ABC123", start, "ROME", "DOE")
+			merged.Owner = "alice"
+			created, err := s.Merge(context.Background(), merged)
+			if err != nil || created {
+				t.Fatalf("Merge() of an existing reference got %v, %v, want an update", created, err)
+			}
+			got, err := s.GetOne(model.Trip{Reference: "ABC123", Owner: "alice"})
+			if err != nil || got.ID != existing.ID || len(got.TripSteps) != 1 || got.TripSteps[0].Location != "ROME" ||
+				len(got.Travellers) != 1 || got.Travellers[0].LastName != "DOE" {
+				t.Errorf("GetOne() after Merge() got %v, %v, want the steps merged into %s", got, err, existing.ID)
+			}
+
+			// the same reference of another owner is another trip
+			other := newTrip("ABC123", start, "OSLO", "DOE")
+			other.Owner = "bob"
+			if created, err := s.Merge(context.Background(), other); err != nil || !created {
+				t.Errorf("Merge() of another owner got %v, %v, want a creation", created, err)
+			}
+			if all, err := s.GetAll(""); err != nil || len(all) != 2 {
+				t.Errorf("GetAll() got %d trips, %v, want 2", len(all), err)
+			}
+
+			// trips without reference are not merged together
+			for _, location := range []string{"OSLO", "ROME"} {
+				unknown := newTrip("", start, location, "DOE")
+				unknown.Owner = "alice"
+				if created, err := s.Merge(context.Background(), unknown); err != nil || !created {
+					t.Errorf("Merge() of a trip without reference got %v, %v, want a creation", created, err)
+				}
+			}
+		})
+	}
+}
+
+func Test_tripRepo_Find(t *testing.T) {
+	for backend, s := range tripRepos(t) {
+		t.Run(backend, func(t *testing.T) {
+			testFind(t, s)
+		})
+	}
+}
+
+func testFind(t *testing.T, s domain.TripRepository) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	createTrips(t, s, now)
+
+	tests := []struct {
+		name      string
+		query     model.TripQuery
+		wantRefs  []string
+		wantTotal int
+	}{
+		{
+			"all trips sorted by start",
+			model.TripQuery{Sort: model.TripSortStart, Order: model.SortOrderAsc},
+			[]string{"PAST01", "PAST02", "NEXT01", "NEXT02"},
+			4,
+		},
+		{
+			"upcoming trips",
+			model.TripQuery{Period: model.TripPeriodUpcoming, Now: now},
+			[]string{"NEXT01", "NEXT02"},
+			2,
+		},
+		{
+			"past trips sorted by reference descending",
+			model.TripQuery{Period: model.TripPeriodPast, Now: now, Sort: model.TripSortReference, Order: model.SortOrderDesc},
+			[]string{"PAST02", "PAST01"},
+			2,
+		},
+		{
+			"trips overlapping a range",
+			model.TripQuery{From: now.AddDate(0, -1, 1), To: now.AddDate(0, 1, 0)},
+			[]string{"PAST02", "NEXT01"},
+			2,
+		},
+		{
+			"trips of an owner",
+			model.TripQuery{Owner: "alice"},
+			[]string{"NEXT01", "NEXT02"},
+			2,
+		},
+		{
+			"trips by location",
+			model.TripQuery{Location: "ham"},
+			[]string{"NEXT01"},
+			1,
+		},
+		{
+			"trips by location with like wildcards",
+			model.TripQuery{Location: "0%_s"},
+			[]string{"NEXT02"},
+			1,
+		},
+		{
+			"trips by step type",
+			model.TripQuery{StepType: model.TripStepTypeFlightStart},
+			nil,
+			0,
+		},
+		{
+			"trips by traveller",
+			model.TripQuery{Traveller: "john smith"},
+			[]string{"PAST01", "NEXT01"},
+			2,
+		},
+		{
+			"trips by free text",
+			model.TripQuery{Text: "rome"},
+			[]string{"PAST02"},
+			1,
+		},
+		{
+			"second page",
+			model.TripQuery{Offset: 1, Limit: 2},
+			[]string{"PAST02", "NEXT01"},
+			4,
+		},
+	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if err := s.Create(tt.args.trip); (err != nil) != tt.wantErr {
-				t.Errorf("Create() error = %v, wantErr %v", err, tt.wantErr)
+			got, err := s.Find(tt.query)
+			if err != nil {
+				t.Errorf("Find() error = %v", err)
+				return
+			}
+			var refs []string
+			for _, tr := range got.Trips {
+				refs = append(refs, tr.Reference)
+				if len(tr.TripSteps) != 1 || len(tr.Travellers) != 1 {
+					t.Errorf("Find() trip %s has %d steps and %d travellers, want 1 and 1",
+						tr.Reference, len(tr.TripSteps), len(tr.Travellers))
+				}
+			}
+			if !reflect.DeepEqual(refs, tt.wantRefs) {
+				t.Errorf("Find() got = %v, want %v", refs, tt.wantRefs)
+			}
+			if got.Total != tt.wantTotal {
+				t.Errorf("Find() total = %d, want %d", got.Total, tt.wantTotal)
 			}
 		})
 	}
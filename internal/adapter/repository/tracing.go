@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	tracingContextKey = "tracing:context"
+	tracingSpanKey    = "tracing:span"
+)
+
+var tracer = otel.Tracer("amadeus-trip-parser/repository")
+
+// withContext traces the operations run with the returned DB as children of the span of ctx
+func withContext(db *gorm.DB, ctx context.Context) *gorm.DB {
+	return db.Set(tracingContextKey, ctx)
+}
+
+// registerTracing adds callbacks starting a span around each operation run with a traced context,
+// operations without context are not traced. system is the semantic convention of the database.
+func registerTracing(db *gorm.DB, system attribute.KeyValue) {
+	cb := db.Callback()
+	cb.Create().Before("gorm:begin_transaction").Register("tracing:start_create", startSpan(system, "create"))
+	cb.Create().After("gorm:commit_or_rollback_transaction").Register("tracing:end_create", endSpan)
+	cb.Update().Before("gorm:begin_transaction").Register("tracing:start_update", startSpan(system, "update"))
+	cb.Update().After("gorm:commit_or_rollback_transaction").Register("tracing:end_update", endSpan)
+	cb.Delete().Before("gorm:begin_transaction").Register("tracing:start_delete", startSpan(system, "delete"))
+	cb.Delete().After("gorm:commit_or_rollback_transaction").Register("tracing:end_delete", endSpan)
+	cb.Query().Before("gorm:query").Register("tracing:start_query", startSpan(system, "query"))
+	cb.Query().After("gorm:after_query").Register("tracing:end_query", endSpan)
+	cb.RowQuery().Before("gorm:row_query").Register("tracing:start_row_query", startSpan(system, "row_query"))
+	cb.RowQuery().After("gorm:row_query").Register("tracing:end_row_query", endSpan)
+}
+
+func startSpan(system attribute.KeyValue, operation string) func(scope *gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		v, ok := scope.Get(tracingContextKey)
+		if !ok {
+			return
+		}
+		ctx, span := tracer.Start(v.(context.Context), "gorm."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				system,
+				semconv.DBOperationKey.String(operation),
+				semconv.DBSQLTableKey.String(scope.TableName())))
+		scope.Set(tracingSpanKey, span)
+		// associations saved along are traced as children
+		scope.Set(tracingContextKey, ctx)
+	}
+}
+
+func endSpan(scope *gorm.Scope) {
+	v, ok := scope.Get(tracingSpanKey)
+	if !ok {
+		return
+	}
+	span := v.(trace.Span)
+	span.SetAttributes(semconv.DBStatementKey.String(scope.SQL), attribute.Int64("db.rows_affected", scope.DB().RowsAffected))
+	if err := scope.DB().Error; err != nil && err != gorm.ErrRecordNotFound {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"testing"
+)
+
+var testTracerProvider = sdktrace.NewTracerProvider()
+
+func init() {
+	// the global provider delegates to the first one set only
+	otel.SetTracerProvider(testTracerProvider)
+}
+
+func Test_registerTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	testTracerProvider.RegisterSpanProcessor(recorder)
+	defer testTracerProvider.UnregisterSpanProcessor(recorder)
+
+	repo, err := NewSQLiteTripRepo(getMemoryDB(t))
+	if !assert.NoError(t, err) {
+		return
+	}
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "parent")
+	assert.NoError(t, repo.Create(ctx, &model.Trip{Reference: "REF"}))
+	parent.End()
+	// operations without context are not traced
+	_, err = repo.GetAll("")
+	assert.NoError(t, err)
+
+	var names []string
+	for _, s := range recorder.Ended() {
+		names = append(names, s.Name())
+		if s.Name() == "gorm.create" {
+			assert.Equal(t, parent.SpanContext().SpanID(), s.Parent().SpanID())
+			assert.Equal(t, parent.SpanContext().TraceID(), s.SpanContext().TraceID())
+		}
+	}
+	assert.Contains(t, names, "gorm.create")
+	assert.NotContains(t, names, "gorm.query")
+}
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"go.opentelemetry.io/otel/attribute"
+	"math"
+	"strings"
+)
+
+// tripRepo stores trips with gorm, its queries are portable across the SQLite and PostgreSQL dialects
+type tripRepo struct {
+	db *gorm.DB
+}
+
+// newTripRepo traces the queries as made to the given database system
+func newTripRepo(db *gorm.DB, system attribute.KeyValue) *tripRepo {
+	registerTracing(db, system)
+	return &tripRepo{db}
+}
+
+func (s *tripRepo) GetAll(owner string) ([]model.Trip, error) {
+	var trips []model.Trip
+	if dbc := s.db.Preload("TripSteps").Preload("Travellers").Where(model.Trip{Owner: owner}).Find(&trips); dbc.Error != nil {
+		return nil, fmt.Errorf("failed database query for getting all trips: %w", dbc.Error)
+	}
+	return trips, nil
+}
+
+func (s *tripRepo) GetOne(query model.Trip) (model.Trip, error) {
+	var trip model.Trip
+	if dbc := s.db.Preload("TripSteps").Preload("Travellers").Where(query).First(&trip); dbc.Error != nil {
+		if dbc.Error == gorm.ErrRecordNotFound {
+			return model.Trip{}, domain.ErrorNotFound
+		}
+		return model.Trip{}, fmt.Errorf("failed database query when looking for trip with query %s: %w", query, dbc.Error)
+	}
+	return trip, nil
+}
+
+func (s *tripRepo) Find(query model.TripQuery) (model.TripPage, error) {
+	db := filterTrips(s.db.Model(&model.Trip{}), query)
+
+	var total int
+	if dbc := db.Count(&total); dbc.Error != nil {
+		return model.TripPage{}, fmt.Errorf("failed database query when counting trips with query %v: %w", query, dbc.Error)
+	}
+
+	db = db.Order(tripOrder(query)).Order("trips.id")
+	if query.Limit > 0 || query.Offset > 0 {
+		// SQLite does not accept an offset without a limit
+		limit := query.Limit
+		if limit <= 0 {
+			limit = math.MaxInt32
+		}
+		db = db.Limit(limit).Offset(query.Offset)
+	}
+	var trips []model.Trip
+	dbc := db.Preload("TripSteps", func(db *gorm.DB) *gorm.DB { return db.Order("trip_steps.date_time") }).
+		Preload("Travellers").
+		Find(&trips)
+	if dbc.Error != nil {
+		return model.TripPage{}, fmt.Errorf("failed database query when looking for trips with query %v: %w", query, dbc.Error)
+	}
+	return model.TripPage{
+		Trips:  trips,
+		Total:  total,
+		Offset: query.Offset,
+		Limit:  query.Limit,
+	}, nil
+}
+
+func (s *tripRepo) Create(ctx context.Context, trip *model.Trip) error {
+	if dbc := withContext(s.db, ctx).Create(&trip); dbc.Error != nil {
+		return fmt.Errorf("failed creating new trip in repository: %w", dbc.Error)
+	}
+	return nil
+}
+
+func (s *tripRepo) Merge(ctx context.Context, trip *model.Trip) (bool, error) {
+	tx := withContext(s.db, ctx).Begin()
+	if tx.Error != nil {
+		return false, fmt.Errorf("failed starting transaction for merging trip: %w", tx.Error)
+	}
+	created, err := mergeTrip(tx, trip)
+	if err != nil {
+		tx.Rollback()
+		return false, err
+	}
+	if dbc := tx.Commit(); dbc.Error != nil {
+		return false, fmt.Errorf("failed committing merged trip: %w", dbc.Error)
+	}
+	return created, nil
+}
+
+// mergeTrip replaces the steps and travellers of the trip with the same owner and reference, which keeps its ID.
+// Trips without reference are matched by ID, they would all be the same otherwise.
+func mergeTrip(tx *gorm.DB, trip *model.Trip) (bool, error) {
+	var existing model.Trip
+	query := tx.Where("owner = ? AND reference = ?", trip.Owner, trip.Reference)
+	if trip.Reference == "" {
+		query = tx.Where("owner = ? AND id = ?", trip.Owner, trip.ID)
+	}
+	dbc := query.First(&existing)
+	if dbc.Error != nil && !gorm.IsRecordNotFoundError(dbc.Error) {
+		return false, fmt.Errorf("failed database query when looking for trip %s of %q: %w", trip.Reference,
+			trip.Owner, dbc.Error)
+	}
+	if dbc.Error != nil {
+		if dbc := tx.Create(trip); dbc.Error != nil {
+			return false, fmt.Errorf("failed creating trip %s in repository: %w", trip.Reference, dbc.Error)
+		}
+		return true, nil
+	}
+	trip.ID = existing.ID
+	for i := range trip.TripSteps {
+		trip.TripSteps[i].TripID = existing.ID
+	}
+	for i := range trip.Travellers {
+		trip.Travellers[i].TripID = existing.ID
+	}
+	if dbc := tx.Where("trip_id = ?", existing.ID).Delete(model.TripStep{}); dbc.Error != nil {
+		return false, fmt.Errorf("failed deleting steps of trip %s: %w", existing.ID, dbc.Error)
+	}
+	if dbc := tx.Where("trip_id = ?", existing.ID).Delete(model.Traveller{}); dbc.Error != nil {
+		return false, fmt.Errorf("failed deleting travellers of trip %s: %w", existing.ID, dbc.Error)
+	}
+	if dbc := tx.Save(trip); dbc.Error != nil {
+		return false, fmt.Errorf("failed updating trip %s in repository: %w", existing.ID, dbc.Error)
+	}
+	return false, nil
+}
+
+// filterTrips translates a trip query into SQL conditions, step and traveller
+// criteria are matched with sub-queries so that pagination still applies to trips
+func filterTrips(db *gorm.DB, q model.TripQuery) *gorm.DB {
+	if q.Owner != "" {
+		db = db.Where("trips.owner = ?", q.Owner)
+	}
+	switch q.Period {
+	case model.TripPeriodUpcoming:
+		db = db.Where(`trips."end" >= ?`, q.Now.UTC())
+	case model.TripPeriodPast:
+		db = db.Where(`trips."end" < ?`, q.Now.UTC())
+	}
+	if !q.From.IsZero() {
+		db = db.Where(`trips."end" >= ?`, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		db = db.Where(`trips.start <= ?`, q.To.UTC())
+	}
+	if q.Location != "" {
+		db = db.Where(`EXISTS (SELECT 1 FROM trip_steps WHERE trip_steps.trip_id = trips.id
+			AND LOWER(trip_steps.location) LIKE ? ESCAPE '\')`, likePattern(q.Location))
+	}
+	if q.StepType != "" {
+		db = db.Where(`EXISTS (SELECT 1 FROM trip_steps WHERE trip_steps.trip_id = trips.id
+			AND trip_steps.type = ?)`, q.StepType)
+	}
+	if q.Traveller != "" {
+		db = db.Where(`EXISTS (SELECT 1 FROM travellers WHERE travellers.trip_id = trips.id
+			AND LOWER(travellers.first_name || ' ' || travellers.last_name) LIKE ? ESCAPE '\')`,
+			likePattern(q.Traveller))
+	}
+	if q.Text != "" {
+		p := likePattern(q.Text)
+		db = db.Where(`LOWER(trips.reference) LIKE ? ESCAPE '\'
+			OR EXISTS (SELECT 1 FROM trip_steps WHERE trip_steps.trip_id = trips.id
+				AND (LOWER(trip_steps.location) LIKE ? ESCAPE '\' OR LOWER(trip_steps.description) LIKE ? ESCAPE '\'))
+			OR EXISTS (SELECT 1 FROM travellers WHERE travellers.trip_id = trips.id
+				AND LOWER(travellers.first_name || ' ' || travellers.last_name) LIKE ? ESCAPE '\')`,
+			p, p, p, p)
+	}
+	return db
+}
+
+func tripOrder(q model.TripQuery) string {
+	column := "trips.start"
+	switch q.Sort {
+	case model.TripSortEnd:
+		column = `trips."end"`
+	case model.TripSortReference:
+		column = "trips.reference"
+	}
+	if q.Order == model.SortOrderDesc {
+		return column + " DESC"
+	}
+	return column + " ASC"
+}
+
+func likePattern(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(strings.ToLower(s))
+	return "%" + s + "%"
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"database/sql"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/jinzhu/gorm"
+)
+
+type userStore struct {
+	db *gorm.DB
+}
+
+func NewUserStore(db *sql.DB, dialect string) (domain.UserStore, error) {
+	gdb, err := openGorm(db, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &userStore{gdb}, nil
+}
+
+func (s *userStore) Create(user *model.User) error {
+	user.ID = uuid.New().String()
+	if dbc := s.db.Create(user); dbc.Error != nil {
+		return fmt.Errorf("failed creating user %s: %w", user.Subject, dbc.Error)
+	}
+	return nil
+}
+
+func (s *userStore) Get(id string) (model.User, error) {
+	return s.first("id = ?", id)
+}
+
+func (s *userStore) GetBySubject(subject string) (model.User, error) {
+	return s.first("subject = ?", subject)
+}
+
+func (s *userStore) first(query string, value string) (model.User, error) {
+	var user model.User
+	if dbc := s.db.Where(query, value).First(&user); dbc.Error != nil {
+		if dbc.Error == gorm.ErrRecordNotFound {
+			return model.User{}, domain.ErrorNoUser
+		}
+		return model.User{}, fmt.Errorf("failed database query when looking for user %s: %w", value, dbc.Error)
+	}
+	return user, nil
+}
+
+func (s *userStore) List() ([]model.User, error) {
+	users := []model.User{}
+	if dbc := s.db.Order("subject").Find(&users); dbc.Error != nil {
+		return nil, fmt.Errorf("failed database query when listing users: %w", dbc.Error)
+	}
+	return users, nil
+}
+
+func (s *userStore) Delete(id string) error {
+	dbc := s.db.Where("id = ?", id).Delete(&model.User{})
+	if dbc.Error != nil {
+		return fmt.Errorf("failed deleting user %s: %w", id, dbc.Error)
+	}
+	if dbc.RowsAffected == 0 {
+		return domain.ErrorNoUser
+	}
+	return nil
+}
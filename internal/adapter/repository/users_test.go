@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"testing"
+)
+
+func Test_userStore(t *testing.T) {
+	for dialect, db := range storeDBs(t) {
+		t.Run(dialect, func(t *testing.T) {
+			s, err := NewUserStore(db, dialect)
+			if err != nil {
+				t.Fatalf("NewUserStore() error = %v", err)
+			}
+
+			user := &model.User{Subject: "alice", Name: "Alice", Role: model.RoleUser}
+			if err := s.Create(user); err != nil || user.ID == "" {
+				t.Fatalf("Create() error = %v, id = %s", err, user.ID)
+			}
+			if err := s.Create(&model.User{Subject: "alice"}); err == nil {
+				t.Errorf("Create() of a user with the same subject succeeded")
+			}
+
+			if got, err := s.Get(user.ID); err != nil || got.Subject != "alice" {
+				t.Errorf("Get() got = %v, %v, want alice", got, err)
+			}
+			if got, err := s.GetBySubject("alice"); err != nil || got.ID != user.ID {
+				t.Errorf("GetBySubject() got = %v, %v, want user %s", got, err, user.ID)
+			}
+			if _, err := s.GetBySubject("bob"); !errors.Is(err, domain.ErrorNoUser) {
+				t.Errorf("GetBySubject() of an unknown subject error = %v, want %v", err, domain.ErrorNoUser)
+			}
+			if list, err := s.List(); err != nil || len(list) != 1 {
+				t.Errorf("List() got = %v, %v, want 1 user", list, err)
+			}
+
+			if err := s.Delete(user.ID); err != nil {
+				t.Errorf("Delete() error = %v", err)
+			}
+			if err := s.Delete(user.ID); !errors.Is(err, domain.ErrorNoUser) {
+				t.Errorf("Delete() of a deleted user error = %v, want %v", err, domain.ErrorNoUser)
+			}
+		})
+	}
+}
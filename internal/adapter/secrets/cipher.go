@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encryptedPrefix marks encrypted data, data without it is read as is so that plain files still work
+var encryptedPrefix = []byte("enc:v1:")
+
+var ErrorNoKey = errors.New("data is encrypted but no key is configured")
+
+// Cipher encrypts secrets at rest, such as mailbox tokens, with AES-256-GCM
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// ParseKey decodes a base64 key of 32 bytes, as generated by 'openssl rand -base64 32'
+func ParseKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("key is not base64: %w", err)
+	}
+	if len(key) != 32 {
+		return nil, fmt.Errorf("key is %d bytes long, expected 32", len(key))
+	}
+	return key, nil
+}
+
+// NewCipher returns nil without key, a nil Cipher reads plain data only
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) == 0 {
+		return nil, nil
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Cipher{aead: aead}, nil
+}
+
+func IsEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, encryptedPrefix)
+}
+
+// Encrypt returns the prefixed base64 of a random nonce followed by the sealed data, it keeps a nil Cipher's data
+// plain
+func (c *Cipher) Encrypt(plain []byte) ([]byte, error) {
+	if c == nil {
+		return plain, nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	sealed := c.aead.Seal(nonce, nonce, plain, nil)
+	out := make([]byte, len(encryptedPrefix)+base64.StdEncoding.EncodedLen(len(sealed)))
+	copy(out, encryptedPrefix)
+	base64.StdEncoding.Encode(out[len(encryptedPrefix):], sealed)
+	return out, nil
+}
+
+// Decrypt opens encrypted data and returns plain data as is
+func (c *Cipher) Decrypt(data []byte) ([]byte, error) {
+	if !IsEncrypted(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, ErrorNoKey
+	}
+	sealed, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(data[len(encryptedPrefix):])))
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode encrypted data: %w", err)
+	}
+	size := c.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, fmt.Errorf("encrypted data is too short")
+	}
+	plain, err := c.aead.Open(nil, sealed[:size], sealed[size:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot decrypt data, wrong key: %w", err)
+	}
+	return plain, nil
+}
@@ -0,0 +1,24 @@
+package secrets
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"fmt"
+)
+
+// providers of the secret:<path>#<key> configuration values
+const (
+	ProviderNone  = ""
+	ProviderVault = "vault"
+)
+
+// NewProvider returns the secret provider of a name, nil for ProviderNone
+func NewProvider(name string, vault VaultConfig) (domain.SecretProvider, error) {
+	switch name {
+	case ProviderNone:
+		return nil, nil
+	case ProviderVault:
+		return NewVaultProvider(vault)
+	default:
+		return nil, fmt.Errorf("unknown secret provider %s", name)
+	}
+}
@@ -0,0 +1,140 @@
+package secrets
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestCipher(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 32)
+	c, err := NewCipher(key)
+	require.NoError(t, err)
+	plain := []byte(`{"access_token":"a","refresh_token":"r"}`)
+
+	encrypted, err := c.Encrypt(plain)
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, string(encrypted), "refresh_token")
+	got, err := c.Decrypt(encrypted)
+	require.NoError(t, err)
+	assert.Equal(t, plain, got)
+
+	got, err = c.Decrypt(plain)
+	assert.NoError(t, err, "plain data is read as is")
+	assert.Equal(t, plain, got)
+
+	other, _ := NewCipher(bytes.Repeat([]byte{2}, 32))
+	_, err = other.Decrypt(encrypted)
+	assert.Error(t, err, "wrong key")
+
+	var none *Cipher
+	_, err = none.Decrypt(encrypted)
+	assert.True(t, errors.Is(err, ErrorNoKey))
+	kept, err := none.Encrypt(plain)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, kept)
+}
+
+func TestParseKey(t *testing.T) {
+	_, err := ParseKey(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 32)))
+	assert.NoError(t, err)
+	_, err = ParseKey(base64.StdEncoding.EncodeToString([]byte("short")))
+	assert.Error(t, err)
+	_, err = ParseKey("not base64!")
+	assert.Error(t, err)
+}
+
+// fakeVault serves KV version 2 secrets of the 'secret' mount to the token 'root'
+func fakeVault(secrets map[string]map[string]interface{}) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "root" {
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"errors":["permission denied"]}`)
+			return
+		}
+		data, ok := secrets[strings.TrimPrefix(r.URL.Path, "/v1/secret/data/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"errors":[]}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"data": data, "metadata": map[string]interface{}{"version": 1}},
+		})
+	}))
+}
+
+func TestVaultProvider_GetSecret(t *testing.T) {
+	addr, token := os.Getenv("TEST_VAULT_ADDR"), os.Getenv("TEST_VAULT_TOKEN")
+	path := "trip-parser-" + uuid.New().String()
+	data := map[string]interface{}{"amadeus_key": "key", "amadeus_secret": "secret"}
+	if addr == "" {
+		// a dev server is started with 'vault server -dev -dev-root-token-id=root'
+		s := fakeVault(map[string]map[string]interface{}{path: data})
+		defer s.Close()
+		addr, token = s.URL, "root"
+	} else {
+		writeVaultSecret(t, addr, token, path, data)
+	}
+
+	p, err := NewVaultProvider(VaultConfig{Address: addr, Token: token})
+	require.NoError(t, err)
+	got, err := p.GetSecret(context.Background(), path, "amadeus_secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", got)
+
+	_, err = p.GetSecret(context.Background(), path, "missing")
+	assert.True(t, errors.Is(err, domain.ErrorNoSecret), "unknown key: %v", err)
+	_, err = p.GetSecret(context.Background(), path+"-missing", "amadeus_key")
+	assert.True(t, errors.Is(err, domain.ErrorNoSecret), "unknown path: %v", err)
+
+	denied, err := NewVaultProvider(VaultConfig{Address: addr, Token: "wrong"})
+	require.NoError(t, err)
+	_, err = denied.GetSecret(context.Background(), path, "amadeus_key")
+	assert.Error(t, err)
+	assert.False(t, errors.Is(err, domain.ErrorNoSecret))
+}
+
+func writeVaultSecret(t *testing.T, addr, token, path string, data map[string]interface{}) {
+	body, _ := json.Marshal(map[string]interface{}{"data": data})
+	req, _ := http.NewRequest(http.MethodPost, addr+"/v1/secret/data/"+path, bytes.NewReader(body))
+	req.Header.Set("X-Vault-Token", token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil || res.StatusCode != http.StatusOK {
+		t.Fatalf("cannot write vault secret: %v", err)
+	}
+	res.Body.Close()
+}
+
+func TestNewVaultProvider(t *testing.T) {
+	_, err := NewVaultProvider(VaultConfig{Token: "root"})
+	assert.Error(t, err, "missing address")
+	_, err = NewVaultProvider(VaultConfig{Address: "http://localhost:8200"})
+	assert.Error(t, err, "missing token")
+	_, err = NewVaultProvider(VaultConfig{Address: "http://localhost:8200", Token: "root", KVVersion: 3})
+	assert.Error(t, err, "unknown version")
+}
+
+func TestNewProvider(t *testing.T) {
+	p, err := NewProvider(ProviderNone, VaultConfig{})
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+	p, err = NewProvider(ProviderVault, VaultConfig{Address: "http://localhost:8200", Token: "root"})
+	assert.NoError(t, err)
+	assert.NotNil(t, p)
+	_, err = NewProvider("aws", VaultConfig{})
+	assert.Error(t, err)
+}
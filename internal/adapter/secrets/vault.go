@@ -0,0 +1,129 @@
+package secrets
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type VaultConfig struct {
+	// Address is the URL of the Vault server, e.g. https://vault:8200
+	Address string
+	Token   string
+	// Mount is the path of the KV secrets engine, 'secret' by default
+	Mount string
+	// KVVersion is the version of the KV secrets engine, 1 or 2, 2 by default
+	KVVersion int
+}
+
+type vaultProvider struct {
+	cfg    VaultConfig
+	client *http.Client
+	mu     sync.Mutex
+	// secrets are read once per path, the keys of a path are usually read together
+	secrets map[string]map[string]interface{}
+}
+
+// NewVaultProvider reads secrets of a HashiCorp Vault KV secrets engine with a token
+func NewVaultProvider(cfg VaultConfig) (domain.SecretProvider, error) {
+	if cfg.Address == "" {
+		return nil, fmt.Errorf("missing vault address")
+	}
+	if cfg.Token == "" {
+		return nil, fmt.Errorf("missing vault token")
+	}
+	if cfg.Mount == "" {
+		cfg.Mount = "secret"
+	}
+	if cfg.KVVersion == 0 {
+		cfg.KVVersion = 2
+	}
+	if cfg.KVVersion != 1 && cfg.KVVersion != 2 {
+		return nil, fmt.Errorf("unknown KV secrets engine version %d", cfg.KVVersion)
+	}
+	return &vaultProvider{
+		cfg:     cfg,
+		client:  &http.Client{Timeout: 10 * time.Second},
+		secrets: make(map[string]map[string]interface{}),
+	}, nil
+}
+
+func (v *vaultProvider) GetSecret(ctx context.Context, path string, key string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	data, ok := v.secrets[path]
+	if !ok {
+		var err error
+		if data, err = v.read(ctx, path); err != nil {
+			return "", err
+		}
+		v.secrets[path] = data
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("%w: no key %s in vault secret %s", domain.ErrorNoSecret, key, path)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+func (v *vaultProvider) read(ctx context.Context, path string) (map[string]interface{}, error) {
+	mount := strings.Trim(v.cfg.Mount, "/")
+	path = strings.Trim(path, "/")
+	u := fmt.Sprintf("%s/v1/%s/%s", strings.TrimRight(v.cfg.Address, "/"), mount, path)
+	if v.cfg.KVVersion == 2 {
+		u = fmt.Sprintf("%s/v1/%s/data/%s", strings.TrimRight(v.cfg.Address, "/"), mount, path)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", v.cfg.Token)
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read vault secret %s: %w", path, err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []string        `json:"errors"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil && res.StatusCode == http.StatusOK {
+		return nil, fmt.Errorf("cannot decode vault secret %s: %w", path, err)
+	}
+	switch {
+	case res.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: no vault secret %s", domain.ErrorNoSecret, path)
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("cannot read vault secret %s: status %d %s", path, res.StatusCode,
+			strings.Join(body.Errors, ", "))
+	}
+
+	// version 2 nests the secret with its metadata
+	var data map[string]interface{}
+	if v.cfg.KVVersion == 2 {
+		var versioned struct {
+			Data map[string]interface{} `json:"data"`
+		}
+		err = json.Unmarshal(body.Data, &versioned)
+		data = versioned.Data
+	} else {
+		err = json.Unmarshal(body.Data, &data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot decode vault secret %s: %w", path, err)
+	}
+	if data == nil {
+		// a deleted version 2 secret has metadata only
+		return nil, fmt.Errorf("%w: vault secret %s is deleted", domain.ErrorNoSecret, path)
+	}
+	return data, nil
+}
@@ -0,0 +1,569 @@
+package config
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the service, read from a config.(yaml|json) file and overridden by environment
+// variables named after the keys, e.g. 'parser.url' by PARSER_URL. Keys missing from both take their default.
+//
+// A value is read from a file by suffixing its key with _file, e.g. PARSER_SECRET_FILE=/run/secrets/amadeus, or
+// from the secret provider when written secret:<path>#<key>.
+type Config struct {
+	API        APIConfig        `yaml:"api"`
+	Shutdown   ShutdownConfig   `yaml:"shutdown"`
+	Parser     ParserConfig     `yaml:"parser"`
+	Mail       MailConfig       `yaml:"mail"`
+	Processor  ProcessorConfig  `yaml:"processor"`
+	Health     HealthConfig     `yaml:"health"`
+	Tracing    TracingConfig    `yaml:"tracing"`
+	Repository RepositoryConfig `yaml:"repository"`
+	Auth       AuthConfig       `yaml:"auth"`
+	Calendar   CalendarConfig   `yaml:"calendar"`
+	Secrets    SecretsConfig    `yaml:"secrets"`
+	Geo        GeoConfig        `yaml:"geo"`
+}
+
+type APIConfig struct {
+	// Listen is the host:port of the API server
+	Listen string `yaml:"listen"`
+}
+
+type ShutdownConfig struct {
+	// Timeout is given to in-flight work on SIGTERM
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+type ParserConfig struct {
+	URL    string `yaml:"url"`
+	Key    string `yaml:"key"`
+	Secret string `yaml:"secret"`
+}
+
+type MailConfig struct {
+	// Credentials is the GMail client credentials file of mailboxes without their own
+	Credentials string `yaml:"credentials"`
+	// Token is the GMail token file of a mailbox connected on start, owned by Owner
+	Token string `yaml:"token"`
+	Owner string `yaml:"owner"`
+	// TokenKey is the base64 AES-256 key encrypting mailbox tokens and credentials at rest, they are plain when empty
+	TokenKey string `yaml:"token_key"`
+}
+
+type ProcessorConfig struct {
+	MailInterval time.Duration `yaml:"mail_interval"`
+	PollInterval time.Duration `yaml:"poll_interval"`
+	QueueSize    int           `yaml:"queue_size"`
+	Workers      WorkersConfig `yaml:"workers"`
+}
+
+type WorkersConfig struct {
+	Create int `yaml:"create"`
+	Status int `yaml:"status"`
+	Result int `yaml:"result"`
+}
+
+type HealthConfig struct {
+	Timeout        time.Duration `yaml:"timeout"`
+	StallThreshold time.Duration `yaml:"stall_threshold"`
+}
+
+// exporters of the traces
+const (
+	ExporterNone   = ""
+	ExporterStdout = "stdout"
+	ExporterOTLP   = "otlp"
+)
+
+type TracingConfig struct {
+	// Exporter is empty, stdout or otlp
+	Exporter string `yaml:"exporter"`
+	Endpoint string `yaml:"endpoint"`
+	Insecure bool   `yaml:"insecure"`
+}
+
+// drivers of the repository
+const (
+	DriverSQLite   = "sqlite3"
+	DriverPostgres = "postgres"
+)
+
+type RepositoryConfig struct {
+	// Name is the SQLite database of the sqlite3 driver
+	Name string `yaml:"name"`
+	// Driver selects the database of the trips, the processing state, the users and the mailboxes. Replicas share
+	// the PostgreSQL database of the postgres driver, a single one of them processes emails.
+	Driver string `yaml:"driver"`
+	// DSN is the PostgreSQL connection string of the postgres driver
+	DSN string `yaml:"dsn"`
+}
+
+type AuthConfig struct {
+	JWT JWTConfig `yaml:"jwt"`
+}
+
+type JWTConfig struct {
+	// JWKS is the URL or the file of the key set of bearer tokens, they are refused when empty
+	JWKS       string `yaml:"jwks"`
+	Issuer     string `yaml:"issuer"`
+	Audience   string `yaml:"audience"`
+	RolesClaim string `yaml:"roles_claim"`
+	AdminRole  string `yaml:"admin_role"`
+}
+
+type CalendarConfig struct {
+	// Tokens are the calendar feed tokens by user
+	Tokens map[string]string `yaml:"tokens"`
+}
+
+// providers of the secret:<path>#<key> values
+const (
+	SecretProviderNone  = ""
+	SecretProviderVault = "vault"
+)
+
+type SecretsConfig struct {
+	Provider string      `yaml:"provider"`
+	Vault    VaultConfig `yaml:"vault"`
+}
+
+type VaultConfig struct {
+	Address string `yaml:"address"`
+	Token   string `yaml:"token"`
+	// Mount is the path of the KV secrets engine
+	Mount     string `yaml:"mount"`
+	KVVersion int    `yaml:"kv_version"`
+}
+
+// geocoders of the hotel addresses
+const (
+	GeocoderNone      = ""
+	GeocoderNominatim = "nominatim"
+)
+
+type GeoConfig struct {
+	// Airports is a CSV file of airports adding to the embedded ones, as iata,name,city,country,latitude,longitude and
+	// an optional IANA timezone
+	Airports string `yaml:"airports"`
+	// Geocoder locates the hotels, they are left without coordinates when empty
+	Geocoder  string `yaml:"geocoder"`
+	URL       string `yaml:"url"`
+	UserAgent string `yaml:"user_agent"`
+	// Timeout is the time given to geocode the hotels of a trip, those not located by then are stored without
+	// coordinates
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func Default() Config {
+	return Config{
+		API:      APIConfig{Listen: ":1323"},
+		Shutdown: ShutdownConfig{Timeout: 30 * time.Second},
+		Parser:   ParserConfig{URL: "https://test.api.amadeus.com"},
+		Processor: ProcessorConfig{
+			MailInterval: 10 * time.Minute,
+			PollInterval: 15 * time.Second,
+			QueueSize:    100,
+			Workers:      WorkersConfig{Create: 4, Status: 4, Result: 2},
+		},
+		Health:     HealthConfig{Timeout: 5 * time.Second, StallThreshold: 5 * time.Minute},
+		Tracing:    TracingConfig{Endpoint: "localhost:4318"},
+		Repository: RepositoryConfig{Name: "trips.db", Driver: DriverSQLite},
+		Auth:       AuthConfig{JWT: JWTConfig{RolesClaim: "roles", AdminRole: "admin"}},
+		Secrets:    SecretsConfig{Vault: VaultConfig{Mount: "secret", KVVersion: 2}},
+		Geo: GeoConfig{URL: "https://nominatim.openstreetmap.org", UserAgent: "amadeus-trip-parser",
+			Timeout: 5 * time.Second},
+	}
+}
+
+// removedKeys are the keys of previous releases, ignored with a warning instead of refused until the next release
+var removedKeys = map[string]string{
+	// the sample file shipped it while the database was always read from repository
+	"storage": "the database is configured under repository",
+}
+
+// Load reads the first config file found in paths, and the environment variables. Defaults are used without file,
+// keys unknown to Config are refused so that a misspelled key is not silently ignored, except the removedKeys. The
+// file read is returned, empty without file.
+func Load(paths ...string) (Config, string, error) {
+	v := viper.New()
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+	v.SetConfigName("config")
+	for _, p := range paths {
+		v.AddConfigPath(p)
+	}
+	// environment variables are only looked up for known keys
+	for key, value := range flatten("", toMap(reflect.ValueOf(Default()))) {
+		v.SetDefault(key, value)
+		if _, ok := value.(string); ok {
+			v.SetDefault(key+fileSuffix, "")
+		}
+	}
+
+	if err := v.ReadInConfig(); err != nil {
+		var notFound viper.ConfigFileNotFoundError
+		if !errors.As(err, &notFound) {
+			return Config{}, "", fmt.Errorf("cannot read config file: %w", err)
+		}
+	}
+	settings := v.AllSettings()
+	for key, reason := range removedKeys {
+		if _, ok := settings[key]; ok {
+			log.Warn().Msgf("config key %s is no longer read and is ignored, %s", key, reason)
+			delete(settings, key)
+		}
+	}
+	if err := readFiles("", settings); err != nil {
+		return Config{}, v.ConfigFileUsed(), err
+	}
+	var c Config
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		ErrorUnused:      true,
+		WeaklyTypedInput: true,
+		TagName:          "yaml",
+		Result:           &c,
+	})
+	if err != nil {
+		return Config{}, v.ConfigFileUsed(), err
+	}
+	if err := decoder.Decode(settings); err != nil {
+		return Config{}, v.ConfigFileUsed(), fmt.Errorf("cannot decode config: %w", err)
+	}
+	return c, v.ConfigFileUsed(), nil
+}
+
+const fileSuffix = "_file"
+
+// readFiles replaces the values of the keys suffixed with _file by the content of the file, without its trailing
+// newline. The file takes precedence over the value.
+func readFiles(prefix string, settings map[string]interface{}) error {
+	for key, value := range settings {
+		if nested, ok := value.(map[string]interface{}); ok {
+			if err := readFiles(prefix+key+".", nested); err != nil {
+				return err
+			}
+			continue
+		}
+		if !strings.HasSuffix(key, fileSuffix) {
+			continue
+		}
+		delete(settings, key)
+		file, ok := value.(string)
+		if !ok || file == "" {
+			continue
+		}
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("cannot read %s%s: %w", prefix, key, err)
+		}
+		settings[strings.TrimSuffix(key, fileSuffix)] = strings.TrimRight(string(b), "\r\n")
+	}
+	return nil
+}
+
+// secretPrefix marks the values read from the secret provider, as secret:<path>#<key>
+const secretPrefix = "secret:"
+
+func parseSecretRef(value string) (path string, key string, ok bool) {
+	if !strings.HasPrefix(value, secretPrefix) {
+		return "", "", false
+	}
+	ref := strings.TrimPrefix(value, secretPrefix)
+	i := strings.LastIndex(ref, "#")
+	if i <= 0 || i == len(ref)-1 {
+		return "", "", false
+	}
+	return ref[:i], ref[i+1:], true
+}
+
+// ResolveSecrets replaces the secret:<path>#<key> values by the secrets of the provider
+func (c Config) ResolveSecrets(ctx context.Context, provider domain.SecretProvider) (Config, error) {
+	c.Calendar.Tokens = copyTokens(c.Calendar.Tokens)
+	err := walkStrings(reflect.ValueOf(&c).Elem(), "", func(key string, value string) (string, error) {
+		if !strings.HasPrefix(value, secretPrefix) {
+			return value, nil
+		}
+		path, name, ok := parseSecretRef(value)
+		if !ok {
+			return "", fmt.Errorf("%s: secret reference must be secret:<path>#<key>, got %q", key, value)
+		}
+		secret, err := provider.GetSecret(ctx, path, name)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", key, err)
+		}
+		return secret, nil
+	})
+	return c, err
+}
+
+// walkStrings calls fn with each string value of the configuration and its key, and sets the returned value
+func walkStrings(v reflect.Value, prefix string, fn func(key string, value string) (string, error)) error {
+	for i := 0; i < v.NumField(); i++ {
+		key := prefix + v.Type().Field(i).Tag.Get("yaml")
+		f := v.Field(i)
+		switch f.Kind() {
+		case reflect.Struct:
+			if err := walkStrings(f, key+".", fn); err != nil {
+				return err
+			}
+		case reflect.String:
+			value, err := fn(key, f.String())
+			if err != nil {
+				return err
+			}
+			f.SetString(value)
+		case reflect.Map:
+			if m, ok := f.Interface().(map[string]string); ok {
+				for k, value := range m {
+					value, err := fn(key+"."+k, value)
+					if err != nil {
+						return err
+					}
+					m[k] = value
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func copyTokens(tokens map[string]string) map[string]string {
+	if tokens == nil {
+		return nil
+	}
+	c := make(map[string]string, len(tokens))
+	for user, token := range tokens {
+		c[user] = token
+	}
+	return c
+}
+
+// ValidationError names the key of an invalid value
+type ValidationError struct {
+	Key     string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Key + ": " + e.Message
+}
+
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Validate returns the ValidationErrors of all invalid keys, nil when the configuration is valid
+func (c Config) Validate() error {
+	var errs ValidationErrors
+	invalid := func(key string, format string, args ...interface{}) {
+		errs = append(errs, ValidationError{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+	positive := func(key string, d time.Duration) {
+		if d <= 0 {
+			invalid(key, "must be a positive duration, got %s", d)
+		}
+	}
+
+	if c.API.Listen == "" {
+		invalid("api.listen", "is required")
+	}
+	positive("shutdown.timeout", c.Shutdown.Timeout)
+	if u, err := url.Parse(c.Parser.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		invalid("parser.url", "must be an http(s) URL, got %q", c.Parser.URL)
+	}
+	if c.Parser.Key == "" {
+		invalid("parser.key", "is required")
+	}
+	if c.Parser.Secret == "" {
+		invalid("parser.secret", "is required")
+	}
+	if c.Mail.Token != "" && c.Mail.Credentials == "" {
+		invalid("mail.credentials", "is required with mail.token")
+	}
+	positive("processor.mail_interval", c.Processor.MailInterval)
+	positive("processor.poll_interval", c.Processor.PollInterval)
+	for key, n := range map[string]int{
+		"processor.queue_size":     c.Processor.QueueSize,
+		"processor.workers.create": c.Processor.Workers.Create,
+		"processor.workers.status": c.Processor.Workers.Status,
+		"processor.workers.result": c.Processor.Workers.Result,
+	} {
+		if n <= 0 {
+			invalid(key, "must be positive, got %d", n)
+		}
+	}
+	positive("health.timeout", c.Health.Timeout)
+	positive("health.stall_threshold", c.Health.StallThreshold)
+	switch c.Tracing.Exporter {
+	case ExporterNone, ExporterStdout, ExporterOTLP:
+	default:
+		invalid("tracing.exporter", "must be empty, %s or %s, got %q", ExporterStdout, ExporterOTLP, c.Tracing.Exporter)
+	}
+	switch c.Repository.Driver {
+	case DriverSQLite:
+		if c.Repository.Name == "" {
+			invalid("repository.name", "is required with the %s driver", DriverSQLite)
+		}
+	case DriverPostgres:
+		if c.Repository.DSN == "" {
+			invalid("repository.dsn", "is required with the %s driver", DriverPostgres)
+		}
+	default:
+		invalid("repository.driver", "must be %s or %s, got %q", DriverSQLite, DriverPostgres, c.Repository.Driver)
+	}
+	if c.Auth.JWT.JWKS == "" && (c.Auth.JWT.Issuer != "" || c.Auth.JWT.Audience != "") {
+		invalid("auth.jwt.jwks", "is required with auth.jwt.issuer or auth.jwt.audience")
+	}
+	for user, token := range c.Calendar.Tokens {
+		if token == "" {
+			invalid("calendar.tokens."+user, "is empty")
+		}
+	}
+	if c.Mail.TokenKey != "" {
+		// as generated by 'openssl rand -base64 32'
+		if key, err := base64.StdEncoding.DecodeString(c.Mail.TokenKey); err != nil {
+			invalid("mail.token_key", "key is not base64: %s", err)
+		} else if len(key) != 32 {
+			invalid("mail.token_key", "key is %d bytes long, expected 32", len(key))
+		}
+	}
+	switch c.Secrets.Provider {
+	case SecretProviderNone:
+	case SecretProviderVault:
+		if c.Secrets.Vault.Address == "" {
+			invalid("secrets.vault.address", "is required with the %s provider", SecretProviderVault)
+		}
+		if c.Secrets.Vault.Token == "" {
+			invalid("secrets.vault.token", "is required with the %s provider", SecretProviderVault)
+		}
+		if c.Secrets.Vault.KVVersion != 1 && c.Secrets.Vault.KVVersion != 2 {
+			invalid("secrets.vault.kv_version", "must be 1 or 2, got %d", c.Secrets.Vault.KVVersion)
+		}
+	default:
+		invalid("secrets.provider", "must be empty or %s, got %q", SecretProviderVault, c.Secrets.Provider)
+	}
+	switch c.Geo.Geocoder {
+	case GeocoderNone:
+	case GeocoderNominatim:
+		if u, err := url.Parse(c.Geo.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			invalid("geo.url", "must be an http(s) URL, got %q", c.Geo.URL)
+		}
+		if c.Geo.UserAgent == "" {
+			invalid("geo.user_agent", "is required with the %s geocoder", GeocoderNominatim)
+		}
+		positive("geo.timeout", c.Geo.Timeout)
+	default:
+		invalid("geo.geocoder", "must be empty or %s, got %q", GeocoderNominatim, c.Geo.Geocoder)
+	}
+	walkStrings(reflect.ValueOf(&c).Elem(), "", func(key string, value string) (string, error) {
+		if !strings.HasPrefix(value, secretPrefix) {
+			return value, nil
+		}
+		if _, _, ok := parseSecretRef(value); !ok {
+			invalid(key, "secret reference must be secret:<path>#<key>, got %q", value)
+		} else if c.Secrets.Provider == SecretProviderNone {
+			invalid(key, "is a secret reference but secrets.provider is not set")
+		}
+		return value, nil
+	})
+
+	if len(errs) == 0 {
+		return nil
+	}
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Key < errs[j].Key })
+	return errs
+}
+
+const redacted = "REDACTED"
+
+var dsnPassword = regexp.MustCompile(`(password=)('[^']*'|\S*)`)
+
+// Redacted is a copy of the configuration without its secrets, a set secret is shown as REDACTED
+func (c Config) Redacted() Config {
+	redact := func(s string) string {
+		if s == "" {
+			return s
+		}
+		return redacted
+	}
+	c.Parser.Key = redact(c.Parser.Key)
+	c.Parser.Secret = redact(c.Parser.Secret)
+	c.Mail.TokenKey = redact(c.Mail.TokenKey)
+	c.Secrets.Vault.Token = redact(c.Secrets.Vault.Token)
+	if u, err := url.Parse(c.Repository.DSN); err == nil && u.User != nil {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redacted)
+			c.Repository.DSN = u.String()
+		}
+	} else {
+		c.Repository.DSN = dsnPassword.ReplaceAllString(c.Repository.DSN, "${1}"+redacted)
+	}
+	tokens := make(map[string]string, len(c.Calendar.Tokens))
+	for user, token := range c.Calendar.Tokens {
+		tokens[user] = redact(token)
+	}
+	c.Calendar.Tokens = tokens
+	return c
+}
+
+// Settings are the nested key values of the configuration, durations are written as strings such as 10m0s
+func (c Config) Settings() map[string]interface{} {
+	return toMap(reflect.ValueOf(c))
+}
+
+func toMap(v reflect.Value) map[string]interface{} {
+	m := make(map[string]interface{})
+	for i := 0; i < v.NumField(); i++ {
+		key := v.Type().Field(i).Tag.Get("yaml")
+		f := v.Field(i)
+		switch value := f.Interface().(type) {
+		case time.Duration:
+			m[key] = value.String()
+		default:
+			if f.Kind() == reflect.Struct {
+				m[key] = toMap(f)
+			} else {
+				m[key] = value
+			}
+		}
+	}
+	return m
+}
+
+// flatten joins nested keys with dots, maps of values such as calendar tokens are left out
+func flatten(prefix string, m map[string]interface{}) map[string]interface{} {
+	keys := make(map[string]interface{})
+	for k, v := range m {
+		switch value := v.(type) {
+		case map[string]interface{}:
+			for sub, subValue := range flatten(prefix+k+".", value) {
+				keys[sub] = subValue
+			}
+		case map[string]string:
+		default:
+			keys[prefix+k] = value
+		}
+	}
+	return keys
+}
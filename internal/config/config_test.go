@@ -0,0 +1,223 @@
+package config
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0600); err != nil {
+		t.Fatalf("cannot write config: %v", err)
+	}
+	return dir
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("defaults without file", func(t *testing.T) {
+		c, file, err := Load(t.TempDir())
+		require.NoError(t, err)
+		assert.Empty(t, file)
+		assert.Equal(t, Default(), c)
+	})
+
+	t.Run("file over defaults", func(t *testing.T) {
+		dir := writeConfig(t, `
+parser:
+  key: key
+  secret: secret
+processor:
+  mail_interval: 5m
+  workers:
+    create: 8
+repository:
+  name: ":memory:"
+calendar:
+  tokens:
+    alice: token
+`)
+		c, file, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, filepath.Join(dir, "config.yaml"), file)
+		assert.Equal(t, "key", c.Parser.Key)
+		assert.Equal(t, "https://test.api.amadeus.com", c.Parser.URL)
+		assert.Equal(t, 5*time.Minute, c.Processor.MailInterval)
+		assert.Equal(t, 8, c.Processor.Workers.Create)
+		assert.Equal(t, Default().Processor.Workers.Status, c.Processor.Workers.Status)
+		assert.Equal(t, ":memory:", c.Repository.Name)
+		assert.Equal(t, map[string]string{"alice": "token"}, c.Calendar.Tokens)
+		assert.NoError(t, c.Validate())
+	})
+
+	t.Run("environment over file", func(t *testing.T) {
+		dir := writeConfig(t, "processor:\n  workers:\n    create: 8\n")
+		os.Setenv("PROCESSOR_WORKERS_CREATE", "16")
+		os.Setenv("HEALTH_TIMEOUT", "1s")
+		defer os.Unsetenv("PROCESSOR_WORKERS_CREATE")
+		defer os.Unsetenv("HEALTH_TIMEOUT")
+		c, _, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, 16, c.Processor.Workers.Create)
+		assert.Equal(t, time.Second, c.Health.Timeout)
+	})
+
+	t.Run("unknown key", func(t *testing.T) {
+		_, _, err := Load(writeConfig(t, "storages:\n  name: trips.db\n"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "storages")
+	})
+
+	t.Run("removed key", func(t *testing.T) {
+		c, _, err := Load(writeConfig(t, "storage:\n  name: \":memory:\"\n"))
+		require.NoError(t, err, "ignored with a warning")
+		assert.Equal(t, Default().Repository, c.Repository)
+	})
+
+	t.Run("values read from files", func(t *testing.T) {
+		dir := writeConfig(t, "parser:\n  key: plain\n  key_file: "+filepath.Join(t.TempDir(), "missing")+"\n")
+		_, _, err := Load(dir)
+		assert.Error(t, err, "missing file")
+
+		secret := filepath.Join(t.TempDir(), "secret")
+		require.NoError(t, ioutil.WriteFile(secret, []byte("s3cret\n"), 0600))
+		dir = writeConfig(t, "parser:\n  key: plain\n  key_file: "+secret+"\n")
+		os.Setenv("PARSER_SECRET_FILE", secret)
+		defer os.Unsetenv("PARSER_SECRET_FILE")
+		c, _, err := Load(dir)
+		require.NoError(t, err)
+		assert.Equal(t, "s3cret", c.Parser.Key, "the file takes precedence")
+		assert.Equal(t, "s3cret", c.Parser.Secret)
+	})
+
+	t.Run("invalid value", func(t *testing.T) {
+		_, _, err := Load(writeConfig(t, "shutdown:\n  timeout: soon\n"))
+		assert.Error(t, err)
+	})
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Default()
+	valid.Parser.Key, valid.Parser.Secret = "key", "secret"
+	tests := []struct {
+		name     string
+		change   func(c *Config)
+		wantKeys []string
+	}{
+		{"valid", func(c *Config) {}, nil},
+		{"missing parser credentials", func(c *Config) {
+			c.Parser.Key, c.Parser.Secret = "", ""
+		}, []string{"parser.key", "parser.secret"}},
+		{"parser url without scheme", func(c *Config) { c.Parser.URL = "test.api.amadeus.com" }, []string{"parser.url"}},
+		{"token without credentials", func(c *Config) { c.Mail.Token = "gmail_token.json" }, []string{"mail.credentials"}},
+		{"zero durations and workers", func(c *Config) {
+			c.Processor.PollInterval = 0
+			c.Processor.Workers.Result = -1
+			c.Health.StallThreshold = 0
+		}, []string{"health.stall_threshold", "processor.poll_interval", "processor.workers.result"}},
+		{"unknown exporter", func(c *Config) { c.Tracing.Exporter = "jaeger" }, []string{"tracing.exporter"}},
+		{"unknown driver", func(c *Config) { c.Repository.Driver = "mysql" }, []string{"repository.driver"}},
+		{"postgres without dsn", func(c *Config) { c.Repository.Driver = DriverPostgres }, []string{"repository.dsn"}},
+		{"issuer without jwks", func(c *Config) { c.Auth.JWT.Issuer = "https://id.example.com" }, []string{"auth.jwt.jwks"}},
+		{"empty calendar token", func(c *Config) {
+			c.Calendar.Tokens = map[string]string{"alice": ""}
+		}, []string{"calendar.tokens.alice"}},
+		{"short token key", func(c *Config) { c.Mail.TokenKey = "c2hvcnQ=" }, []string{"mail.token_key"}},
+		{"secret reference without provider", func(c *Config) {
+			c.Parser.Secret = "secret:trip-parser#amadeus_secret"
+		}, []string{"parser.secret"}},
+		{"malformed secret reference", func(c *Config) {
+			c.Secrets.Provider = SecretProviderVault
+			c.Secrets.Vault.Address, c.Secrets.Vault.Token = "http://vault:8200", "token"
+			c.Parser.Secret = "secret:trip-parser"
+		}, []string{"parser.secret"}},
+		{"vault without address", func(c *Config) {
+			c.Secrets.Provider = SecretProviderVault
+			c.Secrets.Vault.Token = "token"
+		}, []string{"secrets.vault.address"}},
+		{"unknown secret provider", func(c *Config) { c.Secrets.Provider = "aws" }, []string{"secrets.provider"}},
+		{"nominatim geocoder", func(c *Config) { c.Geo.Geocoder = GeocoderNominatim }, nil},
+		{"nominatim without url", func(c *Config) {
+			c.Geo.Geocoder, c.Geo.URL, c.Geo.UserAgent = GeocoderNominatim, "nominatim", ""
+		}, []string{"geo.url", "geo.user_agent"}},
+		{"unknown geocoder", func(c *Config) { c.Geo.Geocoder = "google" }, []string{"geo.geocoder"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := valid
+			tt.change(&c)
+			err := c.Validate()
+			if tt.wantKeys == nil {
+				assert.NoError(t, err)
+				return
+			}
+			var errs ValidationErrors
+			require.True(t, errors.As(err, &errs), "Validate() error = %v", err)
+			var keys []string
+			for _, e := range errs {
+				keys = append(keys, e.Key)
+			}
+			assert.Equal(t, tt.wantKeys, keys)
+		})
+	}
+}
+
+func TestConfig_Redacted(t *testing.T) {
+	c := Default()
+	c.Parser.Key, c.Parser.Secret = "key", "secret"
+	c.Repository.DSN = "postgres://trips:secret@db:5432/trips?sslmode=disable"
+	c.Calendar.Tokens = map[string]string{"alice": "token"}
+
+	r := c.Redacted()
+	assert.Equal(t, "REDACTED", r.Parser.Key)
+	assert.Equal(t, "REDACTED", r.Parser.Secret)
+	assert.Equal(t, "postgres://trips:REDACTED@db:5432/trips?sslmode=disable", r.Repository.DSN)
+	assert.Equal(t, map[string]string{"alice": "REDACTED"}, r.Calendar.Tokens)
+	assert.Equal(t, "token", c.Calendar.Tokens["alice"], "the configuration is left unchanged")
+
+	c.Repository.DSN = "host=db user=trips password='s3cret pass' dbname=trips"
+	assert.Equal(t, "host=db user=trips password=REDACTED dbname=trips", c.Redacted().Repository.DSN)
+}
+
+func TestConfig_Settings(t *testing.T) {
+	s := Default().Settings()
+	assert.Equal(t, "10m0s", s["processor"].(map[string]interface{})["mail_interval"])
+	assert.Equal(t, 4, s["processor"].(map[string]interface{})["workers"].(map[string]interface{})["create"])
+}
+
+type mapProvider map[string]string
+
+func (m mapProvider) GetSecret(ctx context.Context, path string, key string) (string, error) {
+	if s, ok := m[path+"#"+key]; ok {
+		return s, nil
+	}
+	return "", fmt.Errorf("%w: %s#%s", domain.ErrorNoSecret, path, key)
+}
+
+func TestConfig_ResolveSecrets(t *testing.T) {
+	provider := mapProvider{"trip-parser#amadeus_secret": "secret", "calendar#alice": "token"}
+	c := Default()
+	c.Parser.Key = "key"
+	c.Parser.Secret = "secret:trip-parser#amadeus_secret"
+	c.Calendar.Tokens = map[string]string{"alice": "secret:calendar#alice"}
+
+	got, err := c.ResolveSecrets(context.Background(), provider)
+	require.NoError(t, err)
+	assert.Equal(t, "key", got.Parser.Key)
+	assert.Equal(t, "secret", got.Parser.Secret)
+	assert.Equal(t, "token", got.Calendar.Tokens["alice"])
+	assert.Equal(t, "secret:calendar#alice", c.Calendar.Tokens["alice"], "the configuration is left unchanged")
+
+	c.Repository.DSN = "secret:postgres#dsn"
+	_, err = c.ResolveSecrets(context.Background(), provider)
+	assert.True(t, errors.Is(err, domain.ErrorNoSecret))
+	assert.Contains(t, err.Error(), "repository.dsn")
+}
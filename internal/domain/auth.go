@@ -0,0 +1,29 @@
+package domain
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+)
+
+var (
+	ErrorUnauthenticated = errors.New("unauthenticated")
+	ErrorForbidden       = errors.New("forbidden")
+)
+
+// TokenVerifier validates bearer tokens issued by an identity provider
+type TokenVerifier interface {
+	Verify(token string) (model.Principal, error)
+}
+
+// Authenticator returns the principal of a credential, or ErrorUnauthenticated
+type Authenticator interface {
+	AuthenticateKey(key string) (model.Principal, error)
+	AuthenticateBearer(token string) (model.Principal, error)
+}
+
+type APIKeyService interface {
+	// Create returns the new key along with its record, the key cannot be retrieved afterwards
+	Create(name string, subject string, role model.Role) (string, model.APIKey, error)
+	List() ([]model.APIKey, error)
+	Revoke(id string) error
+}
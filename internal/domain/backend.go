@@ -1,13 +1,86 @@
 package domain
 
-import "amadeus-trip-parser/internal/domain/model"
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Backends receive the context of the email being processed, to carry its trace to external calls.
+// Their health tells whether their credentials are still valid.
+
+// ErrorMailboxRevoked is returned once the authorization of a mailbox is revoked or expired, it must be
+// granted again by its owner
+var ErrorMailboxRevoked = errors.New("mailbox authorization revoked")
 
 type EmailProvider interface {
-	GetEmails(filter string) []*model.Email
+	HealthChecker
+	// GetEmails returns the emails matching filter, the ones fetched are returned with a *FetchError when
+	// some messages or the listing failed
+	GetEmails(ctx context.Context, filter string) ([]*model.Email, error)
+}
+
+// MessageError is the failure to fetch a single message, the other messages are fetched
+type MessageError struct {
+	MessageID string
+	Err       error
+}
+
+func (e *MessageError) Error() string {
+	return fmt.Sprintf("message %s: %v", e.MessageID, e.Err)
+}
+
+func (e *MessageError) Unwrap() error {
+	return e.Err
+}
+
+// FetchError lists the messages that could not be fetched, and the error ending the listing of the messages
+// before the last one when not nil. It unwraps to the latter.
+type FetchError struct {
+	Messages []*MessageError
+	Err      error
+}
+
+func (e *FetchError) Error() string {
+	var msgs []string
+	if e.Err != nil {
+		msgs = append(msgs, e.Err.Error())
+	}
+	for _, m := range e.Messages {
+		msgs = append(msgs, m.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *FetchError) Unwrap() error {
+	return e.Err
+}
+
+// EmailProviderFactory connects to a mailbox with its own credentials
+type EmailProviderFactory interface {
+	Provider(mailbox model.Mailbox) (EmailProvider, error)
 }
 
 type EmailParser interface {
-	CreateJob(mail *model.Email) (*model.EmailParsingJob, error)
-	GetJobStatus(job model.EmailParsingJob) (*model.EmailParsingJob, error)
-	GetJobResult(job model.EmailParsingJob) (*model.EmailParsingJob, error)
+	HealthChecker
+	CreateJob(ctx context.Context, mail *model.Email) (*model.EmailParsingJob, error)
+	GetJobStatus(ctx context.Context, job model.EmailParsingJob) (*model.EmailParsingJob, error)
+	GetJobResult(ctx context.Context, job model.EmailParsingJob) (*model.EmailParsingJob, error)
+}
+
+var ErrorNoSecret = errors.New("secret not found")
+
+// SecretProvider reads the key of a secret stored at path, such as the Amadeus credentials in a secret store
+type SecretProvider interface {
+	GetSecret(ctx context.Context, path string, key string) (string, error)
+}
+
+// ErrorNotGeocoded is returned by a geocoder which does not know the location
+var ErrorNotGeocoded = errors.New("location not geocoded")
+
+// Geocoder resolves the coordinates of a location, such as an address or an airport code
+type Geocoder interface {
+	Geocode(ctx context.Context, location string) (model.Coordinates, error)
 }
@@ -0,0 +1,25 @@
+package domain
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+)
+
+type HealthChecker interface {
+	// CheckHealth returns an error when the component cannot serve, it returns when ctx is done at the latest
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthCheckerFunc adapts a function to a HealthChecker, e.g. the PingContext of a database
+type HealthCheckerFunc func(ctx context.Context) error
+
+func (f HealthCheckerFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
+type HealthService interface {
+	// Live tells whether the application is running
+	Live() model.Health
+	// Ready checks every dependency and processor stage
+	Ready(ctx context.Context) model.Health
+}
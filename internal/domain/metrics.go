@@ -0,0 +1,37 @@
+package domain
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"time"
+)
+
+// Metrics receives the measures of the processing pipeline and of its backends
+type Metrics interface {
+	EmailsFetched(source string, count int)
+	JobCreated()
+	JobFailed(stage model.FailureStage)
+	JobCompleted()
+	// TripStored measures the time from an email being fetched to its trip being stored
+	TripStored(sinceFetched time.Duration)
+	// ParserCall measures a parser API request, status is the HTTP status code or 'error' without response
+	ParserCall(endpoint string, status string, duration time.Duration)
+	QueueDepth(stage string, depth int)
+	TokenRefreshed(source string)
+	// TokenRevoked counts the authorizations found revoked, whose tokens can no longer be refreshed
+	TokenRevoked(source string)
+	RepositoryError(operation string)
+}
+
+// NopMetrics discards all measures
+type NopMetrics struct{}
+
+func (NopMetrics) EmailsFetched(string, int)                {}
+func (NopMetrics) JobCreated()                              {}
+func (NopMetrics) JobFailed(model.FailureStage)             {}
+func (NopMetrics) JobCompleted()                            {}
+func (NopMetrics) TripStored(time.Duration)                 {}
+func (NopMetrics) ParserCall(string, string, time.Duration) {}
+func (NopMetrics) QueueDepth(string, int)                   {}
+func (NopMetrics) TokenRefreshed(string)                    {}
+func (NopMetrics) TokenRevoked(string)                      {}
+func (NopMetrics) RepositoryError(string)                   {}
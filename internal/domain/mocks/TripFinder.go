@@ -13,22 +13,20 @@ type TripFinder struct {
 	mock.Mock
 }
 
-// Get provides a mock function with given fields:
-func (_m *TripFinder) Get() ([]model.Trip, error) {
-	ret := _m.Called()
+// Get provides a mock function with given fields: user, query
+func (_m *TripFinder) Get(user model.Principal, query model.TripQuery) (model.TripPage, error) {
+	ret := _m.Called(user, query)
 
-	var r0 []model.Trip
-	if rf, ok := ret.Get(0).(func() []model.Trip); ok {
-		r0 = rf()
+	var r0 model.TripPage
+	if rf, ok := ret.Get(0).(func(model.Principal, model.TripQuery) model.TripPage); ok {
+		r0 = rf(user, query)
 	} else {
-		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]model.Trip)
-		}
+		r0 = ret.Get(0).(model.TripPage)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(model.Principal, model.TripQuery) error); ok {
+		r1 = rf(user, query)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -36,20 +34,41 @@ func (_m *TripFinder) Get() ([]model.Trip, error) {
 	return r0, r1
 }
 
-// GetByReference provides a mock function with given fields: ref
-func (_m *TripFinder) GetByReference(ref string) (model.Trip, error) {
-	ret := _m.Called(ref)
+// GetByID provides a mock function with given fields: user, id
+func (_m *TripFinder) GetByID(user model.Principal, id string) (model.Trip, error) {
+	ret := _m.Called(user, id)
 
 	var r0 model.Trip
-	if rf, ok := ret.Get(0).(func(string) model.Trip); ok {
-		r0 = rf(ref)
+	if rf, ok := ret.Get(0).(func(model.Principal, string) model.Trip); ok {
+		r0 = rf(user, id)
 	} else {
 		r0 = ret.Get(0).(model.Trip)
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(string) error); ok {
-		r1 = rf(ref)
+	if rf, ok := ret.Get(1).(func(model.Principal, string) error); ok {
+		r1 = rf(user, id)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetByReference provides a mock function with given fields: user, ref
+func (_m *TripFinder) GetByReference(user model.Principal, ref string) (model.Trip, error) {
+	ret := _m.Called(user, ref)
+
+	var r0 model.Trip
+	if rf, ok := ret.Get(0).(func(model.Principal, string) model.Trip); ok {
+		r0 = rf(user, ref)
+	} else {
+		r0 = ret.Get(0).(model.Trip)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(model.Principal, string) error); ok {
+		r1 = rf(user, ref)
 	} else {
 		r1 = ret.Error(1)
 	}
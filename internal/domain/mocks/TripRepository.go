@@ -3,6 +3,8 @@
 package mocks
 
 import (
+	context "context"
+
 	model "amadeus-trip-parser/internal/domain/model"
 
 	mock "github.com/stretchr/testify/mock"
@@ -13,13 +15,13 @@ type TripRepository struct {
 	mock.Mock
 }
 
-// Create provides a mock function with given fields: trip
-func (_m *TripRepository) Create(trip *model.Trip) error {
-	ret := _m.Called(trip)
+// Create provides a mock function with given fields: ctx, trip
+func (_m *TripRepository) Create(ctx context.Context, trip *model.Trip) error {
+	ret := _m.Called(ctx, trip)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(*model.Trip) error); ok {
-		r0 = rf(trip)
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Trip) error); ok {
+		r0 = rf(ctx, trip)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -27,13 +29,34 @@ func (_m *TripRepository) Create(trip *model.Trip) error {
 	return r0
 }
 
-// GetAll provides a mock function with given fields:
-func (_m *TripRepository) GetAll() ([]model.Trip, error) {
-	ret := _m.Called()
+// Find provides a mock function with given fields: query
+func (_m *TripRepository) Find(query model.TripQuery) (model.TripPage, error) {
+	ret := _m.Called(query)
+
+	var r0 model.TripPage
+	if rf, ok := ret.Get(0).(func(model.TripQuery) model.TripPage); ok {
+		r0 = rf(query)
+	} else {
+		r0 = ret.Get(0).(model.TripPage)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(model.TripQuery) error); ok {
+		r1 = rf(query)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAll provides a mock function with given fields: owner
+func (_m *TripRepository) GetAll(owner string) ([]model.Trip, error) {
+	ret := _m.Called(owner)
 
 	var r0 []model.Trip
-	if rf, ok := ret.Get(0).(func() []model.Trip); ok {
-		r0 = rf()
+	if rf, ok := ret.Get(0).(func(string) []model.Trip); ok {
+		r0 = rf(owner)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]model.Trip)
@@ -41,8 +64,8 @@ func (_m *TripRepository) GetAll() ([]model.Trip, error) {
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func() error); ok {
-		r1 = rf()
+	if rf, ok := ret.Get(1).(func(string) error); ok {
+		r1 = rf(owner)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -70,3 +93,24 @@ func (_m *TripRepository) GetOne(query model.Trip) (model.Trip, error) {
 
 	return r0, r1
 }
+
+// Merge provides a mock function with given fields: ctx, trip
+func (_m *TripRepository) Merge(ctx context.Context, trip *model.Trip) (bool, error) {
+	ret := _m.Called(ctx, trip)
+
+	var r0 bool
+	if rf, ok := ret.Get(0).(func(context.Context, *model.Trip) bool); ok {
+		r0 = rf(ctx, trip)
+	} else {
+		r0 = ret.Get(0).(bool)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, *model.Trip) error); ok {
+		r1 = rf(ctx, trip)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
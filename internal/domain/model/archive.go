@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+type ArchiveFormat string
+
+const (
+	// ArchiveFormatJSON is a single document holding the header and the trips
+	ArchiveFormatJSON = "json"
+	// ArchiveFormatJSONL is the header on the first line followed by a trip per line, it is streamed
+	ArchiveFormatJSONL = "jsonl"
+)
+
+const (
+	ArchiveKind = "amadeus-trip-parser/trips"
+	// ArchiveVersion is the version of the archives written, older ones are still read
+	ArchiveVersion = 1
+)
+
+// Archive is a portable dump of the trips of every owner with their steps and travellers. In the JSON Lines format
+// Trips is left out of the header.
+type Archive struct {
+	Kind      string
+	Version   int
+	CreatedAt time.Time
+	Trips     []Trip `json:",omitempty"`
+}
+
+// ArchiveImport counts the trips of an archive created, and the ones merged into a trip of the same owner and
+// reference
+type ArchiveImport struct {
+	Created int
+	Updated int
+}
@@ -0,0 +1,30 @@
+package model
+
+import "time"
+
+type Role string
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
+
+// Principal is the authenticated caller of the API, it sees the trips it owns, or all of them as admin
+type Principal struct {
+	Subject string
+	Role    Role
+}
+
+func (p Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// APIKey authenticates its subject, only the SHA-256 hash of the key is stored
+type APIKey struct {
+	ID        string
+	Name      string
+	Subject   string
+	Role      Role
+	Hash      string `gorm:"unique_index" json:"-"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// Backfill imports the past emails of a mailbox received from After to Before, from Sender when set, one window of
+// time after the other from the oldest. Checkpoint is the end of the last window queued, an interrupted backfill
+// resumes from it.
+type Backfill struct {
+	ID         string
+	MailboxID  string
+	Sender     string
+	After      time.Time
+	Before     time.Time
+	Checkpoint time.Time
+	// Fetched counts the emails found up to the checkpoint, Queued the ones not processed yet
+	Fetched   int
+	Queued    int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Done tells whether every window of the range is queued
+func (b Backfill) Done() bool {
+	return !b.Checkpoint.Before(b.Before)
+}
@@ -1,5 +1,15 @@
 package model
 
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"github.com/google/uuid"
+	"mime"
+	"net/mail"
+	"strings"
+)
+
 type Email struct {
 	Subject string
 	Size    int64
@@ -9,6 +19,29 @@ type Email struct {
 	Content string
 }
 
+// NewEmail builds an email as fetched from a mailbox, whose content is the base64url encoded raw message
+func NewEmail(raw []byte) (*Email, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("cannot read RFC822 message: %w", err)
+	}
+	id := strings.Trim(msg.Header.Get("Message-Id"), "<>")
+	if id == "" {
+		id = uuid.New().String()
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	return &Email{
+		Subject: subject,
+		Size:    int64(len(raw)),
+		ID:      id,
+		Date:    msg.Header.Get("Date"),
+		Content: base64.URLEncoding.EncodeToString(raw),
+	}, nil
+}
+
 type MailParsingStatus string
 
 const (
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type FailureStage string
+
+const (
+	// FailureStageFetch is a message that could not be read from its mailbox, it is fetched again by next poll
+	FailureStageFetch  = "fetch"
+	FailureStageCreate = "create"
+	FailureStageParse  = "parse"
+	FailureStageStore  = "store"
+)
+
+// Failure is a dead letter: an email whose processing failed, kept until it is replayed successfully or deleted
+type Failure struct {
+	ID string
+	// EmailID references the original email, queued with its content
+	EmailID  string `gorm:"unique_index"`
+	Subject  string
+	Date     string
+	Stage    FailureStage
+	JobID    string
+	Detail   string
+	Warnings Warnings `gorm:"type:text"`
+	// Attempts counts the failed processings of the email
+	Attempts  int
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Warnings are the parser warnings, stored as a JSON array
+type Warnings []string
+
+func (w Warnings) Value() (driver.Value, error) {
+	if w == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(w))
+	return string(b), err
+}
+
+func (w *Warnings) Scan(src interface{}) error {
+	var b []byte
+	switch v := src.(type) {
+	case nil:
+		*w = nil
+		return nil
+	case string:
+		b = []byte(v)
+	case []byte:
+		b = v
+	default:
+		return fmt.Errorf("cannot scan %T into warnings", src)
+	}
+	return json.Unmarshal(b, (*[]string)(w))
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"strconv"
+	"time"
+)
+
+// Degrees are a latitude or a longitude
+type Degrees float64
+
+func (d Degrees) String() string {
+	return strconv.FormatFloat(float64(d), 'f', -1, 64)
+}
+
+// Coordinates are WGS 84 degrees, with the IANA time zone of the place when known, such as Europe/Paris
+type Coordinates struct {
+	Latitude  float64
+	Longitude float64
+	TimeZone  string
+}
+
+// Geocode caches the result of geocoding an address, Found is false when the geocoder does not know it
+type Geocode struct {
+	Address   string `gorm:"primary_key"`
+	Found     bool
+	Latitude  float64
+	Longitude float64
+	CreatedAt time.Time
+}
@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+type HealthStatus string
+
+const (
+	HealthStatusUp   HealthStatus = "up"
+	HealthStatusDown HealthStatus = "down"
+)
+
+// Health is the status of the application, down as soon as one of its components is
+type Health struct {
+	Status     HealthStatus
+	Components map[string]ComponentHealth `json:",omitempty"`
+}
+
+type ComponentHealth struct {
+	Status HealthStatus
+	Detail string `json:",omitempty"`
+	// LastProgress and Pending are reported by processor stages
+	LastProgress *time.Time `json:",omitempty"`
+	Pending      int        `json:",omitempty"`
+}
+
+// StageProgress tells when a processor stage last completed some work
+type StageProgress struct {
+	Stage        string
+	LastProgress time.Time
+	// Pending is the number of items waiting for the stage
+	Pending int
+	// Interval is the delay between two runs of a stage working without input, zero when it waits for items
+	Interval time.Duration
+}
@@ -0,0 +1,23 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// ProcessedMessage records the outcome of an email sent to the parser, so that it is not submitted again
+type ProcessedMessage struct {
+	MessageID   string `gorm:"primary_key"`
+	ContentHash string
+	Outcome     ProcessingState
+	JobID       string
+	TripID      string
+	Detail      string
+	ProcessedAt time.Time
+}
+
+func ContentHash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
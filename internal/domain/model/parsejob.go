@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// ParseJob tracks an email submitted on demand through its parsing lifecycle
+type ParseJob struct {
+	ID          string
+	Owner       string
+	ParserJobID string
+	Status      MailParsingStatus
+	Subject     string
+	Warnings    []string
+	Detail      string
+	Trip        *Trip
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+type TripPeriod string
+
+const (
+	TripPeriodAll      = ""
+	TripPeriodUpcoming = "upcoming"
+	TripPeriodPast     = "past"
+)
+
+type TripSortField string
+
+const (
+	TripSortStart     = "start"
+	TripSortEnd       = "end"
+	TripSortReference = "reference"
+)
+
+type SortOrder string
+
+const (
+	SortOrderAsc  = "asc"
+	SortOrderDesc = "desc"
+)
+
+// TripQuery filters, sorts and paginates trips. Zero values mean no filtering.
+type TripQuery struct {
+	// Owner keeps the trips of a user, all trips are kept when empty
+	Owner string
+	// Period keeps trips not yet ended (upcoming) or already ended (past) at Now
+	Period TripPeriod
+	Now    time.Time
+	// From and To keep trips overlapping the given range
+	From      time.Time
+	To        time.Time
+	Location  string
+	StepType  TripStepType
+	Traveller string
+	Text      string
+	Sort      TripSortField
+	Order     SortOrder
+	Offset    int
+	Limit     int
+}
+
+type TripPage struct {
+	Trips  []Trip
+	Total  int
+	Offset int
+	Limit  int
+}
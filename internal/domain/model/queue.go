@@ -0,0 +1,66 @@
+package model
+
+import "time"
+
+type ProcessingState string
+
+const (
+	ProcessingStateFetched   = "fetched"
+	ProcessingStateSubmitted = "submitted"
+	ProcessingStatePending   = "pending"
+	ProcessingStateDone      = "done"
+	ProcessingStateFailed    = "failed"
+)
+
+// QueueItem is an email going through the processing pipeline, it keeps the raw email
+// and the parser job so that unfinished work can be resumed after a restart
+type QueueItem struct {
+	ID          string
+	EmailID     string `gorm:"index"`
+	Subject     string
+	Date        string
+	Size        int64
+	Content     string
+	State       ProcessingState `gorm:"index"`
+	JobID       string
+	TripID      string
+	Detail      string
+	NextCheckAt time.Time
+	// TraceParent is the W3C trace context of the email, its processing steps are traced in it
+	TraceParent string
+	// MailboxID is the mailbox the email was fetched from, the trip belongs to the Owner of the mailbox
+	MailboxID string
+	Owner     string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (q QueueItem) Email() *Email {
+	return &Email{
+		Subject: q.Subject,
+		Size:    q.Size,
+		ID:      q.EmailID,
+		Date:    q.Date,
+		Content: q.Content,
+	}
+}
+
+func (q QueueItem) Job() EmailParsingJob {
+	status := MailParsingStatus(MailParsingStatusPending)
+	switch q.State {
+	case ProcessingStateDone:
+		status = MailParsingStatusDone
+	case ProcessingStateFailed:
+		status = MailParsingStatusError
+	}
+	return EmailParsingJob{
+		ID:      q.JobID,
+		Status:  status,
+		Detail:  q.Detail,
+		Subject: q.Subject,
+	}
+}
+
+func (q QueueItem) Finished() bool {
+	return q.State == ProcessingStateDone || q.State == ProcessingStateFailed
+}
@@ -12,17 +12,53 @@ const (
 
 type TripStep struct {
 	ID          string
-	TripID      string
+	TripID      string `gorm:"index"`
 	Type        TripStepType
 	DateTime    time.Time
 	Location    string
 	Description string
+	// LocationCode is the IATA code of the airport of a flight step
+	LocationCode string `json:",omitempty"`
+	// Latitude and Longitude are nil until the location is resolved
+	Latitude  *Degrees `json:",omitempty"`
+	Longitude *Degrees `json:",omitempty"`
+	// TimeZone is the IANA time zone of the location, in which DateTime is given, empty when unknown
+	TimeZone string `json:",omitempty"`
+	// Provider is the carrier of a flight step or the hotel of a hotel step, empty when unknown
+	Provider string `json:",omitempty"`
 }
 
-type Trip struct {
+// Coordinates returns the coordinates of the step, false when its location is not resolved
+func (s TripStep) Coordinates() (Coordinates, bool) {
+	if s.Latitude == nil || s.Longitude == nil {
+		return Coordinates{}, false
+	}
+	return Coordinates{Latitude: float64(*s.Latitude), Longitude: float64(*s.Longitude)}, true
+}
+
+// SetCoordinates resolves the location of the step, and its time zone unless the parser gave it
+func (s *TripStep) SetCoordinates(c Coordinates) {
+	lat, lon := Degrees(c.Latitude), Degrees(c.Longitude)
+	s.Latitude, s.Longitude = &lat, &lon
+	if s.TimeZone == "" {
+		s.TimeZone = c.TimeZone
+	}
+}
+
+type Traveller struct {
 	ID        string
-	Reference string
-	Start     time.Time
-	End       time.Time
-	TripSteps []TripStep
+	TripID    string `gorm:"index"`
+	FirstName string
+	LastName  string
+}
+
+type Trip struct {
+	ID string
+	// Owner is the subject of the user the trip belongs to
+	Owner      string    `gorm:"index"`
+	Reference  string    `gorm:"index"`
+	Start      time.Time `gorm:"index"`
+	End        time.Time `gorm:"index"`
+	TripSteps  []TripStep
+	Travellers []Traveller
 }
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// User owns trips and mailboxes, its Subject is the one of its API keys and bearer tokens
+type User struct {
+	ID        string
+	Subject   string `gorm:"unique_index"`
+	Name      string
+	Role      Role
+	CreatedAt time.Time
+}
+
+type MailboxProvider string
+
+const (
+	MailboxProviderGmail = "gmail"
+)
+
+// Mailbox is polled for the confirmations of its owner, with its own provider credentials
+type Mailbox struct {
+	ID string
+	// Owner is the subject of the user the trips of the mailbox belong to
+	Owner    string `gorm:"index"`
+	Provider MailboxProvider
+	Address  string
+	// Credentials is the OAuth client JSON of the mailbox, the configured one is used when empty
+	Credentials string `json:"-"`
+	// Token is the OAuth token JSON giving access to the mailbox
+	Token     string `json:"-"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
@@ -2,13 +2,111 @@ package domain
 
 import (
 	"amadeus-trip-parser/internal/domain/model"
+	"context"
 	"errors"
 )
 
-var ErrorNotFound = errors.New("trip not found")
+var (
+	ErrorNotFound      = errors.New("trip not found")
+	ErrorAlreadyQueued = errors.New("email already queued")
+	ErrorItemNotFound  = errors.New("queue item not found")
+	ErrorNotProcessed  = errors.New("message not processed")
+	ErrorNoFailure     = errors.New("failure not found")
+	ErrorNoAPIKey      = errors.New("api key not found")
+	ErrorNoUser        = errors.New("user not found")
+	ErrorNoMailbox     = errors.New("mailbox not found")
+	ErrorNoBackfill    = errors.New("backfill not found")
+	ErrorNoGeocode     = errors.New("geocode not found")
+)
 
 type TripRepository interface {
-	GetAll() ([]model.Trip, error)
+	// GetAll returns the trips of owner, or all trips when empty
+	GetAll(owner string) ([]model.Trip, error)
 	GetOne(query model.Trip) (model.Trip, error)
-	Create(trip *model.Trip) error
+	Find(query model.TripQuery) (model.TripPage, error)
+	// Create stores a trip, ctx carries the trace of the email it comes from
+	Create(ctx context.Context, trip *model.Trip) error
+	// Merge stores a trip in place of the one with the same owner and reference, whose ID is kept, along with its
+	// steps and travellers. Trips without reference are only the same by ID. It reports whether the trip was created.
+	Merge(ctx context.Context, trip *model.Trip) (bool, error)
+}
+
+// JobQueue durably keeps emails and their parser jobs while they are processed
+type JobQueue interface {
+	// Enqueue fails with ErrorAlreadyQueued when the email is already being processed
+	Enqueue(item *model.QueueItem) error
+	Update(item *model.QueueItem) error
+	Get(id string) (model.QueueItem, error)
+	// GetByEmailID returns the latest item of the email
+	GetByEmailID(emailID string) (model.QueueItem, error)
+	ListByState(states ...model.ProcessingState) ([]model.QueueItem, error)
+}
+
+// MessageLedger records which emails were already submitted to the parser and how it went
+type MessageLedger interface {
+	Get(messageID string) (model.ProcessedMessage, error)
+	Record(msg *model.ProcessedMessage) error
+	Delete(messageID string) error
+}
+
+// FailureStore keeps the emails whose processing failed, with one failure per email
+type FailureStore interface {
+	// Record adds a failure, or counts one more attempt when the email already failed
+	Record(f *model.Failure) error
+	Get(id string) (model.Failure, error)
+	List() ([]model.Failure, error)
+	Delete(id string) error
+	// DeleteByEmailID forgets the failure of an email, if any
+	DeleteByEmailID(emailID string) error
+}
+
+type APIKeyStore interface {
+	Create(key *model.APIKey) error
+	GetByHash(hash string) (model.APIKey, error)
+	List() ([]model.APIKey, error)
+	Delete(id string) error
+	DeleteBySubject(subject string) error
+}
+
+type UserStore interface {
+	Create(user *model.User) error
+	Get(id string) (model.User, error)
+	GetBySubject(subject string) (model.User, error)
+	List() ([]model.User, error)
+	Delete(id string) error
+}
+
+type MailboxStore interface {
+	// Save creates the mailbox, or updates it when its ID exists
+	Save(mailbox *model.Mailbox) error
+	// UpdateToken replaces the token of a mailbox once refreshed, the mailbox is left unchanged otherwise
+	UpdateToken(id string, token string) error
+	Get(id string) (model.Mailbox, error)
+	// List returns the mailboxes of owner, or all mailboxes when empty
+	List(owner string) ([]model.Mailbox, error)
+	Delete(id string) error
+	DeleteByOwner(owner string) error
+}
+
+// BackfillStore keeps the checkpoint of each backfill
+type BackfillStore interface {
+	Get(id string) (model.Backfill, error)
+	// Save creates the backfill, or updates it when its ID exists
+	Save(backfill *model.Backfill) error
+}
+
+// GeocodeCache keeps the results of the geocoder by address, the addresses it does not know included
+type GeocodeCache interface {
+	Get(address string) (model.Geocode, error)
+	// Save creates the geocode, or updates it when its address exists
+	Save(geocode *model.Geocode) error
+}
+
+// ProcessingLock is held by the single replica processing emails among the replicas sharing a database
+type ProcessingLock interface {
+	// TryLock takes the lock, it reports false when another replica holds it
+	TryLock(ctx context.Context) (bool, error)
+	// Check fails once the lock is lost, along with the database session holding it
+	Check(ctx context.Context) error
+	Unlock() error
 }
@@ -1,13 +1,93 @@
 package domain
 
-import "amadeus-trip-parser/internal/domain/model"
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"io"
+)
+
+var (
+	ErrorInvalidQuery   = errors.New("invalid trip query")
+	ErrorInvalidEmail   = errors.New("invalid email")
+	ErrorJobNotFound    = errors.New("job not found")
+	ErrorInvalidUser    = errors.New("invalid user")
+	ErrorInvalidMailbox = errors.New("invalid mailbox")
+	ErrorInvalidRange   = errors.New("invalid date range")
+	ErrorStopped        = errors.New("email processor stopped")
+	ErrorInvalidArchive = errors.New("invalid trip archive")
+	ErrorStandby        = errors.New("emails are processed by another replica")
+)
 
 type EmailProcessor interface {
 	Process()
-	Stop()
+	// Stop returns once in-flight work is saved, or with an error when ctx is done first
+	Stop(ctx context.Context) error
+	// Enqueue queues the emails of a mailbox as a poll does, the ones already processed are skipped. It returns
+	// the number of emails queued, and fails with ErrorStopped when the processor stops first. Both Enqueue and
+	// Reprocess fail with ErrorStandby on a replica which does not process emails.
+	Enqueue(ctx context.Context, mailbox model.Mailbox, emails []*model.Email) (int, error)
+	// Reprocess forgets the outcome of a message and submits it again to the parser
+	Reprocess(messageID string) error
+	// Progress reports the last progress of each stage
+	Progress() []model.StageProgress
+}
+
+// BackfillService imports the past emails of a mailbox through the email processor
+type BackfillService interface {
+	// Run queues the emails of the backfill range, resuming from the checkpoint of a previous run with the same
+	// mailbox, start and sender. Progress is given the backfill after each window queued.
+	Run(ctx context.Context, backfill model.Backfill, progress func(model.Backfill)) (model.Backfill, error)
 }
 
+// TripFinder looks up the trips of user, or any trip when user is an admin
 type TripFinder interface {
-	Get() ([]model.Trip, error)
-	GetByReference(ref string) (model.Trip, error)
+	Get(user model.Principal, query model.TripQuery) (model.TripPage, error)
+	GetByReference(user model.Principal, ref string) (model.Trip, error)
+	GetByID(user model.Principal, id string) (model.Trip, error)
+}
+
+// ArchiveService dumps the trips of every owner and loads them back, in any repository backend
+type ArchiveService interface {
+	// Export writes all trips as an archive of the format, it returns the number of trips written
+	Export(ctx context.Context, w io.Writer, format model.ArchiveFormat) (int, error)
+	// Import merges the trips of an archive of either format by owner and reference. It fails with
+	// ErrorInvalidArchive on a malformed archive, the trips merged before are kept.
+	Import(ctx context.Context, r io.Reader) (model.ArchiveImport, error)
+}
+
+type ParseJobService interface {
+	// Submit starts parsing an email uploaded by user, its trace continues the one of ctx. Jobs are kept in memory,
+	// for an hour once finished.
+	Submit(ctx context.Context, user model.Principal, email *model.Email) (model.ParseJob, error)
+	// Get returns a job submitted by user, or any job for an admin
+	Get(user model.Principal, id string) (model.ParseJob, error)
+	// Parse runs the parser job of an email until its result, the trip is not stored
+	Parse(ctx context.Context, email *model.Email) (*model.EmailParsingJob, error)
+	// Stop cancels the submitted jobs still running and waits for them to end, or for ctx to be done. Submit fails
+	// with ErrorStopped afterwards.
+	Stop(ctx context.Context) error
+}
+
+// UserService manages the users and their mailboxes
+type UserService interface {
+	CreateUser(subject string, name string, role model.Role) (model.User, error)
+	GetUser(id string) (model.User, error)
+	GetUserBySubject(subject string) (model.User, error)
+	ListUsers() ([]model.User, error)
+	// DeleteUser deletes the user along with its mailboxes and API keys, its trips are kept
+	DeleteUser(id string) error
+	// ConnectMailbox adds a mailbox polled for the user
+	ConnectMailbox(userID string, mailbox model.Mailbox) (model.Mailbox, error)
+	// ListMailboxes returns the mailboxes of a user, or all mailboxes when userID is empty
+	ListMailboxes(userID string) ([]model.Mailbox, error)
+	DisconnectMailbox(id string) error
+}
+
+type FailureService interface {
+	List() ([]model.Failure, error)
+	Get(id string) (model.Failure, error)
+	// Retry submits the failed email again, its failure is deleted once it is processed successfully
+	Retry(id string) error
+	Delete(id string) error
 }
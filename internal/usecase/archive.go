@@ -0,0 +1,123 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/google/uuid"
+	"io"
+	"sort"
+	"time"
+)
+
+type archiveService struct {
+	repo domain.TripRepository
+}
+
+func NewArchiveService(repo domain.TripRepository) domain.ArchiveService {
+	return &archiveService{repo: repo}
+}
+
+func (s *archiveService) Export(ctx context.Context, w io.Writer, format model.ArchiveFormat) (int, error) {
+	if format != model.ArchiveFormatJSON && format != model.ArchiveFormatJSONL {
+		return 0, fmt.Errorf("unknown archive format %q", format)
+	}
+	trips, err := s.repo.GetAll("")
+	if err != nil {
+		return 0, err
+	}
+	// a stable order keeps the archives of the same trips comparable
+	sort.Slice(trips, func(i, j int) bool {
+		if !trips[i].Start.Equal(trips[j].Start) {
+			return trips[i].Start.Before(trips[j].Start)
+		}
+		return trips[i].ID < trips[j].ID
+	})
+	archive := model.Archive{Kind: model.ArchiveKind, Version: model.ArchiveVersion, CreatedAt: time.Now().UTC()}
+	enc := json.NewEncoder(w)
+	if format == model.ArchiveFormatJSON {
+		archive.Trips = trips
+		return len(trips), enc.Encode(archive)
+	}
+	if err := enc.Encode(archive); err != nil {
+		return 0, err
+	}
+	for i := range trips {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+		if err := enc.Encode(trips[i]); err != nil {
+			return i, err
+		}
+	}
+	return len(trips), nil
+}
+
+func (s *archiveService) Import(ctx context.Context, r io.Reader) (model.ArchiveImport, error) {
+	var res model.ArchiveImport
+	dec := json.NewDecoder(r)
+	var archive model.Archive
+	if err := dec.Decode(&archive); err != nil {
+		return res, fmt.Errorf("%w: cannot read header: %v", domain.ErrorInvalidArchive, err)
+	}
+	if archive.Kind != model.ArchiveKind {
+		return res, fmt.Errorf("%w: unknown kind %q", domain.ErrorInvalidArchive, archive.Kind)
+	}
+	if archive.Version < 1 || archive.Version > model.ArchiveVersion {
+		return res, fmt.Errorf("%w: version %d is not supported, up to %d is", domain.ErrorInvalidArchive,
+			archive.Version, model.ArchiveVersion)
+	}
+	// a JSON archive carries its trips, the following values are the trips of a JSON Lines one
+	for i := range archive.Trips {
+		if err := s.merge(ctx, &archive.Trips[i], i+1, &res); err != nil {
+			return res, err
+		}
+	}
+	for n := len(archive.Trips) + 1; dec.More(); n++ {
+		var trip model.Trip
+		if err := dec.Decode(&trip); err != nil {
+			return res, fmt.Errorf("%w: cannot read trip %d: %v", domain.ErrorInvalidArchive, n, err)
+		}
+		if err := s.merge(ctx, &trip, n, &res); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
+// merge stores the n-th trip of an archive, the IDs missing from a hand written archive are generated
+func (s *archiveService) merge(ctx context.Context, trip *model.Trip, n int, res *model.ArchiveImport) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if trip.Reference == "" {
+		return fmt.Errorf("%w: trip %d has no reference", domain.ErrorInvalidArchive, n)
+	}
+	if trip.ID == "" {
+		trip.ID = uuid.New().String()
+	}
+	for i := range trip.TripSteps {
+		if trip.TripSteps[i].ID == "" {
+			trip.TripSteps[i].ID = uuid.New().String()
+		}
+		trip.TripSteps[i].TripID = trip.ID
+	}
+	for i := range trip.Travellers {
+		if trip.Travellers[i].ID == "" {
+			trip.Travellers[i].ID = uuid.New().String()
+		}
+		trip.Travellers[i].TripID = trip.ID
+	}
+	created, err := s.repo.Merge(ctx, trip)
+	if err != nil {
+		return fmt.Errorf("cannot merge trip %s: %w", trip.Reference, err)
+	}
+	if created {
+		res.Created++
+	} else {
+		res.Updated++
+	}
+	return nil
+}
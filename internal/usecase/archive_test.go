@@ -0,0 +1,113 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryTrips is a TripRepository keeping trips in memory, merged by owner and reference
+type memoryTrips struct {
+	domain.TripRepository
+	mu    sync.Mutex
+	trips []model.Trip
+}
+
+func (r *memoryTrips) GetAll(owner string) ([]model.Trip, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]model.Trip(nil), r.trips...), nil
+}
+
+func (r *memoryTrips) Merge(_ context.Context, trip *model.Trip) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, t := range r.trips {
+		if t.Owner == trip.Owner && t.Reference == trip.Reference {
+			trip.ID = t.ID
+			r.trips[i] = *trip
+			return false, nil
+		}
+	}
+	r.trips = append(r.trips, *trip)
+	return true, nil
+}
+
+func archivedTrips() []model.Trip {
+	start := time.Date(2023, 5, 1, 8, 0, 0, 0, time.UTC)
+	return []model.Trip{
+		{ID: "T1", Owner: "bob", Reference: "DEF456", Start: start.AddDate(0, 1, 0), End: start.AddDate(0, 1, 2)},
+		{ID: "T0", Owner: "alice", Reference: "ABC123", Start: start, End: start.AddDate(0, 0, 3),
+			TripSteps: []model.TripStep{{ID: "S0", TripID: "T0", Type: model.TripStepTypeHotel, DateTime: start,
+				Location: "PARIS", Description: "Hotel"}},
+			Travellers: []model.Traveller{{ID: "P0", TripID: "T0", FirstName: "JOHN", LastName: "SMITH"}}},
+	}
+}
+
+func Test_archiveService_roundTrip(t *testing.T) {
+	for _, format := range []model.ArchiveFormat{model.ArchiveFormatJSONL, model.ArchiveFormatJSON} {
+		t.Run(string(format), func(t *testing.T) {
+			var b bytes.Buffer
+			n, err := NewArchiveService(&memoryTrips{trips: archivedTrips()}).Export(context.Background(), &b, format)
+			require.NoError(t, err)
+			assert.Equal(t, 2, n)
+			if format == model.ArchiveFormatJSONL {
+				assert.Equal(t, 3, strings.Count(b.String(), "\n"), "header and a line per trip")
+			}
+
+			target := &memoryTrips{trips: []model.Trip{{ID: "OLD", Owner: "alice", Reference: "ABC123"}}}
+			res, err := NewArchiveService(target).Import(context.Background(), bytes.NewReader(b.Bytes()))
+			require.NoError(t, err)
+			assert.Equal(t, model.ArchiveImport{Created: 1, Updated: 1}, res)
+			require.Len(t, target.trips, 2)
+			assert.Equal(t, "OLD", target.trips[0].ID, "the merged trip keeps its ID")
+			assert.Equal(t, "PARIS", target.trips[0].TripSteps[0].Location)
+			assert.Equal(t, "T1", target.trips[1].ID)
+		})
+	}
+}
+
+func Test_archiveService_Import(t *testing.T) {
+	header := `{"Kind":"amadeus-trip-parser/trips","Version":1}` + "\n"
+	tests := []struct {
+		name    string
+		archive string
+		want    model.ArchiveImport
+		wantErr bool
+	}{
+		{"empty archive", header, model.ArchiveImport{}, false},
+		{"trips without IDs", header + `{"Reference":"ABC123","TripSteps":[{"Location":"PARIS"}]}` + "\n" +
+			`{"Reference":"ABC123"}`, model.ArchiveImport{Created: 1, Updated: 1}, false},
+		{"not an archive", `{"Reference":"ABC123"}`, model.ArchiveImport{}, true},
+		{"newer version", `{"Kind":"amadeus-trip-parser/trips","Version":2}`, model.ArchiveImport{}, true},
+		{"trip without reference", header + `{"Reference":"ABC123"}` + "\n" + `{"ID":"T1"}`,
+			model.ArchiveImport{Created: 1}, true},
+		{"truncated trip", header + `{"Reference":"ABC`, model.ArchiveImport{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &memoryTrips{}
+			got, err := NewArchiveService(repo).Import(context.Background(), strings.NewReader(tt.archive))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, domain.ErrorInvalidArchive)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, got)
+			for _, trip := range repo.trips {
+				assert.NotEmpty(t, trip.ID)
+				for _, s := range trip.TripSteps {
+					assert.NotEmpty(t, s.ID)
+					assert.Equal(t, trip.ID, s.TripID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+)
+
+// apiKeyPrefix tells API keys apart from other secrets, e.g. when scanning for leaked credentials
+const apiKeyPrefix = "tp_"
+
+type authenticator struct {
+	keys     domain.APIKeyStore
+	verifier domain.TokenVerifier
+}
+
+// NewAuthenticator checks API keys against their stored hashes, and bearer tokens with verifier,
+// bearer tokens are refused when verifier is nil
+func NewAuthenticator(keys domain.APIKeyStore, verifier domain.TokenVerifier) domain.Authenticator {
+	return &authenticator{keys: keys, verifier: verifier}
+}
+
+func (a *authenticator) AuthenticateKey(key string) (model.Principal, error) {
+	k, err := a.keys.GetByHash(hashAPIKey(key))
+	if err != nil {
+		if errors.Is(err, domain.ErrorNoAPIKey) {
+			return model.Principal{}, fmt.Errorf("%w: invalid api key", domain.ErrorUnauthenticated)
+		}
+		return model.Principal{}, err
+	}
+	return model.Principal{Subject: k.Subject, Role: k.Role}, nil
+}
+
+func (a *authenticator) AuthenticateBearer(token string) (model.Principal, error) {
+	if a.verifier == nil {
+		return model.Principal{}, fmt.Errorf("%w: bearer tokens are not accepted", domain.ErrorUnauthenticated)
+	}
+	return a.verifier.Verify(token)
+}
+
+type apiKeyService struct {
+	keys domain.APIKeyStore
+}
+
+func NewAPIKeyService(keys domain.APIKeyStore) domain.APIKeyService {
+	return &apiKeyService{keys: keys}
+}
+
+func (s *apiKeyService) Create(name string, subject string, role model.Role) (string, model.APIKey, error) {
+	if subject == "" {
+		return "", model.APIKey{}, errors.New("cannot create api key without subject")
+	}
+	role, err := checkRole(role)
+	if err != nil {
+		return "", model.APIKey{}, fmt.Errorf("cannot create api key: %w", err)
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", model.APIKey{}, fmt.Errorf("cannot generate api key: %w", err)
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(b)
+	k := model.APIKey{Name: name, Subject: subject, Role: role, Hash: hashAPIKey(key)}
+	if err := s.keys.Create(&k); err != nil {
+		return "", model.APIKey{}, err
+	}
+	return key, k, nil
+}
+
+func (s *apiKeyService) List() ([]model.APIKey, error) {
+	return s.keys.List()
+}
+
+func (s *apiKeyService) Revoke(id string) error {
+	return s.keys.Delete(id)
+}
+
+// checkRole defaults an empty role to user and rejects unknown ones
+func checkRole(role model.Role) (model.Role, error) {
+	switch role {
+	case "":
+		return model.RoleUser, nil
+	case model.RoleUser, model.RoleAdmin:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role %s", role)
+	}
+}
+
+// hashAPIKey hashes keys without salt so that they can be looked up, they are random enough not to be guessed
+func hashAPIKey(key string) string {
+	h := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(h[:])
+}
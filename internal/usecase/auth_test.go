@@ -0,0 +1,135 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type memoryAPIKeys struct {
+	mu   sync.Mutex
+	keys map[string]model.APIKey
+}
+
+func newMemoryAPIKeys() *memoryAPIKeys {
+	return &memoryAPIKeys{keys: make(map[string]model.APIKey)}
+}
+
+func (m *memoryAPIKeys) Create(key *model.APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	key.ID = key.Hash[:8]
+	m.keys[key.ID] = *key
+	return nil
+}
+
+func (m *memoryAPIKeys) GetByHash(hash string) (model.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, k := range m.keys {
+		if k.Hash == hash {
+			return k, nil
+		}
+	}
+	return model.APIKey{}, domain.ErrorNoAPIKey
+}
+
+func (m *memoryAPIKeys) List() ([]model.APIKey, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var keys []model.APIKey
+	for _, k := range m.keys {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (m *memoryAPIKeys) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.keys[id]; !ok {
+		return domain.ErrorNoAPIKey
+	}
+	delete(m.keys, id)
+	return nil
+}
+
+func (m *memoryAPIKeys) DeleteBySubject(subject string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, k := range m.keys {
+		if k.Subject == subject {
+			delete(m.keys, id)
+		}
+	}
+	return nil
+}
+
+type mockVerifier map[string]model.Principal
+
+func (m mockVerifier) Verify(token string) (model.Principal, error) {
+	if p, ok := m[token]; ok {
+		return p, nil
+	}
+	return model.Principal{}, domain.ErrorUnauthenticated
+}
+
+func Test_authenticator(t *testing.T) {
+	keys := newMemoryAPIKeys()
+	service := NewAPIKeyService(keys)
+	alice, created, err := service.Create("laptop", "alice", "")
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !strings.HasPrefix(alice, apiKeyPrefix) || created.Hash == alice || created.Role != model.RoleUser {
+		t.Errorf("Create() got key %s and record %v, want a prefixed key stored hashed with user role", alice, created)
+	}
+	admin, _, _ := service.Create("ops", "root", model.RoleAdmin)
+	if _, _, err := service.Create("ops", "", model.RoleAdmin); err == nil {
+		t.Errorf("Create() of a key without subject succeeded")
+	}
+	if _, _, err := service.Create("ops", "root", "owner"); err == nil {
+		t.Errorf("Create() of a key with unknown role succeeded")
+	}
+
+	tests := []struct {
+		name     string
+		verifier domain.TokenVerifier
+		key      string
+		bearer   string
+		want     model.Principal
+		wantErr  error
+	}{
+		{"user key", nil, alice, "", model.Principal{Subject: "alice", Role: model.RoleUser}, nil},
+		{"admin key", nil, admin, "", model.Principal{Subject: "root", Role: model.RoleAdmin}, nil},
+		{"unknown key", nil, apiKeyPrefix + "guess", "", model.Principal{}, domain.ErrorUnauthenticated},
+		{"bearer without verifier", nil, "", "jwt", model.Principal{}, domain.ErrorUnauthenticated},
+		{"bearer", mockVerifier{"jwt": {Subject: "bob", Role: model.RoleUser}}, "", "jwt",
+			model.Principal{Subject: "bob", Role: model.RoleUser}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := NewAuthenticator(keys, tt.verifier)
+			var got model.Principal
+			var err error
+			if tt.key != "" {
+				got, err = a.AuthenticateKey(tt.key)
+			} else {
+				got, err = a.AuthenticateBearer(tt.bearer)
+			}
+			if !errors.Is(err, tt.wantErr) || got != tt.want {
+				t.Errorf("authenticate got = %v, %v, want %v, %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+
+	if err := service.Revoke(created.ID); err != nil {
+		t.Errorf("Revoke() error = %v", err)
+	}
+	if _, err := NewAuthenticator(keys, nil).AuthenticateKey(alice); !errors.Is(err, domain.ErrorUnauthenticated) {
+		t.Errorf("AuthenticateKey() of a revoked key error = %v, want %v", err, domain.ErrorUnauthenticated)
+	}
+}
@@ -0,0 +1,125 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"fmt"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"strings"
+	"time"
+)
+
+// DefaultBackfillWindow is the time range of the emails fetched at once
+const DefaultBackfillWindow = 30 * 24 * time.Hour
+
+type backfillService struct {
+	mailboxes domain.MailboxStore
+	providers domain.EmailProviderFactory
+	processor domain.EmailProcessor
+	store     domain.BackfillStore
+	window    time.Duration
+}
+
+// NewBackfillService queues the past emails of mailboxes to the processor, window is the time range fetched at once
+// and defaults to DefaultBackfillWindow
+func NewBackfillService(mailboxes domain.MailboxStore, providers domain.EmailProviderFactory,
+	processor domain.EmailProcessor, store domain.BackfillStore, window time.Duration) domain.BackfillService {
+	if window <= 0 {
+		window = DefaultBackfillWindow
+	}
+	return &backfillService{mailboxes: mailboxes, providers: providers, processor: processor, store: store,
+		window: window}
+}
+
+// backfillID identifies the backfills of the same mailbox, start and sender, so that they resume each other
+func backfillID(b model.Backfill) string {
+	return model.ContentHash(strings.Join([]string{b.MailboxID, b.Sender, b.After.UTC().Format(time.RFC3339)},
+		"|"))[:16]
+}
+
+// backfillFilter selects the emails of a window, dates are given as seconds since epoch to be exact
+func backfillFilter(sender string, after time.Time, before time.Time) string {
+	filter := fmt.Sprintf("after:%d before:%d", after.Unix(), before.Unix())
+	if sender != "" {
+		filter = fmt.Sprintf("from:%s %s", sender, filter)
+	}
+	return filter
+}
+
+func (s *backfillService) Run(ctx context.Context, request model.Backfill,
+	progress func(model.Backfill)) (model.Backfill, error) {
+	if request.After.IsZero() || !request.After.Before(request.Before) {
+		return request, fmt.Errorf("%w: %s is not before %s", domain.ErrorInvalidRange,
+			request.After.Format(time.RFC3339), request.Before.Format(time.RFC3339))
+	}
+	mailbox, err := s.mailboxes.Get(request.MailboxID)
+	if err != nil {
+		return request, err
+	}
+	provider, err := s.providers.Provider(mailbox)
+	if err != nil {
+		return request, err
+	}
+	b, err := s.store.Get(backfillID(request))
+	switch {
+	case errors.Is(err, domain.ErrorNoBackfill):
+		b = request
+		b.ID = backfillID(request)
+		b.Checkpoint = b.After
+	case err != nil:
+		return request, err
+	default:
+		// the range of a backfill resumed may end later
+		b.Before = request.Before
+	}
+
+	for !b.Done() {
+		if err := ctx.Err(); err != nil {
+			return b, err
+		}
+		end := b.Checkpoint.Add(s.window)
+		if end.After(b.Before) {
+			end = b.Before
+		}
+		fetched, queued, err := s.runWindow(ctx, mailbox, provider, b, end)
+		if err != nil {
+			return b, err
+		}
+		b.Checkpoint = end
+		b.Fetched += fetched
+		b.Queued += queued
+		if err := s.store.Save(&b); err != nil {
+			return b, err
+		}
+		progress(b)
+	}
+	return b, nil
+}
+
+// runWindow queues the emails of the window from the checkpoint to end. A message that cannot be fetched fails the
+// window, which is fetched again on next run.
+func (s *backfillService) runWindow(ctx context.Context, mailbox model.Mailbox, provider domain.EmailProvider,
+	b model.Backfill, end time.Time) (int, int, error) {
+	ctx, span := tracer.Start(ctx, "backfill.window", trace.WithAttributes(
+		attribute.String("mailbox.id", mailbox.ID),
+		attribute.String("backfill.after", b.Checkpoint.Format(time.RFC3339)),
+		attribute.String("backfill.before", end.Format(time.RFC3339))))
+	defer span.End()
+	emails, fetchErr := provider.GetEmails(ctx, backfillFilter(b.Sender, b.Checkpoint, end))
+	// the emails fetched are queued anyway, they are skipped on next run
+	queued, err := s.processor.Enqueue(ctx, mailbox, emails)
+	if fetchErr != nil {
+		span.RecordError(fetchErr)
+		return len(emails), queued, fmt.Errorf("cannot fetch emails from %s to %s: %w",
+			b.Checkpoint.Format(time.RFC3339), end.Format(time.RFC3339), fetchErr)
+	}
+	if err != nil {
+		span.RecordError(err)
+		return len(emails), queued, err
+	}
+	span.SetAttributes(attribute.Int("backfill.fetched", len(emails)), attribute.Int("backfill.queued", queued))
+	return len(emails), queued, nil
+}
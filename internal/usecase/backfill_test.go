@@ -0,0 +1,132 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryBackfills is a BackfillStore keeping backfills in memory
+type memoryBackfills struct {
+	mu        sync.Mutex
+	backfills map[string]model.Backfill
+}
+
+func (s *memoryBackfills) Get(id string) (model.Backfill, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if b, ok := s.backfills[id]; ok {
+		return b, nil
+	}
+	return model.Backfill{}, domain.ErrorNoBackfill
+}
+
+func (s *memoryBackfills) Save(b *model.Backfill) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.backfills[b.ID] = *b
+	return nil
+}
+
+// singleProvider connects every mailbox to the same provider
+type singleProvider struct {
+	provider domain.EmailProvider
+}
+
+func (f singleProvider) Provider(model.Mailbox) (domain.EmailProvider, error) {
+	return f.provider, nil
+}
+
+func Test_backfillService_Run(t *testing.T) {
+	mailboxes := newMemoryMailboxes()
+	require.NoError(t, mailboxes.Save(&model.Mailbox{ID: "M0", Owner: "alice"}))
+	after := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	request := model.Backfill{MailboxID: "M0", Sender: "booking@example.com", After: after,
+		Before: after.AddDate(0, 0, 25)}
+	windows := []string{
+		backfillFilter(request.Sender, after, after.AddDate(0, 0, 10)),
+		backfillFilter(request.Sender, after.AddDate(0, 0, 10), after.AddDate(0, 0, 20)),
+		backfillFilter(request.Sender, after.AddDate(0, 0, 20), after.AddDate(0, 0, 25)),
+	}
+	first := []*model.Email{{ID: "MSG0"}, {ID: "MSG1"}}
+	second := []*model.Email{{ID: "MSG2"}}
+
+	failing := &mocks.EmailProvider{}
+	failing.On("GetEmails", mock.Anything, windows[0]).Return(first, nil)
+	failing.On("GetEmails", mock.Anything, windows[1]).Return(nil, &domain.FetchError{Err: errors.New("unavailable")})
+	provider := &mocks.EmailProvider{}
+	provider.On("GetEmails", mock.Anything, windows[1]).Return(second, nil)
+	provider.On("GetEmails", mock.Anything, windows[2]).Return([]*model.Email{}, nil)
+
+	processor := &mocks.EmailProcessor{}
+	processor.On("Enqueue", mock.Anything, mock.Anything, first).Return(1, nil)
+	processor.On("Enqueue", mock.Anything, mock.Anything, second).Return(1, nil)
+	processor.On("Enqueue", mock.Anything, mock.Anything, mock.Anything).Return(0, nil)
+	store := &memoryBackfills{backfills: make(map[string]model.Backfill)}
+
+	// the second window fails, the first one is kept
+	var progress []time.Time
+	s := NewBackfillService(mailboxes, singleProvider{failing}, processor, store, 10*24*time.Hour)
+	b, err := s.Run(context.Background(), request, func(b model.Backfill) { progress = append(progress, b.Checkpoint) })
+	assert.Error(t, err)
+	assert.Equal(t, []time.Time{after.AddDate(0, 0, 10)}, progress)
+	saved, _ := store.Get(b.ID)
+	assert.Equal(t, after.AddDate(0, 0, 10), saved.Checkpoint)
+	assert.Equal(t, 2, saved.Fetched)
+	assert.Equal(t, 1, saved.Queued)
+
+	// the same backfill resumes from the checkpoint
+	s = NewBackfillService(mailboxes, singleProvider{provider}, processor, store, 10*24*time.Hour)
+	b, err = s.Run(context.Background(), request, func(b model.Backfill) { progress = append(progress, b.Checkpoint) })
+	require.NoError(t, err)
+	assert.True(t, b.Done())
+	assert.Equal(t, []time.Time{after.AddDate(0, 0, 10), after.AddDate(0, 0, 20), request.Before}, progress)
+	assert.Equal(t, 3, b.Fetched)
+	assert.Equal(t, 2, b.Queued)
+	provider.AssertNotCalled(t, "GetEmails", mock.Anything, windows[0])
+
+	// a done backfill fetches nothing
+	_, err = s.Run(context.Background(), request, func(model.Backfill) { t.Error("done backfill progressed") })
+	assert.NoError(t, err)
+}
+
+func Test_backfillService_Run_invalid(t *testing.T) {
+	s := NewBackfillService(newMemoryMailboxes(), singleProvider{}, &mocks.EmailProcessor{},
+		&memoryBackfills{backfills: make(map[string]model.Backfill)}, 0)
+	now := time.Now()
+	_, err := s.Run(context.Background(), model.Backfill{MailboxID: "M0", After: now, Before: now.AddDate(0, 0, -1)},
+		func(model.Backfill) {})
+	assert.True(t, errors.Is(err, domain.ErrorInvalidRange))
+	_, err = s.Run(context.Background(), model.Backfill{MailboxID: "M0", After: now.AddDate(0, 0, -1), Before: now},
+		func(model.Backfill) {})
+	assert.True(t, errors.Is(err, domain.ErrorNoMailbox))
+}
+
+func Test_rateLimitedParser(t *testing.T) {
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{ID: "AJ0"}, nil)
+	limited := NewRateLimitedParser(parser, 100)
+
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		_, err := limited.CreateJob(context.Background(), email)
+		require.NoError(t, err)
+	}
+	assert.GreaterOrEqual(t, int64(time.Since(start)), int64(40*time.Millisecond), "calls are spaced by 10ms")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	slow := NewRateLimitedParser(parser, 0.1)
+	_, _ = slow.CreateJob(ctx, email)
+	_, err := slow.CreateJob(ctx, email)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Same(t, parser, NewRateLimitedParser(parser, 0), "no limit")
+}
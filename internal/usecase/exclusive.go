@@ -0,0 +1,141 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"github.com/rs/zerolog/log"
+	"sync"
+	"time"
+)
+
+// DefaultLockInterval is the delay between two attempts of a standby replica to take the processing lock, and
+// between two checks of the lock once taken
+const DefaultLockInterval = 30 * time.Second
+
+// exclusiveProcessor runs the email processor of a replica only while it holds the processing lock, so that the
+// replicas sharing a database do not poll the mailboxes nor call the parser for the same emails. The others stand
+// by and take over once the lock is released.
+type exclusiveProcessor struct {
+	processor domain.EmailProcessor
+	lock      domain.ProcessingLock
+	interval  time.Duration
+	ctx       context.Context
+	cancel    context.CancelFunc
+	done      chan struct{}
+
+	mu      sync.Mutex
+	started bool
+	active  bool
+}
+
+// NewExclusiveProcessor processes emails with processor once lock is taken, it is tried again after each interval
+func NewExclusiveProcessor(processor domain.EmailProcessor, lock domain.ProcessingLock,
+	interval time.Duration) domain.EmailProcessor {
+	if interval <= 0 {
+		interval = DefaultLockInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &exclusiveProcessor{processor: processor, lock: lock, interval: interval, ctx: ctx, cancel: cancel,
+		done: make(chan struct{})}
+}
+
+func (p *exclusiveProcessor) Process() {
+	p.mu.Lock()
+	p.started = true
+	p.mu.Unlock()
+	go p.run()
+}
+
+func (p *exclusiveProcessor) run() {
+	defer close(p.done)
+	standby := false
+	for {
+		if !p.isActive() {
+			locked, err := p.lock.TryLock(p.ctx)
+			switch {
+			case err != nil:
+				log.Error().Msgf("cannot take the processing lock: %s", err)
+			case locked:
+				log.Info().Msg("processing emails, the other replicas stand by")
+				p.setActive(true)
+				p.processor.Process()
+			case !standby:
+				log.Info().Msg("emails are processed by another replica, standing by")
+				standby = true
+			}
+		} else if err := p.lock.Check(p.ctx); err != nil && p.ctx.Err() == nil {
+			// another replica may take over from now on, the processor of this one cannot be restarted
+			log.Error().Msgf("processing lock lost, this replica stops processing emails: %s", err)
+			p.setActive(false)
+			if err := p.processor.Stop(p.ctx); err != nil {
+				log.Error().Msgf("cannot stop email processor gracefully: %s", err)
+			}
+			p.lock.Unlock()
+			return
+		}
+		select {
+		case <-time.After(p.interval):
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *exclusiveProcessor) isActive() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.active
+}
+
+func (p *exclusiveProcessor) setActive(active bool) {
+	p.mu.Lock()
+	p.active = active
+	p.mu.Unlock()
+}
+
+// Stop stops the processor when the lock is held, and releases the lock for another replica to take over
+func (p *exclusiveProcessor) Stop(ctx context.Context) error {
+	p.mu.Lock()
+	started := p.started
+	p.mu.Unlock()
+	p.cancel()
+	if !started {
+		return nil
+	}
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if !p.isActive() {
+		return nil
+	}
+	err := p.processor.Stop(ctx)
+	if uerr := p.lock.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+func (p *exclusiveProcessor) Enqueue(ctx context.Context, mailbox model.Mailbox, emails []*model.Email) (int, error) {
+	if !p.isActive() {
+		return 0, domain.ErrorStandby
+	}
+	return p.processor.Enqueue(ctx, mailbox, emails)
+}
+
+func (p *exclusiveProcessor) Reprocess(messageID string) error {
+	if !p.isActive() {
+		return domain.ErrorStandby
+	}
+	return p.processor.Reprocess(messageID)
+}
+
+// Progress reports no stage on a standby replica
+func (p *exclusiveProcessor) Progress() []model.StageProgress {
+	if !p.isActive() {
+		return nil
+	}
+	return p.processor.Progress()
+}
@@ -0,0 +1,116 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryLock is a processing lock shared by the processors of a test
+type memoryLock struct {
+	mu     sync.Mutex
+	holder *memoryLockHolder
+}
+
+type memoryLockHolder struct {
+	lock *memoryLock
+	lost bool
+}
+
+func (l *memoryLock) holderLock() *memoryLockHolder {
+	return &memoryLockHolder{lock: l}
+}
+
+func (h *memoryLockHolder) TryLock(context.Context) (bool, error) {
+	h.lock.mu.Lock()
+	defer h.lock.mu.Unlock()
+	if h.lock.holder != nil && h.lock.holder != h {
+		return false, nil
+	}
+	h.lock.holder = h
+	return true, nil
+}
+
+func (h *memoryLockHolder) Check(context.Context) error {
+	h.lock.mu.Lock()
+	defer h.lock.mu.Unlock()
+	if h.lost {
+		return errors.New("session closed")
+	}
+	return nil
+}
+
+func (h *memoryLockHolder) Unlock() error {
+	h.lock.mu.Lock()
+	defer h.lock.mu.Unlock()
+	if h.lock.holder == h {
+		h.lock.holder = nil
+	}
+	return nil
+}
+
+func (h *memoryLockHolder) lose() {
+	h.lock.mu.Lock()
+	defer h.lock.mu.Unlock()
+	h.lost = true
+}
+
+func Test_exclusiveProcessor(t *testing.T) {
+	lock := &memoryLock{}
+	first, second := &mocks.EmailProcessor{}, &mocks.EmailProcessor{}
+	for _, proc := range []*mocks.EmailProcessor{first, second} {
+		proc.On("Process").Return()
+		proc.On("Stop", mock.Anything).Return(nil)
+		proc.On("Reprocess", "MSG0").Return(nil)
+		proc.On("Progress").Return([]model.StageProgress{{Stage: stagePoll}})
+	}
+
+	firstLock := lock.holderLock()
+	active := NewExclusiveProcessor(first, firstLock, 10*time.Millisecond)
+	active.Process()
+	assert.Eventually(t, func() bool { return active.Reprocess("MSG0") == nil }, time.Second, 5*time.Millisecond)
+	first.AssertCalled(t, "Process")
+
+	standby := NewExclusiveProcessor(second, lock.holderLock(), 10*time.Millisecond)
+	standby.Process()
+	time.Sleep(50 * time.Millisecond)
+	second.AssertNotCalled(t, "Process")
+	assert.ErrorIs(t, standby.Reprocess("MSG0"), domain.ErrorStandby)
+	_, err := standby.Enqueue(context.Background(), model.Mailbox{}, nil)
+	assert.ErrorIs(t, err, domain.ErrorStandby)
+	assert.Empty(t, standby.Progress())
+	assert.Len(t, active.Progress(), 1)
+
+	// the standby replica takes over once the lock is released
+	assert.NoError(t, active.Stop(context.Background()))
+	first.AssertCalled(t, "Stop", mock.Anything)
+	assert.Eventually(t, func() bool { return standby.Reprocess("MSG0") == nil }, time.Second, 5*time.Millisecond)
+	second.AssertCalled(t, "Process")
+	assert.NoError(t, standby.Stop(context.Background()))
+}
+
+func Test_exclusiveProcessor_lockLost(t *testing.T) {
+	proc := &mocks.EmailProcessor{}
+	proc.On("Process").Return()
+	proc.On("Stop", mock.Anything).Return(nil)
+	proc.On("Reprocess", "MSG0").Return(nil)
+
+	holder := (&memoryLock{}).holderLock()
+	p := NewExclusiveProcessor(proc, holder, 10*time.Millisecond)
+	p.Process()
+	assert.Eventually(t, func() bool { return p.Reprocess("MSG0") == nil }, time.Second, 5*time.Millisecond)
+
+	holder.lose()
+	assert.Eventually(t, func() bool { return errors.Is(p.Reprocess("MSG0"), domain.ErrorStandby) }, time.Second,
+		5*time.Millisecond)
+	// stopped once, when the lock was lost
+	assert.NoError(t, p.Stop(context.Background()))
+	proc.AssertNumberOfCalls(t, "Stop", 1)
+}
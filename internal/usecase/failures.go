@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"fmt"
+)
+
+type failureService struct {
+	store     domain.FailureStore
+	processor domain.EmailProcessor
+}
+
+func NewFailureService(store domain.FailureStore, processor domain.EmailProcessor) domain.FailureService {
+	return &failureService{store: store, processor: processor}
+}
+
+func (s *failureService) List() ([]model.Failure, error) {
+	return s.store.List()
+}
+
+func (s *failureService) Get(id string) (model.Failure, error) {
+	return s.store.Get(id)
+}
+
+// Retry replays the queued email, the failure is kept so that its attempts keep being counted. An email that
+// could not be fetched is not queued, it is fetched again by next poll.
+func (s *failureService) Retry(id string) error {
+	f, err := s.store.Get(id)
+	if err != nil {
+		return err
+	}
+	if f.Stage == model.FailureStageFetch {
+		return nil
+	}
+	if err := s.processor.Reprocess(f.EmailID); err != nil {
+		return fmt.Errorf("cannot retry failure %s: %w", id, err)
+	}
+	return nil
+}
+
+func (s *failureService) Delete(id string) error {
+	return s.store.Delete(id)
+}
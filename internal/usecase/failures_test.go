@@ -0,0 +1,44 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"testing"
+)
+
+func Test_failureService_Retry(t *testing.T) {
+	store := newMemoryFailures(
+		model.Failure{ID: "F0", EmailID: "MSG0"},
+		model.Failure{ID: "F1", EmailID: "MSG1"},
+		model.Failure{ID: "F2", EmailID: "MSG2", Stage: model.FailureStageFetch},
+	)
+	mockProcessor := &mocks.EmailProcessor{}
+	mockProcessor.On("Reprocess", "MSG0").Return(nil)
+	mockProcessor.On("Reprocess", "MSG1").Return(domain.ErrorAlreadyQueued)
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{"retry failure", "F0", nil},
+		{"retry failure being processed", "F1", domain.ErrorAlreadyQueued},
+		// fetched again by next poll, without reprocessing
+		{"retry failure to fetch", "F2", nil},
+		{"retry unknown failure", "1111", domain.ErrorNoFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewFailureService(store, mockProcessor)
+			if err := s.Retry(tt.id); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Retry() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	// the failure is kept until the email is processed
+	if _, err := store.Get("F0"); err != nil {
+		t.Errorf("Get() of a retried failure error = %v", err)
+	}
+}
@@ -0,0 +1,127 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// HealthConfig bounds readiness checks, zero values are replaced by defaults
+type HealthConfig struct {
+	// Timeout is given to each dependency check
+	Timeout time.Duration
+	// StallThreshold is the time after which a stage with pending items, or past its interval, is stalled
+	StallThreshold time.Duration
+}
+
+func DefaultHealthConfig() HealthConfig {
+	return HealthConfig{
+		Timeout:        5 * time.Second,
+		StallThreshold: 5 * time.Minute,
+	}
+}
+
+func (c HealthConfig) withDefaults() HealthConfig {
+	d := DefaultHealthConfig()
+	if c.Timeout <= 0 {
+		c.Timeout = d.Timeout
+	}
+	if c.StallThreshold <= 0 {
+		c.StallThreshold = d.StallThreshold
+	}
+	return c
+}
+
+type healthService struct {
+	checks    map[string]domain.HealthChecker
+	processor domain.EmailProcessor
+	config    HealthConfig
+}
+
+// NewHealthService checks the dependencies by component name, and the progress of the processor stages
+func NewHealthService(checks map[string]domain.HealthChecker, processor domain.EmailProcessor,
+	config HealthConfig) domain.HealthService {
+	return &healthService{checks: checks, processor: processor, config: config.withDefaults()}
+}
+
+func (s *healthService) Live() model.Health {
+	return model.Health{Status: model.HealthStatusUp}
+}
+
+// Ready runs the dependency checks concurrently, then reports each processor stage as 'processor.<stage>'
+func (s *healthService) Ready(ctx context.Context) model.Health {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	components := make(map[string]model.ComponentHealth)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range s.checks {
+		wg.Add(1)
+		go func(name string, check domain.HealthChecker) {
+			defer wg.Done()
+			health := model.ComponentHealth{Status: model.HealthStatusUp}
+			if err := check.CheckHealth(ctx); err != nil {
+				health = model.ComponentHealth{Status: model.HealthStatusDown, Detail: err.Error()}
+			}
+			mu.Lock()
+			components[name] = health
+			mu.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+
+	now := time.Now()
+	for _, p := range s.processor.Progress() {
+		components["processor."+p.Stage] = s.stageHealth(p, now)
+	}
+
+	health := model.Health{Status: model.HealthStatusUp, Components: components}
+	for _, c := range components {
+		if c.Status != model.HealthStatusUp {
+			health.Status = model.HealthStatusDown
+		}
+	}
+	return health
+}
+
+func (s *healthService) stageHealth(p model.StageProgress, now time.Time) model.ComponentHealth {
+	if p.LastProgress.IsZero() {
+		return model.ComponentHealth{Status: model.HealthStatusDown, Detail: "not started"}
+	}
+	last := p.LastProgress
+	health := model.ComponentHealth{Status: model.HealthStatusUp, LastProgress: &last, Pending: p.Pending}
+	since := now.Sub(p.LastProgress)
+	switch {
+	case p.Interval > 0 && since > p.Interval+s.config.StallThreshold:
+		health.Status = model.HealthStatusDown
+		health.Detail = fmt.Sprintf("no run for %s", since.Round(time.Second))
+	case p.Pending > 0 && since > s.config.StallThreshold:
+		health.Status = model.HealthStatusDown
+		health.Detail = fmt.Sprintf("no progress for %s with %d pending items", since.Round(time.Second), p.Pending)
+	}
+	return health
+}
+
+// NewMailboxHealthChecker checks the credentials of every mailbox, it fails with the first mailbox failing
+func NewMailboxHealthChecker(mailboxes domain.MailboxStore, providers domain.EmailProviderFactory) domain.HealthChecker {
+	return domain.HealthCheckerFunc(func(ctx context.Context) error {
+		list, err := mailboxes.List("")
+		if err != nil {
+			return err
+		}
+		for _, m := range list {
+			provider, err := providers.Provider(m)
+			if err == nil {
+				err = provider.CheckHealth(ctx)
+			}
+			if err != nil {
+				return fmt.Errorf("mailbox %s of %s: %w", m.ID, m.Owner, err)
+			}
+		}
+		return nil
+	})
+}
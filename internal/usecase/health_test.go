@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+func Test_healthService_Ready(t *testing.T) {
+	now := time.Now()
+	up := domain.HealthCheckerFunc(func(context.Context) error { return nil })
+	down := domain.HealthCheckerFunc(func(context.Context) error { return errors.New("token revoked") })
+	// a check outliving the timeout fails
+	slow := domain.HealthCheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	tests := []struct {
+		name       string
+		checks     map[string]domain.HealthChecker
+		progress   []model.StageProgress
+		wantStatus model.HealthStatus
+		wantDown   []string
+	}{
+		{
+			"all up",
+			map[string]domain.HealthChecker{"sqlite": up, "gmail": up},
+			[]model.StageProgress{
+				{Stage: stagePoll, LastProgress: now.Add(-14 * time.Minute), Interval: 10 * time.Minute},
+				{Stage: stageCreate, LastProgress: now.Add(-time.Hour)},
+				{Stage: stageStatus, LastProgress: now.Add(-time.Second), Pending: 3},
+			},
+			model.HealthStatusUp,
+			nil,
+		},
+		{
+			"dependency down",
+			map[string]domain.HealthChecker{"sqlite": up, "gmail": down, "amadeus": slow},
+			nil,
+			model.HealthStatusDown,
+			[]string{"gmail", "amadeus"},
+		},
+		{
+			"stages stalled",
+			map[string]domain.HealthChecker{"sqlite": up},
+			[]model.StageProgress{
+				{Stage: stagePoll, LastProgress: now.Add(-20 * time.Minute), Interval: 10 * time.Minute},
+				{Stage: stageCreate, LastProgress: now.Add(-time.Hour), Pending: 1},
+				{Stage: stageResult},
+			},
+			model.HealthStatusDown,
+			[]string{"processor.poll", "processor.create", "processor.result"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockProcessor := &mocks.EmailProcessor{}
+			mockProcessor.On("Progress").Return(tt.progress)
+			s := NewHealthService(tt.checks, mockProcessor,
+				HealthConfig{Timeout: 10 * time.Millisecond, StallThreshold: 5 * time.Minute})
+
+			got := s.Ready(context.Background())
+			assert.Equal(t, tt.wantStatus, got.Status)
+			assert.Len(t, got.Components, len(tt.checks)+len(tt.progress))
+			var gotDown []string
+			for name, c := range got.Components {
+				if c.Status == model.HealthStatusDown {
+					gotDown = append(gotDown, name)
+					assert.NotEmpty(t, c.Detail, name)
+				}
+			}
+			assert.ElementsMatch(t, tt.wantDown, gotDown)
+		})
+	}
+}
+
+func Test_mailboxHealthChecker(t *testing.T) {
+	valid := &mocks.EmailProvider{}
+	valid.On("CheckHealth", mock.Anything).Return(nil)
+	revoked := &mocks.EmailProvider{}
+	revoked.On("CheckHealth", mock.Anything).Return(errors.New("token revoked"))
+	mailboxes := &testMailboxes{
+		mailboxes: []model.Mailbox{{ID: "M0", Owner: "alice"}},
+		providers: map[string]domain.EmailProvider{"M0": valid, "M1": revoked},
+	}
+	check := NewMailboxHealthChecker(mailboxes, mailboxes)
+	assert.NoError(t, check.CheckHealth(context.Background()))
+
+	mailboxes.mailboxes = append(mailboxes.mailboxes, model.Mailbox{ID: "M1", Owner: "bob"})
+	assert.Error(t, check.CheckHealth(context.Background()), "revoked token")
+	mailboxes.mailboxes[1].ID = "M2"
+	assert.Error(t, check.CheckHealth(context.Background()), "mailbox without provider")
+}
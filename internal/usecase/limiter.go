@@ -0,0 +1,69 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"sync"
+	"time"
+)
+
+// rateLimitedParser spaces the calls to the parser API by interval, whatever the number of workers
+type rateLimitedParser struct {
+	domain.EmailParser
+	interval time.Duration
+	mu       sync.Mutex
+	next     time.Time
+}
+
+// NewRateLimitedParser makes at most perSecond calls to parser each second, health checks are not limited
+func NewRateLimitedParser(parser domain.EmailParser, perSecond float64) domain.EmailParser {
+	if perSecond <= 0 {
+		return parser
+	}
+	return &rateLimitedParser{EmailParser: parser, interval: time.Duration(float64(time.Second) / perSecond)}
+}
+
+// wait returns once the call is allowed, or with the error of ctx when done first
+func (p *rateLimitedParser) wait(ctx context.Context) error {
+	p.mu.Lock()
+	now := time.Now()
+	at := p.next
+	if at.Before(now) {
+		at = now
+	}
+	p.next = at.Add(p.interval)
+	p.mu.Unlock()
+
+	t := time.NewTimer(time.Until(at))
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *rateLimitedParser) CreateJob(ctx context.Context, mail *model.Email) (*model.EmailParsingJob, error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	return p.EmailParser.CreateJob(ctx, mail)
+}
+
+func (p *rateLimitedParser) GetJobStatus(ctx context.Context, job model.EmailParsingJob) (*model.EmailParsingJob,
+	error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	return p.EmailParser.GetJobStatus(ctx, job)
+}
+
+func (p *rateLimitedParser) GetJobResult(ctx context.Context, job model.EmailParsingJob) (*model.EmailParsingJob,
+	error) {
+	if err := p.wait(ctx); err != nil {
+		return nil, err
+	}
+	return p.EmailParser.GetJobResult(ctx, job)
+}
@@ -0,0 +1,107 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"github.com/rs/zerolog/log"
+	"time"
+)
+
+// cachedGeocoder asks geocoder once per address, the addresses it does not know included
+type cachedGeocoder struct {
+	geocoder domain.Geocoder
+	cache    domain.GeocodeCache
+}
+
+func NewCachedGeocoder(geocoder domain.Geocoder, cache domain.GeocodeCache) domain.Geocoder {
+	return &cachedGeocoder{geocoder: geocoder, cache: cache}
+}
+
+// Geocode returns the cached coordinates of address, failures other than ErrorNotGeocoded are not cached so that
+// the address is geocoded again next time
+func (g *cachedGeocoder) Geocode(ctx context.Context, address string) (model.Coordinates, error) {
+	cached, err := g.cache.Get(address)
+	switch {
+	case err == nil:
+		if !cached.Found {
+			return model.Coordinates{}, domain.ErrorNotGeocoded
+		}
+		return model.Coordinates{Latitude: cached.Latitude, Longitude: cached.Longitude}, nil
+	case !errors.Is(err, domain.ErrorNoGeocode):
+		log.Error().Msgf("cannot read geocode cache: %v", err)
+	}
+
+	c, err := g.geocoder.Geocode(ctx, address)
+	if err != nil && !errors.Is(err, domain.ErrorNotGeocoded) {
+		return c, err
+	}
+	geocode := model.Geocode{Address: address, Found: err == nil, Latitude: c.Latitude, Longitude: c.Longitude,
+		CreatedAt: time.Now()}
+	if err := g.cache.Save(&geocode); err != nil {
+		log.Error().Msgf("cannot save geocode of %q: %v", address, err)
+	}
+	return c, err
+}
+
+// locatingRepository resolves the coordinates of the steps of the trips stored
+type locatingRepository struct {
+	domain.TripRepository
+	airports domain.Geocoder
+	geocoder domain.Geocoder
+	timeout  time.Duration
+}
+
+// NewLocatingRepository locates flight steps by their airport code with airports, and the other steps, such as
+// hotels, by their address with geocoder. Airports missing from airports are located by their city with geocoder,
+// without time zone. Steps which cannot be located are stored without coordinates, geocoder may be nil to only locate
+// airports. The addresses of a trip are given timeout at most, so that a slow geocoder does not
+// hold the trip back: those not geocoded by then are left without coordinates.
+func NewLocatingRepository(repo domain.TripRepository, airports domain.Geocoder, geocoder domain.Geocoder,
+	timeout time.Duration) domain.TripRepository {
+	return &locatingRepository{TripRepository: repo, airports: airports, geocoder: geocoder, timeout: timeout}
+}
+
+func (r *locatingRepository) Create(ctx context.Context, trip *model.Trip) error {
+	r.locate(ctx, trip)
+	return r.TripRepository.Create(ctx, trip)
+}
+
+func (r *locatingRepository) Merge(ctx context.Context, trip *model.Trip) (bool, error) {
+	r.locate(ctx, trip)
+	return r.TripRepository.Merge(ctx, trip)
+}
+
+// locate sets the coordinates of the steps not located yet, a failure only leaves the step as is
+func (r *locatingRepository) locate(ctx context.Context, trip *model.Trip) {
+	geocodeCtx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	for i := range trip.TripSteps {
+		s := &trip.TripSteps[i]
+		// airports located before their time zones were known are located again
+		if _, ok := s.Coordinates(); ok && (s.TimeZone != "" || s.LocationCode == "") {
+			continue
+		}
+		geocoder, location, locateCtx := r.geocoder, s.Location, geocodeCtx
+		if s.LocationCode != "" {
+			geocoder, location, locateCtx = r.airports, s.LocationCode, ctx
+		}
+		if geocoder == nil || location == "" {
+			continue
+		}
+		c, err := geocoder.Geocode(locateCtx, location)
+		if errors.Is(err, domain.ErrorNotGeocoded) && s.LocationCode != "" && r.geocoder != nil && s.Location != "" {
+			location = s.Location
+			c, err = r.geocoder.Geocode(geocodeCtx, location)
+		}
+		switch {
+		case err == nil:
+			s.SetCoordinates(c)
+		case errors.Is(err, domain.ErrorNotGeocoded):
+			log.Debug().Msgf("location %q of trip %s not found", location, trip.Reference)
+		default:
+			log.Warn().Msgf("cannot locate %q of trip %s: %v", location, trip.Reference, err)
+		}
+	}
+}
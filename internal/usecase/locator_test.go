@@ -0,0 +1,147 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+// fakeGeocoder knows the locations of its map, and counts the calls by location
+type fakeGeocoder struct {
+	known map[string]model.Coordinates
+	err   error
+	calls map[string]int
+}
+
+func (g *fakeGeocoder) Geocode(_ context.Context, location string) (model.Coordinates, error) {
+	if g.calls == nil {
+		g.calls = make(map[string]int)
+	}
+	g.calls[location]++
+	if g.err != nil {
+		return model.Coordinates{}, g.err
+	}
+	c, ok := g.known[location]
+	if !ok {
+		return model.Coordinates{}, domain.ErrorNotGeocoded
+	}
+	return c, nil
+}
+
+// slowGeocoder answers once ctx is done
+type slowGeocoder struct{}
+
+func (slowGeocoder) Geocode(ctx context.Context, _ string) (model.Coordinates, error) {
+	<-ctx.Done()
+	return model.Coordinates{}, ctx.Err()
+}
+
+type memoryGeocodes map[string]model.Geocode
+
+func (m memoryGeocodes) Get(address string) (model.Geocode, error) {
+	g, ok := m[address]
+	if !ok {
+		return model.Geocode{}, domain.ErrorNoGeocode
+	}
+	return g, nil
+}
+
+func (m memoryGeocodes) Save(geocode *model.Geocode) error {
+	m[geocode.Address] = *geocode
+	return nil
+}
+
+var hammamet = model.Coordinates{Latitude: 36.4, Longitude: 10.6}
+
+func Test_cachedGeocoder_Geocode(t *testing.T) {
+	geocoder := &fakeGeocoder{known: map[string]model.Coordinates{"Hammamet": hammamet}}
+	cache := memoryGeocodes{}
+	g := NewCachedGeocoder(geocoder, cache)
+	for i := 0; i < 2; i++ {
+		got, err := g.Geocode(context.Background(), "Hammamet")
+		assert.NoError(t, err)
+		assert.Equal(t, hammamet, got)
+		_, err = g.Geocode(context.Background(), "Nowhere")
+		assert.ErrorIs(t, err, domain.ErrorNotGeocoded)
+	}
+	assert.Equal(t, map[string]int{"Hammamet": 1, "Nowhere": 1}, geocoder.calls, "found or not, addresses are cached")
+
+	geocoder.err = errors.New("geocoder unavailable")
+	_, err := g.Geocode(context.Background(), "Tunis")
+	assert.Equal(t, geocoder.err, err)
+	assert.NotContains(t, cache, "Tunis", "failures are not cached")
+}
+
+func Test_locatingRepository(t *testing.T) {
+	airports := &fakeGeocoder{known: map[string]model.Coordinates{
+		"ORY": {Latitude: 48.7233, Longitude: 2.3794, TimeZone: "Europe/Paris"}}}
+	tunis := model.Coordinates{Latitude: 36.8065, Longitude: 10.1815}
+	geocoder := &fakeGeocoder{known: map[string]model.Coordinates{"Hammamet, 8050, Tunisia": hammamet, "TUNIS": tunis}}
+	located := model.TripStep{Type: model.TripStepTypeHotel, Location: "Hammamet, 8050, Tunisia"}
+	located.SetCoordinates(model.Coordinates{Latitude: 1, Longitude: 2})
+	// located before the time zones of airports were known
+	locatedAirport := model.TripStep{Type: model.TripStepTypeFlightEnd, Location: "PARIS", LocationCode: "ORY"}
+	locatedAirport.SetCoordinates(model.Coordinates{Latitude: 48.7233, Longitude: 2.3794})
+	trip := model.Trip{Reference: "XXX999", TripSteps: []model.TripStep{
+		{Type: model.TripStepTypeFlightStart, Location: "PARIS", LocationCode: "ORY"},
+		{Type: model.TripStepTypeFlightEnd, Location: "TUNIS", LocationCode: "XXX"},
+		{Type: model.TripStepTypeHotel, Location: "Hammamet, 8050, Tunisia"},
+		{Type: model.TripStepTypeHotel},
+		located,
+		locatedAirport,
+	}}
+
+	repo := &memoryTrips{}
+	created, err := NewLocatingRepository(repo, airports, geocoder, time.Second).Merge(context.Background(), &trip)
+	require.NoError(t, err)
+	assert.True(t, created)
+	require.Len(t, repo.trips, 1)
+	var got []*model.Coordinates
+	var zones []string
+	for _, s := range repo.trips[0].TripSteps {
+		if c, ok := s.Coordinates(); ok {
+			got = append(got, &c)
+		} else {
+			got = append(got, nil)
+		}
+		zones = append(zones, s.TimeZone)
+	}
+	assert.Equal(t, []*model.Coordinates{{Latitude: 48.7233, Longitude: 2.3794}, &tunis, &hammamet, nil,
+		{Latitude: 1, Longitude: 2}, {Latitude: 48.7233, Longitude: 2.3794}}, got)
+	assert.Equal(t, []string{"Europe/Paris", "", "", "", "", "Europe/Paris"}, zones)
+	assert.Equal(t, map[string]int{"ORY": 2, "XXX": 1}, airports.calls, "flights are located by airport")
+	assert.Equal(t, map[string]int{"Hammamet, 8050, Tunisia": 1, "TUNIS": 1}, geocoder.calls,
+		"hotels are located by address, once, and airports missing from the list by city")
+
+	geocoder.err = errors.New("geocoder unavailable")
+	trip = model.Trip{TripSteps: []model.TripStep{{Type: model.TripStepTypeHotel, Location: "Tunis"}}}
+	_, err = NewLocatingRepository(repo, airports, geocoder, time.Second).Merge(context.Background(), &trip)
+	assert.NoError(t, err, "a step not located is stored")
+	_, err = NewLocatingRepository(repo, airports, nil, time.Second).Merge(context.Background(), &trip)
+	assert.NoError(t, err, "hotels are not located without geocoder")
+}
+
+func Test_locatingRepository_timeout(t *testing.T) {
+	airports := &fakeGeocoder{known: map[string]model.Coordinates{"ORY": {Latitude: 48.7233, Longitude: 2.3794}}}
+	trip := model.Trip{TripSteps: []model.TripStep{
+		{Type: model.TripStepTypeHotel, Location: "Hammamet, 8050, Tunisia"},
+		{Type: model.TripStepTypeHotel, Location: "Tunis"},
+		{Type: model.TripStepTypeFlightStart, LocationCode: "ORY"},
+	}}
+	start := time.Now()
+	repo := &memoryTrips{}
+	_, err := NewLocatingRepository(repo, airports, slowGeocoder{}, 50*time.Millisecond).Merge(context.Background(),
+		&trip)
+	require.NoError(t, err)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second), "the geocoder is given the timeout for the trip")
+	require.Len(t, repo.trips, 1)
+	_, ok := repo.trips[0].TripSteps[1].Coordinates()
+	assert.False(t, ok)
+	_, ok = repo.trips[0].TripSteps[2].Coordinates()
+	assert.True(t, ok, "airports are located past the timeout")
+}
@@ -0,0 +1,240 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"time"
+)
+
+// ParseJobRetention is how long a finished job is kept for its owner to get it
+const ParseJobRetention = time.Hour
+
+type parseJobService struct {
+	parser       domain.EmailParser
+	repo         domain.TripRepository
+	metrics      domain.Metrics
+	pollInterval time.Duration
+	maxPolls     int
+	retention    time.Duration
+	// ctx is cancelled by Stop, wg tracks the running jobs
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.RWMutex
+	jobs   map[string]*model.ParseJob
+}
+
+func NewParseJobService(parser domain.EmailParser, repo domain.TripRepository,
+	metrics domain.Metrics) domain.ParseJobService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &parseJobService{
+		parser:       parser,
+		repo:         repo,
+		metrics:      metrics,
+		pollInterval: 15 * time.Second,
+		maxPolls:     40,
+		retention:    ParseJobRetention,
+		ctx:          ctx,
+		cancel:       cancel,
+		jobs:         make(map[string]*model.ParseJob),
+	}
+}
+
+func (s *parseJobService) Submit(ctx context.Context, user model.Principal, email *model.Email) (model.ParseJob,
+	error) {
+	if user.Subject == "" {
+		return model.ParseJob{}, fmt.Errorf("%w: no authenticated user", domain.ErrorForbidden)
+	}
+	if email == nil || email.Content == "" {
+		return model.ParseJob{}, fmt.Errorf("%w: no content to parse", domain.ErrorInvalidEmail)
+	}
+	s.metrics.EmailsFetched("upload", 1)
+	now := time.Now()
+	job := &model.ParseJob{
+		ID:        uuid.New().String(),
+		Owner:     user.Subject,
+		Status:    model.MailParsingStatusPending,
+		Subject:   email.Subject,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	s.mu.Lock()
+	// checked under the lock for Stop not to miss a job
+	if s.ctx.Err() != nil {
+		s.mu.Unlock()
+		return model.ParseJob{}, domain.ErrorStopped
+	}
+	s.evict(now)
+	s.jobs[job.ID] = job
+	submitted := *job
+	s.wg.Add(1)
+	s.mu.Unlock()
+
+	// the job outlives the request, it keeps its trace only and is cancelled by Stop
+	go func() {
+		defer s.wg.Done()
+		s.run(detach(s.ctx, ctx), submitted.ID, submitted.Owner, email)
+	}()
+	return submitted, nil
+}
+
+// evict forgets the jobs finished for longer than the retention, s.mu is held
+func (s *parseJobService) evict(now time.Time) {
+	for id, job := range s.jobs {
+		if job.Status != model.MailParsingStatusPending && now.Sub(job.UpdatedAt) > s.retention {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// Stop cancels the running jobs, they fail as the service cannot resume them, and waits for them to end
+func (s *parseJobService) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	s.cancel()
+	s.mu.Unlock()
+	stopped := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("parse jobs did not stop in time: %w", ctx.Err())
+	}
+}
+
+func (s *parseJobService) Get(user model.Principal, id string) (model.ParseJob, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	job, ok := s.jobs[id]
+	// the jobs of other users are not disclosed
+	if !ok || (!user.IsAdmin() && job.Owner != user.Subject) {
+		return model.ParseJob{}, domain.ErrorJobNotFound
+	}
+	return *job, nil
+}
+
+func (s *parseJobService) update(id string, f func(job *model.ParseJob)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job, ok := s.jobs[id]; ok {
+		f(job)
+		job.UpdatedAt = time.Now()
+	}
+}
+
+func (s *parseJobService) fail(ctx context.Context, id string, stage model.FailureStage, err error) {
+	detail := err.Error()
+	log.Debug().Msgf("parse job %s failed: %s", id, detail)
+	spanError(ctx, detail)
+	s.metrics.JobFailed(stage)
+	s.update(id, func(job *model.ParseJob) {
+		job.Status = model.MailParsingStatusError
+		job.Detail = detail
+	})
+}
+
+// Parse runs the parser job of an email until its result, the trip is returned without being stored
+func (s *parseJobService) Parse(ctx context.Context, email *model.Email) (*model.EmailParsingJob, error) {
+	if email == nil || email.Content == "" {
+		return nil, fmt.Errorf("%w: no content to parse", domain.ErrorInvalidEmail)
+	}
+	ctx, span := tracer.Start(ctx, "parse_job.parse")
+	defer span.End()
+	result, stage, err := s.parse(ctx, email, func(*model.EmailParsingJob) {})
+	if err != nil {
+		spanError(ctx, err.Error())
+		s.metrics.JobFailed(stage)
+		return nil, err
+	}
+	s.metrics.JobCompleted()
+	return result, nil
+}
+
+// run parses an uploaded email and stores its trip for owner
+func (s *parseJobService) run(ctx context.Context, id string, owner string, email *model.Email) {
+	ctx, span := tracer.Start(ctx, "parse_job.run", trace.WithAttributes(attribute.String("parse_job.id", id)))
+	defer span.End()
+	result, stage, err := s.parse(ctx, email, func(job *model.EmailParsingJob) {
+		parserJobID, warnings := job.ID, job.Warnings
+		s.update(id, func(j *model.ParseJob) {
+			j.ParserJobID = parserJobID
+			j.Warnings = warnings
+		})
+	})
+	if err != nil {
+		s.fail(ctx, id, stage, err)
+		return
+	}
+	trip := result.Trip
+	trip.Owner = owner
+	// an email uploaded again updates the trip of its reference
+	if _, err := s.repo.Merge(ctx, &trip); err != nil {
+		s.metrics.RepositoryError("trips")
+		s.fail(ctx, id, model.FailureStageStore, fmt.Errorf("cannot store trip %s: %w", trip.Reference, err))
+		return
+	}
+	s.metrics.JobCompleted()
+	log.Debug().Msgf("parse job %s stored trip %s (ref: %s)", id, trip.ID, trip.Reference)
+	s.update(id, func(j *model.ParseJob) {
+		j.Status = model.MailParsingStatusDone
+		j.Warnings = result.Warnings
+		j.Trip = &trip
+	})
+}
+
+// parse goes through the parser job lifecycle: creation, status polling until done, then result retrieval.
+// The job is given to progress once created and after each status check. The result holds the warnings of the
+// last status check and of the result, an error comes with the stage failing.
+func (s *parseJobService) parse(ctx context.Context, email *model.Email,
+	progress func(job *model.EmailParsingJob)) (*model.EmailParsingJob, model.FailureStage, error) {
+	created, err := s.parser.CreateJob(ctx, email)
+	if err != nil {
+		return nil, model.FailureStageCreate, fmt.Errorf("cannot create parser job: %w", err)
+	}
+	s.metrics.JobCreated()
+	progress(created)
+
+	job := created
+	for polls := 0; job.Status != model.MailParsingStatusDone; polls++ {
+		if polls >= s.maxPolls {
+			return nil, model.FailureStageParse,
+				fmt.Errorf("parser job %s still pending after %d status checks", created.ID, polls)
+		}
+		select {
+		case <-time.After(s.pollInterval):
+		case <-ctx.Done():
+			return nil, model.FailureStageParse, fmt.Errorf("parser job %s not checked: %w", created.ID, ctx.Err())
+		}
+		job, err = s.parser.GetJobStatus(ctx, *job)
+		if err != nil {
+			return nil, model.FailureStageParse, fmt.Errorf("cannot refresh parser job %s: %w", created.ID, err)
+		}
+		progress(&model.EmailParsingJob{ID: created.ID, Status: job.Status, Warnings: job.Warnings})
+		switch job.Status {
+		case model.MailParsingStatusDone, model.MailParsingStatusPending:
+		case model.MailParsingStatusError:
+			return nil, model.FailureStageParse, errors.New(job.Detail)
+		default:
+			return nil, model.FailureStageParse,
+				fmt.Errorf("parser job %s has unknown status %s", created.ID, job.Status)
+		}
+	}
+
+	result, err := s.parser.GetJobResult(ctx, *job)
+	if err != nil {
+		return nil, model.FailureStageParse, fmt.Errorf("cannot get result of parser job %s: %w", created.ID, err)
+	}
+	result.Warnings = append(append([]string(nil), job.Warnings...), result.Warnings...)
+	return result, "", nil
+}
@@ -0,0 +1,216 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+var email = &model.Email{
+	Subject: "Your booking XXX999",
+	ID:      "MSG0",
+	Content: "RW1haWwgY29udGVudA==",
+}
+
+func newParseJobService(parser domain.EmailParser, repo domain.TripRepository) *parseJobService {
+	s := NewParseJobService(parser, repo, domain.NopMetrics{}).(*parseJobService)
+	s.pollInterval = time.Millisecond
+	s.maxPolls = 3
+	return s
+}
+
+func Test_parseJobService_Submit(t *testing.T) {
+	s := newParseJobService(&mocks.EmailParser{}, &mocks.TripRepository{})
+
+	_, err := s.Submit(context.Background(), alice, &model.Email{Subject: "empty"})
+	assert.True(t, errors.Is(err, domain.ErrorInvalidEmail))
+
+	_, err = s.Submit(context.Background(), model.Principal{}, email)
+	assert.True(t, errors.Is(err, domain.ErrorForbidden))
+
+	_, err = s.Get(alice, "1111")
+	assert.True(t, errors.Is(err, domain.ErrorJobNotFound))
+
+	// jobs are only visible to their owner and admins
+	s.jobs["J0"] = &model.ParseJob{ID: "J0", Owner: "bob"}
+	_, err = s.Get(alice, "J0")
+	assert.True(t, errors.Is(err, domain.ErrorJobNotFound))
+	_, err = s.Get(admin, "J0")
+	assert.NoError(t, err)
+}
+
+func Test_parseJobService_run(t *testing.T) {
+	created := &model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending, Subject: email.Subject}
+	pending := &model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending, Subject: email.Subject}
+	done := &model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusDone, Subject: email.Subject,
+		Warnings: []string{"partial"}}
+	failed := &model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusError, Detail: "unsupported email"}
+	result := &model.EmailParsingJob{ID: "AJ0", Trip: trip[0]}
+
+	okParser := &mocks.EmailParser{}
+	okParser.On("CreateJob", mock.Anything, email).Return(created, nil)
+	okParser.On("GetJobStatus", mock.Anything, *created).Return(done, nil)
+	okParser.On("GetJobResult", mock.Anything, *done).Return(result, nil)
+
+	errParser := &mocks.EmailParser{}
+	errParser.On("CreateJob", mock.Anything, email).Return(created, nil)
+	errParser.On("GetJobStatus", mock.Anything, *created).Return(failed, nil)
+
+	createErrParser := &mocks.EmailParser{}
+	createErrParser.On("CreateJob", mock.Anything, email).Return(nil, errors.New("unauthorized"))
+
+	pendingParser := &mocks.EmailParser{}
+	pendingParser.On("CreateJob", mock.Anything, email).Return(created, nil)
+	pendingParser.On("GetJobStatus", mock.Anything, mock.Anything).Return(pending, nil)
+
+	repo := &mocks.TripRepository{}
+	repo.On("Merge", mock.Anything, mock.MatchedBy(func(t *model.Trip) bool { return t.Owner == "alice" })).
+		Return(true, nil)
+
+	tests := []struct {
+		name   string
+		parser domain.EmailParser
+		want   func(*testing.T, model.ParseJob)
+	}{
+		{
+			"job done",
+			okParser,
+			func(t *testing.T, job model.ParseJob) {
+				assert.Equal(t, model.MailParsingStatus(model.MailParsingStatusDone), job.Status)
+				assert.Equal(t, "AJ0", job.ParserJobID)
+				assert.Equal(t, []string{"partial"}, job.Warnings)
+				if assert.NotNil(t, job.Trip) {
+					assert.Equal(t, trip[0].Reference, job.Trip.Reference)
+				}
+			},
+		},
+		{
+			"job in error",
+			errParser,
+			func(t *testing.T, job model.ParseJob) {
+				assert.Equal(t, model.MailParsingStatus(model.MailParsingStatusError), job.Status)
+				assert.Equal(t, "unsupported email", job.Detail)
+				assert.Nil(t, job.Trip)
+			},
+		},
+		{
+			"job not created",
+			createErrParser,
+			func(t *testing.T, job model.ParseJob) {
+				assert.Equal(t, model.MailParsingStatus(model.MailParsingStatusError), job.Status)
+				assert.Contains(t, job.Detail, "unauthorized")
+			},
+		},
+		{
+			"job pending for too long",
+			pendingParser,
+			func(t *testing.T, job model.ParseJob) {
+				assert.Equal(t, model.MailParsingStatus(model.MailParsingStatusError), job.Status)
+				assert.Contains(t, job.Detail, "still pending")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newParseJobService(tt.parser, repo)
+			job := &model.ParseJob{ID: "J0", Owner: "alice", Status: model.MailParsingStatusPending,
+				Subject: email.Subject}
+			s.jobs[job.ID] = job
+
+			s.run(context.Background(), job.ID, job.Owner, email)
+			got, err := s.Get(alice, job.ID)
+			assert.NoError(t, err)
+			tt.want(t, got)
+		})
+	}
+}
+
+func Test_parseJobService_Parse(t *testing.T) {
+	created := &model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending}
+	done := &model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusDone, Warnings: []string{"partial"}}
+	result := &model.EmailParsingJob{ID: "AJ0", Trip: trip[0], Warnings: []string{"no hotel"}}
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, email).Return(created, nil)
+	parser.On("GetJobStatus", mock.Anything, *created).Return(done, nil)
+	parser.On("GetJobResult", mock.Anything, *done).Return(result, nil)
+	// the trip is not stored
+	repo := &mocks.TripRepository{}
+	s := newParseJobService(parser, repo)
+
+	got, err := s.Parse(context.Background(), email)
+	if assert.NoError(t, err) {
+		assert.Equal(t, trip[0].Reference, got.Trip.Reference)
+		assert.Equal(t, []string{"partial", "no hotel"}, got.Warnings)
+	}
+	repo.AssertNotCalled(t, "Merge", mock.Anything, mock.Anything)
+
+	_, err = s.Parse(context.Background(), &model.Email{Subject: "empty"})
+	assert.True(t, errors.Is(err, domain.ErrorInvalidEmail))
+
+	failing := &mocks.EmailParser{}
+	failing.On("CreateJob", mock.Anything, email).Return(created, nil)
+	failing.On("GetJobStatus", mock.Anything, *created).
+		Return(&model.EmailParsingJob{Status: model.MailParsingStatusError, Detail: "unsupported email"}, nil)
+	_, err = newParseJobService(failing, repo).Parse(context.Background(), email)
+	assert.EqualError(t, err, "unsupported email")
+}
+
+func Test_parseJobService_evict(t *testing.T) {
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, email).Return(nil, errors.New("unauthorized"))
+	s := newParseJobService(parser, &mocks.TripRepository{})
+	old := time.Now().Add(-2 * ParseJobRetention)
+	s.jobs["J0"] = &model.ParseJob{ID: "J0", Owner: "alice", Status: model.MailParsingStatusDone, UpdatedAt: old}
+	s.jobs["J1"] = &model.ParseJob{ID: "J1", Owner: "alice", Status: model.MailParsingStatusPending, UpdatedAt: old}
+	s.jobs["J2"] = &model.ParseJob{ID: "J2", Owner: "alice", Status: model.MailParsingStatusError,
+		UpdatedAt: time.Now()}
+
+	_, err := s.Submit(context.Background(), alice, email)
+	assert.NoError(t, err)
+	_, err = s.Get(alice, "J0")
+	assert.ErrorIs(t, err, domain.ErrorJobNotFound, "job finished before the retention")
+	// running jobs are kept whatever their age
+	for _, id := range []string{"J1", "J2"} {
+		_, err := s.Get(alice, id)
+		assert.NoError(t, err, id)
+	}
+	assert.NoError(t, s.Stop(context.Background()))
+}
+
+func Test_parseJobService_Stop(t *testing.T) {
+	created := &model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending}
+	cause := errors.New("unauthorized")
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, email).Return(created, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.Anything).Return(created, nil)
+	s := newParseJobService(parser, &mocks.TripRepository{})
+	s.pollInterval, s.maxPolls = time.Hour, 1
+
+	job, err := s.Submit(context.Background(), alice, email)
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool {
+		got, _ := s.Get(alice, job.ID)
+		return got.ParserJobID == "AJ0"
+	}, time.Second, time.Millisecond)
+
+	// the running job is cancelled instead of waiting for its next check
+	assert.NoError(t, s.Stop(context.Background()))
+	got, err := s.Get(alice, job.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.MailParsingStatus(model.MailParsingStatusError), got.Status)
+	assert.Contains(t, got.Detail, context.Canceled.Error())
+	_, err = s.Submit(context.Background(), alice, email)
+	assert.ErrorIs(t, err, domain.ErrorStopped)
+
+	// the parser errors are wrapped
+	failing := &mocks.EmailParser{}
+	failing.On("CreateJob", mock.Anything, email).Return(nil, cause)
+	_, err = newParseJobService(failing, &mocks.TripRepository{}).Parse(context.Background(), email)
+	assert.ErrorIs(t, err, cause)
+}
@@ -3,142 +3,592 @@ package usecase
 import (
 	"amadeus-trip-parser/internal/domain"
 	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"fmt"
 	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
 	"time"
 )
 
+// ProcessorConfig sizes the processing pipeline, zero values are replaced by defaults
+type ProcessorConfig struct {
+	// MailInterval is the delay between two mailbox polls
+	MailInterval time.Duration
+	// PollInterval is the delay between two status checks of a parser job
+	PollInterval time.Duration
+	// QueueSize is the capacity of the queue in front of each stage, producers wait when it is full
+	QueueSize int
+	// CreateWorkers, StatusWorkers and ResultWorkers are the number of concurrent parser calls per stage
+	CreateWorkers int
+	StatusWorkers int
+	ResultWorkers int
+	// DisablePoll leaves the mailboxes unpolled, emails are only queued with Enqueue, such as by a backfill
+	DisablePoll bool
+}
+
+func DefaultProcessorConfig() ProcessorConfig {
+	return ProcessorConfig{
+		MailInterval:  10 * time.Minute,
+		PollInterval:  15 * time.Second,
+		QueueSize:     100,
+		CreateWorkers: 4,
+		StatusWorkers: 4,
+		ResultWorkers: 2,
+	}
+}
+
+func (c ProcessorConfig) withDefaults() ProcessorConfig {
+	d := DefaultProcessorConfig()
+	if c.MailInterval <= 0 {
+		c.MailInterval = d.MailInterval
+	}
+	if c.PollInterval <= 0 {
+		c.PollInterval = d.PollInterval
+	}
+	if c.QueueSize <= 0 {
+		c.QueueSize = d.QueueSize
+	}
+	if c.CreateWorkers <= 0 {
+		c.CreateWorkers = d.CreateWorkers
+	}
+	if c.StatusWorkers <= 0 {
+		c.StatusWorkers = d.StatusWorkers
+	}
+	if c.ResultWorkers <= 0 {
+		c.ResultWorkers = d.ResultWorkers
+	}
+	return c
+}
+
+// stages of the pipeline, named in metrics and progress reports
+const (
+	stagePoll   = "poll"
+	stageCreate = "create"
+	stageStatus = "status"
+	stageResult = "result"
+)
+
 type emailProcessor struct {
-	provider       domain.EmailProvider
-	parser         domain.EmailParser
-	repo           domain.TripRepository
-	parserInterval time.Duration
-	mailInterval   time.Duration
-	emails         chan *model.Email
-	toRefresh      chan *model.EmailParsingJob
-	resultReady    chan *model.EmailParsingJob
-	done           chan bool
-}
-
-func NewEmailProcessor(provider domain.EmailProvider, parser domain.EmailParser,
-	repo domain.TripRepository) domain.EmailProcessor {
+	mailboxes   domain.MailboxStore
+	providers   domain.EmailProviderFactory
+	parser      domain.EmailParser
+	repo        domain.TripRepository
+	queue       domain.JobQueue
+	ledger      domain.MessageLedger
+	failures    domain.FailureStore
+	metrics     domain.Metrics
+	config      ProcessorConfig
+	emails      chan *model.QueueItem
+	toRefresh   chan *model.QueueItem
+	resultReady chan *model.QueueItem
+	checks      *checkScheduler
+	progressMu  sync.Mutex
+	progress    map[string]time.Time
+	ctx         context.Context
+	cancel      context.CancelFunc
+	mu          sync.Mutex
+	wg          sync.WaitGroup
+}
+
+// NewEmailProcessor polls every mailbox of the store, the trips of a mailbox belong to its owner
+func NewEmailProcessor(mailboxes domain.MailboxStore, providers domain.EmailProviderFactory, parser domain.EmailParser,
+	repo domain.TripRepository, queue domain.JobQueue, ledger domain.MessageLedger, failures domain.FailureStore,
+	metrics domain.Metrics, config ProcessorConfig) domain.EmailProcessor {
+	config = config.withDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
 	return &emailProcessor{
-		provider,
-		parser,
-		repo,
-		15 * time.Second,
-		10 * time.Minute,
-		make(chan *model.Email),
-		make(chan *model.EmailParsingJob),
-		make(chan *model.EmailParsingJob),
-		make(chan bool),
+		mailboxes:   mailboxes,
+		providers:   providers,
+		parser:      parser,
+		repo:        repo,
+		queue:       queue,
+		ledger:      ledger,
+		failures:    failures,
+		metrics:     metrics,
+		config:      config,
+		emails:      make(chan *model.QueueItem, config.QueueSize),
+		toRefresh:   make(chan *model.QueueItem, config.QueueSize),
+		resultReady: make(chan *model.QueueItem, config.QueueSize),
+		checks:      newCheckScheduler(),
+		progress:    make(map[string]time.Time),
+		ctx:         ctx,
+		cancel:      cancel,
 	}
 }
 
 func (e *emailProcessor) Process() {
-	go e.fetchEmail()
-	go e.createJob()
-	go e.checkJobStatus()
-	go e.getResult()
+	for _, stage := range []string{stagePoll, stageCreate, stageStatus, stageResult} {
+		e.progressed(stage)
+	}
+	// list unfinished work before fetching so that new emails are not resumed twice
+	items := e.unfinished()
+	e.goTracked(func() { e.resume(items) })
+	if !e.config.DisablePoll {
+		e.goTracked(e.fetchEmail)
+	}
+	e.goTracked(func() { e.checks.run(e.ctx, e.toRefresh) })
+	e.pool(stageCreate, e.config.CreateWorkers, e.emails, e.createJob)
+	e.pool(stageStatus, e.config.StatusWorkers, e.toRefresh, e.checkJobStatus)
+	e.pool(stageResult, e.config.ResultWorkers, e.resultReady, e.getResult)
 }
 
-func (e *emailProcessor) Stop() {
-	e.done <- true
+func (e *emailProcessor) Stop(ctx context.Context) error {
+	e.mu.Lock()
+	e.cancel()
+	e.mu.Unlock()
+	stopped := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		log.Debug().Msg("email processor stopped")
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("email processor did not stop in time: %w", ctx.Err())
+	}
 }
 
-func (e *emailProcessor) fetchEmail() {
-	for {
-		//TODO allow mail filter configuration
-		emails := e.provider.GetEmails("is:unread")
-		select {
-		case <-e.done:
-			close(e.emails)
-			return
-		default:
-			for _, em := range emails {
-				e.emails <- em
-			}
+func (e *emailProcessor) Reprocess(messageID string) error {
+	item, err := e.queue.GetByEmailID(messageID)
+	if errors.Is(err, domain.ErrorItemNotFound) {
+		// content is unknown, the message will be processed again by the next poll if still selected
+		if err := e.ledger.Delete(messageID); err != nil {
+			return fmt.Errorf("cannot forget message %s: %w", messageID, err)
 		}
-		time.Sleep(e.mailInterval)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("cannot find message %s: %w", messageID, err)
+	}
+	if !item.Finished() {
+		return fmt.Errorf("%w: %s", domain.ErrorAlreadyQueued, messageID)
+	}
+	if err := e.ledger.Delete(messageID); err != nil && !errors.Is(err, domain.ErrorNotProcessed) {
+		return fmt.Errorf("cannot forget message %s: %w", messageID, err)
+	}
+	retry := &model.QueueItem{
+		EmailID:   item.EmailID,
+		MailboxID: item.MailboxID,
+		Owner:     item.Owner,
+		Subject:   item.Subject,
+		Date:      item.Date,
+		Size:      item.Size,
+		Content:   item.Content,
+	}
+	if err := e.enqueue(retry, trace.LinkFromContext(continueTrace(e.ctx, item.TraceParent))); err != nil {
+		return fmt.Errorf("cannot queue message %s again: %w", messageID, err)
 	}
+	log.Debug().Msgf("message %s queued again for processing", messageID)
+	e.goTracked(func() { e.send(e.emails, retry) })
+	return nil
 }
 
-func (e *emailProcessor) createJob() {
-	for {
-		select {
-		case email := <-e.emails:
-			job, err := e.parser.CreateJob(email)
-			if err != nil {
-				log.Debug().Msgf("error when creating job for %v: %v", email, err)
-				continue
+// Progress reports the stages as of the last item they handled, a stage progresses on start too.
+// The mailboxes are polled without input, every mail interval.
+func (e *emailProcessor) Progress() []model.StageProgress {
+	e.progressMu.Lock()
+	defer e.progressMu.Unlock()
+	return []model.StageProgress{
+		{Stage: stagePoll, LastProgress: e.progress[stagePoll], Interval: e.config.MailInterval},
+		{Stage: stageCreate, LastProgress: e.progress[stageCreate], Pending: len(e.emails)},
+		{Stage: stageStatus, LastProgress: e.progress[stageStatus], Pending: len(e.toRefresh)},
+		{Stage: stageResult, LastProgress: e.progress[stageResult], Pending: len(e.resultReady)},
+	}
+}
+
+func (e *emailProcessor) progressed(stage string) {
+	e.progressMu.Lock()
+	e.progress[stage] = time.Now()
+	e.progressMu.Unlock()
+}
+
+// pool starts n workers handling the items of ch until the processor is stopped
+func (e *emailProcessor) pool(stage string, n int, ch <-chan *model.QueueItem, handle func(item *model.QueueItem)) {
+	for i := 0; i < n; i++ {
+		e.goTracked(func() {
+			for {
+				select {
+				case item := <-ch:
+					e.reportDepths()
+					handle(item)
+					e.progressed(stage)
+				case <-e.ctx.Done():
+					return
+				}
 			}
-			log.Debug().Msgf("job created %v", job)
-			e.toRefresh <- job
-		case <-e.done:
-			close(e.toRefresh)
+		})
+	}
+}
+
+// goTracked runs f in a goroutine awaited by Stop, nothing is started once stopped
+func (e *emailProcessor) goTracked(f func()) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.ctx.Err() != nil {
+		return
+	}
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		f()
+	}()
+}
+
+// send hands an item to the next stage, it returns false when the processor is stopped first
+func (e *emailProcessor) send(ch chan<- *model.QueueItem, item *model.QueueItem) bool {
+	select {
+	case ch <- item:
+		e.reportDepths()
+		return true
+	case <-e.ctx.Done():
+		return false
+	}
+}
+
+// reportDepths measures the number of items waiting in front of each stage
+func (e *emailProcessor) reportDepths() {
+	e.metrics.QueueDepth(stageCreate, len(e.emails))
+	e.metrics.QueueDepth(stageStatus, len(e.toRefresh))
+	e.metrics.QueueDepth(stageResult, len(e.resultReady))
+	e.metrics.QueueDepth("scheduled", e.checks.len())
+}
+
+// sleep waits for d, it returns false when the processor is stopped first
+func (e *emailProcessor) sleep(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-e.ctx.Done():
+		return false
+	}
+}
+
+// processed tells whether the email was already submitted to the parser with the same content
+func (e *emailProcessor) processed(emailID string, content string) bool {
+	msg, err := e.ledger.Get(emailID)
+	if err != nil {
+		if !errors.Is(err, domain.ErrorNotProcessed) {
+			e.metrics.RepositoryError("ledger")
+			log.Error().Msgf("cannot check whether email %s was processed: %v", emailID, err)
+		}
+		return false
+	}
+	return msg.ContentHash == model.ContentHash(content)
+}
+
+func (e *emailProcessor) record(item *model.QueueItem) {
+	msg := &model.ProcessedMessage{
+		MessageID:   item.EmailID,
+		ContentHash: model.ContentHash(item.Content),
+		Outcome:     item.State,
+		JobID:       item.JobID,
+		TripID:      item.TripID,
+		Detail:      item.Detail,
+	}
+	if err := e.ledger.Record(msg); err != nil {
+		e.metrics.RepositoryError("ledger")
+		log.Error().Msgf("cannot record outcome of email %s: %v", item.EmailID, err)
+	}
+}
+
+func (e *emailProcessor) unfinished() []model.QueueItem {
+	items, err := e.queue.ListByState(model.ProcessingStateFetched,
+		model.ProcessingStateSubmitted, model.ProcessingStatePending)
+	if err != nil {
+		e.metrics.RepositoryError("queue")
+		log.Error().Msgf("cannot resume unfinished work: %v", err)
+		return nil
+	}
+	return items
+}
+
+// resume feeds the pipeline with the work left unfinished by a previous run
+func (e *emailProcessor) resume(items []model.QueueItem) {
+	log.Debug().Msgf("resuming %d unfinished emails", len(items))
+	for i := range items {
+		item := &items[i]
+		if item.State != model.ProcessingStateFetched {
+			// submitted and pending jobs are checked again at their saved check time
+			e.checks.schedule(item)
+			continue
+		}
+		if !e.send(e.emails, item) {
 			return
 		}
-		time.Sleep(e.parserInterval)
 	}
 }
 
-func (e *emailProcessor) checkJobStatus() {
+func (e *emailProcessor) fetchEmail() {
 	for {
-		select {
-		case job := <-e.toRefresh:
-			refreshedJob, err := e.parser.GetJobStatus(*job)
-			if err != nil {
-				log.Debug().Msgf("error when refreshing job %s: %v", job, err)
-				continue
-			}
-			switch refreshedJob.Status {
-			case model.MailParsingStatusPending:
-				log.Debug().Msgf("job %s is still pending", refreshedJob.ID)
-				go func() {
-					time.Sleep(e.parserInterval)
-					select {
-					case <-e.done:
-						return
-					default:
-						e.toRefresh <- refreshedJob
-					}
-				}()
-			case model.MailParsingStatusError:
-				log.Debug().Msgf("job %s is in error: %s", refreshedJob.ID, refreshedJob.Detail)
-			case model.MailParsingStatusDone:
-				log.Debug().Msgf("job %s is done", refreshedJob.ID)
-				e.resultReady <- refreshedJob
-			default:
-				log.Debug().Msgf("job %s has unknown parsing status %s", refreshedJob.ID, refreshedJob.Status)
-			}
-		case <-e.done:
-			close(e.resultReady)
+		if !e.poll() || !e.sleep(e.config.MailInterval) {
 			return
 		}
-		time.Sleep(e.parserInterval)
 	}
 }
 
-func (e *emailProcessor) getResult() {
-	for {
-		select {
-		case job := <-e.resultReady:
-			jobWithResult, err := e.parser.GetJobResult(*job)
-			if err != nil {
-				log.Debug().Msgf("failed to retrieve result for job %s : %v", job.ID, err)
-				continue
+// poll queues the new emails of every mailbox, it returns false when the processor is stopped first
+func (e *emailProcessor) poll() bool {
+	mailboxes, err := e.mailboxes.List("")
+	if err != nil {
+		e.metrics.RepositoryError("mailboxes")
+		log.Error().Msgf("cannot list mailboxes to poll: %v", err)
+		return true
+	}
+	for _, mailbox := range mailboxes {
+		if !e.pollMailbox(mailbox) {
+			return false
+		}
+	}
+	e.progressed(stagePoll)
+	return true
+}
+
+// pollMailbox queues the new emails of a mailbox, it returns false when the processor is stopped first
+func (e *emailProcessor) pollMailbox(mailbox model.Mailbox) bool {
+	ctx, span := tracer.Start(e.ctx, "processor.poll", trace.WithAttributes(
+		attribute.String("mailbox.id", mailbox.ID),
+		attribute.String("mailbox.owner", mailbox.Owner)))
+	defer span.End()
+	provider, err := e.providers.Provider(mailbox)
+	if err != nil {
+		span.RecordError(err)
+		log.Error().Msgf("cannot poll mailbox %s of %s: %v", mailbox.ID, mailbox.Owner, err)
+		return true
+	}
+	//TODO allow mail filter configuration
+	emails, err := provider.GetEmails(ctx, "is:unread")
+	if err != nil {
+		// the emails fetched are queued, the others are fetched on next poll
+		span.RecordError(err)
+		e.fetchFailed(mailbox, err)
+	}
+	_, err = e.Enqueue(ctx, mailbox, emails)
+	return err == nil
+}
+
+// Enqueue queues the emails not processed yet, the trace of each one is linked to the one of ctx
+func (e *emailProcessor) Enqueue(ctx context.Context, mailbox model.Mailbox, emails []*model.Email) (int, error) {
+	queued := 0
+	for _, em := range emails {
+		if e.processed(em.ID, em.Content) {
+			continue
+		}
+		item := &model.QueueItem{
+			EmailID:   em.ID,
+			MailboxID: mailbox.ID,
+			Owner:     mailbox.Owner,
+			Subject:   em.Subject,
+			Date:      em.Date,
+			Size:      em.Size,
+			Content:   em.Content,
+		}
+		if err := e.enqueue(item, trace.LinkFromContext(ctx)); err != nil {
+			if !errors.Is(err, domain.ErrorAlreadyQueued) {
+				e.metrics.RepositoryError("queue")
+				log.Error().Msgf("cannot queue email %s: %v", em.ID, err)
 			}
-			e.storeTrip(jobWithResult.Trip)
-		case <-e.done:
-			return
+			continue
+		}
+		// the email is saved as fetched, it is resumed on next start if the processor stops first
+		if !e.send(e.emails, item) {
+			return queued, domain.ErrorStopped
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// fetchFailed reports the emails of a mailbox that could not be fetched. Each message failing is recorded as a
+// failure, it stays unread and unprocessed so that it is retried by next poll.
+func (e *emailProcessor) fetchFailed(mailbox model.Mailbox, err error) {
+	var fetchErr *domain.FetchError
+	if !errors.As(err, &fetchErr) {
+		fetchErr = &domain.FetchError{Err: err}
+	}
+	for _, m := range fetchErr.Messages {
+		log.Warn().Msgf("cannot fetch email %s of mailbox %s: %v", m.MessageID, mailbox.ID, m.Err)
+		e.metrics.JobFailed(model.FailureStageFetch)
+		f := &model.Failure{EmailID: m.MessageID, Stage: model.FailureStageFetch, Detail: m.Err.Error()}
+		if err := e.failures.Record(f); err != nil {
+			e.metrics.RepositoryError("failures")
+			log.Error().Msgf("cannot record failure of email %s: %v", m.MessageID, err)
 		}
-		time.Sleep(e.parserInterval)
+	}
+	switch {
+	case fetchErr.Err == nil:
+	case errors.Is(fetchErr.Err, domain.ErrorMailboxRevoked):
+		log.Error().Msgf("authorization of mailbox %s of %s is revoked, its owner must grant access again: %v",
+			mailbox.ID, mailbox.Owner, fetchErr.Err)
+	default:
+		log.Error().Msgf("cannot poll mailbox %s of %s: %v", mailbox.ID, mailbox.Owner, fetchErr.Err)
+	}
+}
+
+// enqueue saves an email in the queue and starts its trace, which is linked to the one queuing it
+func (e *emailProcessor) enqueue(item *model.QueueItem, links ...trace.Link) error {
+	ctx, span := tracer.Start(e.ctx, "processor.enqueue", trace.WithNewRoot(),
+		trace.WithLinks(links...), trace.WithAttributes(attribute.String("email.id", item.EmailID)))
+	defer span.End()
+	item.TraceParent = traceParent(ctx)
+	if err := e.queue.Enqueue(item); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	return nil
+}
+
+// startStage starts the span of a pipeline stage in the trace of the email, its context is cancelled by Stop
+func (e *emailProcessor) startStage(item *model.QueueItem, name string) (context.Context, trace.Span) {
+	return tracer.Start(continueTrace(e.ctx, item.TraceParent), name, trace.WithAttributes(
+		attribute.String("email.id", item.EmailID),
+		attribute.String("job.id", item.JobID)))
+}
+
+func (e *emailProcessor) createJob(item *model.QueueItem) {
+	ctx, span := e.startStage(item, "processor.create_job")
+	defer span.End()
+	if e.processed(item.EmailID, item.Content) {
+		log.Debug().Msgf("email %s was already processed, skipping", item.EmailID)
+		e.setState(item, model.ProcessingStateDone, "already processed")
+		return
+	}
+	job, err := e.parser.CreateJob(ctx, item.Email())
+	if err != nil && e.ctx.Err() != nil {
+		// cancelled by Stop, the email stays fetched and is submitted on next start
+		return
+	}
+	if err != nil {
+		e.fail(ctx, item, model.FailureStageCreate, fmt.Sprintf("cannot create parser job: %v", err), nil)
+		return
+	}
+	log.Debug().Msgf("job created %v", job)
+	e.metrics.JobCreated()
+	item.JobID = job.ID
+	e.scheduleCheck(item, model.ProcessingStateSubmitted)
+}
+
+func (e *emailProcessor) checkJobStatus(item *model.QueueItem) {
+	ctx, span := e.startStage(item, "processor.check_job_status")
+	defer span.End()
+	refreshedJob, err := e.parser.GetJobStatus(ctx, item.Job())
+	if err != nil {
+		span.RecordError(err)
+		log.Debug().Msgf("error when refreshing job %s: %v", item.JobID, err)
+		e.scheduleCheck(item, item.State)
+		return
+	}
+	span.SetAttributes(attribute.String("job.status", string(refreshedJob.Status)))
+	switch refreshedJob.Status {
+	case model.MailParsingStatusPending:
+		log.Debug().Msgf("job %s is still pending", refreshedJob.ID)
+		e.scheduleCheck(item, model.ProcessingStatePending)
+	case model.MailParsingStatusError:
+		e.fail(ctx, item, model.FailureStageParse, refreshedJob.Detail, refreshedJob.Warnings)
+	case model.MailParsingStatusDone:
+		log.Debug().Msgf("job %s is done", refreshedJob.ID)
+		e.send(e.resultReady, item)
+	default:
+		// the job would never be checked again
+		e.fail(ctx, item, model.FailureStageParse,
+			fmt.Sprintf("parser job %s has unknown status %s", item.JobID, refreshedJob.Status), refreshedJob.Warnings)
+	}
+}
+
+func (e *emailProcessor) getResult(item *model.QueueItem) {
+	ctx, span := e.startStage(item, "processor.get_result")
+	defer span.End()
+	jobWithResult, err := e.parser.GetJobResult(ctx, item.Job())
+	if err != nil {
+		span.RecordError(err)
+		log.Debug().Msgf("failed to retrieve result for job %s : %v", item.JobID, err)
+		// the job stays done on the parser side, its status is checked again to retry
+		e.scheduleCheck(item, item.State)
+		return
+	}
+	trip := jobWithResult.Trip
+	err = e.storeTrip(ctx, item.Owner, &trip)
+	if err != nil && e.ctx.Err() != nil {
+		// cancelled by Stop, the result is retrieved again on next start
+		return
+	}
+	if err != nil {
+		e.fail(ctx, item, model.FailureStageStore, fmt.Sprintf("cannot store trip: %v", err), jobWithResult.Warnings)
+		return
+	}
+	item.TripID = trip.ID
+	e.finish(item, model.ProcessingStateDone, "")
+	e.metrics.JobCompleted()
+	if !item.CreatedAt.IsZero() {
+		e.metrics.TripStored(time.Since(item.CreatedAt))
+	}
+	// a replayed email is not a failure anymore
+	if err := e.failures.DeleteByEmailID(item.EmailID); err != nil {
+		e.metrics.RepositoryError("failures")
+		log.Error().Msgf("cannot clear failure of email %s: %v", item.EmailID, err)
+	}
+}
+
+// scheduleCheck saves the item with its next check time and hands it to the scheduler
+func (e *emailProcessor) scheduleCheck(item *model.QueueItem, state model.ProcessingState) {
+	item.NextCheckAt = time.Now().Add(e.config.PollInterval)
+	e.setState(item, state, "")
+	e.checks.schedule(item)
+	e.reportDepths()
+}
+
+func (e *emailProcessor) setState(item *model.QueueItem, state model.ProcessingState, detail string) {
+	item.State = state
+	item.Detail = detail
+	if err := e.queue.Update(item); err != nil {
+		e.metrics.RepositoryError("queue")
+		log.Error().Msgf("cannot save state %s of queued email %s: %v", state, item.EmailID, err)
+	}
+}
+
+// finish stores the final state of an email submitted to the parser and records it in the ledger
+func (e *emailProcessor) finish(item *model.QueueItem, state model.ProcessingState, detail string) {
+	e.setState(item, state, detail)
+	e.record(item)
+}
+
+// fail finishes the item as failed and keeps it as a dead letter to be inspected and replayed
+func (e *emailProcessor) fail(ctx context.Context, item *model.QueueItem, stage model.FailureStage, detail string,
+	warnings []string) {
+	log.Warn().Msgf("processing of email %s failed at %s stage: %s", item.EmailID, stage, detail)
+	spanError(ctx, detail)
+	e.metrics.JobFailed(stage)
+	e.finish(item, model.ProcessingStateFailed, detail)
+	f := &model.Failure{
+		EmailID:  item.EmailID,
+		Subject:  item.Subject,
+		Date:     item.Date,
+		Stage:    stage,
+		JobID:    item.JobID,
+		Detail:   detail,
+		Warnings: warnings,
+	}
+	if err := e.failures.Record(f); err != nil {
+		e.metrics.RepositoryError("failures")
+		log.Error().Msgf("cannot record failure of email %s: %v", item.EmailID, err)
 	}
 }
 
-func (e *emailProcessor) storeTrip(trip model.Trip) {
-	if err := e.repo.Create(&trip); err != nil {
+// storeTrip merges the trip into the trip of owner with the same reference, so that an email processed again, when
+// reprocessed, retried or changed, updates its trip rather than adding another one. Its ID becomes the stored one.
+func (e *emailProcessor) storeTrip(ctx context.Context, owner string, trip *model.Trip) error {
+	trip.Owner = owner
+	if _, err := e.repo.Merge(ctx, trip); err != nil {
+		e.metrics.RepositoryError("trips")
 		log.Debug().Msgf("failed to store trip %v: %v", trip, err)
-	} else {
-		log.Debug().Msgf("trip %s (ref: %s) written in repository", trip.ID, trip.Reference)
+		return err
 	}
+	log.Debug().Msgf("trip %s (ref: %s) written in repository", trip.ID, trip.Reference)
+	return nil
 }
@@ -0,0 +1,748 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/mocks"
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memoryQueue is a JobQueue keeping items in memory
+type memoryQueue struct {
+	mu    sync.Mutex
+	items map[string]model.QueueItem
+}
+
+func newMemoryQueue(items ...model.QueueItem) *memoryQueue {
+	q := &memoryQueue{items: make(map[string]model.QueueItem)}
+	for _, i := range items {
+		q.items[i.ID] = i
+	}
+	return q
+}
+
+func (q *memoryQueue) Enqueue(item *model.QueueItem) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, i := range q.items {
+		if i.EmailID == item.EmailID && !i.Finished() {
+			return domain.ErrorAlreadyQueued
+		}
+	}
+	if item.ID == "" {
+		item.ID = fmt.Sprintf("%s-%d", item.EmailID, len(q.items))
+	}
+	if item.State == "" {
+		item.State = model.ProcessingStateFetched
+	}
+	q.items[item.ID] = *item
+	return nil
+}
+
+func (q *memoryQueue) Update(item *model.QueueItem) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items[item.ID] = *item
+	return nil
+}
+
+func (q *memoryQueue) Get(id string) (model.QueueItem, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	i, ok := q.items[id]
+	if !ok {
+		return model.QueueItem{}, domain.ErrorItemNotFound
+	}
+	return i, nil
+}
+
+func (q *memoryQueue) GetByEmailID(emailID string) (model.QueueItem, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var latest *model.QueueItem
+	for _, i := range q.items {
+		if i.EmailID == emailID && (latest == nil || !i.Finished()) {
+			i := i
+			latest = &i
+		}
+	}
+	if latest == nil {
+		return model.QueueItem{}, domain.ErrorItemNotFound
+	}
+	return *latest, nil
+}
+
+func (q *memoryQueue) ListByState(states ...model.ProcessingState) ([]model.QueueItem, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var items []model.QueueItem
+	for _, i := range q.items {
+		for _, s := range states {
+			if i.State == s {
+				items = append(items, i)
+			}
+		}
+	}
+	return items, nil
+}
+
+func (q *memoryQueue) state(emailID string) model.ProcessingState {
+	i, _ := q.GetByEmailID(emailID)
+	return i.State
+}
+
+// memoryLedger is a MessageLedger keeping messages in memory
+type memoryLedger struct {
+	mu       sync.Mutex
+	messages map[string]model.ProcessedMessage
+}
+
+func newMemoryLedger(messages ...model.ProcessedMessage) *memoryLedger {
+	l := &memoryLedger{messages: make(map[string]model.ProcessedMessage)}
+	for _, m := range messages {
+		l.messages[m.MessageID] = m
+	}
+	return l
+}
+
+func (l *memoryLedger) Get(messageID string) (model.ProcessedMessage, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	m, ok := l.messages[messageID]
+	if !ok {
+		return model.ProcessedMessage{}, domain.ErrorNotProcessed
+	}
+	return m, nil
+}
+
+func (l *memoryLedger) Record(msg *model.ProcessedMessage) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.messages[msg.MessageID] = *msg
+	return nil
+}
+
+func (l *memoryLedger) Delete(messageID string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.messages[messageID]; !ok {
+		return domain.ErrorNotProcessed
+	}
+	delete(l.messages, messageID)
+	return nil
+}
+
+// memoryFailures is a FailureStore keeping failures in memory, by email
+type memoryFailures struct {
+	mu       sync.Mutex
+	failures map[string]model.Failure
+}
+
+func newMemoryFailures(failures ...model.Failure) *memoryFailures {
+	s := &memoryFailures{failures: make(map[string]model.Failure)}
+	for _, f := range failures {
+		s.failures[f.EmailID] = f
+	}
+	return s
+}
+
+func (s *memoryFailures) Record(f *model.Failure) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f.ID = f.EmailID
+	f.Attempts = s.failures[f.EmailID].Attempts + 1
+	s.failures[f.EmailID] = *f
+	return nil
+}
+
+func (s *memoryFailures) Get(id string) (model.Failure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.failures {
+		if f.ID == id {
+			return f, nil
+		}
+	}
+	return model.Failure{}, domain.ErrorNoFailure
+}
+
+func (s *memoryFailures) List() ([]model.Failure, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var failures []model.Failure
+	for _, f := range s.failures {
+		failures = append(failures, f)
+	}
+	return failures, nil
+}
+
+func (s *memoryFailures) Delete(id string) error {
+	f, err := s.Get(id)
+	if err != nil {
+		return err
+	}
+	return s.DeleteByEmailID(f.EmailID)
+}
+
+func (s *memoryFailures) DeleteByEmailID(emailID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, emailID)
+	return nil
+}
+
+func (s *memoryFailures) get(emailID string) (model.Failure, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.failures[emailID]
+	return f, ok
+}
+
+// failureMetrics counts the failed jobs by stage
+type failureMetrics struct {
+	domain.NopMetrics
+	mu     sync.Mutex
+	failed map[model.FailureStage]int
+}
+
+func (m *failureMetrics) JobFailed(stage model.FailureStage) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.failed[stage]++
+}
+
+// testMailboxes serves the providers of its mailboxes by mailbox ID, a mailbox without provider cannot be polled
+type testMailboxes struct {
+	mailboxes []model.Mailbox
+	providers map[string]domain.EmailProvider
+}
+
+// aliceMailbox is a single mailbox of alice served by provider
+func aliceMailbox(provider domain.EmailProvider) *testMailboxes {
+	return &testMailboxes{
+		mailboxes: []model.Mailbox{{ID: "M0", Owner: "alice", Provider: model.MailboxProviderGmail}},
+		providers: map[string]domain.EmailProvider{"M0": provider},
+	}
+}
+
+func (m *testMailboxes) Save(*model.Mailbox) error        { return nil }
+func (m *testMailboxes) UpdateToken(string, string) error { return nil }
+func (m *testMailboxes) Get(string) (model.Mailbox, error) {
+	return model.Mailbox{}, domain.ErrorNoMailbox
+}
+func (m *testMailboxes) List(string) ([]model.Mailbox, error) { return m.mailboxes, nil }
+func (m *testMailboxes) Delete(string) error                  { return nil }
+func (m *testMailboxes) DeleteByOwner(string) error           { return nil }
+
+func (m *testMailboxes) Provider(mailbox model.Mailbox) (domain.EmailProvider, error) {
+	if p, ok := m.providers[mailbox.ID]; ok {
+		return p, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func newTestProcessor(provider domain.EmailProvider, parser domain.EmailParser, repo domain.TripRepository,
+	queue domain.JobQueue, ledger domain.MessageLedger, failures domain.FailureStore) *emailProcessor {
+	mailboxes := aliceMailbox(provider)
+	return NewEmailProcessor(mailboxes, mailboxes, parser, repo, queue, ledger, failures, domain.NopMetrics{},
+		ProcessorConfig{
+			MailInterval: time.Hour,
+			PollInterval: time.Millisecond,
+		}).(*emailProcessor)
+}
+
+func Test_emailProcessor_Process(t *testing.T) {
+	resumed := model.QueueItem{ID: "MSG9", EmailID: "MSG9", State: model.ProcessingStatePending, JobID: "AJ9"}
+	queue := newMemoryQueue(resumed)
+	known := &model.Email{ID: "MSG8", Content: "known"}
+	ledger := newMemoryLedger(model.ProcessedMessage{
+		MessageID:   known.ID,
+		ContentHash: model.ContentHash(known.Content),
+		Outcome:     model.ProcessingStateDone,
+	})
+
+	provider := &mocks.EmailProvider{}
+	provider.On("GetEmails", mock.Anything, mock.Anything).Return([]*model.Email{email, known}, nil)
+
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, email).Return(&model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending}, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{Status: model.MailParsingStatusDone}, nil)
+	parser.On("GetJobResult", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{Trip: trip[0]}, nil)
+
+	repo := &mocks.TripRepository{}
+	repo.On("Merge", mock.Anything, mock.Anything).Return(true, nil)
+
+	// a previous failure of the email is cleared once it is processed
+	failures := newMemoryFailures(model.Failure{ID: "F0", EmailID: email.ID, Attempts: 1})
+
+	p := newTestProcessor(provider, parser, repo, queue, ledger, failures)
+	p.Process()
+
+	assert.Eventually(t, func() bool {
+		return queue.state("MSG9") == model.ProcessingStateDone && queue.state(email.ID) == model.ProcessingStateDone
+	}, time.Second, 5*time.Millisecond)
+	_, failed := failures.get(email.ID)
+	assert.False(t, failed)
+
+	item, _ := queue.GetByEmailID(email.ID)
+	assert.Equal(t, "AJ0", item.JobID)
+	assert.Equal(t, trip[0].ID, item.TripID)
+	parser.AssertCalled(t, "GetJobStatus", mock.Anything, resumed.Job())
+	parser.AssertNumberOfCalls(t, "CreateJob", 1)
+	_, err := queue.GetByEmailID(known.ID)
+	assert.Equal(t, domain.ErrorItemNotFound, err)
+
+	recorded, err := ledger.Get(email.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, model.ProcessingState(model.ProcessingStateDone), recorded.Outcome)
+	assert.Equal(t, "AJ0", recorded.JobID)
+	assert.Equal(t, trip[0].ID, recorded.TripID)
+	assert.Equal(t, model.ContentHash(email.Content), recorded.ContentHash)
+}
+
+func Test_emailProcessor_Process_partialEmails(t *testing.T) {
+	// the emails fetched are processed, the others are failures until fetched by a next poll
+	provider := &mocks.EmailProvider{}
+	provider.On("GetEmails", mock.Anything, mock.Anything).Return([]*model.Email{email}, &domain.FetchError{
+		Messages: []*domain.MessageError{{MessageID: "MSG7", Err: errors.New("unable to retrieve message")}},
+		Err:      fmt.Errorf("unable to retrieve messages: %w", domain.ErrorMailboxRevoked),
+	})
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, email).Return(&model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending}, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{Status: model.MailParsingStatusDone}, nil)
+	parser.On("GetJobResult", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{Trip: trip[0]}, nil)
+	repo := &mocks.TripRepository{}
+	repo.On("Merge", mock.Anything, mock.Anything).Return(true, nil)
+
+	queue := newMemoryQueue()
+	failures := newMemoryFailures()
+	p := newTestProcessor(provider, parser, repo, queue, newMemoryLedger(), failures)
+	p.Process()
+	assert.Eventually(t, func() bool {
+		return queue.state(email.ID) == model.ProcessingStateDone
+	}, time.Second, 5*time.Millisecond)
+	assert.NoError(t, p.Stop(context.Background()))
+
+	f, failed := failures.get("MSG7")
+	if assert.True(t, failed) {
+		assert.Equal(t, model.FailureStage(model.FailureStageFetch), f.Stage)
+		assert.Equal(t, 1, f.Attempts)
+	}
+	_, err := queue.GetByEmailID("MSG7")
+	assert.Equal(t, domain.ErrorItemNotFound, err)
+}
+
+var testTracerProvider = sdktrace.NewTracerProvider()
+
+func init() {
+	// the global provider delegates to the first one set only
+	otel.SetTracerProvider(testTracerProvider)
+}
+
+// recordSpans records the spans ended until the end of the test
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	testTracerProvider.RegisterSpanProcessor(recorder)
+	t.Cleanup(func() { testTracerProvider.UnregisterSpanProcessor(recorder) })
+	return recorder
+}
+
+func Test_emailProcessor_Process_trace(t *testing.T) {
+	recorder := recordSpans(t)
+
+	provider := &mocks.EmailProvider{}
+	provider.On("GetEmails", mock.Anything, mock.Anything).Return([]*model.Email{email}, nil)
+	// backends are called within the trace of the email
+	var traceIDs sync.Map
+	traced := func(name string) func(mock.Arguments) {
+		return func(args mock.Arguments) {
+			traceIDs.Store(name, trace.SpanContextFromContext(args.Get(0).(context.Context)).TraceID())
+		}
+	}
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, email).Run(traced("CreateJob")).
+		Return(&model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending}, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.Anything).Run(traced("GetJobStatus")).
+		Return(&model.EmailParsingJob{Status: model.MailParsingStatusDone}, nil)
+	parser.On("GetJobResult", mock.Anything, mock.Anything).Run(traced("GetJobResult")).
+		Return(&model.EmailParsingJob{Trip: trip[0]}, nil)
+	repo := &mocks.TripRepository{}
+	// trips of the mailbox belong to its owner
+	repo.On("Merge", mock.Anything, mock.MatchedBy(func(t *model.Trip) bool { return t.Owner == "alice" })).
+		Run(traced("Merge")).Return(true, nil)
+
+	queue := newMemoryQueue()
+	p := newTestProcessor(provider, parser, repo, queue, newMemoryLedger(), newMemoryFailures())
+	p.Process()
+	assert.Eventually(t, func() bool {
+		return queue.state(email.ID) == model.ProcessingStateDone
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, p.Stop(context.Background()))
+
+	item, _ := queue.GetByEmailID(email.ID)
+	emailTrace := trace.SpanContextFromContext(continueTrace(context.Background(), item.TraceParent)).TraceID()
+	assert.True(t, emailTrace.IsValid())
+	for _, name := range []string{"CreateJob", "GetJobStatus", "GetJobResult", "Merge"} {
+		id, _ := traceIDs.Load(name)
+		assert.Equal(t, emailTrace, id, name)
+	}
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range recorder.Ended() {
+		spans[s.Name()] = s
+	}
+	for _, name := range []string{"processor.enqueue", "processor.create_job", "processor.check_job_status",
+		"processor.get_result"} {
+		if assert.Contains(t, spans, name) {
+			assert.Equal(t, emailTrace, spans[name].SpanContext().TraceID(), name)
+		}
+	}
+	// the email trace is linked to the mailbox poll
+	if assert.Contains(t, spans, "processor.poll") && assert.Len(t, spans["processor.enqueue"].Links(), 1) {
+		assert.Equal(t, spans["processor.poll"].SpanContext(), spans["processor.enqueue"].Links()[0].SpanContext)
+	}
+}
+
+func Test_emailProcessor_Process_failures(t *testing.T) {
+	rejected := &model.Email{ID: "MSG0", Subject: "Rejected", Content: "0"}
+	unparsed := &model.Email{ID: "MSG1", Subject: "Unparsed", Content: "1"}
+	unstored := &model.Email{ID: "MSG2", Subject: "Unstored", Content: "2"}
+	unknown := &model.Email{ID: "MSG3", Subject: "Unknown", Content: "3"}
+	provider := &mocks.EmailProvider{}
+	provider.On("GetEmails", mock.Anything, mock.Anything).
+		Return([]*model.Email{rejected, unparsed, unstored, unknown}, nil)
+
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, rejected).Return(nil, errors.New("unauthorized"))
+	parser.On("CreateJob", mock.Anything, unparsed).Return(&model.EmailParsingJob{ID: "AJ1", Status: model.MailParsingStatusPending}, nil)
+	parser.On("CreateJob", mock.Anything, unstored).Return(&model.EmailParsingJob{ID: "AJ2", Status: model.MailParsingStatusPending}, nil)
+	parser.On("CreateJob", mock.Anything, unknown).Return(&model.EmailParsingJob{ID: "AJ3", Status: model.MailParsingStatusPending}, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.MatchedBy(func(j model.EmailParsingJob) bool { return j.ID == "AJ1" })).
+		Return(&model.EmailParsingJob{ID: "AJ1", Status: model.MailParsingStatusError, Detail: "no trip found",
+			Warnings: []string{"unknown carrier"}}, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.MatchedBy(func(j model.EmailParsingJob) bool { return j.ID == "AJ2" })).
+		Return(&model.EmailParsingJob{ID: "AJ2", Status: model.MailParsingStatusDone}, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.MatchedBy(func(j model.EmailParsingJob) bool { return j.ID == "AJ3" })).
+		Return(&model.EmailParsingJob{ID: "AJ3", Status: "EXPIRED"}, nil)
+	parser.On("GetJobResult", mock.Anything, mock.Anything).
+		Return(&model.EmailParsingJob{ID: "AJ2", Trip: trip[0], Warnings: []string{"missing date"}}, nil)
+
+	repo := &mocks.TripRepository{}
+	repo.On("Merge", mock.Anything, mock.Anything).Return(false, errors.New("disk full"))
+
+	failures := newMemoryFailures(model.Failure{ID: "F0", EmailID: rejected.ID, Attempts: 1})
+	queue := newMemoryQueue()
+	metrics := &failureMetrics{failed: make(map[model.FailureStage]int)}
+	p := newTestProcessor(provider, parser, repo, queue, newMemoryLedger(), failures)
+	p.metrics = metrics
+	p.Process()
+	defer p.Stop(context.Background())
+
+	assert.Eventually(t, func() bool {
+		return queue.state(rejected.ID) == model.ProcessingStateFailed &&
+			queue.state(unparsed.ID) == model.ProcessingStateFailed &&
+			queue.state(unstored.ID) == model.ProcessingStateFailed &&
+			queue.state(unknown.ID) == model.ProcessingStateFailed
+	}, time.Second, 10*time.Millisecond)
+
+	tests := []struct {
+		email    *model.Email
+		stage    model.FailureStage
+		jobID    string
+		detail   string
+		warnings model.Warnings
+		attempts int
+	}{
+		{rejected, model.FailureStageCreate, "", "cannot create parser job: unauthorized", nil, 2},
+		{unparsed, model.FailureStageParse, "AJ1", "no trip found", model.Warnings{"unknown carrier"}, 1},
+		{unstored, model.FailureStageStore, "AJ2", "cannot store trip: disk full", model.Warnings{"missing date"}, 1},
+		{unknown, model.FailureStageParse, "AJ3", "parser job AJ3 has unknown status EXPIRED", nil, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.email.Subject, func(t *testing.T) {
+			f, ok := failures.get(tt.email.ID)
+			if !assert.True(t, ok) {
+				return
+			}
+			assert.Equal(t, tt.email.Subject, f.Subject)
+			assert.Equal(t, tt.stage, f.Stage)
+			assert.Equal(t, tt.jobID, f.JobID)
+			assert.Equal(t, tt.detail, f.Detail)
+			assert.Equal(t, tt.warnings, f.Warnings)
+			assert.Equal(t, tt.attempts, f.Attempts)
+			failed := 0
+			for _, other := range tests {
+				if other.stage == tt.stage {
+					failed++
+				}
+			}
+			metrics.mu.Lock()
+			defer metrics.mu.Unlock()
+			assert.Equal(t, failed, metrics.failed[tt.stage])
+		})
+	}
+}
+
+func Test_emailProcessor_Process_concurrency(t *testing.T) {
+	emails := []*model.Email{{ID: "MSG0", Content: "0"}, {ID: "MSG1", Content: "1"}, {ID: "MSG2", Content: "2"}}
+	provider := &mocks.EmailProvider{}
+	provider.On("GetEmails", mock.Anything, mock.Anything).Return(emails, nil)
+
+	// each job creation waits until all of them are in progress
+	var started sync.WaitGroup
+	started.Add(len(emails))
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, mock.Anything).
+		Run(func(mock.Arguments) {
+			started.Done()
+			started.Wait()
+		}).
+		Return(&model.EmailParsingJob{ID: "AJ", Status: model.MailParsingStatusPending}, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{Status: model.MailParsingStatusPending}, nil)
+
+	queue := newMemoryQueue()
+	mailboxes := aliceMailbox(provider)
+	p := NewEmailProcessor(mailboxes, mailboxes, parser, &mocks.TripRepository{}, queue, newMemoryLedger(),
+		newMemoryFailures(), domain.NopMetrics{}, ProcessorConfig{
+			MailInterval:  time.Hour,
+			PollInterval:  time.Hour,
+			QueueSize:     1,
+			CreateWorkers: len(emails),
+		}).(*emailProcessor)
+	p.Process()
+	defer p.Stop(context.Background())
+
+	assert.Eventually(t, func() bool {
+		for _, em := range emails {
+			if queue.state(em.ID) != model.ProcessingStateSubmitted {
+				return false
+			}
+		}
+		// submitted jobs wait for their next check time
+		return p.checks.len() == len(emails)
+	}, time.Second, 10*time.Millisecond)
+	parser.AssertNotCalled(t, "GetJobStatus", mock.Anything, mock.Anything)
+}
+
+func Test_emailProcessor_Process_mailboxes(t *testing.T) {
+	aliceProvider := &mocks.EmailProvider{}
+	aliceProvider.On("GetEmails", mock.Anything, mock.Anything).Return([]*model.Email{{ID: "MSG0", Content: "0"}}, nil)
+	bobProvider := &mocks.EmailProvider{}
+	bobProvider.On("GetEmails", mock.Anything, mock.Anything).Return([]*model.Email{{ID: "MSG1", Content: "1"}}, nil)
+	mailboxes := &testMailboxes{
+		mailboxes: []model.Mailbox{{ID: "M0", Owner: "alice"}, {ID: "M1", Owner: "carol"}, {ID: "M2", Owner: "bob"}},
+		providers: map[string]domain.EmailProvider{"M0": aliceProvider, "M2": bobProvider},
+	}
+
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, mock.Anything).
+		Return(&model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending}, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{Status: model.MailParsingStatusDone}, nil)
+	parser.On("GetJobResult", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{Trip: trip[0]}, nil)
+	var owners sync.Map
+	repo := &mocks.TripRepository{}
+	repo.On("Merge", mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) { owners.Store(args.Get(1).(*model.Trip).Owner, true) }).Return(true, nil)
+
+	queue := newMemoryQueue()
+	p := NewEmailProcessor(mailboxes, mailboxes, parser, repo, queue, newMemoryLedger(), newMemoryFailures(),
+		domain.NopMetrics{}, ProcessorConfig{MailInterval: time.Hour, PollInterval: time.Millisecond}).(*emailProcessor)
+	p.Process()
+	// the mailbox of carol cannot be polled, the others are
+	assert.Eventually(t, func() bool {
+		return queue.state("MSG0") == model.ProcessingStateDone && queue.state("MSG1") == model.ProcessingStateDone
+	}, time.Second, 10*time.Millisecond)
+	assert.NoError(t, p.Stop(context.Background()))
+
+	for _, owner := range []string{"alice", "bob"} {
+		_, ok := owners.Load(owner)
+		assert.True(t, ok, "trip of %s", owner)
+	}
+	item, _ := queue.GetByEmailID("MSG1")
+	assert.Equal(t, "M2", item.MailboxID)
+}
+
+func Test_emailProcessor_Reprocess(t *testing.T) {
+	done := model.QueueItem{ID: "Q0", EmailID: "MSG0", State: model.ProcessingStateDone, Content: email.Content}
+	running := model.QueueItem{ID: "Q1", EmailID: "MSG1", State: model.ProcessingStatePending}
+	queue := newMemoryQueue(done, running)
+	ledger := newMemoryLedger(
+		model.ProcessedMessage{MessageID: "MSG0", ContentHash: model.ContentHash(email.Content)},
+		model.ProcessedMessage{MessageID: "MSG2"},
+	)
+
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending}, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{Status: model.MailParsingStatusPending}, nil)
+	p := newTestProcessor(&mocks.EmailProvider{}, parser, &mocks.TripRepository{}, queue, ledger, newMemoryFailures())
+	p.pool(stageCreate, 1, p.emails, p.createJob)
+
+	tests := []struct {
+		name    string
+		id      string
+		wantErr error
+	}{
+		{"processed message", "MSG0", nil},
+		{"message being processed", "MSG1", domain.ErrorAlreadyQueued},
+		{"processed message without queued content", "MSG2", nil},
+		{"unknown message", "MSG3", domain.ErrorNotProcessed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := p.Reprocess(tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Reprocess() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	assert.Eventually(t, func() bool {
+		return queue.state("MSG0") == model.ProcessingStateSubmitted
+	}, time.Second, 5*time.Millisecond)
+	_, err := ledger.Get("MSG2")
+	assert.Equal(t, domain.ErrorNotProcessed, err)
+}
+
+func Test_emailProcessor_Reprocess_sameTrip(t *testing.T) {
+	provider := &mocks.EmailProvider{}
+	provider.On("GetEmails", mock.Anything, mock.Anything).Return([]*model.Email{email}, nil)
+	parser := &mocks.EmailParser{}
+	parser.On("CreateJob", mock.Anything, mock.Anything).
+		Return(&model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending}, nil)
+	parser.On("GetJobStatus", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{Status: model.MailParsingStatusDone}, nil)
+	// the parser gives the trip a new ID each time
+	parser.On("GetJobResult", mock.Anything, mock.Anything).Return(
+		func(context.Context, model.EmailParsingJob) *model.EmailParsingJob {
+			parsed := trip[0]
+			parsed.ID = uuid.New().String()
+			return &model.EmailParsingJob{Trip: parsed}
+		}, nil)
+	repo := &memoryTrips{}
+	queue := newMemoryQueue()
+	p := newTestProcessor(provider, parser, repo, queue, newMemoryLedger(), newMemoryFailures())
+	p.Process()
+	defer p.Stop(context.Background())
+	assert.Eventually(t, func() bool {
+		return queue.state(email.ID) == model.ProcessingStateDone
+	}, time.Second, 5*time.Millisecond)
+	stored, _ := queue.GetByEmailID(email.ID)
+
+	require.NoError(t, p.Reprocess(email.ID))
+	assert.Eventually(t, func() bool {
+		return queue.state(email.ID) == model.ProcessingStateDone
+	}, time.Second, 5*time.Millisecond)
+	parser.AssertNumberOfCalls(t, "GetJobResult", 2)
+	trips, _ := repo.GetAll("")
+	if assert.Len(t, trips, 1, "the reprocessed email updates its trip") {
+		assert.Equal(t, stored.TripID, trips[0].ID)
+	}
+	item, _ := queue.GetByEmailID(email.ID)
+	assert.Equal(t, stored.TripID, item.TripID)
+}
+
+func Test_emailProcessor_Stop(t *testing.T) {
+	provider := &mocks.EmailProvider{}
+	provider.On("GetEmails", mock.Anything, mock.Anything).Return([]*model.Email{email}, nil)
+
+	pendingParser := &mocks.EmailParser{}
+	pendingParser.On("CreateJob", mock.Anything, email).Return(&model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending}, nil)
+	pendingParser.On("GetJobStatus", mock.Anything, mock.Anything).Return(&model.EmailParsingJob{Status: model.MailParsingStatusPending}, nil)
+
+	release := make(chan struct{})
+	defer close(release)
+	blocked := make(chan struct{})
+	var once sync.Once
+	blockedParser := &mocks.EmailParser{}
+	blockedParser.On("CreateJob", mock.Anything, email).
+		Run(func(mock.Arguments) {
+			once.Do(func() { close(blocked) })
+			<-release
+		}).
+		Return(&model.EmailParsingJob{ID: "AJ0", Status: model.MailParsingStatusPending}, nil)
+
+	calling := make(chan struct{})
+	var callOnce sync.Once
+	cancelledParser := &mocks.EmailParser{}
+	cancelledParser.On("CreateJob", mock.Anything, email).
+		Run(func(args mock.Arguments) {
+			callOnce.Do(func() { close(calling) })
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(nil, context.Canceled)
+
+	tests := []struct {
+		name      string
+		parser    domain.EmailParser
+		reached   func(*memoryQueue) bool
+		wantErr   bool
+		wantState model.ProcessingState
+	}{
+		{
+			"stop with pending jobs",
+			pendingParser,
+			func(q *memoryQueue) bool { return q.state(email.ID) == model.ProcessingStatePending },
+			false,
+			model.ProcessingStatePending,
+		},
+		{
+			"stop with a parser call exceeding the deadline",
+			blockedParser,
+			func(*memoryQueue) bool {
+				select {
+				case <-blocked:
+					return true
+				default:
+					return false
+				}
+			},
+			true,
+			model.ProcessingStateFetched,
+		},
+		{
+			// not failed, the email is submitted again on next start
+			"stop cancelling a parser call",
+			cancelledParser,
+			func(*memoryQueue) bool {
+				select {
+				case <-calling:
+					return true
+				default:
+					return false
+				}
+			},
+			false,
+			model.ProcessingStateFetched,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newMemoryQueue()
+			p := newTestProcessor(provider, tt.parser, &mocks.TripRepository{}, queue, newMemoryLedger(),
+				newMemoryFailures())
+			p.Process()
+			assert.Eventually(t, func() bool { return tt.reached(queue) }, time.Second, 10*time.Millisecond)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			err := p.Stop(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Stop() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			// unfinished work is kept to be resumed
+			assert.Equal(t, tt.wantState, queue.state(email.ID))
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"container/heap"
+	"context"
+	"sync"
+	"time"
+)
+
+// checkScheduler releases queued items when their next check time is reached, a single timer
+// is armed for the earliest item instead of one sleeping goroutine per pending job
+type checkScheduler struct {
+	mu    sync.Mutex
+	items checkHeap
+	wake  chan struct{}
+}
+
+func newCheckScheduler() *checkScheduler {
+	return &checkScheduler{wake: make(chan struct{}, 1)}
+}
+
+// schedule adds an item to be released at its NextCheckAt time, right away when it is zero or past
+func (s *checkScheduler) schedule(item *model.QueueItem) {
+	s.mu.Lock()
+	heap.Push(&s.items, item)
+	s.mu.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// len returns the number of items waiting for their check time
+func (s *checkScheduler) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.items.Len()
+}
+
+// next pops the first due item, or returns the time at which the earliest item is due
+func (s *checkScheduler) next(now time.Time) (*model.QueueItem, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.items.Len() == 0 {
+		return nil, time.Time{}
+	}
+	if first := s.items[0]; first.NextCheckAt.After(now) {
+		return nil, first.NextCheckAt
+	}
+	return heap.Pop(&s.items).(*model.QueueItem), time.Time{}
+}
+
+// run sends due items to out until ctx is done, it blocks while out is full
+func (s *checkScheduler) run(ctx context.Context, out chan<- *model.QueueItem) {
+	for {
+		item, at := s.next(time.Now())
+		if item != nil {
+			select {
+			case out <- item:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		var due <-chan time.Time
+		var t *time.Timer
+		if !at.IsZero() {
+			t = time.NewTimer(time.Until(at))
+			due = t.C
+		}
+		select {
+		case <-due:
+		case <-s.wake:
+		case <-ctx.Done():
+		}
+		if t != nil {
+			t.Stop()
+		}
+		if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// checkHeap orders items by next check time
+type checkHeap []*model.QueueItem
+
+func (h checkHeap) Len() int            { return len(h) }
+func (h checkHeap) Less(i, j int) bool  { return h[i].NextCheckAt.Before(h[j].NextCheckAt) }
+func (h checkHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *checkHeap) Push(x interface{}) { *h = append(*h, x.(*model.QueueItem)) }
+func (h *checkHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	item := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return item
+}
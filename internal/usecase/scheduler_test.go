@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain/model"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func Test_checkScheduler_run(t *testing.T) {
+	now := time.Now()
+	s := newCheckScheduler()
+	s.schedule(&model.QueueItem{ID: "later", NextCheckAt: now.Add(60 * time.Millisecond)})
+	s.schedule(&model.QueueItem{ID: "never", NextCheckAt: now.Add(time.Hour)})
+	s.schedule(&model.QueueItem{ID: "due", NextCheckAt: now.Add(-time.Second)})
+	s.schedule(&model.QueueItem{ID: "unset"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	out := make(chan *model.QueueItem)
+	go s.run(ctx, out)
+
+	var released []string
+	for i := 0; i < 3; i++ {
+		select {
+		case item := <-out:
+			released = append(released, item.ID)
+		case <-time.After(time.Second):
+			t.Fatalf("released %v before timeout", released)
+		}
+	}
+	assert.Equal(t, []string{"unset", "due", "later"}, released)
+	assert.False(t, time.Now().Before(now.Add(60*time.Millisecond)), "item released before its check time")
+	assert.Equal(t, 1, s.len())
+
+	// an item scheduled while waiting for a later one is released first
+	s.schedule(&model.QueueItem{ID: "new"})
+	select {
+	case item := <-out:
+		assert.Equal(t, "new", item.ID)
+	case <-time.After(time.Second):
+		t.Fatal("new item not released")
+	}
+}
@@ -0,0 +1,36 @@
+package usecase
+
+import (
+	"context"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("amadeus-trip-parser/usecase")
+
+// the trace of an email is saved with it as a W3C traceparent, whatever the configured propagation
+var traceContext = propagation.TraceContext{}
+
+// traceParent returns the traceparent of the span of ctx, empty when ctx is not traced
+func traceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	traceContext.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// continueTrace returns ctx carrying the span of a saved traceparent, spans started from it are part of the same
+// trace
+func continueTrace(ctx context.Context, parent string) context.Context {
+	return traceContext.Extract(ctx, propagation.MapCarrier{"traceparent": parent})
+}
+
+// detach keeps the span of ctx but takes the cancellation of base instead, for work outliving a request
+func detach(base context.Context, ctx context.Context) context.Context {
+	return trace.ContextWithSpanContext(base, trace.SpanContextFromContext(ctx))
+}
+
+func spanError(ctx context.Context, detail string) {
+	trace.SpanFromContext(ctx).SetStatus(codes.Error, detail)
+}
@@ -3,6 +3,13 @@ package usecase
 import (
 	"amadeus-trip-parser/internal/domain"
 	"amadeus-trip-parser/internal/domain/model"
+	"fmt"
+	"time"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
 )
 
 type tripFinder struct {
@@ -13,10 +20,83 @@ func NewTripFinder(repo domain.TripRepository) domain.TripFinder {
 	return &tripFinder{repo}
 }
 
-func (f tripFinder) GetByReference(ref string) (model.Trip, error) {
-	return f.repo.GetOne(model.Trip{Reference: ref})
+func (f tripFinder) GetByReference(user model.Principal, ref string) (model.Trip, error) {
+	owner, err := f.owner(user)
+	if err != nil {
+		return model.Trip{}, err
+	}
+	return f.repo.GetOne(model.Trip{Reference: ref, Owner: owner})
+}
+
+func (f tripFinder) GetByID(user model.Principal, id string) (model.Trip, error) {
+	owner, err := f.owner(user)
+	if err != nil {
+		return model.Trip{}, err
+	}
+	return f.repo.GetOne(model.Trip{ID: id, Owner: owner})
+}
+
+func (f tripFinder) Get(user model.Principal, query model.TripQuery) (model.TripPage, error) {
+	owner, err := f.owner(user)
+	if err != nil {
+		return model.TripPage{}, err
+	}
+	q, err := f.normalize(query)
+	if err != nil {
+		return model.TripPage{}, err
+	}
+	q.Owner = owner
+	return f.repo.Find(q)
+}
+
+// owner returns the owner to which the queries of user are scoped, none for an admin
+func (f tripFinder) owner(user model.Principal) (string, error) {
+	if user.IsAdmin() {
+		return "", nil
+	}
+	if user.Subject == "" {
+		// an empty owner would match every trip
+		return "", fmt.Errorf("%w: no authenticated user", domain.ErrorForbidden)
+	}
+	return user.Subject, nil
 }
 
-func (f tripFinder) Get() ([]model.Trip, error) {
-	return f.repo.GetAll()
+func (f tripFinder) normalize(q model.TripQuery) (model.TripQuery, error) {
+	switch q.Period {
+	case model.TripPeriodAll, model.TripPeriodUpcoming, model.TripPeriodPast:
+	default:
+		return q, fmt.Errorf("%w: unknown period %s", domain.ErrorInvalidQuery, q.Period)
+	}
+	switch q.Sort {
+	case "":
+		q.Sort = model.TripSortStart
+	case model.TripSortStart, model.TripSortEnd, model.TripSortReference:
+	default:
+		return q, fmt.Errorf("%w: unknown sort field %s", domain.ErrorInvalidQuery, q.Sort)
+	}
+	switch q.Order {
+	case "":
+		q.Order = model.SortOrderAsc
+	case model.SortOrderAsc, model.SortOrderDesc:
+	default:
+		return q, fmt.Errorf("%w: unknown sort order %s", domain.ErrorInvalidQuery, q.Order)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && q.To.Before(q.From) {
+		return q, fmt.Errorf("%w: range end %s is before its start %s", domain.ErrorInvalidQuery, q.To, q.From)
+	}
+	if q.Offset < 0 {
+		return q, fmt.Errorf("%w: negative offset %d", domain.ErrorInvalidQuery, q.Offset)
+	}
+	switch {
+	case q.Limit < 0:
+		return q, fmt.Errorf("%w: negative limit %d", domain.ErrorInvalidQuery, q.Limit)
+	case q.Limit == 0:
+		q.Limit = defaultPageLimit
+	case q.Limit > maxPageLimit:
+		q.Limit = maxPageLimit
+	}
+	if q.Now.IsZero() {
+		q.Now = time.Now()
+	}
+	return q, nil
 }
@@ -5,6 +5,7 @@ import (
 	"amadeus-trip-parser/internal/domain/mocks"
 	"amadeus-trip-parser/internal/domain/model"
 	"errors"
+	"github.com/stretchr/testify/mock"
 	"reflect"
 	"testing"
 	"time"
@@ -37,33 +38,97 @@ var trip = []model.Trip{
 	},
 }
 
+var (
+	alice = model.Principal{Subject: "alice", Role: model.RoleUser}
+	admin = model.Principal{Subject: "root", Role: model.RoleAdmin}
+)
+
 func Test_tripFinder_Get(t *testing.T) {
+	now := time.Now()
+	page := model.TripPage{Trips: trip, Total: 1, Limit: defaultPageLimit}
+
 	mockRepo := &mocks.TripRepository{}
-	mockRepo.On("GetAll").Return(trip, nil)
+	mockRepo.On("Find", model.TripQuery{
+		Now:   now,
+		Sort:  model.TripSortStart,
+		Order: model.SortOrderAsc,
+		Limit: defaultPageLimit,
+	}).Return(page, nil)
+	mockRepo.On("Find", model.TripQuery{
+		Period:   model.TripPeriodUpcoming,
+		Now:      now,
+		Location: "PARIS",
+		Sort:     model.TripSortEnd,
+		Order:    model.SortOrderDesc,
+		Offset:   10,
+		Limit:    maxPageLimit,
+	}).Return(page, nil)
 
 	mockRepoErr := &mocks.TripRepository{}
-	mockRepoErr.On("GetAll").Return(nil, errors.New("error"))
+	mockRepoErr.On("Find", mock.Anything).Return(model.TripPage{}, errors.New("error"))
 
 	type fields struct {
 		repo domain.TripRepository
 	}
+	type args struct {
+		query model.TripQuery
+	}
 	tests := []struct {
 		name    string
 		fields  fields
-		want    []model.Trip
-		wantErr bool
+		args    args
+		want    model.TripPage
+		wantErr error
 	}{
 		{
-			"get all trips",
+			"get all trips with defaults",
 			fields{mockRepo},
-			trip,
-			false,
+			args{model.TripQuery{Now: now}},
+			page,
+			nil,
+		},
+		{
+			"get filtered trips with capped limit",
+			fields{mockRepo},
+			args{model.TripQuery{
+				Period:   model.TripPeriodUpcoming,
+				Now:      now,
+				Location: "PARIS",
+				Sort:     model.TripSortEnd,
+				Order:    model.SortOrderDesc,
+				Offset:   10,
+				Limit:    maxPageLimit + 1,
+			}},
+			page,
+			nil,
+		},
+		{
+			"get trips with unknown sort field",
+			fields{mockRepo},
+			args{model.TripQuery{Sort: "price"}},
+			model.TripPage{},
+			domain.ErrorInvalidQuery,
+		},
+		{
+			"get trips with reversed range",
+			fields{mockRepo},
+			args{model.TripQuery{From: now, To: now.Add(-time.Hour)}},
+			model.TripPage{},
+			domain.ErrorInvalidQuery,
+		},
+		{
+			"get trips with negative offset",
+			fields{mockRepo},
+			args{model.TripQuery{Offset: -1}},
+			model.TripPage{},
+			domain.ErrorInvalidQuery,
 		},
 		{
 			"get all trips with error",
 			fields{mockRepoErr},
-			nil,
-			true,
+			args{model.TripQuery{}},
+			model.TripPage{},
+			errors.New("error"),
 		},
 	}
 	for _, tt := range tests {
@@ -71,8 +136,12 @@ func Test_tripFinder_Get(t *testing.T) {
 			f := tripFinder{
 				repo: tt.fields.repo,
 			}
-			got, err := f.Get()
-			if (err != nil) != tt.wantErr {
+			got, err := f.Get(admin, tt.args.query)
+			if (err != nil) != (tt.wantErr != nil) {
+				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if errors.Is(tt.wantErr, domain.ErrorInvalidQuery) && !errors.Is(err, domain.ErrorInvalidQuery) {
 				t.Errorf("Get() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
@@ -124,7 +193,7 @@ func Test_tripFinder_GetByReference(t *testing.T) {
 			f := tripFinder{
 				repo: tt.fields.repo,
 			}
-			got, err := f.GetByReference(tt.args.ref)
+			got, err := f.GetByReference(admin, tt.args.ref)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("GetByReference() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -135,3 +204,59 @@ func Test_tripFinder_GetByReference(t *testing.T) {
 		})
 	}
 }
+
+func Test_tripFinder_GetByID(t *testing.T) {
+	mockRepo := &mocks.TripRepository{}
+	mockRepo.On("GetOne", model.Trip{ID: trip[0].ID}).Return(trip[0], nil)
+	mockRepo.On("GetOne", model.Trip{ID: "1111"}).Return(model.Trip{}, domain.ErrorNotFound)
+
+	tests := []struct {
+		name    string
+		id      string
+		want    model.Trip
+		wantErr bool
+	}{
+		{"get a trip by id", trip[0].ID, trip[0], false},
+		{"get an unknown trip", "1111", model.Trip{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := tripFinder{repo: mockRepo}
+			got, err := f.GetByID(admin, tt.id)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("GetByID() error = %v, wantErr %v", err, tt.wantErr)
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetByID() got = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tripFinder_owner(t *testing.T) {
+	mockRepo := &mocks.TripRepository{}
+	mockRepo.On("Find", mock.MatchedBy(func(q model.TripQuery) bool { return q.Owner == "alice" })).
+		Return(model.TripPage{Trips: trip, Total: 1}, nil)
+	mockRepo.On("GetOne", model.Trip{ID: trip[0].ID, Owner: "alice"}).Return(trip[0], nil)
+	mockRepo.On("GetOne", model.Trip{Reference: trip[0].Reference, Owner: "alice"}).Return(trip[0], nil)
+	f := tripFinder{repo: mockRepo}
+
+	if _, err := f.Get(alice, model.TripQuery{}); err != nil {
+		t.Errorf("Get() error = %v", err)
+	}
+	if _, err := f.GetByID(alice, trip[0].ID); err != nil {
+		t.Errorf("GetByID() error = %v", err)
+	}
+	if _, err := f.GetByReference(alice, trip[0].Reference); err != nil {
+		t.Errorf("GetByReference() error = %v", err)
+	}
+	// a principal without subject would see every trip
+	if _, err := f.Get(model.Principal{}, model.TripQuery{}); !errors.Is(err, domain.ErrorForbidden) {
+		t.Errorf("Get() without user error = %v, want %v", err, domain.ErrorForbidden)
+	}
+	if _, err := f.GetByID(model.Principal{Role: model.RoleUser}, trip[0].ID); !errors.Is(err, domain.ErrorForbidden) {
+		t.Errorf("GetByID() without user error = %v, want %v", err, domain.ErrorForbidden)
+	}
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,98 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"fmt"
+)
+
+type userService struct {
+	users     domain.UserStore
+	mailboxes domain.MailboxStore
+	keys      domain.APIKeyStore
+}
+
+func NewUserService(users domain.UserStore, mailboxes domain.MailboxStore, keys domain.APIKeyStore) domain.UserService {
+	return &userService{users: users, mailboxes: mailboxes, keys: keys}
+}
+
+func (s *userService) CreateUser(subject string, name string, role model.Role) (model.User, error) {
+	if subject == "" {
+		return model.User{}, fmt.Errorf("%w: no subject", domain.ErrorInvalidUser)
+	}
+	role, err := checkRole(role)
+	if err != nil {
+		return model.User{}, fmt.Errorf("%w: %v", domain.ErrorInvalidUser, err)
+	}
+	user := model.User{Subject: subject, Name: name, Role: role}
+	if _, err := s.users.GetBySubject(subject); err == nil {
+		return model.User{}, fmt.Errorf("%w: subject %s is taken", domain.ErrorInvalidUser, subject)
+	}
+	if err := s.users.Create(&user); err != nil {
+		return model.User{}, err
+	}
+	return user, nil
+}
+
+func (s *userService) GetUser(id string) (model.User, error) {
+	return s.users.Get(id)
+}
+
+func (s *userService) GetUserBySubject(subject string) (model.User, error) {
+	return s.users.GetBySubject(subject)
+}
+
+func (s *userService) ListUsers() ([]model.User, error) {
+	return s.users.List()
+}
+
+func (s *userService) DeleteUser(id string) error {
+	user, err := s.users.Get(id)
+	if err != nil {
+		return err
+	}
+	if err := s.mailboxes.DeleteByOwner(user.Subject); err != nil {
+		return err
+	}
+	if err := s.keys.DeleteBySubject(user.Subject); err != nil {
+		return err
+	}
+	return s.users.Delete(id)
+}
+
+func (s *userService) ConnectMailbox(userID string, mailbox model.Mailbox) (model.Mailbox, error) {
+	user, err := s.users.Get(userID)
+	if err != nil {
+		return model.Mailbox{}, err
+	}
+	if mailbox.Provider == "" {
+		mailbox.Provider = model.MailboxProviderGmail
+	}
+	if mailbox.Provider != model.MailboxProviderGmail {
+		return model.Mailbox{}, fmt.Errorf("%w: unknown provider %s", domain.ErrorInvalidMailbox, mailbox.Provider)
+	}
+	if mailbox.Token == "" {
+		return model.Mailbox{}, fmt.Errorf("%w: no token", domain.ErrorInvalidMailbox)
+	}
+	mailbox.ID = ""
+	mailbox.Owner = user.Subject
+	if err := s.mailboxes.Save(&mailbox); err != nil {
+		return model.Mailbox{}, err
+	}
+	return mailbox, nil
+}
+
+func (s *userService) ListMailboxes(userID string) ([]model.Mailbox, error) {
+	if userID == "" {
+		return s.mailboxes.List("")
+	}
+	user, err := s.users.Get(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.mailboxes.List(user.Subject)
+}
+
+func (s *userService) DisconnectMailbox(id string) error {
+	return s.mailboxes.Delete(id)
+}
@@ -0,0 +1,205 @@
+package usecase
+
+import (
+	"amadeus-trip-parser/internal/domain"
+	"amadeus-trip-parser/internal/domain/model"
+	"errors"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+type memoryUsers struct {
+	mu    sync.Mutex
+	users map[string]model.User
+}
+
+func newMemoryUsers() *memoryUsers {
+	return &memoryUsers{users: make(map[string]model.User)}
+}
+
+func (m *memoryUsers) Create(user *model.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Subject == user.Subject {
+			return errors.New("duplicate subject")
+		}
+	}
+	user.ID = "U-" + user.Subject
+	m.users[user.ID] = *user
+	return nil
+}
+
+func (m *memoryUsers) Get(id string) (model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if u, ok := m.users[id]; ok {
+		return u, nil
+	}
+	return model.User{}, domain.ErrorNoUser
+}
+
+func (m *memoryUsers) GetBySubject(subject string) (model.User, error) {
+	return m.Get("U-" + subject)
+}
+
+func (m *memoryUsers) List() ([]model.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var users []model.User
+	for _, u := range m.users {
+		users = append(users, u)
+	}
+	return users, nil
+}
+
+func (m *memoryUsers) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.users[id]; !ok {
+		return domain.ErrorNoUser
+	}
+	delete(m.users, id)
+	return nil
+}
+
+type memoryMailboxes struct {
+	mu        sync.Mutex
+	mailboxes map[string]model.Mailbox
+}
+
+func newMemoryMailboxes() *memoryMailboxes {
+	return &memoryMailboxes{mailboxes: make(map[string]model.Mailbox)}
+}
+
+func (m *memoryMailboxes) Save(mailbox *model.Mailbox) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mailbox.ID == "" {
+		mailbox.ID = fmt.Sprintf("M%d", len(m.mailboxes))
+	}
+	m.mailboxes[mailbox.ID] = *mailbox
+	return nil
+}
+
+func (m *memoryMailboxes) UpdateToken(id string, token string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	mb, ok := m.mailboxes[id]
+	if !ok {
+		return domain.ErrorNoMailbox
+	}
+	mb.Token = token
+	m.mailboxes[id] = mb
+	return nil
+}
+
+func (m *memoryMailboxes) Get(id string) (model.Mailbox, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mb, ok := m.mailboxes[id]; ok {
+		return mb, nil
+	}
+	return model.Mailbox{}, domain.ErrorNoMailbox
+}
+
+func (m *memoryMailboxes) List(owner string) ([]model.Mailbox, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var mailboxes []model.Mailbox
+	for _, mb := range m.mailboxes {
+		if owner == "" || mb.Owner == owner {
+			mailboxes = append(mailboxes, mb)
+		}
+	}
+	return mailboxes, nil
+}
+
+func (m *memoryMailboxes) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.mailboxes[id]; !ok {
+		return domain.ErrorNoMailbox
+	}
+	delete(m.mailboxes, id)
+	return nil
+}
+
+func (m *memoryMailboxes) DeleteByOwner(owner string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, mb := range m.mailboxes {
+		if mb.Owner == owner {
+			delete(m.mailboxes, id)
+		}
+	}
+	return nil
+}
+
+func Test_userService_CreateUser(t *testing.T) {
+	s := NewUserService(newMemoryUsers(), newMemoryMailboxes(), newMemoryAPIKeys())
+	tests := []struct {
+		name    string
+		subject string
+		role    model.Role
+		want    model.Role
+		wantErr bool
+	}{
+		{"default role", "alice", "", model.RoleUser, false},
+		{"admin", "root", model.RoleAdmin, model.RoleAdmin, false},
+		{"same subject", "alice", model.RoleUser, "", true},
+		{"unknown role", "bob", "owner", "", true},
+		{"no subject", "", model.RoleUser, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.CreateUser(tt.subject, "", tt.role)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateUser() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got.Role != tt.want {
+				t.Errorf("CreateUser() role = %v, want %v", got.Role, tt.want)
+			}
+		})
+	}
+}
+
+func Test_userService_mailboxes(t *testing.T) {
+	mailboxes := newMemoryMailboxes()
+	keys := newMemoryAPIKeys()
+	s := NewUserService(newMemoryUsers(), mailboxes, keys)
+	alice, _ := s.CreateUser("alice", "Alice", model.RoleUser)
+	bob, _ := s.CreateUser("bob", "Bob", model.RoleUser)
+	_, _, _ = NewAPIKeyService(keys).Create("laptop", "alice", model.RoleUser)
+
+	m, err := s.ConnectMailbox(alice.ID, model.Mailbox{ID: "chosen", Owner: "bob", Token: "{}"})
+	if assert.NoError(t, err) {
+		assert.NotEqual(t, "chosen", m.ID)
+		assert.Equal(t, "alice", m.Owner)
+		assert.Equal(t, model.MailboxProvider(model.MailboxProviderGmail), m.Provider)
+	}
+	_, err = s.ConnectMailbox(bob.ID, model.Mailbox{Token: "{}"})
+	assert.NoError(t, err)
+	_, err = s.ConnectMailbox(bob.ID, model.Mailbox{Provider: "imap", Token: "{}"})
+	assert.Error(t, err, "unknown provider")
+	_, err = s.ConnectMailbox(bob.ID, model.Mailbox{})
+	assert.Error(t, err, "no token")
+	_, err = s.ConnectMailbox("U-carol", model.Mailbox{Token: "{}"})
+	assert.True(t, errors.Is(err, domain.ErrorNoUser), "unknown user error = %v", err)
+
+	list, _ := s.ListMailboxes(alice.ID)
+	assert.Len(t, list, 1)
+	list, _ = s.ListMailboxes("")
+	assert.Len(t, list, 2)
+
+	// the mailboxes and keys of a deleted user go with it
+	assert.NoError(t, s.DeleteUser(alice.ID))
+	list, _ = s.ListMailboxes("")
+	assert.Len(t, list, 1)
+	remaining, _ := keys.List()
+	assert.Empty(t, remaining)
+	_, err = s.GetUser(alice.ID)
+	assert.True(t, errors.Is(err, domain.ErrorNoUser), "deleted user error = %v", err)
+}